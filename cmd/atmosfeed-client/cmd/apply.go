@@ -5,7 +5,9 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -13,10 +15,26 @@ import (
 
 const (
 	feedRkeyFlag       = "feed-rkey"
+	feedDIDFlag        = "feed-did"
 	feedClassifierFlag = "feed-classifier"
 	feedPinnedDIDFlag  = "pinned-feed-did"
 	feedPinnedRkeyFlag = "pinned-feed-rkey"
 	clearPinnedFlag    = "clear-pinned"
+	feedRetentionFlag  = "feed-retention"
+	clearRetentionFlag = "clear-retention"
+	feedRankingFlag    = "feed-ranking"
+	feedHalfLifeFlag   = "feed-half-life"
+
+	feedTTLFlag             = "feed-ttl"
+	feedMaxPageSizeFlag     = "feed-max-page-size"
+	feedDefaultPageSizeFlag = "feed-default-page-size"
+	clearPageSettingsFlag   = "clear-page-settings"
+
+	feedHideViewerPostsFlag  = "feed-hide-viewer-posts"
+	feedHideBlockedPostsFlag = "feed-hide-blocked-posts"
+
+	feedRerankerFlag  = "feed-reranker"
+	clearRerankerFlag = "clear-reranker"
 )
 
 var applyCmd = &cobra.Command{
@@ -28,7 +46,7 @@ var applyCmd = &cobra.Command{
 			return err
 		}
 
-		_, auth, err := authorize(cmd.Context())
+		token, service, err := authorizeAtmosfeed(cmd.Context())
 		if err != nil {
 			return err
 		}
@@ -49,7 +67,8 @@ var applyCmd = &cobra.Command{
 
 			q := u.Query()
 			q.Add("rkey", viper.GetString(feedRkeyFlag))
-			q.Add("service", viper.GetString(pdsURLFlag))
+			q.Add("service", service)
+			q.Add("feedDID", viper.GetString(feedDIDFlag))
 			u.RawQuery = q.Encode()
 
 			req, err := http.NewRequest(http.MethodPut, u.String(), f)
@@ -57,7 +76,7 @@ var applyCmd = &cobra.Command{
 				return err
 			}
 
-			req.Header.Set("Authorization", "Bearer "+auth.AccessJwt)
+			req.Header.Set("Authorization", "Bearer "+token)
 
 			resp, err := http.DefaultClient.Do(req)
 			if err != nil {
@@ -66,7 +85,7 @@ var applyCmd = &cobra.Command{
 			defer resp.Body.Close()
 
 			if resp.StatusCode != http.StatusOK {
-				return errors.New(resp.Status)
+				return getUploadError(resp)
 			}
 		}
 
@@ -76,7 +95,8 @@ var applyCmd = &cobra.Command{
 
 			q := u.Query()
 			q.Add("rkey", viper.GetString(feedRkeyFlag))
-			q.Add("service", viper.GetString(pdsURLFlag))
+			q.Add("service", service)
+			q.Add("feedDID", viper.GetString(feedDIDFlag))
 			q.Add("pinnedDID", viper.GetString(feedPinnedDIDFlag))
 			q.Add("pinnedRkey", viper.GetString(feedPinnedRkeyFlag))
 			u.RawQuery = q.Encode()
@@ -86,7 +106,203 @@ var applyCmd = &cobra.Command{
 				return err
 			}
 
-			req.Header.Set("Authorization", "Bearer "+auth.AccessJwt)
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				return errors.New(resp.Status)
+			}
+		}
+
+		if viper.GetDuration(feedRetentionFlag) > 0 || viper.GetBool(clearRetentionFlag) {
+			u := u.JoinPath("admin", "feeds")
+
+			q := u.Query()
+			q.Add("rkey", viper.GetString(feedRkeyFlag))
+			q.Add("service", service)
+			q.Add("feedDID", viper.GetString(feedDIDFlag))
+			q.Add("retention", viper.GetDuration(feedRetentionFlag).String())
+			u.RawQuery = q.Encode()
+
+			req, err := http.NewRequest(http.MethodPatch, u.String(), nil)
+			if err != nil {
+				return err
+			}
+
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				return errors.New(resp.Status)
+			}
+		}
+
+		if strings.TrimSpace(viper.GetString(feedRankingFlag)) != "" {
+			u := u.JoinPath("admin", "feeds")
+
+			q := u.Query()
+			q.Add("rkey", viper.GetString(feedRkeyFlag))
+			q.Add("service", service)
+			q.Add("feedDID", viper.GetString(feedDIDFlag))
+			q.Add("ranking", viper.GetString(feedRankingFlag))
+			q.Add("halfLife", viper.GetDuration(feedHalfLifeFlag).String())
+			u.RawQuery = q.Encode()
+
+			req, err := http.NewRequest(http.MethodPatch, u.String(), nil)
+			if err != nil {
+				return err
+			}
+
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				return errors.New(resp.Status)
+			}
+		}
+
+		if viper.GetDuration(feedTTLFlag) > 0 ||
+			viper.GetInt(feedMaxPageSizeFlag) > 0 ||
+			viper.GetInt(feedDefaultPageSizeFlag) > 0 ||
+			viper.GetBool(clearPageSettingsFlag) {
+			u := u.JoinPath("admin", "feeds")
+
+			q := u.Query()
+			q.Add("rkey", viper.GetString(feedRkeyFlag))
+			q.Add("service", service)
+			q.Add("feedDID", viper.GetString(feedDIDFlag))
+
+			// Empty values are only sent if the page settings should be cleared so that they don't overwrite non-empty values
+			if viper.GetDuration(feedTTLFlag) > 0 || viper.GetBool(clearPageSettingsFlag) {
+				q.Add("ttl", viper.GetDuration(feedTTLFlag).String())
+			}
+
+			if viper.GetInt(feedMaxPageSizeFlag) > 0 || viper.GetBool(clearPageSettingsFlag) {
+				q.Add("maxPageSize", strconv.Itoa(viper.GetInt(feedMaxPageSizeFlag)))
+			}
+
+			if viper.GetInt(feedDefaultPageSizeFlag) > 0 || viper.GetBool(clearPageSettingsFlag) {
+				q.Add("defaultPageSize", strconv.Itoa(viper.GetInt(feedDefaultPageSizeFlag)))
+			}
+
+			u.RawQuery = q.Encode()
+
+			req, err := http.NewRequest(http.MethodPatch, u.String(), nil)
+			if err != nil {
+				return err
+			}
+
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				return errors.New(resp.Status)
+			}
+		}
+
+		// Viewer filters are only sent if they were set explicitly so that the defaults don't overwrite existing values
+		if viper.IsSet(feedHideViewerPostsFlag) || viper.IsSet(feedHideBlockedPostsFlag) {
+			u := u.JoinPath("admin", "feeds")
+
+			q := u.Query()
+			q.Add("rkey", viper.GetString(feedRkeyFlag))
+			q.Add("service", service)
+			q.Add("feedDID", viper.GetString(feedDIDFlag))
+
+			if viper.IsSet(feedHideViewerPostsFlag) {
+				q.Add("hideViewerPosts", strconv.FormatBool(viper.GetBool(feedHideViewerPostsFlag)))
+			}
+
+			if viper.IsSet(feedHideBlockedPostsFlag) {
+				q.Add("hideBlockedPosts", strconv.FormatBool(viper.GetBool(feedHideBlockedPostsFlag)))
+			}
+
+			u.RawQuery = q.Encode()
+
+			req, err := http.NewRequest(http.MethodPatch, u.String(), nil)
+			if err != nil {
+				return err
+			}
+
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				return errors.New(resp.Status)
+			}
+		}
+
+		if strings.TrimSpace(viper.GetString(feedRerankerFlag)) != "" {
+			u := u.JoinPath("admin", "feeds", "reranker")
+
+			q := u.Query()
+			q.Add("rkey", viper.GetString(feedRkeyFlag))
+			q.Add("service", service)
+			q.Add("feedDID", viper.GetString(feedDIDFlag))
+			u.RawQuery = q.Encode()
+
+			f, err := os.Open(viper.GetString(feedRerankerFlag))
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			req, err := http.NewRequest(http.MethodPut, u.String(), f)
+			if err != nil {
+				return err
+			}
+
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				return getUploadError(resp)
+			}
+		} else if viper.GetBool(clearRerankerFlag) {
+			u := u.JoinPath("admin", "feeds", "reranker")
+
+			q := u.Query()
+			q.Add("rkey", viper.GetString(feedRkeyFlag))
+			q.Add("service", service)
+			q.Add("feedDID", viper.GetString(feedDIDFlag))
+			u.RawQuery = q.Encode()
+
+			req, err := http.NewRequest(http.MethodDelete, u.String(), nil)
+			if err != nil {
+				return err
+			}
+
+			req.Header.Set("Authorization", "Bearer "+token)
 
 			resp, err := http.DefaultClient.Do(req)
 			if err != nil {
@@ -105,13 +321,34 @@ var applyCmd = &cobra.Command{
 
 func init() {
 	applyCmd.PersistentFlags().String(feedRkeyFlag, "trending", "Machine-readable key for the feed")
+	applyCmd.PersistentFlags().String(feedDIDFlag, "", "DID of the account that owns the feed (if left empty, the authenticated account is used; set it to manage a feed that is shared with you)")
 
 	applyCmd.PersistentFlags().String(feedClassifierFlag, "local-trending-latest.scale", "Path to the feed classifier to upload")
 
-	applyCmd.PersistentFlags().String(feedPinnedDIDFlag, "", "DID of the pinned post for the feed (if left empty, no post will be pinned; empty values don't overwrite non-empty values, see --clear-pinned)")
-	applyCmd.PersistentFlags().String(feedPinnedRkeyFlag, "", "Machine-readable key of the pinned post for the feed (if left empty, no post will be pinned; empty values don't overwrite non-empty values, see --clear-pinned)")
+	applyCmd.PersistentFlags().String(feedPinnedDIDFlag, "", "DID of the pinned post for the feed, which replaces all other pins (if left empty, no post will be pinned; empty values don't overwrite non-empty values, see --clear-pinned; see pin for multiple pins)")
+	applyCmd.PersistentFlags().String(feedPinnedRkeyFlag, "", "Machine-readable key of the pinned post for the feed, which replaces all other pins (if left empty, no post will be pinned; empty values don't overwrite non-empty values, see --clear-pinned; see pin for multiple pins)")
+
+	applyCmd.PersistentFlags().Bool(clearPinnedFlag, false, "Whether to clear all pinned posts of the feed")
+
+	applyCmd.PersistentFlags().Duration(feedRetentionFlag, 0, "Maximum age of posts to keep in the feed (if left empty, the server's retention is used; empty values don't overwrite non-empty values, see --clear-retention)")
+
+	applyCmd.PersistentFlags().Bool(clearRetentionFlag, false, "Whether to clear the feed retention field")
+
+	applyCmd.PersistentFlags().String(feedRankingFlag, "", "Ranking strategy for the feed's posts (one of weight, newest or decayed; if left empty, the ranking is not changed)")
+	applyCmd.PersistentFlags().Duration(feedHalfLifeFlag, time.Hour, "Amount of time after which a post's weight is halved if the decayed ranking strategy is used")
+
+	applyCmd.PersistentFlags().Duration(feedTTLFlag, 0, "Maximum age of posts to return for the feed (if left empty, the server's TTL is used; can't exceed the server's TTL; empty values don't overwrite non-empty values, see --clear-page-settings)")
+	applyCmd.PersistentFlags().Int(feedMaxPageSizeFlag, 0, "Maximum amount of posts to return for the feed per page (if left empty, the server's limit is used; can't exceed the server's limit; empty values don't overwrite non-empty values, see --clear-page-settings)")
+	applyCmd.PersistentFlags().Int(feedDefaultPageSizeFlag, 0, "Amount of posts to return for the feed per page if the client doesn't specify a limit (if left empty, the server's default limit is used; empty values don't overwrite non-empty values, see --clear-page-settings)")
+
+	applyCmd.PersistentFlags().Bool(clearPageSettingsFlag, false, "Whether to clear the feed TTL and page size fields")
+
+	applyCmd.PersistentFlags().Bool(feedHideViewerPostsFlag, false, "Whether to hide the viewer's own posts from the feed (if not set, the value is not changed)")
+	applyCmd.PersistentFlags().Bool(feedHideBlockedPostsFlag, false, "Whether to hide posts from accounts that the viewer blocked from the feed (if not set, the value is not changed)")
+
+	applyCmd.PersistentFlags().String(feedRerankerFlag, "", "Path to the feed reranker to upload, which can reorder and filter each page of the feed for the viewer (if left empty, the reranker is not changed; see --clear-reranker)")
 
-	applyCmd.PersistentFlags().Bool(clearPinnedFlag, false, "Whether to clear the pinned post field")
+	applyCmd.PersistentFlags().Bool(clearRerankerFlag, false, "Whether to remove the feed reranker")
 
 	viper.AutomaticEnv()
 
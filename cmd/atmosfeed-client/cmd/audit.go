@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	auditLimitFlag = "limit"
+)
+
+type auditEvent struct {
+	ID             int64     `json:"id"`
+	Did            string    `json:"did"`
+	Action         string    `json:"action"`
+	FeedDid        string    `json:"feedDID"`
+	FeedRkey       string    `json:"feedRkey"`
+	ClassifierHash string    `json:"classifierHash"`
+	Origin         string    `json:"origin"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+var auditCmd = &cobra.Command{
+	Use:     "audit",
+	Aliases: []string{"au"},
+	Short:   "List the audit log of a feed on an Atmosfeed server",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := viper.BindPFlags(cmd.PersistentFlags()); err != nil {
+			return err
+		}
+
+		token, service, err := authorizeAtmosfeed(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		u, err := url.Parse(viper.GetString(atmosfeedURLFlag))
+		if err != nil {
+			return err
+		}
+
+		u = u.JoinPath("admin", "feeds", "audit")
+
+		q := u.Query()
+		q.Add("rkey", viper.GetString(feedRkeyFlag))
+		q.Add("service", service)
+		q.Add("feedDID", viper.GetString(feedDIDFlag))
+		q.Add("limit", strconv.Itoa(viper.GetInt(auditLimitFlag)))
+		u.RawQuery = q.Encode()
+
+		req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+		if err != nil {
+			return err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return errors.New(resp.Status)
+		}
+
+		events := []auditEvent{}
+		if err := json.NewDecoder(resp.Body).Decode(&events); err != nil {
+			return err
+		}
+
+		return yaml.NewEncoder(os.Stdout).Encode(events)
+	},
+}
+
+func init() {
+	auditCmd.PersistentFlags().String(feedRkeyFlag, "trending", "Machine-readable key for the feed")
+	auditCmd.PersistentFlags().String(feedDIDFlag, "", "DID of the account that owns the feed (if left empty, the authenticated account is used; set it to manage a feed that is shared with you)")
+	auditCmd.PersistentFlags().Int(auditLimitFlag, 100, "Maximum amount of audit events to list, starting with the latest one (at most 1000)")
+
+	viper.AutomaticEnv()
+
+	rootCmd.AddCommand(auditCmd)
+}
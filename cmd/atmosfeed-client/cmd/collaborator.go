@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	collaboratorDIDFlag  = "did"
+	collaboratorRoleFlag = "role"
+)
+
+type feedCollaborator struct {
+	Did  string `json:"did"`
+	Role string `json:"role"`
+}
+
+var collaboratorCmd = &cobra.Command{
+	Use:     "collaborator",
+	Aliases: []string{"co"},
+	Short:   "Manage the collaborators of a feed on an Atmosfeed server",
+}
+
+var collaboratorAddCmd = &cobra.Command{
+	Use:     "add",
+	Aliases: []string{"a"},
+	Short:   "Add a collaborator to a feed or change their role on an Atmosfeed server",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := viper.BindPFlags(cmd.PersistentFlags()); err != nil {
+			return err
+		}
+
+		token, service, err := authorizeAtmosfeed(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		u, err := url.Parse(viper.GetString(atmosfeedURLFlag))
+		if err != nil {
+			return err
+		}
+
+		u = u.JoinPath("admin", "feeds", "collaborators")
+
+		q := u.Query()
+		q.Add("rkey", viper.GetString(feedRkeyFlag))
+		q.Add("service", service)
+		q.Add("feedDID", viper.GetString(feedDIDFlag))
+		q.Add("did", viper.GetString(collaboratorDIDFlag))
+		q.Add("role", viper.GetString(collaboratorRoleFlag))
+		u.RawQuery = q.Encode()
+
+		req, err := http.NewRequest(http.MethodPut, u.String(), nil)
+		if err != nil {
+			return err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return errors.New(resp.Status)
+		}
+
+		return nil
+	},
+}
+
+var collaboratorListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"l"},
+	Short:   "List the collaborators of a feed on an Atmosfeed server",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := viper.BindPFlags(cmd.PersistentFlags()); err != nil {
+			return err
+		}
+
+		token, service, err := authorizeAtmosfeed(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		u, err := url.Parse(viper.GetString(atmosfeedURLFlag))
+		if err != nil {
+			return err
+		}
+
+		u = u.JoinPath("admin", "feeds", "collaborators")
+
+		q := u.Query()
+		q.Add("rkey", viper.GetString(feedRkeyFlag))
+		q.Add("service", service)
+		q.Add("feedDID", viper.GetString(feedDIDFlag))
+		u.RawQuery = q.Encode()
+
+		req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+		if err != nil {
+			return err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return errors.New(resp.Status)
+		}
+
+		collaborators := []feedCollaborator{}
+		if err := json.NewDecoder(resp.Body).Decode(&collaborators); err != nil {
+			return err
+		}
+
+		return yaml.NewEncoder(os.Stdout).Encode(collaborators)
+	},
+}
+
+var collaboratorRemoveCmd = &cobra.Command{
+	Use:     "remove",
+	Aliases: []string{"r"},
+	Short:   "Remove a collaborator from a feed on an Atmosfeed server",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := viper.BindPFlags(cmd.PersistentFlags()); err != nil {
+			return err
+		}
+
+		token, service, err := authorizeAtmosfeed(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		u, err := url.Parse(viper.GetString(atmosfeedURLFlag))
+		if err != nil {
+			return err
+		}
+
+		u = u.JoinPath("admin", "feeds", "collaborators")
+
+		q := u.Query()
+		q.Add("rkey", viper.GetString(feedRkeyFlag))
+		q.Add("service", service)
+		q.Add("feedDID", viper.GetString(feedDIDFlag))
+		q.Add("did", viper.GetString(collaboratorDIDFlag))
+		u.RawQuery = q.Encode()
+
+		req, err := http.NewRequest(http.MethodDelete, u.String(), nil)
+		if err != nil {
+			return err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return errors.New(resp.Status)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	collaboratorAddCmd.PersistentFlags().String(feedRkeyFlag, "trending", "Machine-readable key for the feed")
+	collaboratorAddCmd.PersistentFlags().String(feedDIDFlag, "", "DID of the account that owns the feed (if left empty, the authenticated account is used; set it to manage a feed that is shared with you)")
+
+	collaboratorAddCmd.PersistentFlags().String(collaboratorDIDFlag, "", "DID of the collaborator")
+	collaboratorAddCmd.PersistentFlags().String(collaboratorRoleFlag, "viewer", "Role of the collaborator (viewer, editor or owner)")
+
+	collaboratorListCmd.PersistentFlags().String(feedRkeyFlag, "trending", "Machine-readable key for the feed")
+	collaboratorListCmd.PersistentFlags().String(feedDIDFlag, "", "DID of the account that owns the feed (if left empty, the authenticated account is used; set it to manage a feed that is shared with you)")
+
+	collaboratorRemoveCmd.PersistentFlags().String(feedRkeyFlag, "trending", "Machine-readable key for the feed")
+	collaboratorRemoveCmd.PersistentFlags().String(feedDIDFlag, "", "DID of the account that owns the feed (if left empty, the authenticated account is used; set it to manage a feed that is shared with you)")
+
+	collaboratorRemoveCmd.PersistentFlags().String(collaboratorDIDFlag, "", "DID of the collaborator (set it to your own DID to leave a feed that is shared with you)")
+
+	viper.AutomaticEnv()
+
+	collaboratorCmd.AddCommand(collaboratorAddCmd)
+	collaboratorCmd.AddCommand(collaboratorListCmd)
+	collaboratorCmd.AddCommand(collaboratorRemoveCmd)
+
+	rootCmd.AddCommand(collaboratorCmd)
+}
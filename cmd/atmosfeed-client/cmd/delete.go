@@ -18,7 +18,7 @@ var deleteCmd = &cobra.Command{
 			return err
 		}
 
-		_, auth, err := authorize(cmd.Context())
+		token, service, err := authorizeAtmosfeed(cmd.Context())
 		if err != nil {
 			return err
 		}
@@ -32,7 +32,8 @@ var deleteCmd = &cobra.Command{
 
 		q := u.Query()
 		q.Add("rkey", viper.GetString(feedRkeyFlag))
-		q.Add("service", viper.GetString(pdsURLFlag))
+		q.Add("service", service)
+		q.Add("feedDID", viper.GetString(feedDIDFlag))
 		u.RawQuery = q.Encode()
 
 		req, err := http.NewRequest(http.MethodDelete, u.String(), nil)
@@ -40,7 +41,7 @@ var deleteCmd = &cobra.Command{
 			return err
 		}
 
-		req.Header.Set("Authorization", "Bearer "+auth.AccessJwt)
+		req.Header.Set("Authorization", "Bearer "+token)
 
 		resp, err := http.DefaultClient.Do(req)
 		if err != nil {
@@ -58,6 +59,7 @@ var deleteCmd = &cobra.Command{
 
 func init() {
 	deleteCmd.PersistentFlags().String(feedRkeyFlag, "trending", "Machine-readable key for the feed")
+	deleteCmd.PersistentFlags().String(feedDIDFlag, "", "DID of the account that owns the feed (if left empty, the authenticated account is used; set it to manage a feed that is shared with you)")
 
 	viper.AutomaticEnv()
 
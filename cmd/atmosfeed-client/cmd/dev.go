@@ -37,7 +37,8 @@ const (
 	minWeightFlag = "min-weight"
 	maxPostsFlag  = "max-posts"
 
-	lexiconFeedPost = "app.bsky.feed.post"
+	lexiconFeedPost   = "app.bsky.feed.post"
+	lexiconFeedRepost = "app.bsky.feed.repost"
 )
 
 var (
@@ -212,6 +213,62 @@ var devCmd = &cobra.Command{
 							if viper.GetBool(verboseFlag) {
 								log.Println("Published like", post)
 							}
+						} else if post.LexiconTypeID == lexiconFeedRepost {
+							var repost bsky.FeedRepost
+							if err := json.Unmarshal(b, &repost); err != nil {
+								if !viper.GetBool(quietFlag) {
+									log.Println("Could not unmarshal repost, skipping:", err)
+								}
+
+								continue l
+							}
+
+							u, err := iutil.ParseAtUri(repost.Subject.Uri)
+							if err != nil {
+								if !viper.GetBool(quietFlag) {
+									log.Println("Could not parse repost subject URI, skipping:", err)
+								}
+
+								continue l
+							}
+
+							p := signature.NewPost()
+
+							p.Did = rp.RepoDid()
+							p.Rkey = path.Base(op.Path)
+
+							createdAt, err := time.Parse(time.RFC3339Nano, repost.CreatedAt)
+							if err != nil {
+								createdAt, err = time.Parse("2006-01-02T15:04:05.999999", repost.CreatedAt) // For some reason, Bsky sometimes seems to not specify the timezone
+								if err != nil {
+									if !viper.GetBool(quietFlag) {
+										log.Println(errMessageInvalidCreatedAt)
+									}
+
+									continue l
+								}
+							}
+
+							p.CreatedAt = createdAt.Unix()
+
+							p.Repost = true
+							p.SubjectDid = u.Did
+							p.SubjectRkey = u.Rkey
+
+							// Reposts carry the text, languages and reply status of the reposted post if it is known
+							postsLock.Lock()
+							if po, ok := posts[u.Did+"/"+u.Rkey]; ok {
+								p.Text = po.Text
+								p.Langs = po.Langs
+								p.Reply = po.Reply
+							}
+							postsLock.Unlock()
+
+							postsCh <- *p
+
+							if viper.GetBool(verboseFlag) {
+								log.Println("Published repost", repost)
+							}
 						}
 					}
 				}
@@ -242,7 +299,11 @@ var devCmd = &cobra.Command{
 			}
 
 			if s.Context.Weight >= viper.GetInt64(minWeightFlag) {
-				fmt.Println(s.Context.Weight, fu.JoinPath("profile", post.Did, "post", post.Rkey), post)
+				if post.Repost {
+					fmt.Println(s.Context.Weight, fu.JoinPath("profile", post.SubjectDid, "post", post.SubjectRkey), "reposted by", post.Did, post)
+				} else {
+					fmt.Println(s.Context.Weight, fu.JoinPath("profile", post.Did, "post", post.Rkey), post)
+				}
 			}
 		}
 
@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/pojntfx/atmosfeed/pkg/models"
 	"github.com/spf13/cobra"
@@ -19,10 +20,25 @@ const (
 	outFlag = "out"
 )
 
+type structuredUserdataFeedPin struct {
+	FeedDid  string     `json:"feedDID"`
+	FeedRkey string     `json:"feedRkey"`
+	PostDid  string     `json:"postDID"`
+	PostRkey string     `json:"postRkey"`
+	Position int32      `json:"position"`
+	StartsAt *time.Time `json:"startsAt,omitempty"`
+	EndsAt   *time.Time `json:"endsAt,omitempty"`
+}
+
 type structuredUserdata struct {
-	Feeds     []models.Feed     `json:"feeds"`
-	Posts     []models.Post     `json:"posts"`
-	FeedPosts []models.FeedPost `json:"feedPosts"`
+	Feeds         []models.Feed               `json:"feeds"`
+	Posts         []models.Post               `json:"posts"`
+	FeedPosts     []models.FeedPost           `json:"feedPosts"`
+	FeedPins      []structuredUserdataFeedPin `json:"feedPins"`
+	Blocks        []models.Block              `json:"blocks"`
+	Interactions  []models.Interaction        `json:"interactions"`
+	Collaborators []models.FeedCollaborator   `json:"collaborators"`
+	AuditEvents   []models.AuditEvent         `json:"auditEvents"`
 }
 
 var exportUserdata = &cobra.Command{
@@ -44,6 +60,11 @@ var exportUserdata = &cobra.Command{
 			return err
 		}
 
+		rerankersDir := filepath.Join(viper.GetString(outFlag), "blobs", "rerankers")
+		if err := os.MkdirAll(rerankersDir, os.ModePerm); err != nil {
+			return err
+		}
+
 		var structuredData structuredUserdata
 		{
 			u, err := url.Parse(viper.GetString(atmosfeedURLFlag))
@@ -138,6 +159,54 @@ var exportUserdata = &cobra.Command{
 			}
 		}
 
+		{
+			for _, feed := range structuredData.Feeds {
+				if !feed.Reranker {
+					continue
+				}
+
+				u, err := url.Parse(viper.GetString(atmosfeedURLFlag))
+				if err != nil {
+					return err
+				}
+
+				u = u.JoinPath("userdata", "blob")
+
+				q := u.Query()
+				q.Add("service", viper.GetString(pdsURLFlag))
+				q.Add("resource", "reranker")
+				q.Add("rkey", feed.Rkey)
+				u.RawQuery = q.Encode()
+
+				req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+				if err != nil {
+					return err
+				}
+
+				req.Header.Set("Authorization", "Bearer "+auth.AccessJwt)
+
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					return err
+				}
+				defer resp.Body.Close()
+
+				if resp.StatusCode != http.StatusOK {
+					return errors.New(resp.Status)
+				}
+
+				rerankerFile, err := os.OpenFile(filepath.Join(rerankersDir, feed.Rkey+".scale"), os.O_RDWR|os.O_TRUNC|os.O_CREATE, os.ModePerm)
+				if err != nil {
+					return err
+				}
+				defer rerankerFile.Close()
+
+				if _, err := io.Copy(rerankerFile, resp.Body); err != nil {
+					return err
+				}
+			}
+		}
+
 		return nil
 	},
 }
@@ -7,6 +7,8 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/bluesky-social/indigo/api/atproto"
 	"github.com/bluesky-social/indigo/xrpc"
@@ -15,10 +17,33 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+type feedPin struct {
+	PostDid  string     `json:"postDID"`
+	PostRkey string     `json:"postRkey"`
+	Position int32      `json:"position"`
+	StartsAt *time.Time `json:"startsAt,omitempty"`
+	EndsAt   *time.Time `json:"endsAt,omitempty"`
+}
+
 type feedMetatadata struct {
-	Rkey       string `json:"rkey"`
-	PinnedDid  string `json:"pinnedDID"`
-	PinnedRkey string `json:"pinnedRkey"`
+	Did                       string    `json:"did"`
+	Rkey                      string    `json:"rkey"`
+	Role                      string    `json:"role"`
+	Pins                      []feedPin `json:"pins"`
+	ClassifierErrors          int32     `json:"classifierErrors"`
+	ClassifierTimeouts        int32     `json:"classifierTimeouts"`
+	Quarantined               bool      `json:"quarantined"`
+	ClassifierLimitViolations int32     `json:"classifierLimitViolations"`
+	Retention                 int32     `json:"retention"`
+	Ranking                   string    `json:"ranking"`
+	HalfLife                  int32     `json:"halfLife"`
+	TTL                       int32     `json:"ttl"`
+	MaxPageSize               int32     `json:"maxPageSize"`
+	DefaultPageSize           int32     `json:"defaultPageSize"`
+	HideViewerPosts           bool      `json:"hideViewerPosts"`
+	HideBlockedPosts          bool      `json:"hideBlockedPosts"`
+	Reranker                  bool      `json:"reranker"`
+	Suspended                 bool      `json:"suspended"`
 }
 
 func authorize(ctx context.Context) (*xrpc.Client, *xrpc.AuthInfo, error) {
@@ -46,6 +71,21 @@ func authorize(ctx context.Context) (*xrpc.Client, *xrpc.AuthInfo, error) {
 	return client, auth, nil
 }
 
+// authorizeAtmosfeed returns the token and PDS URL to authenticate against the Atmosfeed server with;
+// API tokens are issued by the Atmosfeed server itself, so they don't require a Bluesky session or PDS URL
+func authorizeAtmosfeed(ctx context.Context) (string, string, error) {
+	if apiToken := viper.GetString(apiTokenFlag); strings.TrimSpace(apiToken) != "" {
+		return apiToken, "", nil
+	}
+
+	_, auth, err := authorize(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	return auth.AccessJwt, viper.GetString(pdsURLFlag), nil
+}
+
 var listCmd = &cobra.Command{
 	Use:     "list",
 	Aliases: []string{"l"},
@@ -55,7 +95,7 @@ var listCmd = &cobra.Command{
 			return err
 		}
 
-		_, auth, err := authorize(cmd.Context())
+		token, service, err := authorizeAtmosfeed(cmd.Context())
 		if err != nil {
 			return err
 		}
@@ -68,7 +108,7 @@ var listCmd = &cobra.Command{
 		u = u.JoinPath("admin", "feeds")
 
 		q := u.Query()
-		q.Add("service", viper.GetString(pdsURLFlag))
+		q.Add("service", service)
 		u.RawQuery = q.Encode()
 
 		req, err := http.NewRequest(http.MethodGet, u.String(), nil)
@@ -76,7 +116,7 @@ var listCmd = &cobra.Command{
 			return err
 		}
 
-		req.Header.Set("Authorization", "Bearer "+auth.AccessJwt)
+		req.Header.Set("Authorization", "Bearer "+token)
 
 		resp, err := http.DefaultClient.Do(req)
 		if err != nil {
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+var listPinsCmd = &cobra.Command{
+	Use:     "list-pins",
+	Aliases: []string{"lp"},
+	Short:   "List the pinned posts of a feed on an Atmosfeed server",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := viper.BindPFlags(cmd.PersistentFlags()); err != nil {
+			return err
+		}
+
+		token, service, err := authorizeAtmosfeed(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		u, err := url.Parse(viper.GetString(atmosfeedURLFlag))
+		if err != nil {
+			return err
+		}
+
+		u = u.JoinPath("admin", "feeds", "pins")
+
+		q := u.Query()
+		q.Add("rkey", viper.GetString(feedRkeyFlag))
+		q.Add("service", service)
+		q.Add("feedDID", viper.GetString(feedDIDFlag))
+		u.RawQuery = q.Encode()
+
+		req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+		if err != nil {
+			return err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return errors.New(resp.Status)
+		}
+
+		pins := []feedPin{}
+		if err := json.NewDecoder(resp.Body).Decode(&pins); err != nil {
+			return err
+		}
+
+		return yaml.NewEncoder(os.Stdout).Encode(pins)
+	},
+}
+
+func init() {
+	listPinsCmd.PersistentFlags().String(feedRkeyFlag, "trending", "Machine-readable key for the feed")
+	listPinsCmd.PersistentFlags().String(feedDIDFlag, "", "DID of the account that owns the feed (if left empty, the authenticated account is used; set it to manage a feed that is shared with you)")
+
+	viper.AutomaticEnv()
+
+	rootCmd.AddCommand(listPinsCmd)
+}
@@ -0,0 +1,327 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	operatorTokenFlag = "operator-token"
+
+	operatorDIDFlag    = "did"
+	operatorReasonFlag = "reason"
+
+	operatorMaxFeedsFlag          = "max-feeds"
+	operatorMaxClassifierSizeFlag = "max-classifier-size"
+	operatorMaxStorageFlag        = "max-storage"
+)
+
+type operatorFeed struct {
+	Did                       string `json:"did"`
+	Rkey                      string `json:"rkey"`
+	Posts                     int64  `json:"posts"`
+	ClassifierErrors          int32  `json:"classifierErrors"`
+	ClassifierTimeouts        int32  `json:"classifierTimeouts"`
+	ClassifierLimitViolations int32  `json:"classifierLimitViolations"`
+	Quarantined               bool   `json:"quarantined"`
+	Suspended                 bool   `json:"suspended"`
+	Reranker                  bool   `json:"reranker"`
+}
+
+type bannedDid struct {
+	Did       string    `json:"did"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// doOperatorRequest sends a request to the operator API of an Atmosfeed server and decodes the response into res if it is not nil
+func doOperatorRequest(method string, query url.Values, res any, elem ...string) error {
+	u, err := url.Parse(viper.GetString(atmosfeedURLFlag))
+	if err != nil {
+		return err
+	}
+
+	u = u.JoinPath(append([]string{"operator"}, elem...)...)
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+viper.GetString(operatorTokenFlag))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.New(resp.Status)
+	}
+
+	if res == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(res)
+}
+
+var operatorCmd = &cobra.Command{
+	Use:     "operator",
+	Aliases: []string{"o"},
+	Short:   "Manage an Atmosfeed server as its operator",
+}
+
+var operatorListFeedsCmd = &cobra.Command{
+	Use:     "list-feeds",
+	Aliases: []string{"lf"},
+	Short:   "List all feeds on an Atmosfeed server with their owners and stats",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		feeds := []operatorFeed{}
+		if err := doOperatorRequest(http.MethodGet, url.Values{}, &feeds, "feeds"); err != nil {
+			return err
+		}
+
+		return yaml.NewEncoder(os.Stdout).Encode(feeds)
+	},
+}
+
+var operatorSuspendCmd = &cobra.Command{
+	Use:     "suspend",
+	Aliases: []string{"s"},
+	Short:   "Suspend a feed on an Atmosfeed server, which stops its classifier and stops serving it",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := viper.BindPFlags(cmd.PersistentFlags()); err != nil {
+			return err
+		}
+
+		q := url.Values{}
+		q.Add("did", viper.GetString(operatorDIDFlag))
+		q.Add("rkey", viper.GetString(feedRkeyFlag))
+		q.Add("suspended", strconv.FormatBool(true))
+
+		return doOperatorRequest(http.MethodPatch, q, nil, "feeds")
+	},
+}
+
+var operatorUnsuspendCmd = &cobra.Command{
+	Use:     "unsuspend",
+	Aliases: []string{"us"},
+	Short:   "Unsuspend a feed on an Atmosfeed server",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := viper.BindPFlags(cmd.PersistentFlags()); err != nil {
+			return err
+		}
+
+		q := url.Values{}
+		q.Add("did", viper.GetString(operatorDIDFlag))
+		q.Add("rkey", viper.GetString(feedRkeyFlag))
+		q.Add("suspended", strconv.FormatBool(false))
+
+		return doOperatorRequest(http.MethodPatch, q, nil, "feeds")
+	},
+}
+
+var operatorDeleteFeedCmd = &cobra.Command{
+	Use:     "delete-feed",
+	Aliases: []string{"df"},
+	Short:   "Force-delete a feed and its classifier from an Atmosfeed server",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := viper.BindPFlags(cmd.PersistentFlags()); err != nil {
+			return err
+		}
+
+		q := url.Values{}
+		q.Add("did", viper.GetString(operatorDIDFlag))
+		q.Add("rkey", viper.GetString(feedRkeyFlag))
+
+		return doOperatorRequest(http.MethodDelete, q, nil, "feeds")
+	},
+}
+
+var operatorBanCmd = &cobra.Command{
+	Use:     "ban",
+	Aliases: []string{"b"},
+	Short:   "Ban a DID from uploading classifiers and rerankers to an Atmosfeed server",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := viper.BindPFlags(cmd.PersistentFlags()); err != nil {
+			return err
+		}
+
+		q := url.Values{}
+		q.Add("did", viper.GetString(operatorDIDFlag))
+		q.Add("reason", viper.GetString(operatorReasonFlag))
+
+		return doOperatorRequest(http.MethodPut, q, nil, "bans")
+	},
+}
+
+var operatorUnbanCmd = &cobra.Command{
+	Use:     "unban",
+	Aliases: []string{"ub"},
+	Short:   "Lift the ban of a DID on an Atmosfeed server",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := viper.BindPFlags(cmd.PersistentFlags()); err != nil {
+			return err
+		}
+
+		q := url.Values{}
+		q.Add("did", viper.GetString(operatorDIDFlag))
+
+		return doOperatorRequest(http.MethodDelete, q, nil, "bans")
+	},
+}
+
+var operatorListBansCmd = &cobra.Command{
+	Use:     "list-bans",
+	Aliases: []string{"lb"},
+	Short:   "List banned DIDs on an Atmosfeed server",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		bans := []bannedDid{}
+		if err := doOperatorRequest(http.MethodGet, url.Values{}, &bans, "bans"); err != nil {
+			return err
+		}
+
+		return yaml.NewEncoder(os.Stdout).Encode(bans)
+	},
+}
+
+var operatorListQuotasCmd = &cobra.Command{
+	Use:     "list-quotas",
+	Aliases: []string{"lq"},
+	Short:   "List the quota usage and limits of all DIDs on an Atmosfeed server",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		quotas := []quota{}
+		if err := doOperatorRequest(http.MethodGet, url.Values{}, &quotas, "quotas"); err != nil {
+			return err
+		}
+
+		return yaml.NewEncoder(os.Stdout).Encode(quotas)
+	},
+}
+
+var operatorSetQuotaCmd = &cobra.Command{
+	Use:     "set-quota",
+	Aliases: []string{"sq"},
+	Short:   "Override the quotas of a DID on an Atmosfeed server",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := viper.BindPFlags(cmd.PersistentFlags()); err != nil {
+			return err
+		}
+
+		q := url.Values{}
+		q.Add("did", viper.GetString(operatorDIDFlag))
+
+		// Limits are only sent if they were set explicitly so that the server's defaults are used otherwise
+		if viper.IsSet(operatorMaxFeedsFlag) {
+			q.Add("maxFeeds", strconv.FormatInt(viper.GetInt64(operatorMaxFeedsFlag), 10))
+		}
+
+		if viper.IsSet(operatorMaxClassifierSizeFlag) {
+			q.Add("maxClassifierSize", strconv.FormatInt(viper.GetInt64(operatorMaxClassifierSizeFlag), 10))
+		}
+
+		if viper.IsSet(operatorMaxStorageFlag) {
+			q.Add("maxStorage", strconv.FormatInt(viper.GetInt64(operatorMaxStorageFlag), 10))
+		}
+
+		return doOperatorRequest(http.MethodPut, q, nil, "quotas")
+	},
+}
+
+var operatorResetQuotaCmd = &cobra.Command{
+	Use:     "reset-quota",
+	Aliases: []string{"rq"},
+	Short:   "Reset the quotas of a DID on an Atmosfeed server to the defaults",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := viper.BindPFlags(cmd.PersistentFlags()); err != nil {
+			return err
+		}
+
+		q := url.Values{}
+		q.Add("did", viper.GetString(operatorDIDFlag))
+
+		return doOperatorRequest(http.MethodDelete, q, nil, "quotas")
+	},
+}
+
+var operatorListAuditEventsCmd = &cobra.Command{
+	Use:     "list-audit-events",
+	Aliases: []string{"la"},
+	Short:   "List the audit log of an Atmosfeed server",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := viper.BindPFlags(cmd.PersistentFlags()); err != nil {
+			return err
+		}
+
+		q := url.Values{}
+		q.Add("did", viper.GetString(operatorDIDFlag))
+		q.Add("limit", strconv.Itoa(viper.GetInt(auditLimitFlag)))
+
+		events := []auditEvent{}
+		if err := doOperatorRequest(http.MethodGet, q, &events, "audit"); err != nil {
+			return err
+		}
+
+		return yaml.NewEncoder(os.Stdout).Encode(events)
+	},
+}
+
+func init() {
+	operatorCmd.PersistentFlags().String(operatorTokenFlag, "", "Operator token of the Atmosfeed server (see the server's --operator-token)")
+
+	operatorSuspendCmd.PersistentFlags().String(operatorDIDFlag, "", "DID of the feed's owner")
+	operatorSuspendCmd.PersistentFlags().String(feedRkeyFlag, "trending", "Machine-readable key for the feed")
+
+	operatorUnsuspendCmd.PersistentFlags().String(operatorDIDFlag, "", "DID of the feed's owner")
+	operatorUnsuspendCmd.PersistentFlags().String(feedRkeyFlag, "trending", "Machine-readable key for the feed")
+
+	operatorDeleteFeedCmd.PersistentFlags().String(operatorDIDFlag, "", "DID of the feed's owner")
+	operatorDeleteFeedCmd.PersistentFlags().String(feedRkeyFlag, "trending", "Machine-readable key for the feed")
+
+	operatorBanCmd.PersistentFlags().String(operatorDIDFlag, "", "DID to ban")
+	operatorBanCmd.PersistentFlags().String(operatorReasonFlag, "", "Reason for the ban, for future reference")
+
+	operatorUnbanCmd.PersistentFlags().String(operatorDIDFlag, "", "DID to unban")
+
+	operatorSetQuotaCmd.PersistentFlags().String(operatorDIDFlag, "", "DID to override the quotas of")
+	operatorSetQuotaCmd.PersistentFlags().Int64(operatorMaxFeedsFlag, 0, "Maximum amount of feeds (0 disables the limit; if not set, the server's default is used)")
+	operatorSetQuotaCmd.PersistentFlags().Int64(operatorMaxClassifierSizeFlag, 0, "Maximum size in bytes of a classifier or reranker (0 disables the limit; if not set, the server's default is used)")
+	operatorSetQuotaCmd.PersistentFlags().Int64(operatorMaxStorageFlag, 0, "Maximum total size in bytes of all classifiers and rerankers (0 disables the limit; if not set, the server's default is used)")
+
+	operatorResetQuotaCmd.PersistentFlags().String(operatorDIDFlag, "", "DID to reset the quotas of")
+
+	operatorListAuditEventsCmd.PersistentFlags().String(operatorDIDFlag, "", "DID to list the audit events of, including events for its feeds (if left empty, the audit events of all DIDs are listed)")
+	operatorListAuditEventsCmd.PersistentFlags().Int(auditLimitFlag, 100, "Maximum amount of audit events to list, starting with the latest one (at most 1000)")
+
+	if err := viper.BindPFlags(operatorCmd.PersistentFlags()); err != nil {
+		panic(err)
+	}
+
+	viper.AutomaticEnv()
+
+	operatorCmd.AddCommand(operatorListFeedsCmd)
+	operatorCmd.AddCommand(operatorSuspendCmd)
+	operatorCmd.AddCommand(operatorUnsuspendCmd)
+	operatorCmd.AddCommand(operatorDeleteFeedCmd)
+	operatorCmd.AddCommand(operatorBanCmd)
+	operatorCmd.AddCommand(operatorUnbanCmd)
+	operatorCmd.AddCommand(operatorListBansCmd)
+	operatorCmd.AddCommand(operatorListQuotasCmd)
+	operatorCmd.AddCommand(operatorSetQuotaCmd)
+	operatorCmd.AddCommand(operatorResetQuotaCmd)
+	operatorCmd.AddCommand(operatorListAuditEventsCmd)
+
+	rootCmd.AddCommand(operatorCmd)
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	postDIDFlag     = "post-did"
+	postRkeyFlag    = "post-rkey"
+	pinPositionFlag = "position"
+	pinStartsAtFlag = "starts-at"
+	pinEndsAtFlag   = "ends-at"
+)
+
+var pinCmd = &cobra.Command{
+	Use:     "pin",
+	Aliases: []string{"pi"},
+	Short:   "Pin a post to a feed on an Atmosfeed server",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := viper.BindPFlags(cmd.PersistentFlags()); err != nil {
+			return err
+		}
+
+		token, service, err := authorizeAtmosfeed(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		u, err := url.Parse(viper.GetString(atmosfeedURLFlag))
+		if err != nil {
+			return err
+		}
+
+		u = u.JoinPath("admin", "feeds", "pins")
+
+		q := u.Query()
+		q.Add("rkey", viper.GetString(feedRkeyFlag))
+		q.Add("service", service)
+		q.Add("feedDID", viper.GetString(feedDIDFlag))
+		q.Add("postDID", viper.GetString(postDIDFlag))
+		q.Add("postRkey", viper.GetString(postRkeyFlag))
+		q.Add("position", strconv.Itoa(viper.GetInt(pinPositionFlag)))
+
+		// The server validates the schedule, so we can pass it through as-is
+		if startsAt := viper.GetString(pinStartsAtFlag); strings.TrimSpace(startsAt) != "" {
+			q.Add("startsAt", startsAt)
+		}
+
+		if endsAt := viper.GetString(pinEndsAtFlag); strings.TrimSpace(endsAt) != "" {
+			q.Add("endsAt", endsAt)
+		}
+
+		u.RawQuery = q.Encode()
+
+		req, err := http.NewRequest(http.MethodPut, u.String(), nil)
+		if err != nil {
+			return err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return errors.New(resp.Status)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	pinCmd.PersistentFlags().String(feedRkeyFlag, "trending", "Machine-readable key for the feed")
+	pinCmd.PersistentFlags().String(feedDIDFlag, "", "DID of the account that owns the feed (if left empty, the authenticated account is used; set it to manage a feed that is shared with you)")
+
+	pinCmd.PersistentFlags().String(postDIDFlag, "", "DID of the post to pin")
+	pinCmd.PersistentFlags().String(postRkeyFlag, "", "Machine-readable key of the post to pin")
+
+	pinCmd.PersistentFlags().Int(pinPositionFlag, 0, "Position of the pin in the feed (pins with lower positions are returned first; pins with the same position are sorted by their post)")
+
+	pinCmd.PersistentFlags().String(pinStartsAtFlag, "", "Time at which the pin becomes active in RFC3339 format (if left empty, the pin is active immediately)")
+	pinCmd.PersistentFlags().String(pinEndsAtFlag, "", "Time at which the pin becomes inactive in RFC3339 format (if left empty, the pin stays active until it is removed)")
+
+	viper.AutomaticEnv()
+
+	rootCmd.AddCommand(pinCmd)
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+type quota struct {
+	Did               string `json:"did"`
+	Feeds             int64  `json:"feeds"`
+	MaxFeeds          int64  `json:"maxFeeds"`
+	Storage           int64  `json:"storage"`
+	MaxStorage        int64  `json:"maxStorage"`
+	MaxClassifierSize int64  `json:"maxClassifierSize"`
+}
+
+type quotaExceededError struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+	Quota   string `json:"quota"`
+	Limit   int64  `json:"limit"`
+	Usage   int64  `json:"usage"`
+}
+
+// getUploadError returns the error for a failed classifier or reranker upload, which includes the exceeded quota if there is one
+func getUploadError(resp *http.Response) error {
+	var quotaErr quotaExceededError
+	if err := json.NewDecoder(resp.Body).Decode(&quotaErr); err != nil || quotaErr.Error != "QuotaExceeded" {
+		return errors.New(resp.Status)
+	}
+
+	return fmt.Errorf("%v: %v", resp.Status, quotaErr.Message)
+}
+
+var quotaCmd = &cobra.Command{
+	Use:     "quota",
+	Aliases: []string{"q"},
+	Short:   "Show the quota usage and limits of your account on an Atmosfeed server",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := viper.BindPFlags(cmd.PersistentFlags()); err != nil {
+			return err
+		}
+
+		token, service, err := authorizeAtmosfeed(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		u, err := url.Parse(viper.GetString(atmosfeedURLFlag))
+		if err != nil {
+			return err
+		}
+
+		u = u.JoinPath("admin", "quota")
+
+		q := u.Query()
+		q.Add("service", service)
+		u.RawQuery = q.Encode()
+
+		req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+		if err != nil {
+			return err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return errors.New(resp.Status)
+		}
+
+		res := quota{}
+		if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+			return err
+		}
+
+		return yaml.NewEncoder(os.Stdout).Encode(res)
+	},
+}
+
+func init() {
+	viper.AutomaticEnv()
+
+	rootCmd.AddCommand(quotaCmd)
+}
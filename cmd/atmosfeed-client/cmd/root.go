@@ -13,6 +13,8 @@ const (
 	pdsURLFlag   = "pds-url"
 	usernameFlag = "username"
 	passwordFlag = "password"
+
+	apiTokenFlag = "api-token"
 )
 
 var rootCmd = &cobra.Command{
@@ -40,6 +42,8 @@ func Execute() error {
 	rootCmd.PersistentFlags().String(usernameFlag, "example.bsky.social", "Bluesky username")
 	rootCmd.PersistentFlags().String(passwordFlag, "", "Bluesky password, preferably an app password (get one from https://bsky.app/settings/app-passwords)")
 
+	rootCmd.PersistentFlags().String(apiTokenFlag, "", "Atmosfeed API token to use instead of a Bluesky session for managing feeds on the Atmosfeed server (see token create; commands that modify Bluesky or user data still require a Bluesky session)")
+
 	if err := viper.BindPFlags(rootCmd.PersistentFlags()); err != nil {
 		return err
 	}
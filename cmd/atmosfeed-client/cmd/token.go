@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	tokenDescriptionFlag = "description"
+	tokenFeedsFlag       = "feeds"
+	tokenScopesFlag      = "scopes"
+	tokenIDFlag          = "id"
+)
+
+type apiToken struct {
+	ID          string    `json:"id"`
+	Token       string    `json:"token,omitempty"`
+	Description string    `json:"description"`
+	Feeds       []string  `json:"feeds"`
+	Scopes      []string  `json:"scopes"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+var tokenCmd = &cobra.Command{
+	Use:     "token",
+	Aliases: []string{"t"},
+	Short:   "Manage API tokens for an Atmosfeed server",
+}
+
+var tokenCreateCmd = &cobra.Command{
+	Use:     "create",
+	Aliases: []string{"c"},
+	Short:   "Create an API token on an Atmosfeed server",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := viper.BindPFlags(cmd.PersistentFlags()); err != nil {
+			return err
+		}
+
+		_, auth, err := authorize(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		u, err := url.Parse(viper.GetString(atmosfeedURLFlag))
+		if err != nil {
+			return err
+		}
+
+		u = u.JoinPath("admin", "tokens")
+
+		q := u.Query()
+		q.Add("service", viper.GetString(pdsURLFlag))
+		q.Add("description", viper.GetString(tokenDescriptionFlag))
+		q.Add("feeds", strings.Join(viper.GetStringSlice(tokenFeedsFlag), ","))
+		q.Add("scopes", strings.Join(viper.GetStringSlice(tokenScopesFlag), ","))
+		u.RawQuery = q.Encode()
+
+		req, err := http.NewRequest(http.MethodPut, u.String(), nil)
+		if err != nil {
+			return err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+auth.AccessJwt)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return errors.New(resp.Status)
+		}
+
+		var token apiToken
+		if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+			return err
+		}
+
+		return yaml.NewEncoder(os.Stdout).Encode(token)
+	},
+}
+
+var tokenListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"l"},
+	Short:   "List API tokens on an Atmosfeed server",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := viper.BindPFlags(cmd.PersistentFlags()); err != nil {
+			return err
+		}
+
+		_, auth, err := authorize(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		u, err := url.Parse(viper.GetString(atmosfeedURLFlag))
+		if err != nil {
+			return err
+		}
+
+		u = u.JoinPath("admin", "tokens")
+
+		q := u.Query()
+		q.Add("service", viper.GetString(pdsURLFlag))
+		u.RawQuery = q.Encode()
+
+		req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+		if err != nil {
+			return err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+auth.AccessJwt)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return errors.New(resp.Status)
+		}
+
+		tokens := []apiToken{}
+		if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+			return err
+		}
+
+		return yaml.NewEncoder(os.Stdout).Encode(tokens)
+	},
+}
+
+var tokenRevokeCmd = &cobra.Command{
+	Use:     "revoke",
+	Aliases: []string{"r"},
+	Short:   "Revoke an API token on an Atmosfeed server",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := viper.BindPFlags(cmd.PersistentFlags()); err != nil {
+			return err
+		}
+
+		_, auth, err := authorize(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		u, err := url.Parse(viper.GetString(atmosfeedURLFlag))
+		if err != nil {
+			return err
+		}
+
+		u = u.JoinPath("admin", "tokens")
+
+		q := u.Query()
+		q.Add("service", viper.GetString(pdsURLFlag))
+		q.Add("id", viper.GetString(tokenIDFlag))
+		u.RawQuery = q.Encode()
+
+		req, err := http.NewRequest(http.MethodDelete, u.String(), nil)
+		if err != nil {
+			return err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+auth.AccessJwt)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return errors.New(resp.Status)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	tokenCreateCmd.PersistentFlags().String(tokenDescriptionFlag, "", "Human-readable description of the token, e.g. the name of the CI pipeline that uses it")
+	tokenCreateCmd.PersistentFlags().StringSlice(tokenFeedsFlag, []string{}, "Machine-readable keys of the feeds that the token can access, with feeds that are shared with you given as did/rkey (if left empty, the token can access all feeds)")
+	tokenCreateCmd.PersistentFlags().StringSlice(tokenScopesFlag, []string{"read"}, "Actions that the token can perform (read, classifier, metadata, delete, collaborators and/or audit)")
+
+	tokenRevokeCmd.PersistentFlags().String(tokenIDFlag, "", "ID of the token to revoke")
+
+	viper.AutomaticEnv()
+
+	tokenCmd.AddCommand(tokenCreateCmd)
+	tokenCmd.AddCommand(tokenListCmd)
+	tokenCmd.AddCommand(tokenRevokeCmd)
+
+	rootCmd.AddCommand(tokenCmd)
+}
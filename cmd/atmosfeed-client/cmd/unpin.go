@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var unpinCmd = &cobra.Command{
+	Use:     "unpin",
+	Aliases: []string{"up"},
+	Short:   "Unpin a post from a feed on an Atmosfeed server",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := viper.BindPFlags(cmd.PersistentFlags()); err != nil {
+			return err
+		}
+
+		token, service, err := authorizeAtmosfeed(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		u, err := url.Parse(viper.GetString(atmosfeedURLFlag))
+		if err != nil {
+			return err
+		}
+
+		u = u.JoinPath("admin", "feeds", "pins")
+
+		q := u.Query()
+		q.Add("rkey", viper.GetString(feedRkeyFlag))
+		q.Add("service", service)
+		q.Add("feedDID", viper.GetString(feedDIDFlag))
+		q.Add("postDID", viper.GetString(postDIDFlag))
+		q.Add("postRkey", viper.GetString(postRkeyFlag))
+		u.RawQuery = q.Encode()
+
+		req, err := http.NewRequest(http.MethodDelete, u.String(), nil)
+		if err != nil {
+			return err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return errors.New(resp.Status)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	unpinCmd.PersistentFlags().String(feedRkeyFlag, "trending", "Machine-readable key for the feed")
+	unpinCmd.PersistentFlags().String(feedDIDFlag, "", "DID of the account that owns the feed (if left empty, the authenticated account is used; set it to manage a feed that is shared with you)")
+
+	unpinCmd.PersistentFlags().String(postDIDFlag, "", "DID of the post to unpin")
+	unpinCmd.PersistentFlags().String(postRkeyFlag, "", "Machine-readable key of the post to unpin")
+
+	viper.AutomaticEnv()
+
+	rootCmd.AddCommand(unpinCmd)
+}
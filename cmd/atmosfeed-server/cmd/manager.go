@@ -1,32 +1,27 @@
 package cmd
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
-	"fmt"
-	"io"
 	"log"
 	"net"
 	"net/http"
 	"net/url"
 	"path"
-	"strconv"
+	"reranker"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/bluesky-social/indigo/api/atproto"
-	"github.com/bluesky-social/indigo/api/bsky"
 	"github.com/bluesky-social/indigo/events"
 	"github.com/bluesky-social/indigo/events/schedulers/sequential"
-	lutil "github.com/bluesky-social/indigo/lex/util"
-	"github.com/bluesky-social/indigo/repo"
-	"github.com/bluesky-social/indigo/repomgr"
-	iutil "github.com/bluesky-social/indigo/util"
-	"github.com/bluesky-social/indigo/xrpc"
 	"github.com/gorilla/websocket"
-	"github.com/pojntfx/atmosfeed/pkg/models"
+	"github.com/loopholelabs/scale"
 	"github.com/pojntfx/atmosfeed/pkg/persisters"
+	"github.com/pojntfx/atmosfeed/pkg/resolvers"
+	"github.com/pojntfx/atmosfeed/pkg/verifiers"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/redis/go-redis/v9"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -36,16 +31,176 @@ const (
 	laddrFlag            = "laddr"
 	ttlFlag              = "ttl"
 	limitFlag            = "limit"
+	defaultLimitFlag     = "default-limit"
 	feedGeneratorDIDFlag = "feed-generator-did"
 	feedGeneratorURLFlag = "feed-generator-url"
 	bgsURLFlag           = "bgs-url"
 
-	lexiconFeedPost = "app.bsky.feed.post"
+	privacyPolicyURLFlag  = "privacy-policy-url"
+	termsOfServiceURLFlag = "terms-of-service-url"
+
+	plcURLFlag                        = "plc-url"
+	resolverCacheTTLFlag              = "resolver-cache-ttl"
+	resolverTimeoutFlag               = "resolver-timeout"
+	resolverAllowPrivateAddressesFlag = "resolver-allow-private-addresses"
+
+	rerankerTimeoutFlag   = "reranker-timeout"
+	rerankerMaxMemoryFlag = "reranker-max-memory"
+	rerankerMaxFuelFlag   = "reranker-max-fuel"
+
+	skeletonCacheTTLFlag = "skeleton-cache-ttl"
+	sessionCacheTTLFlag  = "session-cache-ttl"
+	metricsLaddrFlag     = "metrics-laddr"
+	operatorTokenFlag    = "operator-token"
+
+	maxFeedsFlag          = "max-feeds"
+	maxClassifierSizeFlag = "max-classifier-size"
+	maxStorageFlag        = "max-storage"
+
+	publicRateLimitFlag            = "public-rate-limit"
+	publicRateLimitBurstFlag       = "public-rate-limit-burst"
+	adminRateLimitFlag             = "admin-rate-limit"
+	adminRateLimitBurstFlag        = "admin-rate-limit-burst"
+	uploadRateLimitFlag            = "upload-rate-limit"
+	uploadRateLimitBurstFlag       = "upload-rate-limit-burst"
+	operatorAuthRateLimitFlag      = "operator-auth-rate-limit"
+	operatorAuthRateLimitBurstFlag = "operator-auth-rate-limit-burst"
+	viewerRateLimitFlag            = "viewer-rate-limit"
+	viewerRateLimitBurstFlag       = "viewer-rate-limit-burst"
+	trustForwardedForFlag          = "trust-forwarded-for"
+
+	retentionFlag          = "retention"
+	retentionIntervalFlag  = "retention-interval"
+	retentionBatchSizeFlag = "retention-batch-size"
+	blockRetentionFlag     = "block-retention"
+
+	lexiconFeedPost      = "app.bsky.feed.post"
+	lexiconFeedRepost    = "app.bsky.feed.repost"
+	lexiconFeedGenerator = "app.bsky.feed.generator"
+	lexiconGraphBlock    = "app.bsky.graph.block"
+	lexiconFeedDefs      = "app.bsky.feed.defs"
+
+	// Service-auth tokens for the admin API need to be bound to this method so that tokens for other methods can't be replayed
+	lexiconAdmin = "com.pojtinger.felicitas.atmosfeed.admin"
+
+	// API tokens are prefixed so that they can be told apart from JWTs and found by secret scanners
+	apiTokenPrefix = "atmosfeed_"
+
+	scopeRead       = "read"
+	scopeClassifier = "classifier"
+	scopeMetadata   = "metadata"
+	scopeDelete     = "delete"
+
+	// Managing a feed's collaborators and reading its audit log can only be granted to owners and tokens
+	scopeCollaborators = "collaborators"
+	scopeAudit         = "audit"
+
+	// Account-wide actions such as managing tokens or user data can't be granted to API tokens
+	scopeAccount = "account"
+
+	roleViewer = "viewer"
+	roleEditor = "editor"
+	roleOwner  = "owner"
+
+	quotaFeeds          = "feeds"
+	quotaClassifierSize = "classifierSize"
+	quotaStorage        = "storage"
+
+	rateLimitClassPublic = "public"
+	rateLimitClassAdmin  = "admin"
+	rateLimitClassUpload = "upload"
+
+	// Feed generator requests that the AppView sent on behalf of a verified viewer use up the budget of the viewer's DID in this class
+	rateLimitClassViewer = "viewer"
+
+	// Only failed attempts to authenticate with the operator token use up the budget of this class
+	rateLimitClassOperatorAuth = "operator-auth"
+
+	auditActionClassifierUpsert = "classifier.upsert"
+	auditActionRerankerUpsert   = "reranker.upsert"
+	auditActionRerankerDelete   = "reranker.delete"
+	auditActionMetadataUpdate   = "metadata.update"
+	auditActionPinUpsert        = "pin.upsert"
+	auditActionPinDelete        = "pin.delete"
+	auditActionFeedDelete       = "feed.delete"
+	auditActionFeedSuspend      = "feed.suspend"
+	auditActionFeedUnsuspend    = "feed.unsuspend"
+	auditActionUserdataDelete   = "userdata.delete"
+	auditActionBanUpsert        = "ban.upsert"
+	auditActionBanDelete        = "ban.delete"
+	auditActionQuotaUpsert      = "quota.upsert"
+	auditActionQuotaDelete      = "quota.delete"
+
+	// The operator isn't identified by a DID, so their actions are recorded with this actor instead
+	auditActorOperator = "operator"
+
+	// Deleted DIDs are replaced with a pseudonym that starts with this prefix, which the database only allows for this purpose
+	auditPseudonymPrefix = "deleted:"
+
+	defaultAuditEvents = 100
+	maxAuditEvents     = 1000
+
+	skeletonReasonPin    = lexiconFeedDefs + "#skeletonReasonPin"
+	skeletonReasonRepost = lexiconFeedDefs + "#skeletonReasonRepost"
+
+	interactionRequestMore          = "requestMore"
+	interactionRequestLess          = "requestLess"
+	interactionSeen                 = "interactionSeen"
+	interactionClickthroughItem     = "clickthroughItem"
+	interactionClickthroughAuthor   = "clickthroughAuthor"
+	interactionClickthroughReposter = "clickthroughReposter"
+	interactionClickthroughEmbed    = "clickthroughEmbed"
+	interactionLike                 = "interactionLike"
+	interactionRepost               = "interactionRepost"
+	interactionReply                = "interactionReply"
+	interactionQuote                = "interactionQuote"
+	interactionShare                = "interactionShare"
+
+	maxInteractions = 100
+
+	// Viewers of feeds that hide blocked posts are only marked as seen once per interval, so that feed requests don't cause a write each
+	blockViewerRefreshInterval = time.Hour
 
 	originFlag         = "origin"
 	deleteAllPostsFlag = "delete-all-posts"
 )
 
+var (
+	knownScopes = []string{scopeRead, scopeClassifier, scopeMetadata, scopeDelete, scopeCollaborators, scopeAudit}
+
+	// Each endpoint class has its own rate limit (in requests per minute) and burst
+	rateLimitFlags = map[string]struct{ rate, burst string }{
+		rateLimitClassPublic: {publicRateLimitFlag, publicRateLimitBurstFlag},
+		rateLimitClassAdmin:  {adminRateLimitFlag, adminRateLimitBurstFlag},
+		rateLimitClassUpload: {uploadRateLimitFlag, uploadRateLimitBurstFlag},
+		rateLimitClassViewer: {viewerRateLimitFlag, viewerRateLimitBurstFlag},
+
+		rateLimitClassOperatorAuth: {operatorAuthRateLimitFlag, operatorAuthRateLimitBurstFlag},
+	}
+
+	// Collaborators can act on a feed with the scopes of their role
+	roleScopes = map[string][]string{
+		roleViewer: {scopeRead},
+		roleEditor: {scopeRead, scopeClassifier, scopeMetadata},
+		roleOwner:  {scopeRead, scopeClassifier, scopeMetadata, scopeDelete, scopeCollaborators, scopeAudit},
+	}
+
+	knownInteractions = map[string]struct{}{
+		interactionRequestMore:          {},
+		interactionRequestLess:          {},
+		interactionSeen:                 {},
+		interactionClickthroughItem:     {},
+		interactionClickthroughAuthor:   {},
+		interactionClickthroughReposter: {},
+		interactionClickthroughEmbed:    {},
+		interactionLike:                 {},
+		interactionRepost:               {},
+		interactionReply:                {},
+		interactionQuote:                {},
+		interactionShare:                {},
+	}
+)
+
 var (
 	errMissingFeedURI             = errors.New("missing feed URI")
 	errInvalidFeedURI             = errors.New("invalid feed URI")
@@ -58,72 +213,100 @@ var (
 	errCouldNotGetPosts           = errors.New("could not get posts")
 	errCouldNotGetFeedPosts       = errors.New("could not get feed posts")
 	errMissingRkey                = errors.New("missing rkey")
+	errInvalidRkey                = errors.New("invalid rkey")
 	errCouldNotUpsertFeedMetadata = errors.New("could not upsert feed metadata")
 	errCouldNotUpsertClassifier   = errors.New("could not upsert feed classifier")
 	errCouldNotDeleteFeed         = errors.New("could not delete feed")
-	errMissingService             = errors.New("missing service")
+	errUntrustedService           = errors.New("PDS is not authoritative for session DID")
+	errInvalidSession             = errors.New("invalid session")
+	errCouldNotVerifySession      = errors.New("could not verify session")
+	errCouldNotInvalidateSession  = errors.New("could not invalidate session")
+	errForbidden                  = errors.New("forbidden")
+	errInvalidApiToken            = errors.New("invalid API token")
+	errInvalidScopes              = errors.New("invalid scopes")
+	errMissingApiTokenID          = errors.New("missing API token ID")
+	errApiTokenNotFound           = errors.New("API token not found")
+	errCouldNotCreateApiToken     = errors.New("could not create API token")
+	errCouldNotGetApiTokens       = errors.New("could not get API tokens")
+	errCouldNotDeleteApiToken     = errors.New("could not delete API token")
 	errMissingResource            = errors.New("missing resource")
 	errInvalidResource            = errors.New("invalid resource")
 	errCouldNotDeletePosts        = errors.New("could not delete posts")
 	errCouldNotDeleteFeedPosts    = errors.New("could not delete feed posts")
+	errInvalidRetention           = errors.New("invalid retention")
+	errInvalidRanking             = errors.New("invalid ranking")
+	errInvalidHalfLife            = errors.New("invalid half-life")
+	errInvalidTTL                 = errors.New("invalid TTL")
+	errInvalidPageSize            = errors.New("invalid page size")
+	errMissingPostDID             = errors.New("missing post DID")
+	errMissingPostRkey            = errors.New("missing post rkey")
+	errInvalidPosition            = errors.New("invalid position")
+	errInvalidPinSchedule         = errors.New("invalid pin schedule")
+	errFeedNotFound               = errors.New("feed not found")
+	errMissingCollaboratorDID     = errors.New("missing collaborator DID")
+	errInvalidCollaborator        = errors.New("invalid collaborator")
+	errInvalidRole                = errors.New("invalid role")
+	errCollaboratorNotFound       = errors.New("collaborator not found")
+	errCouldNotGetCollaborators   = errors.New("could not get feed collaborators")
+	errCouldNotUpsertCollaborator = errors.New("could not upsert feed collaborator")
+	errCouldNotDeleteCollaborator = errors.New("could not delete feed collaborator")
+	errCouldNotGetFeedPins        = errors.New("could not get feed pins")
+	errCouldNotUpsertFeedPin      = errors.New("could not upsert feed pin")
+	errCouldNotDeleteFeedPin      = errors.New("could not delete feed pin")
+	errInvalidServiceAuth         = errors.New("invalid service auth")
+	errInvalidViewerFilter        = errors.New("invalid viewer filter")
+	errCouldNotGetBlocks          = errors.New("could not get blocks")
+	errCouldNotDeleteBlocks       = errors.New("could not delete blocks")
+	errCouldNotUpsertReranker     = errors.New("could not upsert feed reranker")
+	errCouldNotDeleteReranker     = errors.New("could not delete feed reranker")
+	errCouldNotGetReranker        = errors.New("could not get feed reranker")
+	errRerankerTimedOut           = errors.New("reranker timed out")
+	errInvalidInteractions        = errors.New("invalid interactions")
+	errTooManyInteractions        = errors.New("too many interactions")
+	errCouldNotCreateInteractions = errors.New("could not create interactions")
+	errCouldNotGetInteractions    = errors.New("could not get interactions")
+	errCouldNotDeleteInteractions = errors.New("could not delete interactions")
+	errInvalidOperatorToken       = errors.New("invalid operator token")
+	errMissingDID                 = errors.New("missing DID")
+	errInvalidSuspended           = errors.New("invalid suspended value")
+	errFeedSuspended              = errors.New("feed is suspended")
+	errUploadsBanned              = errors.New("DID is banned from uploading")
+	errBanNotFound                = errors.New("ban not found")
+	errCouldNotGetBans            = errors.New("could not get bans")
+	errCouldNotUpsertBan          = errors.New("could not upsert ban")
+	errCouldNotDeleteBan          = errors.New("could not delete ban")
+	errCouldNotSuspendFeed        = errors.New("could not suspend feed")
+	errCouldNotGetQuotas          = errors.New("could not get quotas")
+	errQuotaExceeded              = errors.New("quota exceeded")
+	errInvalidQuota               = errors.New("invalid quota")
+	errQuotaNotFound              = errors.New("quota not found")
+	errCouldNotUpsertQuota        = errors.New("could not upsert quota")
+	errCouldNotDeleteQuota        = errors.New("could not delete quota")
+	errCouldNotReadUpload         = errors.New("could not read upload")
+	errCouldNotLockQuota          = errors.New("could not lock quota")
+	errCouldNotUnlockQuota        = errors.New("could not unlock quota")
+	errRateLimited                = errors.New("rate limit exceeded")
+	errCouldNotRateLimit          = errors.New("could not check rate limit")
+	errCouldNotGetAuditEvents     = errors.New("could not get audit events")
+	errCouldNotCreateAuditEvent   = errors.New("could not create audit event")
+	errCouldNotPseudonymizeAudit  = errors.New("could not pseudonymize audit events")
 )
 
-type feedSkeleton struct {
-	Feed   []feedSkeletonPost `json:"feed"`
-	Cursor string             `json:"cursor"`
-}
-
-type feedSkeletonPost struct {
-	Post string `json:"post"`
-}
-
-type wellKnownDidDocument struct {
-	Context []string           `json:"@context"`
-	ID      string             `json:"id"`
-	Service []wellKnownService `json:"service"`
-}
-
-type wellKnownService struct {
-	ID              string `json:"id"`
-	Type            string `json:"type"`
-	ServiceEndpoint string `json:"serviceEndpoint"`
-}
-
-type structuredUserdataFeed struct {
-	Did        string `json:"did"`
-	Rkey       string `json:"rkey"`
-	PinnedDid  string `json:"pinnedDID"`
-	PinnedRkey string `json:"pinnedRkey"`
-}
-
-type structuredUserdataFeedPost struct {
-	FeedDid  string `json:"feedDID"`
-	FeedRkey string `json:"feedRkey"`
-	PostDid  string `json:"postDID"`
-	PostRkey string `json:"postRkey"`
-	Weight   int32  `json:"weight"`
-}
+// manager holds the state that the handlers of a manager share
+type manager struct {
+	ctx context.Context
 
-type structuredUserdataPost struct {
-	Did       string    `json:"did"`
-	Rkey      string    `json:"rkey"`
-	CreatedAt time.Time `json:"createdAt"`
-	Text      string    `json:"text"`
-	Reply     bool      `json:"reply"`
-	Langs     []string  `json:"langs"`
-	Likes     int32     `json:"likes"`
-}
+	persister  *persisters.ManagerPersister
+	broker     persisters.Broker
+	resolver   *resolvers.CachedResolver
+	verifier   *verifiers.ServiceAuthVerifier
+	httpClient *http.Client
 
-type structuredUserdata struct {
-	Feeds     []structuredUserdataFeed     `json:"feeds"`
-	Posts     []structuredUserdataPost     `json:"posts"`
-	FeedPosts []structuredUserdataFeedPost `json:"feedPosts"`
-}
+	skeletonCacheRequests *prometheus.CounterVec
 
-type feedMetatadata struct {
-	Rkey       string `json:"rkey"`
-	PinnedDid  string `json:"pinnedDID"`
-	PinnedRkey string `json:"pinnedRkey"`
+	// Compiled rerankers by feed, see getReranker
+	rerankerLock sync.Mutex
+	rerankers    map[string]*scale.Scale[*reranker.Signature]
 }
 
 var managerCmd = &cobra.Command{
@@ -135,654 +318,259 @@ var managerCmd = &cobra.Command{
 			return err
 		}
 
-		pu, err := url.Parse(viper.GetString(bgsURLFlag))
-		if err != nil {
-			return err
-		}
-		pu.Scheme = "wss"
-		pu = pu.JoinPath("xrpc", "com.atproto.sync.subscribeRepos")
-
-		conn, _, err := websocket.DefaultDialer.DialContext(cmd.Context(), pu.String(), nil)
+		broker, cache, err := persisters.NewBroker(viper.GetString(redisURLFlag))
 		if err != nil {
 			return err
 		}
-		defer conn.Close()
-
-		log.Println("Connected to BGS", viper.GetString(bgsURLFlag))
-
-		options, err := redis.ParseURL(viper.GetString(redisURLFlag))
-		if err != nil {
-			return err
-		}
-
-		broker := redis.NewClient(options)
 		defer broker.Close()
 
-		log.Println("Connected to Redis")
-
-		persister := persisters.NewManagerPersister(viper.GetString(postgresURLFlag), broker, viper.GetString(s3URLFlag))
-
-		if err := persister.Init(cmd.Context()); err != nil {
-			return err
-		}
-
-		log.Println("Connected to PostgreSQL and S3")
-
-		if viper.GetBool(deleteAllPostsFlag) {
-			if viper.GetBool(verboseFlag) {
-				log.Println("Deleting all posts")
-			}
-
-			if err := persister.DeleteAllPosts(cmd.Context()); err != nil {
-				return err
-			}
-		}
-
-		lis, err := net.Listen("tcp", viper.GetString(laddrFlag))
-		if err != nil {
-			return err
+		if _, ok := broker.(*persisters.MemoryBroker); ok {
+			return errInProcessBroker
 		}
-		defer lis.Close()
 
-		log.Println("Listening on", lis.Addr())
-
-		mux := http.NewServeMux()
-
-		mux.HandleFunc("/xrpc/app.bsky.feed.getFeedSkeleton", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			feedURL := r.URL.Query().Get("feed")
-			if strings.TrimSpace(feedURL) == "" {
-				http.Error(w, errMissingFeedURI.Error(), http.StatusUnprocessableEntity)
-
-				log.Println(errMissingFeedURI)
-
-				return
-			}
-
-			u, err := iutil.ParseAtUri(feedURL)
-			if err != nil {
-				http.Error(w, errInvalidFeedURI.Error(), http.StatusUnprocessableEntity)
-
-				log.Println(errInvalidFeedURI)
-
-				return
-			}
-
-			rawFeedLimit := r.URL.Query().Get("limit")
-			if strings.TrimSpace(rawFeedLimit) == "" {
-				rawFeedLimit = "1"
-			}
+		log.Println("Connected to broker")
 
-			feedLimit, err := strconv.Atoi(rawFeedLimit)
-			if err != nil {
-				http.Error(w, errInvalidLimit.Error(), http.StatusUnprocessableEntity)
-
-				log.Println(errInvalidLimit)
-
-				return
-			}
-
-			if feedLimit > viper.GetInt(limitFlag) {
-				http.Error(w, errLimitTooHigh.Error(), http.StatusUnprocessableEntity)
-
-				log.Println(errLimitTooHigh)
-
-				return
-			}
-
-			feedCursor := r.URL.Query().Get("cursor")
-
-			defer func() {
-				if err := recover(); err != nil {
-					w.WriteHeader(http.StatusInternalServerError)
-
-					log.Printf("Client disconnected with error: %v", err)
-				}
-			}()
-
-			rawFeedPosts := []models.GetFeedPostsRow{}
-			if strings.TrimSpace(feedCursor) == "" {
-				rawFeedPosts, err = persister.GetFeedPosts(
-					cmd.Context(),
-					u.Did,
-					u.Rkey,
-					time.Now().Add(-viper.GetDuration(ttlFlag)),
-					int32(feedLimit),
-				)
-				if err != nil {
-					panic(err)
-				}
-			} else {
-				cursor, err := iutil.ParseAtUri(feedCursor)
-				if err != nil {
-					http.Error(w, errInvalidFeedCursor.Error(), http.StatusUnprocessableEntity)
-
-					log.Println(errInvalidFeedCursor)
-
-					return
-				}
-
-				fp, err := persister.GetFeedPostsCursor(
-					cmd.Context(),
-					u.Did,
-					u.Rkey,
-					time.Now().Add(-viper.GetDuration(ttlFlag)),
-					int32(feedLimit),
-					cursor.Did,
-					cursor.Rkey,
-				)
-				if err != nil {
-					panic(err)
-				}
-
-				for _, p := range fp {
-					rawFeedPosts = append(rawFeedPosts, models.GetFeedPostsRow(p))
-				}
-			}
-
-			res := feedSkeleton{
-				Feed: []feedSkeletonPost{},
-			}
-			for _, rawFeedPost := range rawFeedPosts {
-				res.Feed = append(res.Feed, feedSkeletonPost{
-					Post: fmt.Sprintf("at://%s/%s/%s", rawFeedPost.Did, lexiconFeedPost, rawFeedPost.Rkey),
-				})
-			}
-
-			if len(res.Feed) > 0 {
-				res.Cursor = res.Feed[len(res.Feed)-1].Post
-			}
+		return runManager(cmd, broker, cache)
+	},
+}
 
-			w.Header().Set("Content-Type", "application/json")
+// runManager runs a manager that exchanges messages with the workers through a broker; without a cache, requests aren't rate limited
+// and sessions and feed skeletons aren't cached
+func runManager(cmd *cobra.Command, broker persisters.Broker, cache *redis.Client) error {
+	if cache == nil {
+		log.Println("Warning: The broker doesn't provide a cache, so requests aren't rate limited and admin sessions and feed skeletons aren't cached; use Redis as the broker in production")
+	}
 
-			if err := json.NewEncoder(w).Encode(res); err != nil {
-				panic(fmt.Errorf("%w: %v", errCouldNotEncode, err))
-			}
-		}))
+	pu, err := url.Parse(viper.GetString(bgsURLFlag))
+	if err != nil {
+		return err
+	}
+	pu.Scheme = "wss"
+	pu = pu.JoinPath("xrpc", "com.atproto.sync.subscribeRepos")
 
-		mux.HandleFunc("/.well-known/did.json", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			defer func() {
-				if err := recover(); err != nil {
-					w.WriteHeader(http.StatusInternalServerError)
+	conn, _, err := websocket.DefaultDialer.DialContext(cmd.Context(), pu.String(), nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
 
-					log.Printf("Client disconnected with error: %v", err)
-				}
-			}()
-
-			res := wellKnownDidDocument{
-				Context: []string{"https://www.w3.org/ns/did/v1"},
-				ID:      viper.GetString(feedGeneratorDIDFlag),
-				Service: []wellKnownService{
-					{
-						ID:              "#bsky_fg",
-						Type:            "BskyFeedGenerator",
-						ServiceEndpoint: viper.GetString(feedGeneratorURLFlag),
-					},
-				},
-			}
+	log.Println("Connected to BGS", viper.GetString(bgsURLFlag))
 
-			w.Header().Set("Content-Type", "application/json")
+	persister := persisters.NewManagerPersister(viper.GetString(postgresURLFlag), broker, cache, viper.GetString(s3URLFlag))
 
-			if err := json.NewEncoder(w).Encode(res); err != nil {
-				panic(fmt.Errorf("%w: %v", errCouldNotEncode, err))
-			}
-		}))
-
-		authorize := func(w http.ResponseWriter, r *http.Request) *atproto.ServerGetSession_Output {
-			if o := r.Header.Get("Origin"); o == viper.GetString(originFlag) {
-				w.Header().Set("Access-Control-Allow-Origin", o)
-				w.Header().Set("Access-Control-Allow-Methods", "GET, PUT, PATCH, DELETE")
-				w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
-				w.Header().Set("Access-Control-Allow-Credentials", "true")
-			}
+	if err := persister.Init(cmd.Context()); err != nil {
+		return err
+	}
 
-			if r.Method == http.MethodOptions {
-				return nil
-			}
+	log.Println("Connected to PostgreSQL and S3")
 
-			accessJWT := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
-			if strings.TrimSpace(accessJWT) == "" {
-				w.WriteHeader(http.StatusUnauthorized)
+	// DID documents and PDSes are chosen by users, so they are only reached through a client that can't be used to reach internal services
+	httpClient := resolvers.NewHTTPClient(viper.GetDuration(resolverTimeoutFlag), viper.GetBool(resolverAllowPrivateAddressesFlag))
 
-				return nil
-			}
+	resolver := resolvers.NewCachedResolver(
+		resolvers.NewHTTPResolver(httpClient, viper.GetString(plcURLFlag)),
+		viper.GetDuration(resolverCacheTTLFlag),
+		resolvers.DefaultMinRefreshInterval,
+	)
 
-			service := r.URL.Query().Get("service")
-			if strings.TrimSpace(service) == "" {
-				http.Error(w, errMissingService.Error(), http.StatusUnprocessableEntity)
+	verifier := verifiers.NewServiceAuthVerifier(resolver, viper.GetString(feedGeneratorDIDFlag))
 
-				log.Println(errMissingService)
+	skeletonCacheRequests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "atmosfeed",
+		Subsystem: "skeleton_cache",
+		Name:      "requests_total",
+		Help:      "Amount of feed skeleton cache lookups by result (hit, miss or error)",
+	}, []string{"result"})
 
-				return nil
-			}
+	prometheus.MustRegister(skeletonCacheRequests)
 
-			client := &xrpc.Client{
-				Client: http.DefaultClient,
-				Host:   service,
-				Auth: &xrpc.AuthInfo{
-					AccessJwt: accessJWT,
-				},
-			}
+	m := &manager{
+		ctx: cmd.Context(),
 
-			session, err := atproto.ServerGetSession(r.Context(), client)
-			if err != nil {
-				http.Error(w, errCouldNotGetSession.Error(), http.StatusUnprocessableEntity)
+		persister:  persister,
+		broker:     broker,
+		resolver:   resolver,
+		verifier:   verifier,
+		httpClient: httpClient,
 
-				log.Println(errCouldNotGetSession)
+		skeletonCacheRequests: skeletonCacheRequests,
 
-				return nil
-			}
+		rerankers: map[string]*scale.Scale[*reranker.Signature]{},
+	}
 
-			return session
+	// Rerankers are cached by every manager, so all of them need to drop their cached runtime if a reranker changes
+	for _, topic := range []string{persisters.TopicFeedRerankerUpsert, persisters.TopicFeedRerankerDelete, persisters.TopicFeedDelete} {
+		subscription, err := broker.Subscribe(cmd.Context(), topic)
+		if err != nil {
+			return err
 		}
 
-		mux.HandleFunc("/admin/feeds", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			session := authorize(w, r)
-			if session == nil {
-				return
-			}
-
-			defer func() {
-				if err := recover(); err != nil {
-					w.WriteHeader(http.StatusInternalServerError)
-
-					log.Printf("Client disconnected with error: %v", err)
-				}
-			}()
-
-			switch r.Method {
-			case http.MethodGet:
-				rawAdminFeeds, err := persister.GetFeedsForDid(r.Context(), session.Did)
-				if err != nil {
-					panic(fmt.Errorf("%w: %v", errCouldNotGetFeeds, err))
-				}
-
-				res := []feedMetatadata{}
-				for _, rawFeed := range rawAdminFeeds {
-					res = append(res, feedMetatadata{
-						Rkey:       rawFeed.Rkey,
-						PinnedDid:  rawFeed.PinnedDid,
-						PinnedRkey: rawFeed.PinnedRkey,
-					})
-				}
-
-				w.Header().Set("Content-Type", "application/json")
-
-				if err := json.NewEncoder(w).Encode(res); err != nil {
-					panic(fmt.Errorf("%w: %v", errCouldNotEncode, err))
-				}
-
-			// Update the classifier
-			case http.MethodPut:
-				rkey := r.URL.Query().Get("rkey")
-				if strings.TrimSpace(rkey) == "" {
-					http.Error(w, errMissingRkey.Error(), http.StatusUnprocessableEntity)
-
-					log.Println(errMissingRkey)
-
-					return
-				}
-
-				if err := persister.UpsertFeedClassifier(cmd.Context(), session.Did, rkey, r.Body); err != nil {
-					panic(fmt.Errorf("%w: %v", errCouldNotUpsertClassifier, err))
-				}
-
-			// Update the feed metadata
-			case http.MethodPatch:
-				rkey := r.URL.Query().Get("rkey")
-				if strings.TrimSpace(rkey) == "" {
-					http.Error(w, errMissingRkey.Error(), http.StatusUnprocessableEntity)
-
-					log.Println(errMissingRkey)
-
-					return
-				}
-
-				pinnedDID := r.URL.Query().Get("pinnedDID")
-				pinnedRkey := r.URL.Query().Get("pinnedRkey")
-
-				if err := persister.UpsertFeedMetadata(cmd.Context(), session.Did, rkey, pinnedDID, pinnedRkey); err != nil {
-					panic(fmt.Errorf("%w: %v", errCouldNotUpsertFeedMetadata, err))
-				}
-
-			case http.MethodDelete:
-				rkey := r.URL.Query().Get("rkey")
-				if strings.TrimSpace(rkey) == "" {
-					http.Error(w, errMissingRkey.Error(), http.StatusUnprocessableEntity)
-
-					log.Println(errMissingRkey)
-
-					return
-				}
-
-				if err := persister.DeleteFeed(cmd.Context(), session.Did, rkey); err != nil {
-					panic(fmt.Errorf("%w: %v", errCouldNotDeleteFeed, err))
-				}
-
-			default:
-				w.WriteHeader(http.StatusMethodNotAllowed)
-			}
-		}))
-
-		mux.HandleFunc("/userdata", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			session := authorize(w, r)
-			if session == nil {
-				return
-			}
-
-			defer func() {
-				if err := recover(); err != nil {
-					w.WriteHeader(http.StatusInternalServerError)
-
-					log.Printf("Client disconnected with error: %v", err)
-				}
-			}()
-
-			switch r.Method {
-			case http.MethodDelete:
-				feeds, err := persister.GetFeedsForDid(r.Context(), session.Did)
-				if err != nil {
-					panic(fmt.Errorf("%w: %v", errCouldNotGetFeeds, err))
-				}
-
-				for _, feed := range feeds {
-					if err := persister.DeleteFeed(r.Context(), session.Did, feed.Rkey); err != nil {
-						panic(fmt.Errorf("%w: %v", errCouldNotDeleteFeed, err))
-					}
-				}
-
-				if err := persister.DeletePostsForDid(r.Context(), session.Did); err != nil {
-					panic(fmt.Errorf("%w: %v", errCouldNotDeletePosts, err))
-				}
-
-				if err := persister.DeleteFeedPostsForDid(r.Context(), session.Did); err != nil {
-					panic(fmt.Errorf("%w: %v", errCouldNotDeleteFeedPosts, err))
-				}
-
-			default:
-				w.WriteHeader(http.StatusMethodNotAllowed)
-			}
-		}))
-
-		mux.HandleFunc("/userdata/structured", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			session := authorize(w, r)
-			if session == nil {
-				return
-			}
-
-			defer func() {
-				if err := recover(); err != nil {
-					w.WriteHeader(http.StatusInternalServerError)
-
-					log.Printf("Client disconnected with error: %v", err)
-				}
-			}()
-
-			switch r.Method {
-			case http.MethodGet:
-				rawFeeds, err := persister.GetFeedsForDid(r.Context(), session.Did)
-				if err != nil {
-					panic(fmt.Errorf("%w: %v", errCouldNotGetFeeds, err))
-				}
-
-				feeds := []structuredUserdataFeed{}
-				for _, feed := range rawFeeds {
-					feeds = append(feeds, structuredUserdataFeed{
-						feed.Did,
-						feed.Rkey,
-						feed.PinnedDid,
-						feed.PinnedRkey,
-					})
-				}
-
-				rawPosts, err := persister.GetPostsForDid(r.Context(), session.Did)
-				if err != nil {
-					panic(fmt.Errorf("%w: %v", errCouldNotGetPosts, err))
-				}
-
-				posts := []structuredUserdataPost{}
-				for _, post := range rawPosts {
-					posts = append(posts, structuredUserdataPost{
-						post.Did,
-						post.Rkey,
-						post.CreatedAt,
-						post.Text,
-						post.Reply,
-						post.Langs,
-						post.Likes,
-					})
-				}
-
-				rawFeedPosts, err := persister.GetFeedPostsForDid(r.Context(), session.Did)
-				if err != nil {
-					panic(fmt.Errorf("%w: %v", errCouldNotGetFeedPosts, err))
-				}
-
-				feedPosts := []structuredUserdataFeedPost{}
-				for _, feedPost := range rawFeedPosts {
-					feedPosts = append(feedPosts, structuredUserdataFeedPost{
-						feedPost.FeedDid,
-						feedPost.FeedRkey,
-						feedPost.PostDid,
-						feedPost.PostRkey,
-						feedPost.Weight,
-					})
-				}
-
-				w.Header().Set("Content-Type", "application/json")
-
-				if err := json.NewEncoder(w).Encode(structuredUserdata{
-					Feeds:     feeds,
-					Posts:     posts,
-					FeedPosts: feedPosts,
-				}); err != nil {
-					panic(fmt.Errorf("%w: %v", errCouldNotEncode, err))
-				}
-
-			default:
-				w.WriteHeader(http.StatusMethodNotAllowed)
-			}
-		}))
-
-		mux.HandleFunc("/userdata/blob", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			session := authorize(w, r)
-			if session == nil {
-				return
-			}
-
-			defer func() {
-				if err := recover(); err != nil {
-					w.WriteHeader(http.StatusInternalServerError)
-
-					log.Printf("Client disconnected with error: %v", err)
-				}
-			}()
-
-			resource := r.URL.Query().Get("resource")
-			if strings.TrimSpace(resource) == "" {
-				panic(fmt.Errorf("%w: %v", errMissingResource, err))
-			}
-
-			if resource != "classifier" {
-				panic(fmt.Errorf("%w: %v", errInvalidResource, err))
-			}
-
-			rkey := r.URL.Query().Get("rkey")
-			if strings.TrimSpace(rkey) == "" {
-				panic(fmt.Errorf("%w: %v", errMissingRkey, err))
-			}
-
-			switch r.Method {
-			case http.MethodGet:
-				classifier, err := persister.GetFeedClassifier(r.Context(), session.Did, rkey)
-				if err != nil {
-					panic(fmt.Errorf("%w: %v", errCouldNotGetFeedPosts, err))
-				}
+		go func() {
+			defer subscription.Close()
 
-				w.Header().Set("Content-Type", "application/octet-stream")
+			for payload := range subscription.Channel() {
+				m.removeReranker(payload)
 
-				if _, err := io.Copy(w, classifier); err != nil {
-					panic(fmt.Errorf("%w: %v", errCouldNotEncode, err))
+				if viper.GetBool(verboseFlag) {
+					log.Println("Invalidated reranker for feed", path.Dir(payload), path.Base(payload))
 				}
-
-			default:
-				w.WriteHeader(http.StatusMethodNotAllowed)
 			}
-		}))
-
-		handlers := events.RepoStreamCallbacks{
-			RepoCommit: func(c *atproto.SyncSubscribeRepos_Commit) error {
-				rp, err := repo.ReadRepoFromCar(cmd.Context(), bytes.NewReader(c.Blocks))
-				if err != nil {
-					log.Println("Could not parse repo, skipping:", err)
-
-					return nil
-				}
+		}()
+	}
 
-			l:
-				for _, op := range c.Ops {
-					switch repomgr.EventKind(op.Action) {
-					case repomgr.EvtKindCreateRecord:
-						_, res, err := rp.GetRecord(cmd.Context(), op.Path)
-						if err != nil {
-							log.Println("Could not parse record, skipping:", err)
-
-							continue l
-						}
-
-						d := lutil.LexiconTypeDecoder{
-							Val: res,
-						}
-
-						b, err := d.MarshalJSON()
-						if err != nil {
-							log.Println("Could not marshal lexicon, skipping:", err)
-
-							continue l
-						}
-
-						var post bsky.FeedPost
-						if err := json.Unmarshal(b, &post); err != nil {
-							log.Println("Could not unmarshal post, skipping:", err)
-
-							continue l
-						}
-
-						if post.LexiconTypeID == lexiconFeedPost {
-							if _, err := broker.XAdd(cmd.Context(), &redis.XAddArgs{
-								Stream: persisters.StreamPostInsert,
-								Values: map[string]interface{}{
-									"did":       rp.RepoDid(),
-									"rkey":      path.Base(op.Path),
-									"createdAt": post.CreatedAt,
-									"text":      post.Text,
-									"reply":     post.Reply != nil,
-									"langs":     strings.Join(post.Langs, ","),
-								},
-							}).Result(); err != nil {
-								log.Println("Could not publish post, skipping:", err)
-
-								continue l
-							}
-
-							if viper.GetBool(verboseFlag) {
-								log.Println("Published post", post)
-							}
-						} else if post.LexiconTypeID == "app.bsky.feed.like" {
-							var like bsky.FeedLike
-							if err := json.Unmarshal(b, &like); err != nil {
-								log.Println("Could not unmarshal like, skipping:", err)
-
-								continue l
-							}
-
-							u, err := iutil.ParseAtUri(like.Subject.Uri)
-							if err != nil {
-								log.Println("Could not parse like subject URI, skipping:", err)
-
-								continue l
-							}
-
-							if _, err := broker.XAdd(cmd.Context(), &redis.XAddArgs{
-								Stream: persisters.StreamPostLike,
-								Values: map[string]interface{}{
-									"did":  u.Did,
-									"rkey": u.Rkey,
-								},
-							}).Result(); err != nil {
-								log.Println("Could not publish like, skipping:", err)
-
-								continue l
-							}
-
-							if viper.GetBool(verboseFlag) {
-								log.Println("Published like", post)
-							}
-						}
-
-					case repomgr.EvtKindDeleteRecord:
-						if lexiconTypeID := path.Dir(op.Path); lexiconTypeID == lexiconFeedPost {
-							did, rkey := rp.SignedCommit().Did, path.Base(op.Path)
-							if err := persister.DeletePost(cmd.Context(), did, rkey); err != nil {
-								log.Println("Could not delete post, skipping:", err)
-
-								continue l
-							}
-
-							if viper.GetBool(verboseFlag) {
-								log.Println("Deleted post", did, rkey)
-							}
-						}
-					}
-				}
+	if viper.GetBool(deleteAllPostsFlag) {
+		if viper.GetBool(verboseFlag) {
+			log.Println("Deleting all posts")
+		}
 
-				return nil
-			},
+		if err := persister.DeleteAllPosts(cmd.Context()); err != nil {
+			return err
 		}
+	}
+
+	lis, err := net.Listen("tcp", viper.GetString(laddrFlag))
+	if err != nil {
+		return err
+	}
+	defer lis.Close()
+
+	log.Println("Listening on", lis.Addr())
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/xrpc/app.bsky.feed.getFeedSkeleton", m.handleGetFeedSkeleton)
+	mux.HandleFunc("/xrpc/app.bsky.feed.sendInteractions", m.handleSendInteractions)
+	mux.HandleFunc("/xrpc/app.bsky.feed.describeFeedGenerator", m.handleDescribeFeedGenerator)
+	mux.HandleFunc("/.well-known/did.json", m.handleWellKnownDID)
+	mux.HandleFunc("/admin/session", m.handleAdminSession)
+	mux.HandleFunc("/admin/tokens", m.handleAdminTokens)
+	mux.HandleFunc("/admin/quota", m.handleAdminQuota)
+	mux.HandleFunc("/admin/feeds", m.handleAdminFeeds)
+	mux.HandleFunc("/admin/feeds/pins", m.handleAdminFeedPins)
+	mux.HandleFunc("/admin/feeds/reranker", m.handleAdminFeedReranker)
+	mux.HandleFunc("/admin/feeds/audit", m.handleAdminFeedAudit)
+	mux.HandleFunc("/admin/feeds/collaborators", m.handleAdminFeedCollaborators)
+	mux.HandleFunc("/userdata", m.handleUserdata)
+	mux.HandleFunc("/userdata/structured", m.handleStructuredUserdata)
+	mux.HandleFunc("/userdata/blob", m.handleUserdataBlob)
+	mux.HandleFunc("/operator/feeds", m.handleOperatorFeeds)
+	mux.HandleFunc("/operator/bans", m.handleOperatorBans)
+	mux.HandleFunc("/operator/quotas", m.handleOperatorQuotas)
+	mux.HandleFunc("/operator/audit", m.handleOperatorAudit)
+
+	errs := make(chan error)
+
+	if metricsLaddr := viper.GetString(metricsLaddrFlag); strings.TrimSpace(metricsLaddr) != "" {
+		metricsLis, err := net.Listen("tcp", metricsLaddr)
+		if err != nil {
+			return err
+		}
+		defer metricsLis.Close()
 
-		errs := make(chan error)
+		log.Println("Serving metrics on", metricsLis.Addr())
+
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", promhttp.Handler())
 
 		go func() {
-			if err := events.HandleRepoStream(
-				cmd.Context(),
-				conn,
-				sequential.NewScheduler(
-					conn.RemoteAddr().String(),
-					handlers.EventHandler,
-				),
-			); err != nil {
+			if err := http.Serve(metricsLis, metricsMux); err != nil {
 				errs <- err
 
 				return
 			}
 		}()
+	}
+
+	if viper.GetDuration(retentionIntervalFlag) > 0 {
+		go m.runRetention()
+	}
+
+	go func() {
+		if err := events.HandleRepoStream(
+			cmd.Context(),
+			conn,
+			sequential.NewScheduler(
+				conn.RemoteAddr().String(),
+				m.getRepoStreamCallbacks().EventHandler,
+			),
+		); err != nil {
+			errs <- err
+
+			return
+		}
+	}()
 
-		go func() {
-			if err := http.Serve(lis, mux); err != nil {
-				errs <- err
-
+	go func() {
+		// Client IPs are rate limited before any other work is done for a request
+		if err := http.Serve(lis, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if class := getRateLimitClass(r); class != "" && !m.rateLimit(w, r, class, path.Join("ip", getClientIP(r, viper.GetBool(trustForwardedForFlag)))) {
 				return
 			}
-		}()
 
-		for err := range errs {
-			if err != nil {
-				return err
-			}
+			mux.ServeHTTP(w, r)
+		})); err != nil {
+			errs <- err
+
+			return
 		}
+	}()
 
-		return nil
-	},
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addManagerFlags adds the flags of a manager to a command
+func addManagerFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().String(bgsURLFlag, "https://bsky.network", "BGS URL")
+	cmd.PersistentFlags().String(laddrFlag, ":1337", "Listen address")
+	cmd.PersistentFlags().Duration(ttlFlag, time.Hour*6, "Maximum age of posts to return for a feed (feeds can configure a lower TTL)")
+	cmd.PersistentFlags().Int(limitFlag, 100, "Maximum amount of posts to return for a feed (feeds can configure a lower maximum page size)")
+	cmd.PersistentFlags().Int(defaultLimitFlag, 1, "Amount of posts to return for a feed if the client doesn't specify a limit (feeds can configure a different default page size)")
+	cmd.PersistentFlags().String(feedGeneratorDIDFlag, "did:web:manager.atmosfeed.p8.lu", "DID of the feed generator (typically the hostname of the publicly reachable URL)")
+	cmd.PersistentFlags().String(feedGeneratorURLFlag, "https://manager.atmosfeed.p8.lu", "Publicly reachable URL of the feed generator")
+	cmd.PersistentFlags().String(plcURLFlag, resolvers.DefaultPLCURL, "PLC directory URL to resolve did:plc DIDs with")
+	cmd.PersistentFlags().Duration(resolverCacheTTLFlag, time.Minute*5, "Amount of time to cache resolved DID documents for")
+	cmd.PersistentFlags().Duration(resolverTimeoutFlag, time.Second*10, "Timeout for requests to resolve DIDs and to verify sessions with PDSes")
+	cmd.PersistentFlags().Bool(resolverAllowPrivateAddressesFlag, false, "Whether to allow resolving DIDs and verifying sessions with servers on loopback, link-local and private addresses (only enable this for local development)")
+	cmd.PersistentFlags().Duration(rerankerTimeoutFlag, time.Millisecond*100, "Amount of time after which to stop a reranker Scale function from running and return the feed in its default order")
+	cmd.PersistentFlags().Uint64(rerankerMaxMemoryFlag, 64*1024*1024, "Maximum amount of memory in bytes that a reranker Scale function can use (0 disables the limit)")
+	cmd.PersistentFlags().Uint64(rerankerMaxFuelFlag, 100*1000*1000, "Maximum amount of fuel that a reranker Scale function can use per page, where one unit of fuel is consumed per function call and loop iteration (0 disables the limit)")
+	cmd.PersistentFlags().Duration(skeletonCacheTTLFlag, time.Second*5, "Amount of time to cache pages of feeds for; pages are also invalidated when new posts are added to or pins change for a feed (0 disables the cache)")
+	cmd.PersistentFlags().Duration(sessionCacheTTLFlag, time.Minute*5, "Maximum amount of time to cache verified admin sessions for; sessions are never cached for longer than their access JWT is valid (0 disables the cache)")
+	cmd.PersistentFlags().Int64(maxFeedsFlag, 25, "Default maximum amount of feeds per DID, which the operator can override per DID (0 disables the limit)")
+	cmd.PersistentFlags().Int64(maxClassifierSizeFlag, 32*1024*1024, "Default maximum size in bytes of a classifier or reranker, which the operator can override per DID (0 disables the limit)")
+	cmd.PersistentFlags().Int64(maxStorageFlag, 128*1024*1024, "Default maximum total size in bytes of the classifiers and rerankers of a DID, which the operator can override per DID (0 disables the limit)")
+	// AppViews send the feed requests of all of their viewers from a few IPs, so a per-IP limit that is low enough to stop abuse would reject their legitimate
+	// requests; requests with service auth are limited per viewer DID instead, while the per-IP limit only applies to anonymous requests and invalid service auth
+	cmd.PersistentFlags().Int64(publicRateLimitFlag, 600, "Maximum amount of anonymous requests or requests with invalid service auth per minute to the public feed generator endpoints per client IP (0 disables the limit)")
+	cmd.PersistentFlags().Int64(publicRateLimitBurstFlag, 60, "Maximum amount of anonymous requests or requests with invalid service auth to the public feed generator endpoints that a client IP can send at once")
+	cmd.PersistentFlags().Int64(viewerRateLimitFlag, 120, "Maximum amount of requests per minute to the public feed generator endpoints per viewer DID for requests that the AppView sent on behalf of a viewer (0 disables the limit)")
+	cmd.PersistentFlags().Int64(viewerRateLimitBurstFlag, 30, "Maximum amount of requests to the public feed generator endpoints that the AppView can send on behalf of a viewer DID at once")
+	cmd.PersistentFlags().Int64(adminRateLimitFlag, 120, "Maximum amount of requests per minute to the admin and user data endpoints per client IP and per DID (0 disables the limit)")
+	cmd.PersistentFlags().Int64(adminRateLimitBurstFlag, 30, "Maximum amount of requests to the admin and user data endpoints that a client IP or DID can send at once")
+	cmd.PersistentFlags().Int64(uploadRateLimitFlag, 10, "Maximum amount of classifier and reranker uploads per minute per client IP and per DID (0 disables the limit)")
+	cmd.PersistentFlags().Int64(uploadRateLimitBurstFlag, 5, "Maximum amount of classifier and reranker uploads that a client IP or DID can send at once")
+	cmd.PersistentFlags().Int64(operatorAuthRateLimitFlag, 5, "Maximum amount of failed operator API authentication attempts per minute per client IP (0 disables the limit)")
+	cmd.PersistentFlags().Int64(operatorAuthRateLimitBurstFlag, 5, "Maximum amount of failed operator API authentication attempts that a client IP can make at once")
+	cmd.PersistentFlags().Bool(trustForwardedForFlag, false, "Whether to rate limit clients by the last address in the X-Forwarded-For header instead of the connection's address (only enable this behind a trusted reverse proxy)")
+	cmd.PersistentFlags().String(operatorTokenFlag, "", "Secret token that authenticates the operator of the server against the operator API (if left empty, the operator API is disabled)")
+	cmd.PersistentFlags().String(metricsLaddrFlag, "localhost:1338", "Listen address for the Prometheus metrics endpoint (if left empty, metrics are not served)")
+	cmd.PersistentFlags().String(privacyPolicyURLFlag, "", "URL of the feed generator's privacy policy (if left empty, no privacy policy is linked)")
+	cmd.PersistentFlags().String(termsOfServiceURLFlag, "", "URL of the feed generator's terms of service (if left empty, no terms of service are linked)")
+	cmd.PersistentFlags().String(originFlag, "https://atmosfeed.p8.lu", "Allowed CORS origin")
+	cmd.PersistentFlags().Bool(deleteAllPostsFlag, true, "Whether to delete all posts from the index on startup (if disabled, posts that were deleted while the manager was offline are only removed from the index once they are older than --retention, as required for compliance with the EU right to be forgotten/GDPR article 17; deletions during uptime are handled using delete commits)")
+	cmd.PersistentFlags().Duration(retentionFlag, time.Hour*6, "Maximum age of posts to keep in the index (0 disables deleting expired posts)")
+	cmd.PersistentFlags().Duration(retentionIntervalFlag, time.Minute, "Interval in which to delete expired posts and feed posts on one of the managers (0 disables the retention job)")
+	cmd.PersistentFlags().Int(retentionBatchSizeFlag, 1000, "Maximum amount of rows to delete in a single query of the retention job")
+	cmd.PersistentFlags().Duration(blockRetentionFlag, time.Hour*24*7, "Amount of time after which the blocks of a viewer are removed from the index if they haven't requested a feed that hides blocked posts (0 disables removing blocks)")
 }
 
 func init() {
-	managerCmd.PersistentFlags().String(bgsURLFlag, "https://bsky.network", "BGS URL")
-	managerCmd.PersistentFlags().String(laddrFlag, ":1337", "Listen address")
-	managerCmd.PersistentFlags().Duration(ttlFlag, time.Hour*6, "Maximum age of posts to return for a feed")
-	managerCmd.PersistentFlags().Int(limitFlag, 100, "Maximum amount of posts to return for a feed")
-	managerCmd.PersistentFlags().String(feedGeneratorDIDFlag, "did:web:manager.atmosfeed.p8.lu", "DID of the feed generator (typically the hostname of the publicly reachable URL)")
-	managerCmd.PersistentFlags().String(feedGeneratorURLFlag, "https://manager.atmosfeed.p8.lu", "Publicly reachable URL of the feed generator")
-	managerCmd.PersistentFlags().String(originFlag, "https://atmosfeed.p8.lu", "Allowed CORS origin")
-	managerCmd.PersistentFlags().Bool(deleteAllPostsFlag, true, "Whether to delete all posts from the index on startup (required for compliance with the EU right to be forgotten/GDPR article 17; deletions during uptime are handled using delete commits)")
+	addManagerFlags(managerCmd)
 
 	viper.AutomaticEnv()
 
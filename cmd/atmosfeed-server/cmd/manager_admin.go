@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// handleAdminSession signs out of the admin API
+func (m *manager) handleAdminSession(w http.ResponseWriter, r *http.Request) {
+	session := m.authorize(w, r)
+	if session == nil {
+		return
+	}
+
+	if !m.permit(w, r, session, scopeAccount, session.Did, "") {
+		return
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			log.Printf("Client disconnected with error: %v", err)
+		}
+	}()
+
+	switch r.Method {
+	case http.MethodDelete:
+		// Logging out only invalidates the session that is used for this request
+		if err := m.persister.InvalidateSession(r.Context(), getTokenHash(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))); err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotInvalidateSession, err))
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAdminTokens lists, creates and deletes the API tokens of a session
+func (m *manager) handleAdminTokens(w http.ResponseWriter, r *http.Request) {
+	session := m.authorize(w, r)
+	if session == nil {
+		return
+	}
+
+	// API tokens can't be used to create or revoke other API tokens
+	if !m.permit(w, r, session, scopeAccount, session.Did, "") {
+		return
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			log.Printf("Client disconnected with error: %v", err)
+		}
+	}()
+
+	switch r.Method {
+	case http.MethodGet:
+		rawTokens, err := m.persister.GetApiTokensForDid(r.Context(), session.Did)
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotGetApiTokens, err))
+		}
+
+		tokens := []apiToken{}
+		for _, rawToken := range rawTokens {
+			tokens = append(tokens, newApiToken(rawToken))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(tokens); err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotEncode, err))
+		}
+
+	case http.MethodPut:
+		// An empty list of feeds grants access to all of the DID's feeds, including feeds that are created later
+		feeds := []string{}
+		if rawFeeds := r.URL.Query().Get("feeds"); strings.TrimSpace(rawFeeds) != "" {
+			feeds = strings.Split(rawFeeds, ",")
+		}
+
+		scopes := strings.Split(r.URL.Query().Get("scopes"), ",")
+		for _, scope := range scopes {
+			if !slices.Contains(knownScopes, scope) {
+				http.Error(w, errInvalidScopes.Error(), http.StatusUnprocessableEntity)
+
+				log.Println(errInvalidScopes)
+
+				return
+			}
+		}
+
+		rawID := make([]byte, 8)
+		if _, err := rand.Read(rawID); err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotCreateApiToken, err))
+		}
+
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotCreateApiToken, err))
+		}
+
+		token := apiTokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+		rawToken, err := m.persister.CreateApiToken(
+			r.Context(),
+			hex.EncodeToString(rawID),
+			session.Did,
+			getTokenHash(token),
+			r.URL.Query().Get("description"),
+			feeds,
+			scopes,
+		)
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotCreateApiToken, err))
+		}
+
+		// Only the token's hash is stored, so this is the only time that the token itself is returned
+		res := newApiToken(rawToken)
+		res.Token = token
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotEncode, err))
+		}
+
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if strings.TrimSpace(id) == "" {
+			http.Error(w, errMissingApiTokenID.Error(), http.StatusUnprocessableEntity)
+
+			log.Println(errMissingApiTokenID)
+
+			return
+		}
+
+		found, err := m.persister.DeleteApiToken(r.Context(), session.Did, id)
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotDeleteApiToken, err))
+		}
+
+		if !found {
+			http.Error(w, errApiTokenNotFound.Error(), http.StatusNotFound)
+
+			log.Println(errApiTokenNotFound)
+
+			return
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAdminQuota returns the quota of a session's DID
+func (m *manager) handleAdminQuota(w http.ResponseWriter, r *http.Request) {
+	session := m.authorize(w, r)
+	if session == nil {
+		return
+	}
+
+	if !m.permit(w, r, session, scopeAccount, session.Did, "") {
+		return
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			log.Printf("Client disconnected with error: %v", err)
+		}
+	}()
+
+	switch r.Method {
+	case http.MethodGet:
+		res, err := m.getQuota(r.Context(), session.Did)
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotGetQuotas, err))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotEncode, err))
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,920 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pojntfx/atmosfeed/pkg/blobs"
+	"github.com/pojntfx/atmosfeed/pkg/models"
+	"github.com/pojntfx/atmosfeed/pkg/persisters"
+)
+
+type feedPin struct {
+	PostDid  string     `json:"postDID"`
+	PostRkey string     `json:"postRkey"`
+	Position int32      `json:"position"`
+	StartsAt *time.Time `json:"startsAt,omitempty"`
+	EndsAt   *time.Time `json:"endsAt,omitempty"`
+}
+
+type feedCollaborator struct {
+	Did  string `json:"did"`
+	Role string `json:"role"`
+}
+
+type feedMetatadata struct {
+	Did                       string    `json:"did"`
+	Rkey                      string    `json:"rkey"`
+	Role                      string    `json:"role"`
+	Pins                      []feedPin `json:"pins"`
+	ClassifierErrors          int32     `json:"classifierErrors"`
+	ClassifierTimeouts        int32     `json:"classifierTimeouts"`
+	Quarantined               bool      `json:"quarantined"`
+	ClassifierLimitViolations int32     `json:"classifierLimitViolations"`
+	Retention                 int32     `json:"retention"`
+	Ranking                   string    `json:"ranking"`
+	HalfLife                  int32     `json:"halfLife"`
+	TTL                       int32     `json:"ttl"`
+	MaxPageSize               int32     `json:"maxPageSize"`
+	DefaultPageSize           int32     `json:"defaultPageSize"`
+	HideViewerPosts           bool      `json:"hideViewerPosts"`
+	HideBlockedPosts          bool      `json:"hideBlockedPosts"`
+	Reranker                  bool      `json:"reranker"`
+	Suspended                 bool      `json:"suspended"`
+}
+
+func newFeedMetadata(rawFeed models.Feed, role string, pins []feedPin) feedMetatadata {
+	return feedMetatadata{
+		Did:                       rawFeed.Did,
+		Rkey:                      rawFeed.Rkey,
+		Role:                      role,
+		Pins:                      pins,
+		ClassifierErrors:          rawFeed.ClassifierErrors,
+		ClassifierTimeouts:        rawFeed.ClassifierTimeouts,
+		Quarantined:               rawFeed.Quarantined,
+		ClassifierLimitViolations: rawFeed.ClassifierLimitViolations,
+		Retention:                 rawFeed.Retention,
+		Ranking:                   rawFeed.Ranking,
+		HalfLife:                  rawFeed.HalfLife,
+		TTL:                       rawFeed.Ttl,
+		MaxPageSize:               rawFeed.MaxPageSize,
+		DefaultPageSize:           rawFeed.DefaultPageSize,
+		HideViewerPosts:           rawFeed.HideViewerPosts,
+		HideBlockedPosts:          rawFeed.HideBlockedPosts,
+		Reranker:                  rawFeed.Reranker,
+		Suspended:                 rawFeed.Suspended,
+	}
+}
+
+func newFeedPin(rawPin models.FeedPin) feedPin {
+	pin := feedPin{
+		PostDid:  rawPin.PostDid,
+		PostRkey: rawPin.PostRkey,
+		Position: rawPin.Position,
+	}
+
+	if rawPin.StartsAt.Valid {
+		pin.StartsAt = &rawPin.StartsAt.Time
+	}
+
+	if rawPin.EndsAt.Valid {
+		pin.EndsAt = &rawPin.EndsAt.Time
+	}
+
+	return pin
+}
+
+// getBlobHash returns the hash of a classifier or reranker, which allows telling which version of it was uploaded
+func getBlobHash(blob []byte) string {
+	hash := sha256.Sum256(blob)
+
+	return hex.EncodeToString(hash[:])
+}
+
+// handleAdminFeeds lists, uploads, updates and deletes the feeds of a session
+func (m *manager) handleAdminFeeds(w http.ResponseWriter, r *http.Request) {
+	session := m.authorize(w, r)
+	if session == nil {
+		return
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			log.Printf("Client disconnected with error: %v", err)
+		}
+	}()
+
+	switch r.Method {
+	case http.MethodGet:
+		rawAdminFeeds, err := m.persister.GetFeedsForDid(r.Context(), session.Did)
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotGetFeeds, err))
+		}
+
+		rawFeedPins, err := m.persister.GetFeedPinsForDid(r.Context(), session.Did)
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotGetFeedPins, err))
+		}
+
+		pins := map[string][]feedPin{}
+		for _, rawFeedPin := range rawFeedPins {
+			pins[rawFeedPin.FeedRkey] = append(pins[rawFeedPin.FeedRkey], newFeedPin(rawFeedPin))
+		}
+
+		res := []feedMetatadata{}
+		for _, rawFeed := range rawAdminFeeds {
+			// API tokens only list the feeds that they have access to
+			if !session.allows(scopeRead, rawFeed.Did, rawFeed.Rkey) {
+				continue
+			}
+
+			feedPins, ok := pins[rawFeed.Rkey]
+			if !ok {
+				feedPins = []feedPin{}
+			}
+
+			res = append(res, newFeedMetadata(rawFeed, roleOwner, feedPins))
+		}
+
+		// Feeds that are shared with the session's DID are listed with the role that it has on them
+		rawSharedFeeds, err := m.persister.GetSharedFeedsForDid(r.Context(), session.Did)
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotGetFeeds, err))
+		}
+
+		for _, rawSharedFeed := range rawSharedFeeds {
+			if !session.allows(scopeRead, rawSharedFeed.Did, rawSharedFeed.Rkey) {
+				continue
+			}
+
+			rawFeedPins, err := m.persister.GetFeedPins(r.Context(), rawSharedFeed.Did, rawSharedFeed.Rkey)
+			if err != nil {
+				panic(fmt.Errorf("%w: %v", errCouldNotGetFeedPins, err))
+			}
+
+			feedPins := []feedPin{}
+			for _, rawFeedPin := range rawFeedPins {
+				feedPins = append(feedPins, newFeedPin(rawFeedPin))
+			}
+
+			res = append(res, newFeedMetadata(models.Feed{
+				Did:                       rawSharedFeed.Did,
+				Rkey:                      rawSharedFeed.Rkey,
+				ClassifierErrors:          rawSharedFeed.ClassifierErrors,
+				ClassifierTimeouts:        rawSharedFeed.ClassifierTimeouts,
+				Quarantined:               rawSharedFeed.Quarantined,
+				ClassifierLimitViolations: rawSharedFeed.ClassifierLimitViolations,
+				Retention:                 rawSharedFeed.Retention,
+				Ranking:                   rawSharedFeed.Ranking,
+				HalfLife:                  rawSharedFeed.HalfLife,
+				Ttl:                       rawSharedFeed.Ttl,
+				MaxPageSize:               rawSharedFeed.MaxPageSize,
+				DefaultPageSize:           rawSharedFeed.DefaultPageSize,
+				HideViewerPosts:           rawSharedFeed.HideViewerPosts,
+				HideBlockedPosts:          rawSharedFeed.HideBlockedPosts,
+				Reranker:                  rawSharedFeed.Reranker,
+				Suspended:                 rawSharedFeed.Suspended,
+			}, rawSharedFeed.Role, feedPins))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotEncode, err))
+		}
+
+	// Update the classifier
+	case http.MethodPut:
+		rkey := r.URL.Query().Get("rkey")
+		if strings.TrimSpace(rkey) == "" {
+			http.Error(w, errMissingRkey.Error(), http.StatusUnprocessableEntity)
+
+			log.Println(errMissingRkey)
+
+			return
+		}
+
+		// Feeds are created by uploading their classifier, so this is the only place where new rkeys need to be validated
+		if err := blobs.ValidateRecordKey(rkey); err != nil {
+			http.Error(w, errInvalidRkey.Error(), http.StatusUnprocessableEntity)
+
+			log.Println(errInvalidRkey)
+
+			return
+		}
+
+		feedDid := session.feedDid(r)
+
+		if !m.permit(w, r, session, scopeClassifier, feedDid, rkey) {
+			return
+		}
+
+		if !m.permitUpload(w, r, session, feedDid) {
+			return
+		}
+
+		if !m.rateLimit(w, r, rateLimitClassUpload, path.Join("did", session.Did)) {
+			return
+		}
+
+		// The upload is read before taking the lock, so that slow clients don't block the other uploads of the DID
+		classifier, ok := m.readUpload(w, r, feedDid)
+		if !ok {
+			return
+		}
+
+		unlock, ok := m.lockQuota(w, r, feedDid)
+		if !ok {
+			return
+		}
+		defer unlock()
+
+		newFeed := false
+		feed, err := m.persister.GetFeed(r.Context(), feedDid, rkey)
+		if err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				panic(fmt.Errorf("%w: %v", errCouldNotGetFeeds, err))
+			}
+
+			newFeed = true
+		}
+
+		if !m.reserveUpload(w, r, feedDid, newFeed, feed.ClassifierSize, int64(len(classifier))) {
+			return
+		}
+
+		if err := m.persister.UpsertFeedClassifier(m.ctx, feedDid, rkey, bytes.NewReader(classifier), int64(len(classifier))); err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotUpsertClassifier, err))
+		}
+
+		m.recordAuditEvent(r, session.Did, auditActionClassifierUpsert, feedDid, rkey, getBlobHash(classifier))
+
+	// Update the feed metadata
+	case http.MethodPatch:
+		rkey := r.URL.Query().Get("rkey")
+		if strings.TrimSpace(rkey) == "" {
+			http.Error(w, errMissingRkey.Error(), http.StatusUnprocessableEntity)
+
+			log.Println(errMissingRkey)
+
+			return
+		}
+
+		feedDid := session.feedDid(r)
+
+		if !m.permit(w, r, session, scopeMetadata, feedDid, rkey) {
+			return
+		}
+
+		// All parameters are validated before any of them is applied, so that invalid requests don't change the feed
+		update := persisters.FeedMetadataUpdate{}
+
+		// Setting a single pinned post replaces all pins, and setting an empty one clears them
+		if r.URL.Query().Has("pinnedDID") || r.URL.Query().Has("pinnedRkey") {
+			update.ReplacePins = true
+
+			pinnedDID := r.URL.Query().Get("pinnedDID")
+			pinnedRkey := r.URL.Query().Get("pinnedRkey")
+
+			if strings.TrimSpace(pinnedDID) != "" && strings.TrimSpace(pinnedRkey) != "" {
+				update.PinnedDid = pinnedDID
+				update.PinnedRkey = pinnedRkey
+			}
+		}
+
+		// A retention of zero falls back to the manager's retention
+		if r.URL.Query().Has("retention") {
+			retention, err := time.ParseDuration(r.URL.Query().Get("retention"))
+			if err != nil || retention < 0 || retention.Seconds() > math.MaxInt32 {
+				http.Error(w, errInvalidRetention.Error(), http.StatusUnprocessableEntity)
+
+				log.Println(errInvalidRetention)
+
+				return
+			}
+
+			update.Retention = sql.NullInt32{Int32: int32(retention.Seconds()), Valid: true}
+		}
+
+		if r.URL.Query().Has("ranking") {
+			ranking := r.URL.Query().Get("ranking")
+
+			halfLife := time.Duration(0)
+			switch ranking {
+			case persisters.RankingWeight, persisters.RankingNewest:
+				break
+
+			case persisters.RankingDecayed:
+				parsedHalfLife, err := time.ParseDuration(r.URL.Query().Get("halfLife"))
+				if err != nil || parsedHalfLife < time.Second || parsedHalfLife.Seconds() > math.MaxInt32 {
+					http.Error(w, errInvalidHalfLife.Error(), http.StatusUnprocessableEntity)
+
+					log.Println(errInvalidHalfLife)
+
+					return
+				}
+
+				halfLife = parsedHalfLife
+
+			default:
+				http.Error(w, errInvalidRanking.Error(), http.StatusUnprocessableEntity)
+
+				log.Println(errInvalidRanking)
+
+				return
+			}
+
+			update.Ranking = sql.NullString{String: ranking, Valid: true}
+			update.HalfLife = int32(halfLife.Seconds())
+		}
+
+		// Settings that are zero fall back to the global defaults, and settings that are missing aren't changed
+		if r.URL.Query().Has("ttl") {
+			parsedTTL, err := time.ParseDuration(r.URL.Query().Get("ttl"))
+			if err != nil || parsedTTL < 0 || parsedTTL.Seconds() > math.MaxInt32 {
+				http.Error(w, errInvalidTTL.Error(), http.StatusUnprocessableEntity)
+
+				log.Println(errInvalidTTL)
+
+				return
+			}
+
+			update.Ttl = sql.NullInt32{Int32: int32(parsedTTL.Seconds()), Valid: true}
+		}
+
+		if r.URL.Query().Has("maxPageSize") {
+			parsedMaxPageSize, err := strconv.ParseInt(r.URL.Query().Get("maxPageSize"), 10, 32)
+			if err != nil || parsedMaxPageSize < 0 {
+				http.Error(w, errInvalidPageSize.Error(), http.StatusUnprocessableEntity)
+
+				log.Println(errInvalidPageSize)
+
+				return
+			}
+
+			update.MaxPageSize = sql.NullInt32{Int32: int32(parsedMaxPageSize), Valid: true}
+		}
+
+		if r.URL.Query().Has("defaultPageSize") {
+			parsedDefaultPageSize, err := strconv.ParseInt(r.URL.Query().Get("defaultPageSize"), 10, 32)
+			if err != nil || parsedDefaultPageSize < 0 {
+				http.Error(w, errInvalidPageSize.Error(), http.StatusUnprocessableEntity)
+
+				log.Println(errInvalidPageSize)
+
+				return
+			}
+
+			update.DefaultPageSize = sql.NullInt32{Int32: int32(parsedDefaultPageSize), Valid: true}
+		}
+
+		if r.URL.Query().Has("hideViewerPosts") {
+			parsedHideViewerPosts, err := strconv.ParseBool(r.URL.Query().Get("hideViewerPosts"))
+			if err != nil {
+				http.Error(w, errInvalidViewerFilter.Error(), http.StatusUnprocessableEntity)
+
+				log.Println(errInvalidViewerFilter)
+
+				return
+			}
+
+			update.HideViewerPosts = sql.NullBool{Bool: parsedHideViewerPosts, Valid: true}
+		}
+
+		if r.URL.Query().Has("hideBlockedPosts") {
+			parsedHideBlockedPosts, err := strconv.ParseBool(r.URL.Query().Get("hideBlockedPosts"))
+			if err != nil {
+				http.Error(w, errInvalidViewerFilter.Error(), http.StatusUnprocessableEntity)
+
+				log.Println(errInvalidViewerFilter)
+
+				return
+			}
+
+			update.HideBlockedPosts = sql.NullBool{Bool: parsedHideBlockedPosts, Valid: true}
+		}
+
+		found, err := m.persister.UpdateFeedMetadata(r.Context(), feedDid, rkey, update)
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotUpsertFeedMetadata, err))
+		}
+
+		if !found {
+			http.Error(w, errFeedNotFound.Error(), http.StatusNotFound)
+
+			log.Println(errFeedNotFound)
+
+			return
+		}
+
+		m.recordAuditEvent(r, session.Did, auditActionMetadataUpdate, feedDid, rkey, "")
+
+	case http.MethodDelete:
+		rkey := r.URL.Query().Get("rkey")
+		if strings.TrimSpace(rkey) == "" {
+			http.Error(w, errMissingRkey.Error(), http.StatusUnprocessableEntity)
+
+			log.Println(errMissingRkey)
+
+			return
+		}
+
+		feedDid := session.feedDid(r)
+
+		if !m.permit(w, r, session, scopeDelete, feedDid, rkey) {
+			return
+		}
+
+		if err := m.persister.DeleteFeed(m.ctx, feedDid, rkey); err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotDeleteFeed, err))
+		}
+
+		m.recordAuditEvent(r, session.Did, auditActionFeedDelete, feedDid, rkey, "")
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAdminFeedPins lists, upserts and deletes the pinned posts of a feed
+func (m *manager) handleAdminFeedPins(w http.ResponseWriter, r *http.Request) {
+	session := m.authorize(w, r)
+	if session == nil {
+		return
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			log.Printf("Client disconnected with error: %v", err)
+		}
+	}()
+
+	rkey := r.URL.Query().Get("rkey")
+	if strings.TrimSpace(rkey) == "" {
+		http.Error(w, errMissingRkey.Error(), http.StatusUnprocessableEntity)
+
+		log.Println(errMissingRkey)
+
+		return
+	}
+
+	feedDid := session.feedDid(r)
+
+	// Pins are part of a feed's metadata
+	scope := scopeMetadata
+	if r.Method == http.MethodGet {
+		scope = scopeRead
+	}
+
+	if !m.permit(w, r, session, scope, feedDid, rkey) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		rawFeedPins, err := m.persister.GetFeedPins(r.Context(), feedDid, rkey)
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotGetFeedPins, err))
+		}
+
+		res := []feedPin{}
+		for _, rawFeedPin := range rawFeedPins {
+			res = append(res, newFeedPin(rawFeedPin))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotEncode, err))
+		}
+
+	// Add or update a pin
+	case http.MethodPut:
+		postDID := r.URL.Query().Get("postDID")
+		if strings.TrimSpace(postDID) == "" {
+			http.Error(w, errMissingPostDID.Error(), http.StatusUnprocessableEntity)
+
+			log.Println(errMissingPostDID)
+
+			return
+		}
+
+		postRkey := r.URL.Query().Get("postRkey")
+		if strings.TrimSpace(postRkey) == "" {
+			http.Error(w, errMissingPostRkey.Error(), http.StatusUnprocessableEntity)
+
+			log.Println(errMissingPostRkey)
+
+			return
+		}
+
+		// Pins are sorted by position first and by post key second
+		position := int64(0)
+		if rawPosition := r.URL.Query().Get("position"); strings.TrimSpace(rawPosition) != "" {
+			parsedPosition, err := strconv.ParseInt(rawPosition, 10, 32)
+			if err != nil || parsedPosition < 0 {
+				http.Error(w, errInvalidPosition.Error(), http.StatusUnprocessableEntity)
+
+				log.Println(errInvalidPosition)
+
+				return
+			}
+
+			position = parsedPosition
+		}
+
+		// Pins without a start time are active immediately, and pins without an end time stay active until they are removed
+		var (
+			startsAt sql.NullTime
+			endsAt   sql.NullTime
+		)
+		if rawStartsAt := r.URL.Query().Get("startsAt"); strings.TrimSpace(rawStartsAt) != "" {
+			parsedStartsAt, err := time.Parse(time.RFC3339, rawStartsAt)
+			if err != nil {
+				http.Error(w, errInvalidPinSchedule.Error(), http.StatusUnprocessableEntity)
+
+				log.Println(errInvalidPinSchedule)
+
+				return
+			}
+
+			startsAt = sql.NullTime{Time: parsedStartsAt.UTC(), Valid: true}
+		}
+
+		if rawEndsAt := r.URL.Query().Get("endsAt"); strings.TrimSpace(rawEndsAt) != "" {
+			parsedEndsAt, err := time.Parse(time.RFC3339, rawEndsAt)
+			if err != nil {
+				http.Error(w, errInvalidPinSchedule.Error(), http.StatusUnprocessableEntity)
+
+				log.Println(errInvalidPinSchedule)
+
+				return
+			}
+
+			endsAt = sql.NullTime{Time: parsedEndsAt.UTC(), Valid: true}
+		}
+
+		if startsAt.Valid && endsAt.Valid && !endsAt.Time.After(startsAt.Time) {
+			http.Error(w, errInvalidPinSchedule.Error(), http.StatusUnprocessableEntity)
+
+			log.Println(errInvalidPinSchedule)
+
+			return
+		}
+
+		found, err := m.persister.UpsertFeedPin(m.ctx, feedDid, rkey, postDID, postRkey, int32(position), startsAt, endsAt)
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotUpsertFeedPin, err))
+		}
+
+		if !found {
+			http.Error(w, errFeedNotFound.Error(), http.StatusNotFound)
+
+			log.Println(errFeedNotFound)
+
+			return
+		}
+
+		m.recordAuditEvent(r, session.Did, auditActionPinUpsert, feedDid, rkey, "")
+
+	case http.MethodDelete:
+		postDID := r.URL.Query().Get("postDID")
+		if strings.TrimSpace(postDID) == "" {
+			http.Error(w, errMissingPostDID.Error(), http.StatusUnprocessableEntity)
+
+			log.Println(errMissingPostDID)
+
+			return
+		}
+
+		postRkey := r.URL.Query().Get("postRkey")
+		if strings.TrimSpace(postRkey) == "" {
+			http.Error(w, errMissingPostRkey.Error(), http.StatusUnprocessableEntity)
+
+			log.Println(errMissingPostRkey)
+
+			return
+		}
+
+		if err := m.persister.DeleteFeedPin(m.ctx, feedDid, rkey, postDID, postRkey); err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotDeleteFeedPin, err))
+		}
+
+		m.recordAuditEvent(r, session.Did, auditActionPinDelete, feedDid, rkey, "")
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAdminFeedReranker uploads and deletes the reranker of a feed
+func (m *manager) handleAdminFeedReranker(w http.ResponseWriter, r *http.Request) {
+	session := m.authorize(w, r)
+	if session == nil {
+		return
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			log.Printf("Client disconnected with error: %v", err)
+		}
+	}()
+
+	rkey := r.URL.Query().Get("rkey")
+	if strings.TrimSpace(rkey) == "" {
+		http.Error(w, errMissingRkey.Error(), http.StatusUnprocessableEntity)
+
+		log.Println(errMissingRkey)
+
+		return
+	}
+
+	feedDid := session.feedDid(r)
+
+	if !m.permit(w, r, session, scopeClassifier, feedDid, rkey) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		if !m.permitUpload(w, r, session, feedDid) {
+			return
+		}
+
+		if !m.rateLimit(w, r, rateLimitClassUpload, path.Join("did", session.Did)) {
+			return
+		}
+
+		rawReranker, ok := m.readUpload(w, r, feedDid)
+		if !ok {
+			return
+		}
+
+		unlock, ok := m.lockQuota(w, r, feedDid)
+		if !ok {
+			return
+		}
+		defer unlock()
+
+		feed, err := m.persister.GetFeed(r.Context(), feedDid, rkey)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, errFeedNotFound.Error(), http.StatusNotFound)
+
+				log.Println(errFeedNotFound)
+
+				return
+			}
+
+			panic(fmt.Errorf("%w: %v", errCouldNotGetFeeds, err))
+		}
+
+		if !m.reserveUpload(w, r, feedDid, false, feed.RerankerSize, int64(len(rawReranker))) {
+			return
+		}
+
+		found, err := m.persister.UpsertFeedReranker(m.ctx, feedDid, rkey, bytes.NewReader(rawReranker), int64(len(rawReranker)))
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotUpsertReranker, err))
+		}
+
+		if !found {
+			http.Error(w, errFeedNotFound.Error(), http.StatusNotFound)
+
+			log.Println(errFeedNotFound)
+
+			return
+		}
+
+		m.recordAuditEvent(r, session.Did, auditActionRerankerUpsert, feedDid, rkey, getBlobHash(rawReranker))
+
+	case http.MethodDelete:
+		found, err := m.persister.DeleteFeedReranker(m.ctx, feedDid, rkey)
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotDeleteReranker, err))
+		}
+
+		if !found {
+			http.Error(w, errFeedNotFound.Error(), http.StatusNotFound)
+
+			log.Println(errFeedNotFound)
+
+			return
+		}
+
+		m.recordAuditEvent(r, session.Did, auditActionRerankerDelete, feedDid, rkey, "")
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAdminFeedAudit returns the audit log of a feed
+func (m *manager) handleAdminFeedAudit(w http.ResponseWriter, r *http.Request) {
+	session := m.authorize(w, r)
+	if session == nil {
+		return
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			log.Printf("Client disconnected with error: %v", err)
+		}
+	}()
+
+	rkey := r.URL.Query().Get("rkey")
+	if strings.TrimSpace(rkey) == "" {
+		http.Error(w, errMissingRkey.Error(), http.StatusUnprocessableEntity)
+
+		log.Println(errMissingRkey)
+
+		return
+	}
+
+	feedDid := session.feedDid(r)
+
+	if !m.permit(w, r, session, scopeAudit, feedDid, rkey) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		limit, err := getAuditLimit(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+
+			log.Println(err)
+
+			return
+		}
+
+		rawEvents, err := m.persister.GetAuditEventsForFeed(r.Context(), feedDid, rkey, limit)
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotGetAuditEvents, err))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(newAuditEvents(rawEvents)); err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotEncode, err))
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAdminFeedCollaborators lists, upserts and deletes the collaborators of a feed
+func (m *manager) handleAdminFeedCollaborators(w http.ResponseWriter, r *http.Request) {
+	session := m.authorize(w, r)
+	if session == nil {
+		return
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			log.Printf("Client disconnected with error: %v", err)
+		}
+	}()
+
+	rkey := r.URL.Query().Get("rkey")
+	if strings.TrimSpace(rkey) == "" {
+		http.Error(w, errMissingRkey.Error(), http.StatusUnprocessableEntity)
+
+		log.Println(errMissingRkey)
+
+		return
+	}
+
+	feedDid := session.feedDid(r)
+
+	switch r.Method {
+	case http.MethodGet:
+		if !m.permit(w, r, session, scopeRead, feedDid, rkey) {
+			return
+		}
+
+		rawCollaborators, err := m.persister.GetFeedCollaborators(r.Context(), feedDid, rkey)
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotGetCollaborators, err))
+		}
+
+		res := []feedCollaborator{}
+		for _, rawCollaborator := range rawCollaborators {
+			res = append(res, feedCollaborator{
+				Did:  rawCollaborator.Did,
+				Role: rawCollaborator.Role,
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotEncode, err))
+		}
+
+	// Add a collaborator or update their role
+	case http.MethodPut:
+		did := r.URL.Query().Get("did")
+		if strings.TrimSpace(did) == "" {
+			http.Error(w, errMissingCollaboratorDID.Error(), http.StatusUnprocessableEntity)
+
+			log.Println(errMissingCollaboratorDID)
+
+			return
+		}
+
+		// The DID that a feed belongs to always owns it
+		if did == feedDid {
+			http.Error(w, errInvalidCollaborator.Error(), http.StatusUnprocessableEntity)
+
+			log.Println(errInvalidCollaborator)
+
+			return
+		}
+
+		role := r.URL.Query().Get("role")
+		if _, ok := roleScopes[role]; !ok {
+			http.Error(w, errInvalidRole.Error(), http.StatusUnprocessableEntity)
+
+			log.Println(errInvalidRole)
+
+			return
+		}
+
+		if !m.permit(w, r, session, scopeCollaborators, feedDid, rkey) {
+			return
+		}
+
+		found, err := m.persister.UpsertFeedCollaborator(m.ctx, feedDid, rkey, did, role)
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotUpsertCollaborator, err))
+		}
+
+		if !found {
+			http.Error(w, errFeedNotFound.Error(), http.StatusNotFound)
+
+			log.Println(errFeedNotFound)
+
+			return
+		}
+
+	case http.MethodDelete:
+		did := r.URL.Query().Get("did")
+		if strings.TrimSpace(did) == "" {
+			http.Error(w, errMissingCollaboratorDID.Error(), http.StatusUnprocessableEntity)
+
+			log.Println(errMissingCollaboratorDID)
+
+			return
+		}
+
+		// Collaborators can always leave a feed, but only owners can remove others
+		scope := scopeCollaborators
+		if did == session.Did {
+			scope = scopeRead
+		}
+
+		if !m.permit(w, r, session, scope, feedDid, rkey) {
+			return
+		}
+
+		found, err := m.persister.DeleteFeedCollaborator(m.ctx, feedDid, rkey, did)
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotDeleteCollaborator, err))
+		}
+
+		if !found {
+			http.Error(w, errCollaboratorNotFound.Error(), http.StatusNotFound)
+
+			log.Println(errCollaboratorNotFound)
+
+			return
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
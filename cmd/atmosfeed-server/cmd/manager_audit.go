@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pojntfx/atmosfeed/pkg/models"
+	"github.com/spf13/viper"
+)
+
+type auditEvent struct {
+	ID             int64     `json:"id"`
+	Did            string    `json:"did"`
+	Action         string    `json:"action"`
+	FeedDid        string    `json:"feedDID"`
+	FeedRkey       string    `json:"feedRkey"`
+	ClassifierHash string    `json:"classifierHash"`
+	Origin         string    `json:"origin"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+func newAuditEvents(rawEvents []models.AuditEvent) []auditEvent {
+	events := []auditEvent{}
+	for _, rawEvent := range rawEvents {
+		events = append(events, auditEvent{
+			ID:             rawEvent.ID,
+			Did:            rawEvent.Did,
+			Action:         rawEvent.Action,
+			FeedDid:        rawEvent.FeedDid,
+			FeedRkey:       rawEvent.FeedRkey,
+			ClassifierHash: rawEvent.ClassifierHash,
+			Origin:         rawEvent.Origin,
+			CreatedAt:      rawEvent.CreatedAt,
+		})
+	}
+
+	return events
+}
+
+// getAuditLimit returns the amount of audit events that a request asks for
+func getAuditLimit(r *http.Request) (int32, error) {
+	rawLimit := r.URL.Query().Get("limit")
+	if strings.TrimSpace(rawLimit) == "" {
+		return defaultAuditEvents, nil
+	}
+
+	limit, err := strconv.Atoi(rawLimit)
+	if err != nil || limit < 1 || limit > maxAuditEvents {
+		return 0, errInvalidLimit
+	}
+
+	return int32(limit), nil
+}
+
+// recordAuditEvent appends an action to the audit log; since the action already happened, failing to record it doesn't fail the request
+func (m *manager) recordAuditEvent(r *http.Request, actor, action, feedDid, rkey, classifierHash string) {
+	if err := m.persister.CreateAuditEvent(m.ctx, actor, action, feedDid, rkey, classifierHash, getClientIP(r, viper.GetBool(trustForwardedForFlag))); err != nil {
+		log.Println(fmt.Errorf("%w: %v", errCouldNotCreateAuditEvent, err))
+	}
+}
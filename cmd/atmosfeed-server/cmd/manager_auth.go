@@ -0,0 +1,392 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bluesky-social/indigo/api/atproto"
+	"github.com/bluesky-social/indigo/xrpc"
+	"github.com/pojntfx/atmosfeed/pkg/models"
+	"github.com/pojntfx/atmosfeed/pkg/resolvers"
+	"github.com/spf13/viper"
+)
+
+// adminSession is an authenticated user of the admin API
+type adminSession struct {
+	Did string
+
+	// Sessions that were authenticated with an API token are limited to its feeds and scopes
+	token *models.ApiToken
+}
+
+// allows checks whether a session's token grants a scope on a feed; feeds of other DIDs are referenced as `did/rkey` in tokens
+func (s *adminSession) allows(scope string, feedDid string, rkey string) bool {
+	if s.token == nil {
+		return true
+	}
+
+	if !slices.Contains(s.token.Scopes, scope) {
+		return false
+	}
+
+	if len(s.token.Feeds) == 0 {
+		return true
+	}
+
+	if feedDid == s.Did && slices.Contains(s.token.Feeds, rkey) {
+		return true
+	}
+
+	return slices.Contains(s.token.Feeds, feedDid+"/"+rkey)
+}
+
+// feedDid returns the DID of the feed that a request targets, which is the session's DID unless the request targets a shared feed
+func (s *adminSession) feedDid(r *http.Request) string {
+	if feedDID := r.URL.Query().Get("feedDID"); strings.TrimSpace(feedDID) != "" {
+		return feedDID
+	}
+
+	return s.Did
+}
+
+type apiToken struct {
+	ID          string    `json:"id"`
+	Token       string    `json:"token,omitempty"`
+	Description string    `json:"description"`
+	Feeds       []string  `json:"feeds"`
+	Scopes      []string  `json:"scopes"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+func newApiToken(rawToken models.ApiToken) apiToken {
+	feeds := rawToken.Feeds
+	if feeds == nil {
+		feeds = []string{}
+	}
+
+	return apiToken{
+		ID:          rawToken.ID,
+		Description: rawToken.Description,
+		Feeds:       feeds,
+		Scopes:      rawToken.Scopes,
+		CreatedAt:   rawToken.CreatedAt,
+	}
+}
+
+// getTokenHash returns the hash of a token, which allows caching sessions without storing their tokens
+func getTokenHash(token string) string {
+	hash := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(hash[:])
+}
+
+// accessTokenClaims are the claims of a PDS access token that are used by the admin API
+type accessTokenClaims struct {
+	Sub string `json:"sub"`
+	Exp int64  `json:"exp"`
+}
+
+// getTokenClaims returns the claims of a JWT without verifying it
+func getTokenClaims(token string) (accessTokenClaims, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return accessTokenClaims{}, false
+	}
+
+	rawClaims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return accessTokenClaims{}, false
+	}
+
+	var claims accessTokenClaims
+	if err := json.Unmarshal(rawClaims, &claims); err != nil {
+		return accessTokenClaims{}, false
+	}
+
+	return claims, true
+}
+
+// getTokenExpiry returns the expiry of a JWT without verifying it, and whether it has one
+func getTokenExpiry(token string) (time.Time, bool) {
+	claims, ok := getTokenClaims(token)
+	if !ok || claims.Exp == 0 {
+		return time.Time{}, false
+	}
+
+	return time.Unix(claims.Exp, 0), true
+}
+
+// getTokenSubject returns the DID that a token was issued for; it isn't verified, so sessions must still be confirmed by the DID's PDS
+func getTokenSubject(token string) (string, bool) {
+	claims, ok := getTokenClaims(token)
+	if !ok || !strings.HasPrefix(claims.Sub, "did:") {
+		return "", false
+	}
+
+	return claims.Sub, true
+}
+
+// isRejectedSession returns whether an error means that a session is invalid, as opposed to the PDS or DID document being unreachable
+func isRejectedSession(err error) bool {
+	var xrpcErr *xrpc.Error
+	if errors.As(err, &xrpcErr) {
+		return xrpcErr.StatusCode == http.StatusBadRequest || xrpcErr.StatusCode == http.StatusUnauthorized || xrpcErr.StatusCode == http.StatusForbidden
+	}
+
+	return errors.Is(err, resolvers.ErrMissingPDS) ||
+		errors.Is(err, resolvers.ErrDIDNotFound) ||
+		errors.Is(err, resolvers.ErrInvalidDID) ||
+		errors.Is(err, resolvers.ErrUnsupportedDIDMethod) ||
+		errors.Is(err, resolvers.ErrDIDDocumentMismatch)
+}
+
+// authenticate checks the session or API token of an admin request and answers CORS preflight requests
+func (m *manager) authenticate(w http.ResponseWriter, r *http.Request) *adminSession {
+	if o := r.Header.Get("Origin"); o == viper.GetString(originFlag) {
+		w.Header().Set("Access-Control-Allow-Origin", o)
+		w.Header().Set("Access-Control-Allow-Methods", "GET, PUT, PATCH, DELETE")
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if r.Method == http.MethodOptions {
+		return nil
+	}
+
+	accessJWT := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if strings.TrimSpace(accessJWT) == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+
+		return nil
+	}
+
+	// API tokens are stored hashed, so they can be looked up without storing the tokens themselves
+	if strings.HasPrefix(accessJWT, apiTokenPrefix) {
+		token, err := m.persister.GetApiTokenByHash(r.Context(), getTokenHash(accessJWT))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, errInvalidApiToken.Error(), http.StatusUnauthorized)
+
+				log.Println(errInvalidApiToken)
+
+				return nil
+			}
+
+			http.Error(w, errCouldNotGetApiTokens.Error(), http.StatusInternalServerError)
+
+			log.Println(fmt.Errorf("%w: %v", errCouldNotGetApiTokens, err))
+
+			return nil
+		}
+
+		return &adminSession{
+			Did:   token.Did,
+			token: &token,
+		}
+	}
+
+	// Service-auth tokens are signed with the key in the DID's document, so they can be verified without asking the PDS
+	service := r.URL.Query().Get("service")
+	if strings.TrimSpace(service) == "" {
+		did, err := m.verifier.VerifyMethod(r.Context(), accessJWT, lexiconAdmin)
+		if err != nil {
+			http.Error(w, errInvalidServiceAuth.Error(), http.StatusUnauthorized)
+
+			log.Println(fmt.Errorf("%w: %v", errInvalidServiceAuth, err))
+
+			return nil
+		}
+
+		return &adminSession{
+			Did: did,
+		}
+	}
+
+	tokenHash := getTokenHash(accessJWT)
+
+	// Sessions that were already verified don't require a request to the PDS
+	did, ok, err := m.persister.GetSession(r.Context(), tokenHash, service)
+	if err != nil {
+		log.Println("Could not get cached session, verifying with PDS:", err)
+	} else if ok {
+		return &adminSession{
+			Did: did,
+		}
+	}
+
+	// getSession fetches the session from a server, and writes an error response if it fails
+	getSession := func(host string) *atproto.ServerGetSession_Output {
+		session, err := atproto.ServerGetSession(r.Context(), &xrpc.Client{
+			Client: m.httpClient,
+			Host:   host,
+			Auth: &xrpc.AuthInfo{
+				AccessJwt: accessJWT,
+			},
+		})
+		if err != nil {
+			if isRejectedSession(err) {
+				http.Error(w, errInvalidSession.Error(), http.StatusUnauthorized)
+
+				log.Println(fmt.Errorf("%w: %v", errInvalidSession, err))
+
+				return nil
+			}
+
+			http.Error(w, errCouldNotGetSession.Error(), http.StatusBadGateway)
+
+			log.Println(fmt.Errorf("%w: %v", errCouldNotGetSession, err))
+
+			return nil
+		}
+
+		return session
+	}
+
+	// Access tokens name their DID as the subject, so the service (e.g. an entryway such as bsky.social) is only asked if they don't
+	did, ok = getTokenSubject(accessJWT)
+	if !ok {
+		session := getSession(service)
+		if session == nil {
+			return nil
+		}
+
+		did = session.Did
+	}
+
+	// Any server can claim any DID, so we only trust sessions that the PDS listed in the DID's document confirms
+	pdsURL, err := resolvers.ResolvePDS(r.Context(), m.resolver, did)
+	if err != nil {
+		if isRejectedSession(err) {
+			http.Error(w, errUntrustedService.Error(), http.StatusUnauthorized)
+
+			log.Println(fmt.Errorf("%w: %v", errUntrustedService, err))
+
+			return nil
+		}
+
+		http.Error(w, errCouldNotVerifySession.Error(), http.StatusBadGateway)
+
+		log.Println(fmt.Errorf("%w: %v", errCouldNotVerifySession, err))
+
+		return nil
+	}
+
+	session := getSession(pdsURL)
+	if session == nil {
+		// If the DID migrated to another PDS, the cached document is outdated, so the next request resolves it again
+		m.resolver.Purge(did)
+
+		return nil
+	}
+
+	if session.Did != did {
+		http.Error(w, errUntrustedService.Error(), http.StatusUnauthorized)
+
+		log.Println(errUntrustedService)
+
+		return nil
+	}
+
+	// Sessions are never cached for longer than their token is valid for, and tokens without an expiry are not cached at all
+	if expiry, ok := getTokenExpiry(accessJWT); ok {
+		if ttl := min(time.Until(expiry), viper.GetDuration(sessionCacheTTLFlag)); ttl > 0 {
+			if err := m.persister.CacheSession(r.Context(), tokenHash, service, session.Did, ttl); err != nil {
+				log.Println("Could not cache session, continuing:", err)
+			}
+		}
+	}
+
+	return &adminSession{
+		Did: session.Did,
+	}
+}
+
+// authorize authenticates a request and rate limits it by the session's DID, so that the limit holds for all of the DID's IPs and tokens
+func (m *manager) authorize(w http.ResponseWriter, r *http.Request) *adminSession {
+	session := m.authenticate(w, r)
+	if session == nil {
+		return nil
+	}
+
+	if !m.rateLimit(w, r, rateLimitClassAdmin, path.Join("did", session.Did)) {
+		return nil
+	}
+
+	return session
+}
+
+// permit checks whether a session is allowed to perform an action on a feed
+func (m *manager) permit(w http.ResponseWriter, r *http.Request, session *adminSession, scope string, feedDid string, rkey string) bool {
+	if !session.allows(scope, feedDid, rkey) {
+		http.Error(w, errForbidden.Error(), http.StatusForbidden)
+
+		log.Println(errForbidden)
+
+		return false
+	}
+
+	if feedDid == session.Did {
+		return true
+	}
+
+	// Feeds of other DIDs can only be accessed by their collaborators, and we don't reveal whether they exist otherwise
+	role, err := m.persister.GetFeedCollaboratorRole(r.Context(), feedDid, rkey, session.Did)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, errFeedNotFound.Error(), http.StatusNotFound)
+
+			log.Println(errFeedNotFound)
+
+			return false
+		}
+
+		http.Error(w, errCouldNotGetCollaborators.Error(), http.StatusInternalServerError)
+
+		log.Println(fmt.Errorf("%w: %v", errCouldNotGetCollaborators, err))
+
+		return false
+	}
+
+	if !slices.Contains(roleScopes[role], scope) {
+		http.Error(w, errForbidden.Error(), http.StatusForbidden)
+
+		log.Println(errForbidden)
+
+		return false
+	}
+
+	return true
+}
+
+// permitUpload checks whether the operator banned the session's or the feed's DID from uploading classifiers and rerankers
+func (m *manager) permitUpload(w http.ResponseWriter, r *http.Request, session *adminSession, feedDid string) bool {
+	banned, err := m.persister.IsAnyDidBanned(r.Context(), session.Did, feedDid)
+	if err != nil {
+		http.Error(w, errCouldNotGetBans.Error(), http.StatusInternalServerError)
+
+		log.Println(fmt.Errorf("%w: %v", errCouldNotGetBans, err))
+
+		return false
+	}
+
+	if banned {
+		http.Error(w, errUploadsBanned.Error(), http.StatusForbidden)
+
+		log.Println(errUploadsBanned)
+
+		return false
+	}
+
+	return true
+}
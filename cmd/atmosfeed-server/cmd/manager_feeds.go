@@ -0,0 +1,635 @@
+package cmd
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	iutil "github.com/bluesky-social/indigo/util"
+	"github.com/pojntfx/atmosfeed/pkg/cursors"
+	"github.com/pojntfx/atmosfeed/pkg/models"
+	"github.com/pojntfx/atmosfeed/pkg/persisters"
+	"github.com/spf13/viper"
+)
+
+type feedSkeleton struct {
+	Feed   []feedSkeletonPost `json:"feed"`
+	Cursor string             `json:"cursor,omitempty"`
+}
+
+type feedSkeletonPost struct {
+	Post        string              `json:"post"`
+	Reason      *feedSkeletonReason `json:"reason,omitempty"`
+	FeedContext string              `json:"feedContext,omitempty"`
+}
+
+type feedSkeletonReason struct {
+	Type   string `json:"$type"`
+	Repost string `json:"repost,omitempty"`
+}
+
+type feedInteractions struct {
+	Interactions []feedInteraction `json:"interactions"`
+}
+
+type feedInteraction struct {
+	Item        string `json:"item"`
+	Event       string `json:"event"`
+	FeedContext string `json:"feedContext"`
+}
+
+type feedGeneratorDescription struct {
+	Did   string                         `json:"did"`
+	Feeds []feedGeneratorDescriptionFeed `json:"feeds"`
+	Links *feedGeneratorDescriptionLinks `json:"links,omitempty"`
+}
+
+type feedGeneratorDescriptionFeed struct {
+	URI string `json:"uri"`
+}
+
+type feedGeneratorDescriptionLinks struct {
+	PrivacyPolicy  string `json:"privacyPolicy,omitempty"`
+	TermsOfService string `json:"termsOfService,omitempty"`
+}
+
+type wellKnownDidDocument struct {
+	Context []string           `json:"@context"`
+	ID      string             `json:"id"`
+	Service []wellKnownService `json:"service"`
+}
+
+type wellKnownService struct {
+	ID              string `json:"id"`
+	Type            string `json:"type"`
+	ServiceEndpoint string `json:"serviceEndpoint"`
+}
+
+// parseAcceptLanguage returns the languages of an Accept-Language header in order of preference, e.g. "en" and "de" for "de;q=0.8, en"
+func parseAcceptLanguage(header string) []string {
+	type weightedLang struct {
+		lang   string
+		weight float64
+	}
+
+	weightedLangs := []weightedLang{}
+	for _, part := range strings.Split(header, ",") {
+		lang, params, _ := strings.Cut(part, ";")
+
+		lang = strings.TrimSpace(lang)
+		if lang == "" || lang == "*" {
+			continue
+		}
+
+		weight := 1.0
+		if rawWeight, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			weight, err = strconv.ParseFloat(rawWeight, 64)
+			if err != nil {
+				continue
+			}
+		}
+
+		// A weight of zero means that the language is not acceptable
+		if weight <= 0 {
+			continue
+		}
+
+		weightedLangs = append(weightedLangs, weightedLang{lang, weight})
+	}
+
+	sort.SliceStable(weightedLangs, func(i, j int) bool {
+		return weightedLangs[i].weight > weightedLangs[j].weight
+	})
+
+	langs := []string{}
+	for _, weightedLang := range weightedLangs {
+		langs = append(langs, weightedLang.lang)
+	}
+
+	return langs
+}
+
+// authorizeViewer verifies the service auth that the AppView sent on behalf of a viewer and rate limits the request by the viewer's DID,
+// since AppViews send the requests of all of their viewers from a few IPs; requests with invalid service auth use up the client IP's budget instead
+func (m *manager) authorizeViewer(w http.ResponseWriter, r *http.Request) (string, bool) {
+	viewer, err := m.verifier.Verify(r.Context(), strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	if err != nil {
+		if !m.rateLimit(w, r, rateLimitClassPublic, path.Join("ip", getClientIP(r, viper.GetBool(trustForwardedForFlag)))) {
+			return "", false
+		}
+
+		http.Error(w, errInvalidServiceAuth.Error(), http.StatusUnauthorized)
+
+		log.Println(fmt.Errorf("%w: %v", errInvalidServiceAuth, err))
+
+		return "", false
+	}
+
+	if !m.rateLimit(w, r, rateLimitClassViewer, path.Join("did", viewer)) {
+		return "", false
+	}
+
+	return viewer, true
+}
+
+// handleGetFeedSkeleton serves the posts of a feed
+func (m *manager) handleGetFeedSkeleton(w http.ResponseWriter, r *http.Request) {
+	feedURL := r.URL.Query().Get("feed")
+	if strings.TrimSpace(feedURL) == "" {
+		http.Error(w, errMissingFeedURI.Error(), http.StatusUnprocessableEntity)
+
+		log.Println(errMissingFeedURI)
+
+		return
+	}
+
+	u, err := iutil.ParseAtUri(feedURL)
+	if err != nil {
+		http.Error(w, errInvalidFeedURI.Error(), http.StatusUnprocessableEntity)
+
+		log.Println(errInvalidFeedURI)
+
+		return
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			log.Printf("Client disconnected with error: %v", err)
+		}
+	}()
+
+	// The AppView authenticates requests on behalf of the viewer with a service-auth JWT; requests without one are anonymous
+	viewer := ""
+	if strings.TrimSpace(r.Header.Get("Authorization")) != "" {
+		var ok bool
+		if viewer, ok = m.authorizeViewer(w, r); !ok {
+			return
+		}
+	}
+
+	// Feeds without metadata use the global defaults
+	feed, err := m.persister.GetFeed(r.Context(), u.Did, u.Rkey)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		panic(err)
+	}
+
+	if feed.Suspended {
+		http.Error(w, errFeedSuspended.Error(), http.StatusNotFound)
+
+		log.Println(errFeedSuspended)
+
+		return
+	}
+
+	// The global TTL and limit are maximums that feeds can only lower
+	ttl := viper.GetDuration(ttlFlag)
+	if feedTTL := time.Duration(feed.Ttl) * time.Second; feedTTL > 0 && feedTTL < ttl {
+		ttl = feedTTL
+	}
+
+	maxFeedLimit := viper.GetInt(limitFlag)
+	if feed.MaxPageSize > 0 && int(feed.MaxPageSize) < maxFeedLimit {
+		maxFeedLimit = int(feed.MaxPageSize)
+	}
+
+	feedLimit := viper.GetInt(defaultLimitFlag)
+	if feed.DefaultPageSize > 0 {
+		feedLimit = int(feed.DefaultPageSize)
+	}
+
+	if feedLimit > maxFeedLimit {
+		feedLimit = maxFeedLimit
+	}
+
+	if rawFeedLimit := r.URL.Query().Get("limit"); strings.TrimSpace(rawFeedLimit) != "" {
+		feedLimit, err = strconv.Atoi(rawFeedLimit)
+		if err != nil {
+			http.Error(w, errInvalidLimit.Error(), http.StatusUnprocessableEntity)
+
+			log.Println(errInvalidLimit)
+
+			return
+		}
+	}
+
+	if feedLimit < 1 {
+		http.Error(w, errInvalidLimit.Error(), http.StatusUnprocessableEntity)
+
+		log.Println(errInvalidLimit)
+
+		return
+	}
+
+	if feedLimit > maxFeedLimit {
+		http.Error(w, errLimitTooHigh.Error(), http.StatusUnprocessableEntity)
+
+		log.Println(errLimitTooHigh)
+
+		return
+	}
+
+	// Viewer filters use values that can never match for anonymous viewers
+	excludedDid, blockerDid := "", ""
+	if feed.HideViewerPosts {
+		excludedDid = viewer
+	}
+
+	if feed.HideBlockedPosts {
+		blockerDid = viewer
+
+		// Blocks are only indexed for viewers of feeds that hide blocked posts
+		if viewer != "" {
+			now := time.Now()
+			if err := m.persister.UpsertBlockViewer(r.Context(), viewer, now, now.Add(-blockViewerRefreshInterval)); err != nil {
+				log.Println("Could not mark viewer as seen, skipping:", err)
+			}
+		}
+	}
+
+	cursor := cursors.FeedCursor{}
+	if feedCursor := r.URL.Query().Get("cursor"); strings.TrimSpace(feedCursor) != "" {
+		cursor, err = cursors.DecodeFeedCursor(feedCursor)
+		if err != nil {
+			http.Error(w, errInvalidFeedCursor.Error(), http.StatusUnprocessableEntity)
+
+			log.Println(errInvalidFeedCursor)
+
+			return
+		}
+	}
+
+	langs := parseAcceptLanguage(r.Header.Get("Accept-Language"))
+
+	// Pages only depend on the viewer if the feed uses viewer filters or a reranker, so we only cache them per viewer in this case
+	page := strconv.Itoa(feedLimit) + " " + r.URL.Query().Get("cursor")
+	if feed.HideViewerPosts || feed.HideBlockedPosts || feed.Reranker {
+		page += " " + viewer
+	}
+
+	if feed.Reranker {
+		page += " " + strings.Join(langs, ",")
+	}
+
+	cacheTTL := viper.GetDuration(skeletonCacheTTLFlag)
+	if cacheTTL > 0 {
+		skeleton, found, err := m.persister.GetFeedSkeleton(r.Context(), u.Did, u.Rkey, page)
+		if err != nil {
+			// The cache is only an optimization, so we fall back to the database if it is unavailable
+			m.skeletonCacheRequests.WithLabelValues("error").Inc()
+
+			log.Println("Could not get cached feed skeleton, skipping:", err)
+		} else if found {
+			m.skeletonCacheRequests.WithLabelValues("hit").Inc()
+
+			w.Header().Set("Content-Type", "application/json")
+
+			if _, err := w.Write(skeleton); err != nil {
+				panic(fmt.Errorf("%w: %v", errCouldNotEncode, err))
+			}
+
+			return
+		} else {
+			m.skeletonCacheRequests.WithLabelValues("miss").Inc()
+		}
+	}
+
+	res := feedSkeleton{
+		Feed: []feedSkeletonPost{},
+	}
+
+	// The AppView sends the feed context back with interactions, which allows us to attribute them to this feed
+	feedContext := fmt.Sprintf("at://%s/%s/%s", u.Did, lexiconFeedGenerator, u.Rkey)
+
+	now := time.Now()
+
+	// Pinned posts are returned before all other posts, and count towards the limit
+	pins := 0
+	if cursor.InPins() {
+		activePins, err := m.persister.GetActiveFeedPins(r.Context(), u.Did, u.Rkey, now)
+		if err != nil {
+			panic(err)
+		}
+
+		for i := cursor.Pins; i < len(activePins) && len(res.Feed) < feedLimit; i++ {
+			res.Feed = append(res.Feed, feedSkeletonPost{
+				Post: fmt.Sprintf("at://%s/%s/%s", activePins[i].PostDid, lexiconFeedPost, activePins[i].PostRkey),
+				Reason: &feedSkeletonReason{
+					Type: skeletonReasonPin,
+				},
+				FeedContext: feedContext,
+			})
+
+			pins++
+		}
+	}
+
+	remainingFeedLimit := feedLimit - len(res.Feed)
+
+	rawFeedPosts := []models.GetFeedPostsRow{}
+	if remainingFeedLimit > 0 {
+		if cursor.InPins() {
+			rawFeedPosts, err = m.persister.GetFeedPosts(
+				r.Context(),
+				u.Did,
+				u.Rkey,
+				now.Add(-ttl),
+				now,
+				excludedDid,
+				blockerDid,
+				int32(remainingFeedLimit),
+			)
+			if err != nil {
+				panic(err)
+			}
+		} else {
+			fp, err := m.persister.GetFeedPostsCursor(
+				r.Context(),
+				u.Did,
+				u.Rkey,
+				now.Add(-ttl),
+				now,
+				excludedDid,
+				blockerDid,
+				int32(remainingFeedLimit),
+				cursor.Score,
+				cursor.CreatedAt,
+				cursor.Did,
+				cursor.Rkey,
+			)
+			if err != nil {
+				panic(err)
+			}
+
+			for _, p := range fp {
+				rawFeedPosts = append(rawFeedPosts, models.GetFeedPostsRow(p))
+			}
+		}
+	}
+
+	feedPosts := rawFeedPosts
+	cacheable := true
+	if feed.Reranker && len(rawFeedPosts) > 0 {
+		reranked, err := m.rerank(r.Context(), u.Did, u.Rkey, viewer, langs, rawFeedPosts)
+		if err != nil {
+			// Rerankers are optional, so failures and timeouts fall back to the default order instead of failing the request
+			log.Println("Could not rerank feed", u.Did, u.Rkey, ", falling back to default order:", err)
+
+			// The next request should try to rerank the page again
+			cacheable = false
+		} else {
+			feedPosts = reranked
+		}
+	}
+
+	for _, rawFeedPost := range feedPosts {
+		// Reposts are returned as the reposted post, with the repost record as the reason
+		if rawFeedPost.SubjectDid != "" {
+			res.Feed = append(res.Feed, feedSkeletonPost{
+				Post: fmt.Sprintf("at://%s/%s/%s", rawFeedPost.SubjectDid, lexiconFeedPost, rawFeedPost.SubjectRkey),
+				Reason: &feedSkeletonReason{
+					Type:   skeletonReasonRepost,
+					Repost: fmt.Sprintf("at://%s/%s/%s", rawFeedPost.PostDid, lexiconFeedRepost, rawFeedPost.PostRkey),
+				},
+				FeedContext: feedContext,
+			})
+
+			continue
+		}
+
+		res.Feed = append(res.Feed, feedSkeletonPost{
+			Post:        fmt.Sprintf("at://%s/%s/%s", rawFeedPost.PostDid, lexiconFeedPost, rawFeedPost.PostRkey),
+			FeedContext: feedContext,
+		})
+	}
+
+	// If we returned less posts than requested, there are no more pages
+	if remainingFeedLimit == 0 {
+		// Pins that start or end between two pages can shift the remaining pins by one
+		res.Cursor = cursors.FeedCursor{
+			Pins: cursor.Pins + pins,
+		}.Encode()
+	} else if len(rawFeedPosts) > 0 && len(rawFeedPosts) >= remainingFeedLimit {
+		lastFeedPost := rawFeedPosts[len(rawFeedPosts)-1]
+
+		res.Cursor = cursors.FeedCursor{
+			Score:     lastFeedPost.Score,
+			CreatedAt: lastFeedPost.CreatedAt,
+			Did:       lastFeedPost.PostDid,
+			Rkey:      lastFeedPost.PostRkey,
+		}.Encode()
+	}
+
+	skeleton, err := json.Marshal(res)
+	if err != nil {
+		panic(fmt.Errorf("%w: %v", errCouldNotEncode, err))
+	}
+
+	if cacheTTL > 0 && cacheable {
+		if err := m.persister.CacheFeedSkeleton(r.Context(), u.Did, u.Rkey, page, skeleton, cacheTTL); err != nil {
+			log.Println("Could not cache feed skeleton, skipping:", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, err := w.Write(skeleton); err != nil {
+		panic(fmt.Errorf("%w: %v", errCouldNotEncode, err))
+	}
+}
+
+// handleSendInteractions records the interactions of a viewer with the posts of a feed
+func (m *manager) handleSendInteractions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	// Interactions are always sent on behalf of a viewer, so unlike feed skeleton requests they can't be anonymous
+	viewer, ok := m.authorizeViewer(w, r)
+	if !ok {
+		return
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			log.Printf("Client disconnected with error: %v", err)
+		}
+	}()
+
+	var req feedInteractions
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, errInvalidInteractions.Error(), http.StatusUnprocessableEntity)
+
+		log.Println(errInvalidInteractions)
+
+		return
+	}
+
+	if len(req.Interactions) > maxInteractions {
+		http.Error(w, errTooManyInteractions.Error(), http.StatusUnprocessableEntity)
+
+		log.Println(errTooManyInteractions)
+
+		return
+	}
+
+	var (
+		feedDids  = []string{}
+		feedRkeys = []string{}
+		postDids  = []string{}
+		postRkeys = []string{}
+		events    = []string{}
+	)
+	for _, interaction := range req.Interactions {
+		// Interactions without the feed context that we returned in the feed skeleton can't be attributed to a feed, so we ignore them
+		feedURI, err := iutil.ParseAtUri(interaction.FeedContext)
+		if err != nil || feedURI.Collection != lexiconFeedGenerator {
+			continue
+		}
+
+		postURI, err := iutil.ParseAtUri(interaction.Item)
+		if err != nil || postURI.Collection != lexiconFeedPost {
+			continue
+		}
+
+		// New events can be added to the lexicon at any time, so we ignore unknown events instead of rejecting the request
+		event, ok := strings.CutPrefix(interaction.Event, lexiconFeedDefs+"#")
+		if !ok {
+			continue
+		}
+
+		if _, ok := knownInteractions[event]; !ok {
+			continue
+		}
+
+		feedDids = append(feedDids, feedURI.Did)
+		feedRkeys = append(feedRkeys, feedURI.Rkey)
+		postDids = append(postDids, postURI.Did)
+		postRkeys = append(postRkeys, postURI.Rkey)
+		events = append(events, event)
+	}
+
+	// Interactions with posts that aren't part of the feed are ignored, and each viewer's interactions are only counted once per post
+	interactedPosts := []models.CreateInteractionsRow{}
+	if len(events) > 0 {
+		var err error
+		interactedPosts, err = m.persister.CreateInteractions(r.Context(), viewer, feedDids, feedRkeys, postDids, postRkeys, events)
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotCreateInteractions, err))
+		}
+	}
+
+	// Posts are reclassified so that classifiers can take the new interactions into account
+	seen := map[string]struct{}{}
+	for _, interactedPost := range interactedPosts {
+		if _, ok := seen[path.Join(interactedPost.PostDid, interactedPost.PostRkey)]; ok {
+			continue
+		}
+
+		seen[path.Join(interactedPost.PostDid, interactedPost.PostRkey)] = struct{}{}
+
+		if err := m.broker.AppendToStream(r.Context(), persisters.StreamPostInteraction, 0, map[string]interface{}{
+			"did":  interactedPost.PostDid,
+			"rkey": interactedPost.PostRkey,
+		}); err != nil {
+			log.Println("Could not publish interaction, skipping:", err)
+
+			continue
+		}
+	}
+
+	if viper.GetBool(verboseFlag) {
+		log.Println("Received", len(req.Interactions), "interactions from", viewer, "for", len(seen), "posts")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(struct{}{}); err != nil {
+		panic(fmt.Errorf("%w: %v", errCouldNotEncode, err))
+	}
+}
+
+// handleDescribeFeedGenerator lists the feeds of the feed generator
+func (m *manager) handleDescribeFeedGenerator(w http.ResponseWriter, r *http.Request) {
+	defer func() {
+		if err := recover(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			log.Printf("Client disconnected with error: %v", err)
+		}
+	}()
+
+	// Quarantined and suspended feeds can't be served, so they aren't advertised to directories either
+	rawFeeds, err := m.persister.GetPublicFeeds(r.Context())
+	if err != nil {
+		panic(fmt.Errorf("%w: %v", errCouldNotGetFeeds, err))
+	}
+
+	res := feedGeneratorDescription{
+		Did:   viper.GetString(feedGeneratorDIDFlag),
+		Feeds: []feedGeneratorDescriptionFeed{},
+	}
+
+	// Feeds are published to their owner's repository, so their URIs use the owner's DID and not the feed generator's
+	for _, rawFeed := range rawFeeds {
+		res.Feeds = append(res.Feeds, feedGeneratorDescriptionFeed{
+			URI: fmt.Sprintf("at://%s/%s/%s", rawFeed.Did, lexiconFeedGenerator, rawFeed.Rkey),
+		})
+	}
+
+	if privacyPolicyURL, termsOfServiceURL := viper.GetString(privacyPolicyURLFlag), viper.GetString(termsOfServiceURLFlag); strings.TrimSpace(privacyPolicyURL) != "" || strings.TrimSpace(termsOfServiceURL) != "" {
+		res.Links = &feedGeneratorDescriptionLinks{
+			PrivacyPolicy:  privacyPolicyURL,
+			TermsOfService: termsOfServiceURL,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		panic(fmt.Errorf("%w: %v", errCouldNotEncode, err))
+	}
+}
+
+// handleWellKnownDID serves the DID document of the feed generator
+func (m *manager) handleWellKnownDID(w http.ResponseWriter, r *http.Request) {
+	defer func() {
+		if err := recover(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			log.Printf("Client disconnected with error: %v", err)
+		}
+	}()
+
+	res := wellKnownDidDocument{
+		Context: []string{"https://www.w3.org/ns/did/v1"},
+		ID:      viper.GetString(feedGeneratorDIDFlag),
+		Service: []wellKnownService{
+			{
+				ID:              "#bsky_fg",
+				Type:            "BskyFeedGenerator",
+				ServiceEndpoint: viper.GetString(feedGeneratorURLFlag),
+			},
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		panic(fmt.Errorf("%w: %v", errCouldNotEncode, err))
+	}
+}
@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/bluesky-social/indigo/api/atproto"
+	"github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/events"
+	lutil "github.com/bluesky-social/indigo/lex/util"
+	"github.com/bluesky-social/indigo/repo"
+	"github.com/bluesky-social/indigo/repomgr"
+	iutil "github.com/bluesky-social/indigo/util"
+	"github.com/pojntfx/atmosfeed/pkg/persisters"
+	"github.com/spf13/viper"
+)
+
+// getRepoStreamCallbacks returns the callbacks that index the posts, likes, reposts and blocks of the firehose
+func (m *manager) getRepoStreamCallbacks() *events.RepoStreamCallbacks {
+	return &events.RepoStreamCallbacks{
+		RepoCommit: func(c *atproto.SyncSubscribeRepos_Commit) error {
+			rp, err := repo.ReadRepoFromCar(m.ctx, bytes.NewReader(c.Blocks))
+			if err != nil {
+				log.Println("Could not parse repo, skipping:", err)
+
+				return nil
+			}
+
+		l:
+			for _, op := range c.Ops {
+				switch repomgr.EventKind(op.Action) {
+				case repomgr.EvtKindCreateRecord:
+					_, res, err := rp.GetRecord(m.ctx, op.Path)
+					if err != nil {
+						log.Println("Could not parse record, skipping:", err)
+
+						continue l
+					}
+
+					d := lutil.LexiconTypeDecoder{
+						Val: res,
+					}
+
+					b, err := d.MarshalJSON()
+					if err != nil {
+						log.Println("Could not marshal lexicon, skipping:", err)
+
+						continue l
+					}
+
+					var post bsky.FeedPost
+					if err := json.Unmarshal(b, &post); err != nil {
+						log.Println("Could not unmarshal post, skipping:", err)
+
+						continue l
+					}
+
+					if post.LexiconTypeID == lexiconFeedPost {
+						if err := m.broker.AppendToStream(m.ctx, persisters.StreamPostInsert, 0, map[string]interface{}{
+							"did":       rp.RepoDid(),
+							"rkey":      path.Base(op.Path),
+							"createdAt": post.CreatedAt,
+							"text":      post.Text,
+							"reply":     strconv.FormatBool(post.Reply != nil),
+							"langs":     strings.Join(post.Langs, ","),
+						}); err != nil {
+							log.Println("Could not publish post, skipping:", err)
+
+							continue l
+						}
+
+						if viper.GetBool(verboseFlag) {
+							log.Println("Published post", post)
+						}
+					} else if post.LexiconTypeID == "app.bsky.feed.like" {
+						var like bsky.FeedLike
+						if err := json.Unmarshal(b, &like); err != nil {
+							log.Println("Could not unmarshal like, skipping:", err)
+
+							continue l
+						}
+
+						u, err := iutil.ParseAtUri(like.Subject.Uri)
+						if err != nil {
+							log.Println("Could not parse like subject URI, skipping:", err)
+
+							continue l
+						}
+
+						if err := m.broker.AppendToStream(m.ctx, persisters.StreamPostLike, 0, map[string]interface{}{
+							"did":  u.Did,
+							"rkey": u.Rkey,
+						}); err != nil {
+							log.Println("Could not publish like, skipping:", err)
+
+							continue l
+						}
+
+						if viper.GetBool(verboseFlag) {
+							log.Println("Published like", post)
+						}
+					} else if post.LexiconTypeID == lexiconFeedRepost {
+						var repost bsky.FeedRepost
+						if err := json.Unmarshal(b, &repost); err != nil {
+							log.Println("Could not unmarshal repost, skipping:", err)
+
+							continue l
+						}
+
+						u, err := iutil.ParseAtUri(repost.Subject.Uri)
+						if err != nil {
+							log.Println("Could not parse repost subject URI, skipping:", err)
+
+							continue l
+						}
+
+						// Reposts are stored like posts so that classifiers can select them, but they inherit the content of the reposted post
+						if err := m.broker.AppendToStream(m.ctx, persisters.StreamPostInsert, 0, map[string]interface{}{
+							"did":         rp.RepoDid(),
+							"rkey":        path.Base(op.Path),
+							"createdAt":   repost.CreatedAt,
+							"text":        "",
+							"reply":       "false",
+							"langs":       "",
+							"subjectDid":  u.Did,
+							"subjectRkey": u.Rkey,
+						}); err != nil {
+							log.Println("Could not publish repost, skipping:", err)
+
+							continue l
+						}
+
+						if viper.GetBool(verboseFlag) {
+							log.Println("Published repost", repost)
+						}
+					} else if post.LexiconTypeID == lexiconGraphBlock {
+						var block bsky.GraphBlock
+						if err := json.Unmarshal(b, &block); err != nil {
+							log.Println("Could not unmarshal block, skipping:", err)
+
+							continue l
+						}
+
+						// Blocks are only used to filter feeds at read time, so they don't need to go through the workers
+						did, rkey := rp.RepoDid(), path.Base(op.Path)
+						if err := m.persister.CreateBlock(m.ctx, did, rkey, block.Subject); err != nil {
+							log.Println("Could not create block, skipping:", err)
+
+							continue l
+						}
+
+						if viper.GetBool(verboseFlag) {
+							log.Println("Created block", did, rkey)
+						}
+					}
+
+				case repomgr.EvtKindDeleteRecord:
+					if lexiconTypeID := path.Dir(op.Path); lexiconTypeID == lexiconFeedPost || lexiconTypeID == lexiconFeedRepost {
+						did, rkey := rp.SignedCommit().Did, path.Base(op.Path)
+						if err := m.persister.DeletePost(m.ctx, did, rkey); err != nil {
+							log.Println("Could not delete post, skipping:", err)
+
+							continue l
+						}
+
+						if viper.GetBool(verboseFlag) {
+							log.Println("Deleted post", did, rkey)
+						}
+					} else if lexiconTypeID == lexiconGraphBlock {
+						did, rkey := rp.SignedCommit().Did, path.Base(op.Path)
+						if err := m.persister.DeleteBlock(m.ctx, did, rkey); err != nil {
+							log.Println("Could not delete block, skipping:", err)
+
+							continue l
+						}
+
+						if viper.GetBool(verboseFlag) {
+							log.Println("Deleted block", did, rkey)
+						}
+					}
+				}
+			}
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,424 @@
+package cmd
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+type operatorFeed struct {
+	Did                       string `json:"did"`
+	Rkey                      string `json:"rkey"`
+	Posts                     int64  `json:"posts"`
+	ClassifierErrors          int32  `json:"classifierErrors"`
+	ClassifierTimeouts        int32  `json:"classifierTimeouts"`
+	ClassifierLimitViolations int32  `json:"classifierLimitViolations"`
+	Quarantined               bool   `json:"quarantined"`
+	Suspended                 bool   `json:"suspended"`
+	Reranker                  bool   `json:"reranker"`
+}
+
+type bannedDid struct {
+	Did       string    `json:"did"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// authorizeOperator checks whether a request was made by the operator of the server
+func (m *manager) authorizeOperator(w http.ResponseWriter, r *http.Request) bool {
+	// The operator API is disabled unless an operator token is configured
+	operatorToken := viper.GetString(operatorTokenFlag)
+	if strings.TrimSpace(operatorToken) == "" {
+		w.WriteHeader(http.StatusNotFound)
+
+		return false
+	}
+
+	// Client IPs that failed to authenticate too often are rejected before the token is compared, so that it can't be guessed
+	clientIP := path.Join("ip", getClientIP(r, viper.GetBool(trustForwardedForFlag)))
+	if !m.checkRateLimit(w, r, rateLimitClassOperatorAuth, clientIP, false) {
+		return false
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(operatorToken)) != 1 {
+		// Only failed attempts use up the budget, so that the operator isn't limited by their own requests
+		if !m.checkRateLimit(w, r, rateLimitClassOperatorAuth, clientIP, true) {
+			return false
+		}
+
+		http.Error(w, errInvalidOperatorToken.Error(), http.StatusUnauthorized)
+
+		log.Println(errInvalidOperatorToken)
+
+		return false
+	}
+
+	return true
+}
+
+// handleOperatorFeeds lists, suspends and deletes the feeds of all DIDs
+func (m *manager) handleOperatorFeeds(w http.ResponseWriter, r *http.Request) {
+	if !m.authorizeOperator(w, r) {
+		return
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			log.Printf("Client disconnected with error: %v", err)
+		}
+	}()
+
+	if r.Method == http.MethodGet {
+		rawFeeds, err := m.persister.GetFeedStats(r.Context())
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotGetFeeds, err))
+		}
+
+		res := []operatorFeed{}
+		for _, rawFeed := range rawFeeds {
+			res = append(res, operatorFeed{
+				Did:                       rawFeed.Did,
+				Rkey:                      rawFeed.Rkey,
+				Posts:                     rawFeed.Posts,
+				ClassifierErrors:          rawFeed.ClassifierErrors,
+				ClassifierTimeouts:        rawFeed.ClassifierTimeouts,
+				ClassifierLimitViolations: rawFeed.ClassifierLimitViolations,
+				Quarantined:               rawFeed.Quarantined,
+				Suspended:                 rawFeed.Suspended,
+				Reranker:                  rawFeed.Reranker,
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotEncode, err))
+		}
+
+		return
+	}
+
+	did := r.URL.Query().Get("did")
+	if strings.TrimSpace(did) == "" {
+		http.Error(w, errMissingDID.Error(), http.StatusUnprocessableEntity)
+
+		log.Println(errMissingDID)
+
+		return
+	}
+
+	rkey := r.URL.Query().Get("rkey")
+	if strings.TrimSpace(rkey) == "" {
+		http.Error(w, errMissingRkey.Error(), http.StatusUnprocessableEntity)
+
+		log.Println(errMissingRkey)
+
+		return
+	}
+
+	switch r.Method {
+	// Suspend or unsuspend a feed
+	case http.MethodPatch:
+		suspended, err := strconv.ParseBool(r.URL.Query().Get("suspended"))
+		if err != nil {
+			http.Error(w, errInvalidSuspended.Error(), http.StatusUnprocessableEntity)
+
+			log.Println(errInvalidSuspended)
+
+			return
+		}
+
+		found, err := m.persister.UpdateFeedSuspended(m.ctx, did, rkey, suspended)
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotSuspendFeed, err))
+		}
+
+		if !found {
+			http.Error(w, errFeedNotFound.Error(), http.StatusNotFound)
+
+			log.Println(errFeedNotFound)
+
+			return
+		}
+
+		if suspended {
+			m.recordAuditEvent(r, auditActorOperator, auditActionFeedSuspend, did, rkey, "")
+		} else {
+			m.recordAuditEvent(r, auditActorOperator, auditActionFeedUnsuspend, did, rkey, "")
+		}
+
+	// Force-delete a feed and its classifier
+	case http.MethodDelete:
+		if _, err := m.persister.GetFeed(r.Context(), did, rkey); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, errFeedNotFound.Error(), http.StatusNotFound)
+
+				log.Println(errFeedNotFound)
+
+				return
+			}
+
+			panic(fmt.Errorf("%w: %v", errCouldNotGetFeeds, err))
+		}
+
+		if err := m.persister.DeleteFeed(m.ctx, did, rkey); err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotDeleteFeed, err))
+		}
+
+		m.recordAuditEvent(r, auditActorOperator, auditActionFeedDelete, did, rkey, "")
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleOperatorBans lists, upserts and deletes upload bans
+func (m *manager) handleOperatorBans(w http.ResponseWriter, r *http.Request) {
+	if !m.authorizeOperator(w, r) {
+		return
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			log.Printf("Client disconnected with error: %v", err)
+		}
+	}()
+
+	switch r.Method {
+	case http.MethodGet:
+		rawBans, err := m.persister.GetBannedDids(r.Context())
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotGetBans, err))
+		}
+
+		res := []bannedDid{}
+		for _, rawBan := range rawBans {
+			res = append(res, bannedDid{
+				Did:       rawBan.Did,
+				Reason:    rawBan.Reason,
+				CreatedAt: rawBan.CreatedAt,
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotEncode, err))
+		}
+
+	// Ban a DID from uploading classifiers and rerankers
+	case http.MethodPut:
+		did := r.URL.Query().Get("did")
+		if strings.TrimSpace(did) == "" {
+			http.Error(w, errMissingDID.Error(), http.StatusUnprocessableEntity)
+
+			log.Println(errMissingDID)
+
+			return
+		}
+
+		if err := m.persister.UpsertBannedDid(m.ctx, did, r.URL.Query().Get("reason")); err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotUpsertBan, err))
+		}
+
+		// Bans and quotas concern a DID instead of one of its feeds, so the events don't have an rkey
+		m.recordAuditEvent(r, auditActorOperator, auditActionBanUpsert, did, "", "")
+
+	case http.MethodDelete:
+		did := r.URL.Query().Get("did")
+		if strings.TrimSpace(did) == "" {
+			http.Error(w, errMissingDID.Error(), http.StatusUnprocessableEntity)
+
+			log.Println(errMissingDID)
+
+			return
+		}
+
+		found, err := m.persister.DeleteBannedDid(m.ctx, did)
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotDeleteBan, err))
+		}
+
+		if !found {
+			http.Error(w, errBanNotFound.Error(), http.StatusNotFound)
+
+			log.Println(errBanNotFound)
+
+			return
+		}
+
+		m.recordAuditEvent(r, auditActorOperator, auditActionBanDelete, did, "", "")
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleOperatorQuotas lists, upserts and deletes quota overrides
+func (m *manager) handleOperatorQuotas(w http.ResponseWriter, r *http.Request) {
+	if !m.authorizeOperator(w, r) {
+		return
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			log.Printf("Client disconnected with error: %v", err)
+		}
+	}()
+
+	switch r.Method {
+	case http.MethodGet:
+		rawQuotas, err := m.persister.GetQuotaUsages(r.Context())
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotGetQuotas, err))
+		}
+
+		res := []quota{}
+		for _, rawQuota := range rawQuotas {
+			res = append(res, newQuota(rawQuota.Did, rawQuota.Feeds, rawQuota.Storage, rawQuota.MaxFeeds, rawQuota.MaxClassifierSize, rawQuota.MaxStorage))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotEncode, err))
+		}
+
+	// Override the quotas of a DID; limits that aren't set use the defaults
+	case http.MethodPut:
+		did := r.URL.Query().Get("did")
+		if strings.TrimSpace(did) == "" {
+			http.Error(w, errMissingDID.Error(), http.StatusUnprocessableEntity)
+
+			log.Println(errMissingDID)
+
+			return
+		}
+
+		limits := map[string]sql.NullInt64{}
+		for _, param := range []string{"maxFeeds", "maxClassifierSize", "maxStorage"} {
+			rawLimit := r.URL.Query().Get(param)
+			if strings.TrimSpace(rawLimit) == "" {
+				continue
+			}
+
+			limit, err := strconv.ParseInt(rawLimit, 10, 64)
+			if err != nil || limit < 0 || (param == "maxFeeds" && limit > math.MaxInt32) {
+				http.Error(w, errInvalidQuota.Error(), http.StatusUnprocessableEntity)
+
+				log.Println(errInvalidQuota)
+
+				return
+			}
+
+			limits[param] = sql.NullInt64{
+				Int64: limit,
+				Valid: true,
+			}
+		}
+
+		if err := m.persister.UpsertQuota(
+			m.ctx,
+			did,
+			sql.NullInt32{
+				Int32: int32(limits["maxFeeds"].Int64),
+				Valid: limits["maxFeeds"].Valid,
+			},
+			limits["maxClassifierSize"],
+			limits["maxStorage"],
+		); err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotUpsertQuota, err))
+		}
+
+		m.recordAuditEvent(r, auditActorOperator, auditActionQuotaUpsert, did, "", "")
+
+	// Reset the quotas of a DID to the defaults
+	case http.MethodDelete:
+		did := r.URL.Query().Get("did")
+		if strings.TrimSpace(did) == "" {
+			http.Error(w, errMissingDID.Error(), http.StatusUnprocessableEntity)
+
+			log.Println(errMissingDID)
+
+			return
+		}
+
+		found, err := m.persister.DeleteQuota(m.ctx, did)
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotDeleteQuota, err))
+		}
+
+		if !found {
+			http.Error(w, errQuotaNotFound.Error(), http.StatusNotFound)
+
+			log.Println(errQuotaNotFound)
+
+			return
+		}
+
+		m.recordAuditEvent(r, auditActorOperator, auditActionQuotaDelete, did, "", "")
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleOperatorAudit returns the audit log of all feeds
+func (m *manager) handleOperatorAudit(w http.ResponseWriter, r *http.Request) {
+	if !m.authorizeOperator(w, r) {
+		return
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			log.Printf("Client disconnected with error: %v", err)
+		}
+	}()
+
+	switch r.Method {
+	// An empty DID returns the audit events of all DIDs
+	case http.MethodGet:
+		limit, err := getAuditLimit(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+
+			log.Println(err)
+
+			return
+		}
+
+		rawEvents, err := m.persister.GetAuditEvents(r.Context(), r.URL.Query().Get("did"), limit)
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotGetAuditEvents, err))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(newAuditEvents(rawEvents)); err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotEncode, err))
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/pojntfx/atmosfeed/pkg/persisters"
+	"github.com/spf13/viper"
+)
+
+type quota struct {
+	Did               string `json:"did"`
+	Feeds             int64  `json:"feeds"`
+	MaxFeeds          int64  `json:"maxFeeds"`
+	Storage           int64  `json:"storage"`
+	MaxStorage        int64  `json:"maxStorage"`
+	MaxClassifierSize int64  `json:"maxClassifierSize"`
+}
+
+// quotaExceededError is returned as JSON so that clients can tell which quota was exceeded
+type quotaExceededError struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+	Quota   string `json:"quota"`
+	Limit   int64  `json:"limit"`
+	Usage   int64  `json:"usage"`
+}
+
+// newQuota returns the usage and effective limits of a DID, which are the operator's overrides or the defaults
+func newQuota(did string, feeds int64, storage int64, maxFeeds sql.NullInt32, maxClassifierSize sql.NullInt64, maxStorage sql.NullInt64) quota {
+	q := quota{
+		Did:               did,
+		Feeds:             feeds,
+		MaxFeeds:          viper.GetInt64(maxFeedsFlag),
+		Storage:           storage,
+		MaxStorage:        viper.GetInt64(maxStorageFlag),
+		MaxClassifierSize: viper.GetInt64(maxClassifierSizeFlag),
+	}
+
+	if maxFeeds.Valid {
+		q.MaxFeeds = int64(maxFeeds.Int32)
+	}
+
+	if maxClassifierSize.Valid {
+		q.MaxClassifierSize = maxClassifierSize.Int64
+	}
+
+	if maxStorage.Valid {
+		q.MaxStorage = maxStorage.Int64
+	}
+
+	return q
+}
+
+// getQuota returns the quota of a DID, falling back to the default limits for the limits that the operator didn't override
+func (m *manager) getQuota(ctx context.Context, did string) (quota, error) {
+	usage, err := m.persister.GetQuotaUsageForDid(ctx, did)
+	if err != nil {
+		return quota{}, err
+	}
+
+	overrides, err := m.persister.GetQuota(ctx, did)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return quota{}, err
+	}
+
+	return newQuota(did, usage.Feeds, usage.Storage, overrides.MaxFeeds, overrides.MaxClassifierSize, overrides.MaxStorage), nil
+}
+
+// writeQuotaExceeded responds with the limit and usage of a quota that a request would exceed
+func (m *manager) writeQuotaExceeded(w http.ResponseWriter, status int, kind string, limit int64, usage int64) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(quotaExceededError{
+		Error:   "QuotaExceeded",
+		Message: fmt.Sprintf("%v quota of %v exceeded with %v", kind, limit, usage),
+		Quota:   kind,
+		Limit:   limit,
+		Usage:   usage,
+	}); err != nil {
+		panic(fmt.Errorf("%w: %v", errCouldNotEncode, err))
+	}
+
+	log.Println(fmt.Errorf("%w: %v", errQuotaExceeded, kind))
+}
+
+// readUpload reads a classifier or reranker into memory while enforcing the classifier size quota of the feed's DID
+func (m *manager) readUpload(w http.ResponseWriter, r *http.Request, feedDid string) ([]byte, bool) {
+	q, err := m.getQuota(r.Context(), feedDid)
+	if err != nil {
+		http.Error(w, errCouldNotGetQuotas.Error(), http.StatusInternalServerError)
+
+		log.Println(fmt.Errorf("%w: %v", errCouldNotGetQuotas, err))
+
+		return nil, false
+	}
+
+	// We only read one byte more than allowed, which is enough to know that an upload is too large
+	body := io.Reader(r.Body)
+	if q.MaxClassifierSize > 0 {
+		body = io.LimitReader(r.Body, q.MaxClassifierSize+1)
+	}
+
+	upload, err := io.ReadAll(body)
+	if err != nil {
+		panic(fmt.Errorf("%w: %v", errCouldNotReadUpload, err))
+	}
+
+	size := int64(len(upload))
+	if q.MaxClassifierSize > 0 && size > q.MaxClassifierSize {
+		m.writeQuotaExceeded(w, http.StatusRequestEntityTooLarge, quotaClassifierSize, q.MaxClassifierSize, max(size, r.ContentLength))
+
+		return nil, false
+	}
+
+	return upload, true
+}
+
+// lockQuota serializes the uploads of a DID across all managers, so that concurrent uploads can't exceed its quotas together.
+// The returned function releases the lock; it must only be called if the lock was taken.
+func (m *manager) lockQuota(w http.ResponseWriter, r *http.Request, feedDid string) (func(), bool) {
+	unlock, err := m.persister.LockDid(r.Context(), persisters.LockQuota, feedDid)
+	if err != nil {
+		http.Error(w, errCouldNotLockQuota.Error(), http.StatusInternalServerError)
+
+		log.Println(fmt.Errorf("%w: %v", errCouldNotLockQuota, err))
+
+		return nil, false
+	}
+
+	return func() {
+		if err := unlock(); err != nil {
+			log.Println(fmt.Errorf("%w: %v", errCouldNotUnlockQuota, err))
+		}
+	}, true
+}
+
+// reserveUpload enforces the feed and storage quotas of the feed's DID for an upload of size bytes, where previousSize is the size of the upload it replaces.
+// It must be called while holding the lock from lockQuota until the upload has been stored.
+func (m *manager) reserveUpload(w http.ResponseWriter, r *http.Request, feedDid string, newFeed bool, previousSize int64, size int64) bool {
+	q, err := m.getQuota(r.Context(), feedDid)
+	if err != nil {
+		http.Error(w, errCouldNotGetQuotas.Error(), http.StatusInternalServerError)
+
+		log.Println(fmt.Errorf("%w: %v", errCouldNotGetQuotas, err))
+
+		return false
+	}
+
+	if newFeed && q.MaxFeeds > 0 && q.Feeds >= q.MaxFeeds {
+		m.writeQuotaExceeded(w, http.StatusForbidden, quotaFeeds, q.MaxFeeds, q.Feeds+1)
+
+		return false
+	}
+
+	if storage := q.Storage - previousSize + size; q.MaxStorage > 0 && storage > q.MaxStorage {
+		m.writeQuotaExceeded(w, http.StatusForbidden, quotaStorage, q.MaxStorage, storage)
+
+		return false
+	}
+
+	return true
+}
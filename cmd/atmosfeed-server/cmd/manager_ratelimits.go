@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// getClientIP returns the IP of the client that sent a request; behind a trusted reverse proxy, this is the last address that the proxy appended to X-Forwarded-For
+func getClientIP(r *http.Request, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		if forwardedFor := r.Header.Values("X-Forwarded-For"); len(forwardedFor) > 0 {
+			addrs := strings.Split(forwardedFor[len(forwardedFor)-1], ",")
+			if addr := strings.TrimSpace(addrs[len(addrs)-1]); addr != "" {
+				return addr
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// getRateLimitClass returns the endpoint class of a request, or an empty string if the request isn't rate limited
+func getRateLimitClass(r *http.Request) string {
+	switch {
+	// CORS preflight requests are sent by browsers before every admin request, so they would use up the budget twice
+	case r.Method == http.MethodOptions:
+		return ""
+
+	// Requests with service auth are limited per viewer DID once it is verified, see authorizeViewer
+	case (r.URL.Path == "/xrpc/app.bsky.feed.getFeedSkeleton" || r.URL.Path == "/xrpc/app.bsky.feed.sendInteractions") && strings.TrimSpace(r.Header.Get("Authorization")) != "":
+		return ""
+
+	case strings.HasPrefix(r.URL.Path, "/xrpc/"), strings.HasPrefix(r.URL.Path, "/.well-known/"):
+		return rateLimitClassPublic
+
+	case r.Method == http.MethodPut && (r.URL.Path == "/admin/feeds" || r.URL.Path == "/admin/feeds/reranker"):
+		return rateLimitClassUpload
+
+	case strings.HasPrefix(r.URL.Path, "/admin/"), strings.HasPrefix(r.URL.Path, "/userdata"):
+		return rateLimitClassAdmin
+
+	// The operator API is only rate limited for failed authentication attempts, see authorizeOperator
+	default:
+		return ""
+	}
+}
+
+// checkRateLimit takes a token from (or, if take is false, only checks) the bucket of a client IP or DID in an endpoint class, and responds with 429 Too Many Requests if there is none left
+func (m *manager) checkRateLimit(w http.ResponseWriter, r *http.Request, class string, subject string, take bool) bool {
+	flags := rateLimitFlags[class]
+
+	perMinute := viper.GetInt64(flags.rate)
+	if perMinute <= 0 {
+		return true
+	}
+
+	getToken := m.persister.TakeRateLimitToken
+	if !take {
+		getToken = m.persister.PeekRateLimitToken
+	}
+
+	ok, wait, err := getToken(r.Context(), class, subject, float64(perMinute)/60, max(1, viper.GetInt64(flags.burst)))
+	if err != nil {
+		// Rate limits protect PostgreSQL and S3, so we don't reject requests just because Redis is unavailable
+		log.Println(fmt.Errorf("%w: %v", errCouldNotRateLimit, err))
+
+		return true
+	}
+
+	if !ok {
+		w.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(wait.Seconds())), 10))
+
+		http.Error(w, errRateLimited.Error(), http.StatusTooManyRequests)
+
+		if viper.GetBool(verboseFlag) {
+			log.Println(fmt.Errorf("%w: %v %v", errRateLimited, class, subject))
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// rateLimit takes a token from the bucket of a client IP or DID in an endpoint class, and responds with 429 Too Many Requests if there is none left
+func (m *manager) rateLimit(w http.ResponseWriter, r *http.Request, class string, subject string) bool {
+	return m.checkRateLimit(w, r, class, subject, true)
+}
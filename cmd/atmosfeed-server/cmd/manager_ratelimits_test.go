@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetRateLimitClass(t *testing.T) {
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		authorization string
+		class         string
+	}{
+		{"anonymous feed skeleton", http.MethodGet, "/xrpc/app.bsky.feed.getFeedSkeleton", "", rateLimitClassPublic},
+		{"feed skeleton for viewer", http.MethodGet, "/xrpc/app.bsky.feed.getFeedSkeleton", "Bearer jwt", ""},
+		{"interactions for viewer", http.MethodPost, "/xrpc/app.bsky.feed.sendInteractions", "Bearer jwt", ""},
+		{"interactions without service auth", http.MethodPost, "/xrpc/app.bsky.feed.sendInteractions", "", rateLimitClassPublic},
+		{"feed generator description with authorization", http.MethodGet, "/xrpc/app.bsky.feed.describeFeedGenerator", "Bearer jwt", rateLimitClassPublic},
+		{"DID document", http.MethodGet, "/.well-known/did.json", "", rateLimitClassPublic},
+		{"classifier upload", http.MethodPut, "/admin/feeds", "Bearer jwt", rateLimitClassUpload},
+		{"reranker upload", http.MethodPut, "/admin/feeds/reranker", "Bearer jwt", rateLimitClassUpload},
+		{"admin", http.MethodGet, "/admin/feeds", "Bearer jwt", rateLimitClassAdmin},
+		{"user data", http.MethodGet, "/userdata/structured", "Bearer jwt", rateLimitClassAdmin},
+		{"CORS preflight", http.MethodOptions, "/admin/feeds", "", ""},
+		{"operator", http.MethodGet, "/operator/feeds", "Bearer token", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+
+			if class := getRateLimitClass(r); class != tt.class {
+				t.Fatalf("expected %q, got %q", tt.class, class)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"reranker"
+
+	"github.com/loopholelabs/scale"
+	"github.com/loopholelabs/scale/scalefunc"
+	"github.com/pojntfx/atmosfeed/pkg/limiters"
+	"github.com/pojntfx/atmosfeed/pkg/models"
+	"github.com/spf13/viper"
+)
+
+// getReranker returns the reranker runtime of a feed, which is fetched and compiled the first time the feed is requested
+func (m *manager) getReranker(ctx context.Context, did, rkey string) (*scale.Scale[*reranker.Signature], error) {
+	m.rerankerLock.Lock()
+	defer m.rerankerLock.Unlock()
+
+	if runtime, ok := m.rerankers[path.Join(did, rkey)]; ok {
+		return runtime, nil
+	}
+
+	rerankerSource, err := m.persister.GetFeedReranker(ctx, did, rkey)
+	if err != nil {
+		return nil, err
+	}
+	defer rerankerSource.Close()
+
+	rawReranker, err := io.ReadAll(rerankerSource)
+	if err != nil {
+		return nil, err
+	}
+
+	fn := &scalefunc.V1BetaSchema{}
+	if err := fn.Decode(rawReranker); err != nil {
+		return nil, err
+	}
+
+	if maxMemory := viper.GetUint64(rerankerMaxMemoryFlag); maxMemory > 0 {
+		fn.Function, err = limiters.LimitMemory(fn.Function, maxMemory)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if maxFuel := viper.GetUint64(rerankerMaxFuelFlag); maxFuel > 0 {
+		fn.Function, err = limiters.LimitFuel(fn.Function, maxFuel)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	runtime, err := scale.New(scale.NewConfig(reranker.New).WithFunction(fn))
+	if err != nil {
+		return nil, err
+	}
+
+	m.rerankers[path.Join(did, rkey)] = runtime
+
+	return runtime, nil
+}
+
+// removeReranker drops the cached reranker runtime of a feed
+func (m *manager) removeReranker(feed string) {
+	m.rerankerLock.Lock()
+	defer m.rerankerLock.Unlock()
+
+	delete(m.rerankers, feed)
+}
+
+// rerank passes a page of posts to the feed's reranker, which can reorder and filter them for the viewer
+func (m *manager) rerank(ctx context.Context, feedDid, feedRkey, viewer string, langs []string, candidates []models.GetFeedPostsRow) ([]models.GetFeedPostsRow, error) {
+	runtime, err := m.getReranker(ctx, feedDid, feedRkey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errCouldNotGetReranker, err)
+	}
+
+	dids, rkeys := []string{}, []string{}
+	for _, candidate := range candidates {
+		dids = append(dids, candidate.PostDid)
+		rkeys = append(rkeys, candidate.PostRkey)
+	}
+
+	rawPosts, err := m.persister.GetPosts(ctx, dids, rkeys)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errCouldNotGetPosts, err)
+	}
+
+	posts := map[string]models.Post{}
+	for _, rawPost := range rawPosts {
+		posts[path.Join(rawPost.Did, rawPost.Rkey)] = rawPost
+	}
+
+	s := reranker.New()
+	s.Context.Viewer.Did = viewer
+	s.Context.Viewer.Langs = langs
+
+	indexedCandidates := map[string]models.GetFeedPostsRow{}
+	for _, candidate := range candidates {
+		indexedCandidates[path.Join(candidate.PostDid, candidate.PostRkey)] = candidate
+
+		p := reranker.NewPost()
+
+		p.Did = candidate.PostDid
+		p.Rkey = candidate.PostRkey
+
+		p.CreatedAt = candidate.CreatedAt.Unix()
+		p.Score = candidate.Score
+
+		// Posts can be deleted between querying the page and fetching their content
+		if post, ok := posts[path.Join(candidate.PostDid, candidate.PostRkey)]; ok {
+			p.Text = post.Text
+			p.Langs = post.Langs
+			p.Likes = int64(post.Likes)
+			p.Reply = post.Reply
+		}
+
+		s.Context.Posts = append(s.Context.Posts, p)
+	}
+
+	instance, err := runtime.Instance()
+	if err != nil {
+		return nil, err
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, viper.GetDuration(rerankerTimeoutFlag))
+	defer cancel()
+
+	if err := instance.Run(runCtx, s); err != nil {
+		if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%w: %v", errRerankerTimedOut, err)
+		}
+
+		return nil, err
+	}
+
+	reranked := []models.GetFeedPostsRow{}
+	seen := map[string]struct{}{}
+	for _, p := range s.Context.Posts {
+		if p == nil {
+			continue
+		}
+
+		// Rerankers can only reorder and filter the page, not add posts to it
+		candidate, ok := indexedCandidates[path.Join(p.Did, p.Rkey)]
+		if !ok {
+			continue
+		}
+
+		if _, ok := seen[path.Join(p.Did, p.Rkey)]; ok {
+			continue
+		}
+
+		seen[path.Join(p.Did, p.Rkey)] = struct{}{}
+		reranked = append(reranked, candidate)
+	}
+
+	return reranked, nil
+}
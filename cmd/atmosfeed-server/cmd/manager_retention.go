@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"log"
+	"time"
+
+	"github.com/pojntfx/atmosfeed/pkg/persisters"
+	"github.com/spf13/viper"
+)
+
+// runRetention periodically deletes expired posts, feed posts and blocks
+func (m *manager) runRetention() {
+	ticker := time.NewTicker(viper.GetDuration(retentionIntervalFlag))
+	defer ticker.Stop()
+
+	batchSize := int32(viper.GetInt(retentionBatchSizeFlag))
+
+	for range ticker.C {
+		// Only one manager runs the retention job at a time, so that replicas don't compete for the same rows
+		unlock, err := m.persister.TryLock(m.ctx, persisters.LockRetention)
+		if err != nil {
+			log.Println("Could not lock retention job, retrying later:", err)
+
+			continue
+		}
+
+		if unlock == nil {
+			if viper.GetBool(verboseFlag) {
+				log.Println("Retention job is running on another manager, skipping")
+			}
+
+			continue
+		}
+
+		// Rows are deleted in small batches so that the locks don't block ingestion for long
+		removedFeedPosts := int64(0)
+		for {
+			removed, err := m.persister.DeleteExpiredFeedPosts(m.ctx, time.Now(), batchSize)
+			if err != nil {
+				log.Println("Could not delete expired feed posts, retrying later:", err)
+
+				break
+			}
+
+			removedFeedPosts += removed
+
+			if removed < int64(batchSize) {
+				break
+			}
+		}
+
+		removedPosts := int64(0)
+		if retention := viper.GetDuration(retentionFlag); retention > 0 {
+			for {
+				removed, err := m.persister.DeleteExpiredPosts(m.ctx, time.Now().Add(-retention), batchSize)
+				if err != nil {
+					log.Println("Could not delete expired posts, retrying later:", err)
+
+					break
+				}
+
+				removedPosts += removed
+
+				if removed < int64(batchSize) {
+					break
+				}
+			}
+		}
+
+		// Blocks are only kept for viewers that recently requested a feed which hides blocked posts
+		removedBlocks := int64(0)
+		if blockRetention := viper.GetDuration(blockRetentionFlag); blockRetention > 0 {
+			for {
+				removed, err := m.persister.DeleteExpiredBlockViewers(m.ctx, time.Now().Add(-blockRetention), batchSize)
+				if err != nil {
+					log.Println("Could not delete expired block viewers, retrying later:", err)
+
+					break
+				}
+
+				if removed < int64(batchSize) {
+					break
+				}
+			}
+
+			for {
+				removed, err := m.persister.DeleteOrphanedBlocks(m.ctx, batchSize)
+				if err != nil {
+					log.Println("Could not delete orphaned blocks, retrying later:", err)
+
+					break
+				}
+
+				removedBlocks += removed
+
+				if removed < int64(batchSize) {
+					break
+				}
+			}
+		}
+
+		if removedPosts > 0 || removedFeedPosts > 0 || removedBlocks > 0 || viper.GetBool(verboseFlag) {
+			log.Println("Removed", removedPosts, "expired posts,", removedFeedPosts, "expired feed posts and", removedBlocks, "expired blocks")
+		}
+
+		if err := unlock(); err != nil {
+			log.Println("Could not unlock retention job:", err)
+		}
+	}
+}
@@ -0,0 +1,380 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type structuredUserdataFeed struct {
+	Did      string `json:"did"`
+	Rkey     string `json:"rkey"`
+	Reranker bool   `json:"reranker"`
+}
+
+type structuredUserdataFeedPin struct {
+	FeedDid  string     `json:"feedDID"`
+	FeedRkey string     `json:"feedRkey"`
+	PostDid  string     `json:"postDID"`
+	PostRkey string     `json:"postRkey"`
+	Position int32      `json:"position"`
+	StartsAt *time.Time `json:"startsAt,omitempty"`
+	EndsAt   *time.Time `json:"endsAt,omitempty"`
+}
+
+type structuredUserdataFeedPost struct {
+	FeedDid  string `json:"feedDID"`
+	FeedRkey string `json:"feedRkey"`
+	PostDid  string `json:"postDID"`
+	PostRkey string `json:"postRkey"`
+	Weight   int32  `json:"weight"`
+}
+
+type structuredUserdataPost struct {
+	Did         string    `json:"did"`
+	Rkey        string    `json:"rkey"`
+	CreatedAt   time.Time `json:"createdAt"`
+	Text        string    `json:"text"`
+	Reply       bool      `json:"reply"`
+	Langs       []string  `json:"langs"`
+	Likes       int32     `json:"likes"`
+	SubjectDid  string    `json:"subjectDID"`
+	SubjectRkey string    `json:"subjectRkey"`
+}
+
+type structuredUserdataInteraction struct {
+	FeedDid   string    `json:"feedDID"`
+	FeedRkey  string    `json:"feedRkey"`
+	PostDid   string    `json:"postDID"`
+	PostRkey  string    `json:"postRkey"`
+	Did       string    `json:"did"`
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type structuredUserdataFeedCollaborator struct {
+	FeedDid  string `json:"feedDID"`
+	FeedRkey string `json:"feedRkey"`
+	Did      string `json:"did"`
+	Role     string `json:"role"`
+}
+
+type structuredUserdataBlock struct {
+	Did     string `json:"did"`
+	Rkey    string `json:"rkey"`
+	Subject string `json:"subject"`
+}
+
+type structuredUserdata struct {
+	Feeds         []structuredUserdataFeed             `json:"feeds"`
+	Posts         []structuredUserdataPost             `json:"posts"`
+	FeedPosts     []structuredUserdataFeedPost         `json:"feedPosts"`
+	FeedPins      []structuredUserdataFeedPin          `json:"feedPins"`
+	Blocks        []structuredUserdataBlock            `json:"blocks"`
+	Interactions  []structuredUserdataInteraction      `json:"interactions"`
+	Collaborators []structuredUserdataFeedCollaborator `json:"collaborators"`
+	AuditEvents   []auditEvent                         `json:"auditEvents"`
+}
+
+// handleUserdata deletes all data stored about a session's DID
+func (m *manager) handleUserdata(w http.ResponseWriter, r *http.Request) {
+	session := m.authorize(w, r)
+	if session == nil {
+		return
+	}
+
+	if !m.permit(w, r, session, scopeAccount, session.Did, "") {
+		return
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			log.Printf("Client disconnected with error: %v", err)
+		}
+	}()
+
+	switch r.Method {
+	case http.MethodDelete:
+		feeds, err := m.persister.GetFeedsForDid(r.Context(), session.Did)
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotGetFeeds, err))
+		}
+
+		for _, feed := range feeds {
+			if err := m.persister.DeleteFeed(r.Context(), session.Did, feed.Rkey); err != nil {
+				panic(fmt.Errorf("%w: %v", errCouldNotDeleteFeed, err))
+			}
+		}
+
+		if err := m.persister.DeletePostsForDid(r.Context(), session.Did); err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotDeletePosts, err))
+		}
+
+		if err := m.persister.DeleteFeedPostsForDid(r.Context(), session.Did); err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotDeleteFeedPosts, err))
+		}
+
+		if err := m.persister.DeleteBlocksForDid(r.Context(), session.Did); err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotDeleteBlocks, err))
+		}
+
+		if err := m.persister.DeleteInteractionsForDid(r.Context(), session.Did); err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotDeleteInteractions, err))
+		}
+
+		// Collaborators of the DID's own feeds were already removed together with the feeds
+		if err := m.persister.DeleteFeedCollaboratorsForDid(r.Context(), session.Did); err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotDeleteCollaborator, err))
+		}
+
+		if err := m.persister.DeleteApiTokensForDid(r.Context(), session.Did); err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotDeleteApiToken, err))
+		}
+
+		if err := m.persister.InvalidateSessionsForDid(r.Context(), session.Did); err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotInvalidateSession, err))
+		}
+
+		// The audit log is append-only, so instead of deleting the DID's audit events we replace the DID with a random pseudonym
+		// and remove the client IPs of the events that it caused; the events stay linked to each other and to this record of the deletion
+		pseudonym := auditPseudonymPrefix + uuid.NewString()
+		if err := m.persister.PseudonymizeAuditEventsForDid(r.Context(), session.Did, pseudonym); err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotPseudonymizeAudit, err))
+		}
+
+		if err := m.persister.CreateAuditEvent(m.ctx, pseudonym, auditActionUserdataDelete, "", "", "", ""); err != nil {
+			log.Println(fmt.Errorf("%w: %v", errCouldNotCreateAuditEvent, err))
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleStructuredUserdata exports the data stored about a session's DID as JSON
+func (m *manager) handleStructuredUserdata(w http.ResponseWriter, r *http.Request) {
+	session := m.authorize(w, r)
+	if session == nil {
+		return
+	}
+
+	if !m.permit(w, r, session, scopeAccount, session.Did, "") {
+		return
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			log.Printf("Client disconnected with error: %v", err)
+		}
+	}()
+
+	switch r.Method {
+	case http.MethodGet:
+		rawFeeds, err := m.persister.GetFeedsForDid(r.Context(), session.Did)
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotGetFeeds, err))
+		}
+
+		feeds := []structuredUserdataFeed{}
+		for _, feed := range rawFeeds {
+			feeds = append(feeds, structuredUserdataFeed{
+				feed.Did,
+				feed.Rkey,
+				feed.Reranker,
+			})
+		}
+
+		rawFeedPins, err := m.persister.GetFeedPinsForDid(r.Context(), session.Did)
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotGetFeedPins, err))
+		}
+
+		feedPins := []structuredUserdataFeedPin{}
+		for _, rawFeedPin := range rawFeedPins {
+			pin := newFeedPin(rawFeedPin)
+
+			feedPins = append(feedPins, structuredUserdataFeedPin{
+				rawFeedPin.FeedDid,
+				rawFeedPin.FeedRkey,
+				pin.PostDid,
+				pin.PostRkey,
+				pin.Position,
+				pin.StartsAt,
+				pin.EndsAt,
+			})
+		}
+
+		rawPosts, err := m.persister.GetPostsForDid(r.Context(), session.Did)
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotGetPosts, err))
+		}
+
+		posts := []structuredUserdataPost{}
+		for _, post := range rawPosts {
+			posts = append(posts, structuredUserdataPost{
+				post.Did,
+				post.Rkey,
+				post.CreatedAt,
+				post.Text,
+				post.Reply,
+				post.Langs,
+				post.Likes,
+				post.SubjectDid,
+				post.SubjectRkey,
+			})
+		}
+
+		rawFeedPosts, err := m.persister.GetFeedPostsForDid(r.Context(), session.Did)
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotGetFeedPosts, err))
+		}
+
+		feedPosts := []structuredUserdataFeedPost{}
+		for _, feedPost := range rawFeedPosts {
+			feedPosts = append(feedPosts, structuredUserdataFeedPost{
+				feedPost.FeedDid,
+				feedPost.FeedRkey,
+				feedPost.PostDid,
+				feedPost.PostRkey,
+				feedPost.Weight,
+			})
+		}
+
+		rawBlocks, err := m.persister.GetBlocksForDid(r.Context(), session.Did)
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotGetBlocks, err))
+		}
+
+		blocks := []structuredUserdataBlock{}
+		for _, block := range rawBlocks {
+			blocks = append(blocks, structuredUserdataBlock{
+				block.Did,
+				block.Rkey,
+				block.Subject,
+			})
+		}
+
+		rawInteractions, err := m.persister.GetInteractionsForDid(r.Context(), session.Did)
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotGetInteractions, err))
+		}
+
+		interactions := []structuredUserdataInteraction{}
+		for _, interaction := range rawInteractions {
+			interactions = append(interactions, structuredUserdataInteraction{
+				interaction.FeedDid,
+				interaction.FeedRkey,
+				interaction.PostDid,
+				interaction.PostRkey,
+				interaction.Did,
+				interaction.Event,
+				interaction.CreatedAt,
+			})
+		}
+
+		rawCollaborators, err := m.persister.GetFeedCollaboratorsForDid(r.Context(), session.Did)
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotGetCollaborators, err))
+		}
+
+		collaborators := []structuredUserdataFeedCollaborator{}
+		for _, collaborator := range rawCollaborators {
+			collaborators = append(collaborators, structuredUserdataFeedCollaborator{
+				collaborator.FeedDid,
+				collaborator.FeedRkey,
+				collaborator.Did,
+				collaborator.Role,
+			})
+		}
+
+		rawAuditEvents, err := m.persister.GetAuditEventsForDid(r.Context(), session.Did)
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotGetAuditEvents, err))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(structuredUserdata{
+			Feeds:         feeds,
+			Posts:         posts,
+			FeedPosts:     feedPosts,
+			FeedPins:      feedPins,
+			Blocks:        blocks,
+			Interactions:  interactions,
+			Collaborators: collaborators,
+			AuditEvents:   newAuditEvents(rawAuditEvents),
+		}); err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotEncode, err))
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleUserdataBlob exports the classifier or reranker of a feed
+func (m *manager) handleUserdataBlob(w http.ResponseWriter, r *http.Request) {
+	session := m.authorize(w, r)
+	if session == nil {
+		return
+	}
+
+	if !m.permit(w, r, session, scopeAccount, session.Did, "") {
+		return
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			log.Printf("Client disconnected with error: %v", err)
+		}
+	}()
+
+	resource := r.URL.Query().Get("resource")
+	if strings.TrimSpace(resource) == "" {
+		panic(errMissingResource)
+	}
+
+	if resource != "classifier" && resource != "reranker" {
+		panic(errInvalidResource)
+	}
+
+	rkey := r.URL.Query().Get("rkey")
+	if strings.TrimSpace(rkey) == "" {
+		panic(errMissingRkey)
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		getBlob := m.persister.GetFeedClassifier
+		if resource == "reranker" {
+			getBlob = m.persister.GetFeedReranker
+		}
+
+		blob, err := getBlob(r.Context(), session.Did, rkey)
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotGetFeedPosts, err))
+		}
+		defer blob.Close()
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+
+		if _, err := io.Copy(w, blob); err != nil {
+			panic(fmt.Errorf("%w: %v", errCouldNotEncode, err))
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
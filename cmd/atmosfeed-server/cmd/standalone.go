@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"errors"
+	"log"
+
+	"github.com/pojntfx/atmosfeed/pkg/persisters"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	// The in-process broker only delivers messages within one process, so separate managers and workers could never reach each other through it
+	errInProcessBroker = errors.New("the in-process broker can only be used with the standalone command")
+)
+
+var standaloneCmd = &cobra.Command{
+	Use:     "standalone",
+	Aliases: []string{"s"},
+	Short:   "Start an Atmosfeed manager and worker in a single process",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := viper.BindPFlags(cmd.PersistentFlags()); err != nil {
+			return err
+		}
+
+		broker, cache, err := persisters.NewBroker(viper.GetString(redisURLFlag))
+		if err != nil {
+			return err
+		}
+		defer broker.Close()
+
+		log.Println("Connected to broker")
+
+		// The worker doesn't migrate the database, so the schema must exist before it starts alongside the manager
+		if err := persisters.Migrate(viper.GetString(postgresURLFlag)); err != nil {
+			return err
+		}
+
+		errs := make(chan error, 2)
+
+		go func() {
+			errs <- runManager(cmd, broker, cache)
+		}()
+
+		go func() {
+			errs <- runWorker(cmd, broker, cache)
+		}()
+
+		return <-errs
+	},
+}
+
+func init() {
+	addManagerFlags(standaloneCmd)
+	addWorkerFlags(standaloneCmd)
+
+	viper.AutomaticEnv()
+
+	rootCmd.AddCommand(standaloneCmd)
+}
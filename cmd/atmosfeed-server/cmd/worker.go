@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"io"
 	"log"
@@ -15,9 +14,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/lib/pq"
-	"github.com/loopholelabs/scale"
-	"github.com/loopholelabs/scale/scalefunc"
+	"github.com/pojntfx/atmosfeed/pkg/hashrings"
 	"github.com/pojntfx/atmosfeed/pkg/models"
 	"github.com/pojntfx/atmosfeed/pkg/persisters"
 	"github.com/redis/go-redis/v9"
@@ -26,10 +23,24 @@ import (
 )
 
 const (
-	classifierTimeoutFlag = "classifier-timeout"
-	workingDirectoryFlag  = "working-directory"
+	classifierTimeoutFlag                     = "classifier-timeout"
+	classifierErrorBudgetFlag                 = "classifier-error-budget"
+	classifierMaxMemoryFlag                   = "classifier-max-memory"
+	classifierMaxFuelFlag                     = "classifier-max-fuel"
+	classifierMaxInstancesPerDIDPerWorkerFlag = "classifier-max-instances-per-did-per-worker"
+	workingDirectoryFlag                      = "working-directory"
+	shardedFlag                               = "sharded"
+	workerIDFlag                              = "worker-id"
+	workerTTLFlag                             = "worker-ttl"
+	flushSizeFlag                             = "flush-size"
+	flushIntervalFlag                         = "flush-interval"
+	batchMaxAttemptsFlag                      = "batch-max-attempts"
+	pendingTimeoutFlag                        = "pending-timeout"
 
 	classifiersPath = "classifiers"
+
+	minConsumeRetryDelay = time.Second
+	maxConsumeRetryDelay = time.Minute
 )
 
 var (
@@ -46,9 +57,65 @@ var (
 	errMessageMissingLangs     = errors.New("message did not contain langs")
 	errMessageInvalidLangs     = errors.New("message contained invalid langs")
 
-	errPostgresForeignKeyViolation = "23503"
+	errClassifierInstanceLimitExceeded = errors.New("classifier instance limit exceeded")
+	errNoWorkers                       = errors.New("no sharded workers available")
+	errShardingWithoutCache            = errors.New("sharding requires Redis as the broker")
 )
 
+// workerPersister is the part of persisters.WorkerPersister that the worker uses, so that tests can run a worker without a database
+type workerPersister interface {
+	GetFeeds(ctx context.Context) ([]models.Feed, error)
+	GetFeed(ctx context.Context, did string, rkey string) (models.Feed, error)
+	GetFeedClassifier(ctx context.Context, did string, rkey string) (io.ReadCloser, error)
+	IncrementFeedClassifierErrors(ctx context.Context, did string, rkey string) (models.IncrementFeedClassifierErrorsRow, error)
+	IncrementFeedClassifierTimeouts(ctx context.Context, did string, rkey string) (models.IncrementFeedClassifierTimeoutsRow, error)
+	IncrementFeedClassifierLimitViolations(ctx context.Context, did string, rkey string) error
+	QuarantineFeed(ctx context.Context, did string, rkey string) (bool, error)
+	UpsertFeedPosts(ctx context.Context, feedDids []string, feedRkeys []string, postDids []string, postRkeys []string, weights []int32) error
+	GetInteractionCounts(ctx context.Context, postDids []string, postRkeys []string) ([]models.GetInteractionCountsRow, error)
+	CreatePosts(ctx context.Context, dids []string, rkeys []string, createdAts []time.Time, texts []string, replies []bool, langs []string, subjectDids []string, subjectRkeys []string) ([]models.Post, error)
+	LikePosts(ctx context.Context, dids []string, rkeys []string, likes []int32) ([]models.Post, error)
+	GetPosts(ctx context.Context, dids []string, rkeys []string) ([]models.Post, error)
+	GetPostClassifyStream(workerID string) string
+	GetDeadLetterStream(stream string) string
+	DeadLetterMessage(ctx context.Context, stream string, message persisters.StreamMessage, reason error) error
+	RegisterWorker(ctx context.Context, workerID string) error
+	HeartbeatWorker(ctx context.Context, workerID string) error
+	GetWorkers(ctx context.Context, ttl time.Duration) ([]string, error)
+	GetDepartedWorkers(ctx context.Context, ttl time.Duration) ([]string, error)
+	ReclaimWorker(ctx context.Context, workerID string) ([]persisters.StreamMessage, error)
+	UnregisterWorker(ctx context.Context, workerID string) error
+	PublishPostsClassify(ctx context.Context, workerIDs []string, dids []string, rkeys []string) error
+}
+
+// classifier is a loaded classifier Scale function, see fetchClassifier
+type classifier interface {
+	Run(ctx context.Context, s *signature.Signature) error
+}
+
+type worker struct {
+	ctx context.Context
+	id  string
+
+	persister workerPersister
+	broker    persisters.Broker
+
+	// Feeds that aren't quarantined or suspended, and the ring that assigns them to sharded workers
+	shardLock sync.Mutex
+	ring      *hashrings.HashRing
+	feeds     map[string]struct{}
+
+	// Classifiers of the feeds that this worker runs, by feed
+	classifierLock sync.Mutex
+	classifiers    map[string]classifier
+
+	rebalanceLock sync.Mutex
+
+	// Delay before a batch that couldn't be handled is retried, which doubles with every attempt up to maxRetryDelay
+	minRetryDelay time.Duration
+	maxRetryDelay time.Duration
+}
+
 var workerCmd = &cobra.Command{
 	Use:     "worker",
 	Aliases: []string{"w"},
@@ -58,447 +125,253 @@ var workerCmd = &cobra.Command{
 			return err
 		}
 
-		if err := os.RemoveAll(filepath.Join(viper.GetString(workingDirectoryFlag), classifiersPath)); err != nil {
-			panic(err)
-		}
-
-		options, err := redis.ParseURL(viper.GetString(redisURLFlag))
+		broker, cache, err := persisters.NewBroker(viper.GetString(redisURLFlag))
 		if err != nil {
-			panic(err)
+			return err
 		}
-
-		broker := redis.NewClient(options)
 		defer broker.Close()
 
-		log.Println("Connected to Redis")
-
-		persister := persisters.NewWorkerPersister(viper.GetString(postgresURLFlag), broker, viper.GetString(s3URLFlag))
-
-		if err := persister.Init(); err != nil {
-			panic(err)
+		if _, ok := broker.(*persisters.MemoryBroker); ok {
+			return errInProcessBroker
 		}
 
-		log.Println("Connected to PostgreSQL")
-
-		var classifierLock sync.Mutex
-		classifiers := map[string]*scale.Instance[*signature.Signature]{}
+		log.Println("Connected to broker")
 
-		fetchClassifier := func(did, rkey string) error {
-			classifierSource, err := persister.GetFeedClassifier(cmd.Context(), did, rkey)
-			if err != nil {
-				return err
-			}
+		return runWorker(cmd, broker, cache)
+	},
+}
 
-			classifierPath := filepath.Join(viper.GetString(workingDirectoryFlag), classifiersPath, did, rkey)
-			if err := os.MkdirAll(filepath.Dir(classifierPath), os.ModePerm); err != nil {
-				return err
-			}
+// runWorker runs a worker that exchanges messages with the manager and other workers through a broker; without a cache, sharding isn't available
+func runWorker(cmd *cobra.Command, broker persisters.Broker, cache *redis.Client) error {
+	// Workers can only be tracked in the cache, so sharding isn't available without it
+	if viper.GetBool(shardedFlag) && cache == nil {
+		return errShardingWithoutCache
+	}
 
-			classifierLock.Lock()
-			defer classifierLock.Unlock()
+	if err := os.RemoveAll(filepath.Join(viper.GetString(workingDirectoryFlag), classifiersPath)); err != nil {
+		panic(err)
+	}
 
-			f, err := os.OpenFile(classifierPath, os.O_RDWR|os.O_CREATE, os.ModePerm)
-			if err != nil {
-				return err
-			}
-			defer f.Close()
+	persister := persisters.NewWorkerPersister(viper.GetString(postgresURLFlag), broker, cache, viper.GetString(s3URLFlag))
 
-			if _, err := io.Copy(f, classifierSource); err != nil {
-				return err
-			}
+	if err := persister.Init(cmd.Context()); err != nil {
+		panic(err)
+	}
 
-			fn, err := scalefunc.Read(classifierPath)
-			if err != nil {
-				return err
-			}
+	log.Println("Connected to PostgreSQL")
 
-			runtime, err := scale.New(scale.NewConfig(signature.New).WithFunction(fn))
-			if err != nil {
-				return err
-			}
+	workerID := viper.GetString(workerIDFlag)
+	if strings.TrimSpace(workerID) == "" {
+		workerID = uuid.NewString()
+	}
 
-			classifier, err := runtime.Instance()
-			if err != nil {
-				return err
-			}
+	w := &worker{
+		ctx: cmd.Context(),
+		id:  workerID,
 
-			classifiers[path.Join(did, rkey)] = classifier
+		persister: persister,
+		broker:    broker,
 
-			return nil
-		}
+		ring:  hashrings.NewHashRing(hashrings.DefaultReplicas),
+		feeds: map[string]struct{}{},
 
-		errs := make(chan error)
+		classifiers: map[string]classifier{},
 
-		go func() {
-			streams := broker.Subscribe(cmd.Context(), persisters.TopicFeedUpsert)
-			defer streams.Close()
-
-			messages := streams.Channel()
-			for message := range messages {
-				did, rkey := path.Dir(message.Payload), path.Base(message.Payload)
-
-				if err := fetchClassifier(did, rkey); err != nil {
-					log.Println("Could not fetch classifier, skipping:", err)
+		minRetryDelay: minConsumeRetryDelay,
+		maxRetryDelay: maxConsumeRetryDelay,
+	}
 
-					continue
-				}
+	if viper.GetBool(shardedFlag) {
+		if err := persister.RegisterWorker(cmd.Context(), workerID); err != nil {
+			panic(err)
+		}
 
-				if viper.GetBool(verboseFlag) {
-					log.Println("Upserted classifier for feed", did, rkey)
-				}
+		defer func() {
+			if err := persister.UnregisterWorker(context.Background(), workerID); err != nil {
+				log.Println("Could not unregister worker:", err)
 			}
 		}()
 
-		go func() {
-			streams := broker.Subscribe(cmd.Context(), persisters.TopicFeedDelete)
-			defer streams.Close()
-
-			messages := streams.Channel()
-			for message := range messages {
-				did, rkey := path.Dir(message.Payload), path.Base(message.Payload)
+		workers, err := persister.GetWorkers(cmd.Context(), viper.GetDuration(workerTTLFlag))
+		if err != nil {
+			panic(err)
+		}
 
-				func() {
-					classifierLock.Lock()
-					defer classifierLock.Unlock()
+		w.ring.SetMembers(workers)
 
-					if err := os.RemoveAll(filepath.Join(viper.GetString(workingDirectoryFlag), classifiersPath, did, rkey)); err != nil {
-						log.Println("Could not remove classifier from disk, skipping:", err)
+		log.Println("Joined", len(workers), "sharded workers as", workerID)
+	}
 
-						return
-					}
+	errs := make(chan error)
 
-					delete(classifiers, path.Join(did, rkey))
+	feedUpserts, err := broker.Subscribe(cmd.Context(), persisters.TopicFeedUpsert)
+	if err != nil {
+		panic(err)
+	}
 
-					if viper.GetBool(verboseFlag) {
-						log.Println("Deleted feed", did, rkey)
-					}
-				}()
-			}
-		}()
+	go func() {
+		defer feedUpserts.Close()
 
-		classifierSources, err := persister.GetFeeds(cmd.Context())
-		if err != nil {
-			panic(err)
+		for payload := range feedUpserts.Channel() {
+			w.upsertFeed(payload)
 		}
+	}()
+
+	feedDeletes, err := broker.Subscribe(cmd.Context(), persisters.TopicFeedDelete)
+	if err != nil {
+		panic(err)
+	}
 
-		for _, classifierSource := range classifierSources {
-			did, rkey := classifierSource.Did, classifierSource.Rkey
+	go func() {
+		defer feedDeletes.Close()
 
-			if err := fetchClassifier(did, rkey); err != nil {
-				log.Println("Could not fetch classifier, skipping:", err)
+		for payload := range feedDeletes.Channel() {
+			if err := w.releaseFeed(payload); err != nil {
+				log.Println("Could not remove classifier from disk, skipping:", err)
 
 				continue
 			}
 
 			if viper.GetBool(verboseFlag) {
-				log.Println("Fetched classifier for feed", did, rkey)
+				log.Println("Deleted feed", path.Dir(payload), path.Base(payload))
 			}
 		}
+	}()
 
-		log.Println("Fetched classifiers")
-
-		classify := func(post models.Post) error {
-			errs := make(chan error)
-
-			classifierLock.Lock()
-			defer classifierLock.Unlock()
-
-			var wg sync.WaitGroup
-			for feed, classifier := range classifiers {
-				wg.Add(1)
-
-				did, rkey := path.Dir(feed), path.Base(feed)
-
-				go func(feedDid, feedRkey string, classifier *scale.Instance[*signature.Signature]) {
-					defer wg.Done()
-
-					p := signature.NewPost()
-
-					p.Did = post.Did
-					p.Rkey = post.Rkey
-					p.Text = post.Text
-
-					p.Langs = post.Langs
+	feedQuarantines, err := broker.Subscribe(cmd.Context(), persisters.TopicFeedQuarantine)
+	if err != nil {
+		panic(err)
+	}
 
-					p.CreatedAt = post.CreatedAt.Unix()
-					p.Likes = int64(post.Likes)
+	go func() {
+		defer feedQuarantines.Close()
 
-					p.Reply = post.Reply
+		for payload := range feedQuarantines.Channel() {
+			if err := w.releaseFeed(payload); err != nil {
+				log.Println("Could not remove classifier from disk, skipping:", err)
 
-					s := signature.New()
-					s.Context.Post = p
+				continue
+			}
 
-					ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration(classifierTimeoutFlag))
-					defer cancel()
+			log.Println("Quarantined feed", path.Dir(payload), path.Base(payload))
+		}
+	}()
 
-					if err := classifier.Run(ctx, s); err != nil {
-						errs <- err
+	if err := w.loadFeeds(); err != nil {
+		panic(err)
+	}
 
-						return
-					}
+	log.Println("Fetched classifiers")
 
-					if s.Context.Weight >= 0 {
-						if err := persister.UpsertFeedPost(cmd.Context(), feedDid, feedRkey, p.Did, p.Rkey, int32(s.Context.Weight)); err != nil {
-							// We can safely ignore inserts if the feed that it should be inserted in was deleted
-							if err, ok := err.(*pq.Error); !ok || err.Code != pq.ErrorCode(errPostgresForeignKeyViolation) {
-								errs <- err
-							}
+	if viper.GetBool(shardedFlag) {
+		// Heartbeats are sent separately from rebalancing, since a slow rebalance would otherwise delay them past --worker-ttl,
+		// after which the other workers reclaim this worker's posts
+		go func() {
+			ticker := time.NewTicker(viper.GetDuration(workerTTLFlag) / 3)
+			defer ticker.Stop()
 
-							return
-						}
-					}
-				}(did, rkey, classifier)
+			for range ticker.C {
+				if err := persister.HeartbeatWorker(cmd.Context(), workerID); err != nil {
+					log.Println("Could not send heartbeat, retrying:", err)
+				}
 			}
+		}()
 
-			go func() {
-				wg.Wait()
-
-				close(errs)
-			}()
+		go func() {
+			ticker := time.NewTicker(viper.GetDuration(workerTTLFlag) / 3)
+			defer ticker.Stop()
 
-			for err := range errs {
-				if err != nil {
-					return err
+			for range ticker.C {
+				if err := w.rebalance(); err != nil {
+					log.Println("Could not rebalance feeds, retrying:", err)
 				}
 			}
+		}()
 
-			return nil
+		workerMemberships, err := broker.Subscribe(cmd.Context(), persisters.TopicWorkerMembership)
+		if err != nil {
+			panic(err)
 		}
 
 		go func() {
-			for {
-				streams, err := broker.XReadGroup(cmd.Context(), &redis.XReadGroupArgs{
-					Group:    persisters.StreamPostInsert,
-					Consumer: uuid.NewString(),
-					Streams:  []string{persisters.StreamPostInsert, ">"},
-					Block:    0,
-					Count:    10,
-				}).Result()
-				if err != nil {
-					errs <- err
-
-					return
-				}
-
-				for _, stream := range streams {
-					for _, message := range stream.Messages {
-						rawDid, ok := message.Values["did"]
-						if !ok {
-							log.Println(errMessageMissingDID)
-
-							continue
-						}
-
-						did, ok := rawDid.(string)
-						if !ok {
-							log.Println(errMessageInvalidDID)
-
-							continue
-						}
-
-						rawRkey, ok := message.Values["rkey"]
-						if !ok {
-							log.Println(errMessageMissingRkey)
-
-							continue
-						}
-
-						rkey, ok := rawRkey.(string)
-						if !ok {
-							log.Println(errMessageInvalidRkey)
-
-							continue
-						}
-
-						rawCreatedAt, ok := message.Values["createdAt"]
-						if !ok {
-							log.Println(errMessageMissingCreatedAt)
-
-							continue
-						}
-
-						createdAtRFC, ok := rawCreatedAt.(string)
-						if !ok {
-							log.Println(errMessageInvalidCreatedAt)
-
-							continue
-						}
-
-						createdAt, err := time.Parse(time.RFC3339Nano, createdAtRFC)
-						if err != nil {
-							createdAt, err = time.Parse("2006-01-02T15:04:05.999999", createdAtRFC) // For some reason, Bsky sometimes seems to not specify the timezone
-							if err != nil {
-								log.Println(errMessageInvalidCreatedAt)
-
-								continue
-							}
-						}
-
-						rawText, ok := message.Values["text"]
-						if !ok {
-							log.Println(errMessageMissingText)
-
-							continue
-						}
-
-						text, ok := rawText.(string)
-						if !ok {
-							log.Println(errMessageInvalidText)
-
-							continue
-						}
-
-						rawReply, ok := message.Values["reply"]
-						if !ok {
-							log.Println(errMessageMissingReply)
-
-							continue
-						}
-
-						replyValue, ok := rawReply.(string)
-						if !ok {
-							log.Println(errMessageInvalidReply)
-
-							continue
-						}
-
-						reply := replyValue == "true"
-
-						rawLangs, ok := message.Values["langs"]
-						if !ok {
-							log.Println(errMessageMissingLangs)
-
-							continue
-						}
-
-						langsJoined, ok := rawLangs.(string)
-						if !ok {
-							log.Println(errMessageInvalidLangs)
+			defer workerMemberships.Close()
 
-							continue
-						}
-
-						langs := strings.Split(langsJoined, ",")
-
-						post, err := persister.CreatePost(
-							cmd.Context(),
-							did,
-							rkey,
-							createdAt,
-							text,
-							reply,
-							langs,
-						)
-						if err != nil {
-							log.Println("Could not insert post, skipping:", err)
-
-							continue
-						}
-
-						if viper.GetBool(verboseFlag) {
-							log.Println("Created post", post)
-						}
-
-						if err := classify(post); err != nil {
-							log.Println("Could not classify post, skipping:", err)
-
-							continue
-						}
-					}
+			for range workerMemberships.Channel() {
+				if err := w.rebalance(); err != nil {
+					log.Println("Could not rebalance feeds, skipping:", err)
 				}
 			}
 		}()
 
 		go func() {
-			for {
-				streams, err := broker.XReadGroup(cmd.Context(), &redis.XReadGroupArgs{
-					Group:    persisters.StreamPostLike,
-					Consumer: uuid.NewString(),
-					Streams:  []string{persisters.StreamPostLike, ">"},
-					Block:    0,
-					Count:    10,
-				}).Result()
-				if err != nil {
-					errs <- err
-
-					return
-				}
-
-				for _, stream := range streams {
-					for _, message := range stream.Messages {
-						rawDid, ok := message.Values["did"]
-						if !ok {
-							log.Println(errMessageMissingDID)
-
-							continue
-						}
-
-						did, ok := rawDid.(string)
-						if !ok {
-							log.Println(errMessageInvalidDID)
-
-							continue
-						}
-
-						rawRkey, ok := message.Values["rkey"]
-						if !ok {
-							log.Println(errMessageMissingRkey)
-
-							continue
-						}
-
-						rkey, ok := rawRkey.(string)
-						if !ok {
-							log.Println(errMessageInvalidRkey)
-
-							continue
-						}
-
-						post, err := persister.LikePost(
-							cmd.Context(),
-							did,
-							rkey,
-						)
-						if err != nil && !errors.Is(err, sql.ErrNoRows) {
-							log.Println("Could not like post, skipping:", err)
-
-							continue
-						}
-
-						if viper.GetBool(verboseFlag) {
-							log.Println("Liked post", post)
-						}
+			if err := w.consume(persister.GetPostClassifyStream(workerID), w.handlePostClassifies); err != nil {
+				errs <- err
+			}
+		}()
 
-						if err := classify(post); err != nil {
-							log.Println("Could not classify post, skipping:", err)
+		go func() {
+			ticker := time.NewTicker(viper.GetDuration(workerTTLFlag))
+			defer ticker.Stop()
 
-							continue
-						}
-					}
-				}
+			for range ticker.C {
+				w.reclaimDepartedWorkers()
 			}
 		}()
+	}
 
-		for err := range errs {
-			if err == nil {
-				return nil
-			}
+	go func() {
+		if err := w.consume(persisters.StreamPostInsert, w.handlePostInserts); err != nil {
+			errs <- err
+		}
+	}()
 
-			return err
+	go func() {
+		if err := w.consume(persisters.StreamPostLike, w.handlePostLikes); err != nil {
+			errs <- err
 		}
+	}()
 
-		return nil
-	},
+	go func() {
+		if err := w.consume(persisters.StreamPostInteraction, w.handlePostInteractions); err != nil {
+			errs <- err
+		}
+	}()
+
+	for err := range errs {
+		if err == nil {
+			return nil
+		}
+
+		return err
+	}
+
+	return nil
 }
 
-func init() {
+// addWorkerFlags adds the flags of a worker to a command
+func addWorkerFlags(cmd *cobra.Command) {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		panic(err)
 	}
 
-	workerCmd.PersistentFlags().Duration(classifierTimeoutFlag, time.Second, "Amount of time after which to stop a classifier Scale function from running")
-	workerCmd.PersistentFlags().String(workingDirectoryFlag, filepath.Join(home, ".local", "share", "atmosfeed", "var", "lib", "atmosfeed"), "Working directory to use")
+	cmd.PersistentFlags().Duration(classifierTimeoutFlag, time.Second, "Amount of time after which to stop a classifier Scale function from running")
+	cmd.PersistentFlags().Int32(classifierErrorBudgetFlag, 100, "Amount of errors and timeouts after which a feed's classifier is quarantined until a new classifier is uploaded (0 disables quarantining)")
+	cmd.PersistentFlags().Uint64(classifierMaxMemoryFlag, 64*1024*1024, "Maximum amount of memory in bytes that a classifier Scale function can use (0 disables the limit)")
+	cmd.PersistentFlags().Uint64(classifierMaxFuelFlag, 100*1000*1000, "Maximum amount of fuel that a classifier Scale function can use per post, where one unit of fuel is consumed per function call and loop iteration (0 disables the limit)")
+	cmd.PersistentFlags().Int(classifierMaxInstancesPerDIDPerWorkerFlag, 10, "Maximum amount of classifier Scale functions of a single DID that can be loaded on this worker; use --max-feeds on the manager to limit the amount of feeds per DID across all workers (0 disables the limit)")
+	cmd.PersistentFlags().String(workingDirectoryFlag, filepath.Join(home, ".local", "share", "atmosfeed", "var", "lib", "atmosfeed"), "Working directory to use")
+	cmd.PersistentFlags().Bool(shardedFlag, false, "Whether to only run the classifiers of the feeds assigned to this worker by consistent hashing (must be enabled on all workers)")
+	cmd.PersistentFlags().String(workerIDFlag, "", "Unique ID of this worker, which is used to resume unacknowledged messages after a restart and to assign feeds in sharded mode (if left empty, a random ID is generated, and the unacknowledged messages of this worker are claimed by other workers after --pending-timeout)")
+	cmd.PersistentFlags().Duration(workerTTLFlag, time.Second*15, "Amount of time without a heartbeat after which a worker is considered to have left in sharded mode")
+	cmd.PersistentFlags().Int(flushSizeFlag, 500, "Maximum amount of messages to batch into a single database write")
+	cmd.PersistentFlags().Duration(flushIntervalFlag, time.Millisecond*500, "Maximum amount of time to wait for a batch to fill up before writing it to the database")
+	cmd.PersistentFlags().Int(batchMaxAttemptsFlag, 5, "Amount of attempts to handle a batch of messages after which its messages are handled one at a time, and the ones that still fail are moved to a dead-letter stream (0 retries batches forever)")
+	cmd.PersistentFlags().Duration(pendingTimeoutFlag, time.Minute*5, "Amount of time after which messages that were delivered to a worker but never acknowledged, e.g. because it crashed, are claimed by another worker (0 disables claiming)")
+}
+
+func init() {
+	addWorkerFlags(workerCmd)
 
 	viper.AutomaticEnv()
 
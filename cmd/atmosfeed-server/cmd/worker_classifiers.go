@@ -0,0 +1,500 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"signature"
+	"sync"
+
+	"github.com/loopholelabs/scale"
+	"github.com/loopholelabs/scale/scalefunc"
+	"github.com/pojntfx/atmosfeed/pkg/limiters"
+	"github.com/pojntfx/atmosfeed/pkg/models"
+	"github.com/spf13/viper"
+)
+
+type feedPost struct {
+	feedDid  string
+	feedRkey string
+	postDid  string
+	postRkey string
+	weight   int32
+}
+
+// fetchClassifier downloads a feed's classifier and loads it with the configured limits
+func (w *worker) fetchClassifier(did, rkey string) error {
+	classifierSource, err := w.persister.GetFeedClassifier(w.ctx, did, rkey)
+	if err != nil {
+		return err
+	}
+	defer classifierSource.Close()
+
+	classifierPath := filepath.Join(viper.GetString(workingDirectoryFlag), classifiersPath, did, rkey)
+	if err := os.MkdirAll(filepath.Dir(classifierPath), os.ModePerm); err != nil {
+		return err
+	}
+
+	w.classifierLock.Lock()
+	defer w.classifierLock.Unlock()
+
+	f, err := os.OpenFile(classifierPath, os.O_RDWR|os.O_CREATE, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, classifierSource); err != nil {
+		return err
+	}
+
+	fn, err := scalefunc.Read(classifierPath)
+	if err != nil {
+		return err
+	}
+
+	recordLimitViolation := func(violation error) error {
+		if err := w.persister.IncrementFeedClassifierLimitViolations(w.ctx, did, rkey); err != nil {
+			return err
+		}
+
+		return violation
+	}
+
+	// This only counts the classifiers that are loaded by this worker, since sharded workers don't share their classifiers;
+	// the amount of feeds per DID across all workers is limited by the manager's feed quota
+	if maxInstances := viper.GetInt(classifierMaxInstancesPerDIDPerWorkerFlag); maxInstances > 0 {
+		instances := 0
+		for feed := range w.classifiers {
+			if path.Dir(feed) == did && path.Base(feed) != rkey {
+				instances++
+			}
+		}
+
+		if instances >= maxInstances {
+			return recordLimitViolation(fmt.Errorf("%w: DID %v already has %v classifier instances on this worker", errClassifierInstanceLimitExceeded, did, instances))
+		}
+	}
+
+	if maxMemory := viper.GetUint64(classifierMaxMemoryFlag); maxMemory > 0 {
+		fn.Function, err = limiters.LimitMemory(fn.Function, maxMemory)
+		if err != nil {
+			return recordLimitViolation(err)
+		}
+	}
+
+	if maxFuel := viper.GetUint64(classifierMaxFuelFlag); maxFuel > 0 {
+		fn.Function, err = limiters.LimitFuel(fn.Function, maxFuel)
+		if err != nil {
+			return recordLimitViolation(err)
+		}
+	}
+
+	runtime, err := scale.New(scale.NewConfig(signature.New).WithFunction(fn))
+	if err != nil {
+		return err
+	}
+
+	classifier, err := runtime.Instance()
+	if err != nil {
+		return err
+	}
+
+	w.classifiers[path.Join(did, rkey)] = classifier
+
+	return nil
+}
+
+func (w *worker) removeClassifier(did, rkey string) error {
+	w.classifierLock.Lock()
+	defer w.classifierLock.Unlock()
+
+	if err := os.RemoveAll(filepath.Join(viper.GetString(workingDirectoryFlag), classifiersPath, did, rkey)); err != nil {
+		return err
+	}
+
+	delete(w.classifiers, path.Join(did, rkey))
+
+	return nil
+}
+
+func (w *worker) hasClassifier(did, rkey string) bool {
+	w.classifierLock.Lock()
+	defer w.classifierLock.Unlock()
+
+	_, ok := w.classifiers[path.Join(did, rkey)]
+
+	return ok
+}
+
+// loadFeeds adds all feeds that aren't quarantined or suspended and fetches the classifiers of the feeds that this worker owns
+func (w *worker) loadFeeds() error {
+	classifierSources, err := w.persister.GetFeeds(w.ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, classifierSource := range classifierSources {
+		did, rkey := classifierSource.Did, classifierSource.Rkey
+
+		if classifierSource.Quarantined {
+			if viper.GetBool(verboseFlag) {
+				log.Println("Skipping classifier for quarantined feed", did, rkey)
+			}
+
+			continue
+		}
+
+		if classifierSource.Suspended {
+			if viper.GetBool(verboseFlag) {
+				log.Println("Skipping classifier for suspended feed", did, rkey)
+			}
+
+			continue
+		}
+
+		w.shardLock.Lock()
+		w.feeds[path.Join(did, rkey)] = struct{}{}
+		w.shardLock.Unlock()
+
+		if !w.ownsFeed(path.Join(did, rkey)) {
+			continue
+		}
+
+		if err := w.fetchClassifier(did, rkey); err != nil {
+			log.Println("Could not fetch classifier, skipping:", err)
+
+			continue
+		}
+
+		if viper.GetBool(verboseFlag) {
+			log.Println("Fetched classifier for feed", did, rkey)
+		}
+	}
+
+	return nil
+}
+
+// upsertFeed handles a feed upsert message by fetching the feed's new classifier, or by removing it if the feed was quarantined or suspended
+func (w *worker) upsertFeed(payload string) {
+	did, rkey := path.Dir(payload), path.Base(payload)
+
+	feed, err := w.persister.GetFeed(w.ctx, did, rkey)
+	if err != nil {
+		log.Println("Could not get feed, skipping:", err)
+
+		return
+	}
+
+	w.shardLock.Lock()
+	if feed.Quarantined || feed.Suspended {
+		delete(w.feeds, payload)
+	} else {
+		w.feeds[payload] = struct{}{}
+	}
+	w.shardLock.Unlock()
+
+	if feed.Quarantined {
+		if viper.GetBool(verboseFlag) {
+			log.Println("Skipping classifier for quarantined feed", did, rkey)
+		}
+
+		return
+	}
+
+	// Feeds are suspended by the operator while we might already run their classifier
+	if feed.Suspended {
+		if w.hasClassifier(did, rkey) {
+			if err := w.removeClassifier(did, rkey); err != nil {
+				log.Println("Could not remove classifier from disk, skipping:", err)
+
+				return
+			}
+		}
+
+		if viper.GetBool(verboseFlag) {
+			log.Println("Skipping classifier for suspended feed", did, rkey)
+		}
+
+		return
+	}
+
+	if !w.ownsFeed(payload) {
+		if viper.GetBool(verboseFlag) {
+			log.Println("Skipping classifier for feed owned by another worker", did, rkey)
+		}
+
+		return
+	}
+
+	if err := w.fetchClassifier(did, rkey); err != nil {
+		log.Println("Could not fetch classifier, skipping:", err)
+
+		return
+	}
+
+	if viper.GetBool(verboseFlag) {
+		log.Println("Upserted classifier for feed", did, rkey)
+	}
+}
+
+// releaseFeed stops running the classifier of a feed that was deleted or quarantined
+func (w *worker) releaseFeed(payload string) error {
+	w.shardLock.Lock()
+	delete(w.feeds, payload)
+	w.shardLock.Unlock()
+
+	return w.removeClassifier(path.Dir(payload), path.Base(payload))
+}
+
+func (w *worker) recordClassifierFailure(feedDid, feedRkey string, timeout bool) error {
+	var (
+		classifierErrors   int32
+		classifierTimeouts int32
+	)
+	if timeout {
+		counts, err := w.persister.IncrementFeedClassifierTimeouts(w.ctx, feedDid, feedRkey)
+		if err != nil {
+			return err
+		}
+
+		classifierErrors, classifierTimeouts = counts.ClassifierErrors, counts.ClassifierTimeouts
+	} else {
+		counts, err := w.persister.IncrementFeedClassifierErrors(w.ctx, feedDid, feedRkey)
+		if err != nil {
+			return err
+		}
+
+		classifierErrors, classifierTimeouts = counts.ClassifierErrors, counts.ClassifierTimeouts
+	}
+
+	if budget := viper.GetInt32(classifierErrorBudgetFlag); budget > 0 && classifierErrors+classifierTimeouts >= budget {
+		// Failures of a feed that is already quarantined, e.g. from posts that were in flight, don't quarantine it again
+		if _, err := w.persister.QuarantineFeed(w.ctx, feedDid, feedRkey); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// classify runs a post through the classifiers of all feeds and returns the feeds that it belongs to
+func (w *worker) classify(post models.Post, interactions map[string]*signature.Interactions) ([]feedPost, error) {
+	var (
+		errs = make(chan error)
+
+		feedPostsLock sync.Mutex
+		feedPosts     = []feedPost{}
+	)
+
+	w.classifierLock.Lock()
+	defer w.classifierLock.Unlock()
+
+	var wg sync.WaitGroup
+	for feed, c := range w.classifiers {
+		wg.Add(1)
+
+		did, rkey := path.Dir(feed), path.Base(feed)
+
+		go func(feedDid, feedRkey string, c classifier) {
+			defer wg.Done()
+
+			p := signature.NewPost()
+
+			p.Did = post.Did
+			p.Rkey = post.Rkey
+			p.Text = post.Text
+
+			p.Langs = post.Langs
+
+			p.CreatedAt = post.CreatedAt.Unix()
+			p.Likes = int64(post.Likes)
+
+			p.Reply = post.Reply
+
+			// Reposts carry the text, languages and reply status of the reposted post if it is known
+			p.Repost = post.SubjectDid != ""
+			p.SubjectDid = post.SubjectDid
+			p.SubjectRkey = post.SubjectRkey
+
+			s := signature.New()
+			s.Context.Post = p
+
+			// Interactions are counted per feed, so each classifier only sees the feedback that was given in its own feed
+			if i, ok := interactions[path.Join(feedDid, feedRkey, post.Did, post.Rkey)]; ok {
+				s.Context.Interactions = i
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration(classifierTimeoutFlag))
+			defer cancel()
+
+			if err := c.Run(ctx, s); err != nil {
+				// Classifier failures are isolated to their feed so that they can't prevent other feeds from receiving the post
+				timeout := errors.Is(ctx.Err(), context.DeadlineExceeded)
+				if timeout {
+					log.Println("Classifier for feed", feedDid, feedRkey, "timed out, skipping")
+				} else if limiters.IsLimitExceeded(err) {
+					log.Println("Classifier for feed", feedDid, feedRkey, "exceeded its fuel or memory limit, skipping:", err)
+
+					if err := w.persister.IncrementFeedClassifierLimitViolations(w.ctx, feedDid, feedRkey); err != nil {
+						errs <- err
+					}
+				} else {
+					log.Println("Classifier for feed", feedDid, feedRkey, "failed, skipping:", err)
+				}
+
+				if err := w.recordClassifierFailure(feedDid, feedRkey, timeout); err != nil {
+					// We can safely ignore failures to record errors if the feed was deleted in the meantime
+					if !errors.Is(err, sql.ErrNoRows) {
+						errs <- err
+					}
+				}
+
+				return
+			}
+
+			if s.Context.Weight >= 0 {
+				feedPostsLock.Lock()
+				defer feedPostsLock.Unlock()
+
+				feedPosts = append(feedPosts, feedPost{
+					feedDid:  feedDid,
+					feedRkey: feedRkey,
+					postDid:  p.Did,
+					postRkey: p.Rkey,
+					weight:   int32(s.Context.Weight),
+				})
+			}
+		}(did, rkey, c)
+	}
+
+	go func() {
+		wg.Wait()
+
+		close(errs)
+	}()
+
+	var feedErrs []error
+	for err := range errs {
+		if err != nil {
+			feedErrs = append(feedErrs, err)
+		}
+	}
+
+	return feedPosts, errors.Join(feedErrs...)
+}
+
+// getInteractions returns the aggregated interaction counts of a batch of posts for each feed
+func (w *worker) getInteractions(posts []models.Post) (map[string]*signature.Interactions, error) {
+	var (
+		dids  = []string{}
+		rkeys = []string{}
+	)
+	for _, post := range posts {
+		dids = append(dids, post.Did)
+		rkeys = append(rkeys, post.Rkey)
+	}
+
+	counts, err := w.persister.GetInteractionCounts(w.ctx, dids, rkeys)
+	if err != nil {
+		return nil, err
+	}
+
+	interactions := map[string]*signature.Interactions{}
+	for _, count := range counts {
+		key := path.Join(count.FeedDid, count.FeedRkey, count.PostDid, count.PostRkey)
+
+		i, ok := interactions[key]
+		if !ok {
+			i = signature.NewInteractions()
+
+			interactions[key] = i
+		}
+
+		switch count.Event {
+		case interactionRequestMore:
+			i.RequestMore += count.Count
+
+		case interactionRequestLess:
+			i.RequestLess += count.Count
+
+		case interactionSeen:
+			i.Seen += count.Count
+
+		case interactionClickthroughItem, interactionClickthroughAuthor, interactionClickthroughReposter, interactionClickthroughEmbed:
+			i.Clickthroughs += count.Count
+
+		case interactionLike:
+			i.Likes += count.Count
+
+		case interactionRepost:
+			i.Reposts += count.Count
+
+		case interactionReply:
+			i.Replies += count.Count
+
+		case interactionQuote:
+			i.Quotes += count.Count
+
+		case interactionShare:
+			i.Shares += count.Count
+		}
+	}
+
+	return interactions, nil
+}
+
+// classifyPosts classifies a batch of posts and writes the resulting feed posts in a single query
+func (w *worker) classifyPosts(posts []models.Post) error {
+	var (
+		feedDids  = []string{}
+		feedRkeys = []string{}
+		postDids  = []string{}
+		postRkeys = []string{}
+		weights   = []int32{}
+
+		indexes = map[string]int{}
+	)
+
+	interactions, err := w.getInteractions(posts)
+	if err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		feedPosts, err := w.classify(post, interactions)
+		if err != nil {
+			log.Println("Could not classify post, skipping:", err)
+		}
+
+		for _, feedPost := range feedPosts {
+			// Upserting the same feed post twice in one query fails, so we only keep the last weight
+			key := path.Join(feedPost.feedDid, feedPost.feedRkey, feedPost.postDid, feedPost.postRkey)
+			if i, ok := indexes[key]; ok {
+				weights[i] = feedPost.weight
+
+				continue
+			}
+
+			indexes[key] = len(weights)
+
+			feedDids = append(feedDids, feedPost.feedDid)
+			feedRkeys = append(feedRkeys, feedPost.feedRkey)
+			postDids = append(postDids, feedPost.postDid)
+			postRkeys = append(postRkeys, feedPost.postRkey)
+			weights = append(weights, feedPost.weight)
+		}
+	}
+
+	if len(weights) == 0 {
+		return nil
+	}
+
+	return w.persister.UpsertFeedPosts(w.ctx, feedDids, feedRkeys, postDids, postRkeys, weights)
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"signature"
+	"sync"
+	"testing"
+
+	"github.com/pojntfx/atmosfeed/pkg/models"
+	"github.com/pojntfx/atmosfeed/pkg/persisters"
+	"github.com/spf13/viper"
+)
+
+// failureWorkerPersister counts the classifier failures and limit violations that are recorded for each feed
+type failureWorkerPersister struct {
+	*testWorkerPersister
+
+	lock            sync.Mutex
+	errors          map[string]int32
+	limitViolations map[string]int32
+}
+
+func (p *failureWorkerPersister) IncrementFeedClassifierErrors(ctx context.Context, did string, rkey string) (models.IncrementFeedClassifierErrorsRow, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.errors[did+"/"+rkey]++
+
+	return models.IncrementFeedClassifierErrorsRow{ClassifierErrors: p.errors[did+"/"+rkey]}, nil
+}
+
+func (p *failureWorkerPersister) IncrementFeedClassifierLimitViolations(ctx context.Context, did string, rkey string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.limitViolations[did+"/"+rkey]++
+
+	return nil
+}
+
+type failingClassifier struct {
+	err error
+}
+
+func (c failingClassifier) Run(ctx context.Context, s *signature.Signature) error {
+	return c.err
+}
+
+func TestClassifyRecordsLimitViolations(t *testing.T) {
+	broker := persisters.NewMemoryBroker()
+	defer broker.Close()
+
+	persister := &failureWorkerPersister{
+		testWorkerPersister: newTestWorkerPersister(broker),
+
+		errors:          map[string]int32{},
+		limitViolations: map[string]int32{},
+	}
+
+	w := newTestWorker(context.Background(), persister, broker, map[string]classifier{
+		// This is how wazero reports the trap that the limiters use once a classifier runs out of fuel or memory
+		"did:plc:feed/limited": failingClassifier{errors.New("could not run function: wasm error: integer overflow")},
+		"did:plc:feed/failing": failingClassifier{errors.New("could not run function: wasm error: unreachable")},
+		"did:plc:feed/working": testClassifier{keyword: "atmosfeed"},
+	})
+
+	viper.Set(classifierErrorBudgetFlag, 0)
+
+	feedPosts, err := w.classify(models.Post{Did: "did:plc:author", Rkey: "1", Text: "Hello from atmosfeed"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Failing classifiers don't prevent the other feeds from receiving the post
+	if len(feedPosts) != 1 || feedPosts[0].feedRkey != "working" {
+		t.Fatalf("expected feed post for working feed, got %+v", feedPosts)
+	}
+
+	for _, tt := range []struct {
+		feed            string
+		errors          int32
+		limitViolations int32
+	}{
+		{"did:plc:feed/limited", 1, 1},
+		{"did:plc:feed/failing", 1, 0},
+		{"did:plc:feed/working", 0, 0},
+	} {
+		if actual := persister.errors[tt.feed]; actual != tt.errors {
+			t.Fatalf("%v: expected %v errors, got %v", tt.feed, tt.errors, actual)
+		}
+
+		if actual := persister.limitViolations[tt.feed]; actual != tt.limitViolations {
+			t.Fatalf("%v: expected %v limit violations, got %v", tt.feed, tt.limitViolations, actual)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"log"
+	"path"
+
+	"github.com/spf13/viper"
+)
+
+// ownsFeed returns whether this worker runs a feed's classifier, which is every feed unless sharding is enabled
+func (w *worker) ownsFeed(feed string) bool {
+	if !viper.GetBool(shardedFlag) {
+		return true
+	}
+
+	w.shardLock.Lock()
+	defer w.shardLock.Unlock()
+
+	return w.ring.Get(feed) == w.id
+}
+
+// rebalance updates the ring with the current sharded workers and fetches or releases the classifiers of the feeds that moved
+func (w *worker) rebalance() error {
+	w.rebalanceLock.Lock()
+	defer w.rebalanceLock.Unlock()
+
+	workers, err := w.persister.GetWorkers(w.ctx, viper.GetDuration(workerTTLFlag))
+	if err != nil {
+		return err
+	}
+
+	// An empty ring doesn't own any feeds, so keep the previous members instead of releasing all classifiers
+	if len(workers) == 0 {
+		return errNoWorkers
+	}
+
+	ownedFeeds, unownedFeeds := []string{}, []string{}
+
+	w.shardLock.Lock()
+	changed := w.ring.SetMembers(workers)
+	for feed := range w.feeds {
+		if w.ring.Get(feed) == w.id {
+			ownedFeeds = append(ownedFeeds, feed)
+		} else {
+			unownedFeeds = append(unownedFeeds, feed)
+		}
+	}
+	w.shardLock.Unlock()
+
+	if !changed {
+		return nil
+	}
+
+	log.Println("Rebalancing", len(ownedFeeds), "of", len(ownedFeeds)+len(unownedFeeds), "feeds to this worker across", len(workers), "workers")
+
+	for _, feed := range ownedFeeds {
+		did, rkey := path.Dir(feed), path.Base(feed)
+
+		if w.hasClassifier(did, rkey) {
+			continue
+		}
+
+		if err := w.fetchClassifier(did, rkey); err != nil {
+			log.Println("Could not fetch classifier, skipping:", err)
+
+			continue
+		}
+
+		if viper.GetBool(verboseFlag) {
+			log.Println("Fetched classifier for feed", did, rkey)
+		}
+	}
+
+	for _, feed := range unownedFeeds {
+		did, rkey := path.Dir(feed), path.Base(feed)
+
+		if !w.hasClassifier(did, rkey) {
+			continue
+		}
+
+		if err := w.removeClassifier(did, rkey); err != nil {
+			log.Println("Could not remove classifier from disk, skipping:", err)
+
+			continue
+		}
+
+		if viper.GetBool(verboseFlag) {
+			log.Println("Released classifier for feed", did, rkey)
+		}
+	}
+
+	return nil
+}
+
+// forward queues posts for classification on every worker that owns at least one feed
+func (w *worker) forward(dids, rkeys []string) error {
+	if len(dids) == 0 {
+		return nil
+	}
+
+	owners := []string{}
+	seen := map[string]struct{}{}
+
+	w.shardLock.Lock()
+	for feed := range w.feeds {
+		owner := w.ring.Get(feed)
+		if owner == "" {
+			w.shardLock.Unlock()
+
+			// Returning an error keeps the posts in their stream so that they are retried once workers have joined
+			return errNoWorkers
+		}
+
+		if _, ok := seen[owner]; ok {
+			continue
+		}
+
+		seen[owner] = struct{}{}
+		owners = append(owners, owner)
+	}
+	w.shardLock.Unlock()
+
+	return w.persister.PublishPostsClassify(w.ctx, owners, dids, rkeys)
+}
+
+// reclaimDepartedWorkers forwards the unclassified posts of workers that stopped sending heartbeats to the new owners of their feeds
+func (w *worker) reclaimDepartedWorkers() {
+	departedWorkers, err := w.persister.GetDepartedWorkers(w.ctx, viper.GetDuration(workerTTLFlag))
+	if err != nil {
+		log.Println("Could not get departed workers, retrying:", err)
+
+		return
+	}
+
+	for _, departedWorker := range departedWorkers {
+		if departedWorker == w.id {
+			continue
+		}
+
+		// Only one worker receives the departed worker's posts, which it then forwards to their new owners
+		messages, err := w.persister.ReclaimWorker(w.ctx, departedWorker)
+		if err != nil {
+			log.Println("Could not reclaim departed worker, retrying:", err)
+
+			continue
+		}
+
+		dids, rkeys := parsePostClassifyMessages(messages)
+		if err := w.forward(dids, rkeys); err != nil {
+			log.Println("Could not forward reclaimed posts, skipping:", err)
+
+			continue
+		}
+
+		log.Println("Reclaimed", len(dids), "posts from departed worker", departedWorker)
+	}
+}
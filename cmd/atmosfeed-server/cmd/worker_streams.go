@@ -0,0 +1,547 @@
+package cmd
+
+import (
+	"errors"
+	"log"
+	"path"
+	"time"
+
+	"github.com/pojntfx/atmosfeed/pkg/models"
+	"github.com/pojntfx/atmosfeed/pkg/persisters"
+	"github.com/spf13/viper"
+)
+
+// parsePostClassifyMessages returns the DIDs and rkeys of the posts in post classify messages, skipping invalid messages
+func parsePostClassifyMessages(messages []persisters.StreamMessage) ([]string, []string) {
+	var (
+		dids  = []string{}
+		rkeys = []string{}
+	)
+	for _, message := range messages {
+		rawDid, ok := message.Values["did"]
+		if !ok {
+			log.Println(errMessageMissingDID)
+
+			continue
+		}
+
+		did, ok := rawDid.(string)
+		if !ok {
+			log.Println(errMessageInvalidDID)
+
+			continue
+		}
+
+		rawRkey, ok := message.Values["rkey"]
+		if !ok {
+			log.Println(errMessageMissingRkey)
+
+			continue
+		}
+
+		rkey, ok := rawRkey.(string)
+		if !ok {
+			log.Println(errMessageInvalidRkey)
+
+			continue
+		}
+
+		dids = append(dids, did)
+		rkeys = append(rkeys, rkey)
+	}
+
+	return dids, rkeys
+}
+
+// dispatch classifies posts locally, or forwards them to every worker that owns at least one feed if sharding is enabled
+func (w *worker) dispatch(posts []models.Post) error {
+	if !viper.GetBool(shardedFlag) {
+		return w.classifyPosts(posts)
+	}
+
+	dids, rkeys := []string{}, []string{}
+	for _, post := range posts {
+		dids = append(dids, post.Did)
+		rkeys = append(rkeys, post.Rkey)
+	}
+
+	return w.forward(dids, rkeys)
+}
+
+// consume reads batches of messages from a stream's consumer group and acknowledges them after they have been handled
+func (w *worker) consume(stream string, handle func(messages []persisters.StreamMessage) error) error {
+	// We start with the messages that were delivered to this worker ID but never acknowledged, e.g. because of a crash
+	id := "0"
+
+	var (
+		retryDelay     = w.minRetryDelay
+		failedAttempts = 0
+		claimedAt      = time.Now()
+	)
+l:
+	for {
+		var (
+			messages        = []persisters.StreamMessage{}
+			firstReceivedAt time.Time
+			pendingTimeout  = viper.GetDuration(pendingTimeoutFlag)
+		)
+
+		// Messages that were delivered to a worker that never came back, e.g. because it had a random ID, would stay pending forever otherwise
+		if pendingTimeout > 0 && id == persisters.StreamIDNew && time.Since(claimedAt) >= pendingTimeout/2 {
+			claimedAt = time.Now()
+
+			claimed, err := w.broker.ClaimPendingStream(w.ctx, stream, w.id, pendingTimeout, int64(viper.GetInt(flushSizeFlag)))
+			if err != nil {
+				if errors.Is(err, persisters.ErrStreamNotFound) {
+					if err := w.recreateStream(stream); err != nil {
+						return err
+					}
+
+					id = "0"
+
+					continue l
+				}
+
+				return err
+			}
+
+			if len(claimed) > 0 {
+				log.Println("Claimed", len(claimed), "pending messages from", stream)
+
+				messages = claimed
+				firstReceivedAt = time.Now()
+			}
+		}
+
+		for len(messages) < viper.GetInt(flushSizeFlag) {
+			block := time.Duration(0) // Block until the first message arrives
+			if len(messages) > 0 {
+				block = viper.GetDuration(flushIntervalFlag) - time.Since(firstReceivedAt)
+				if block < time.Millisecond {
+					break
+				}
+			} else if pendingTimeout > 0 {
+				// ... or until it is time to claim pending messages again
+				block = max(pendingTimeout/2-time.Since(claimedAt), time.Millisecond)
+			}
+
+			received, err := w.broker.ReadStream(
+				w.ctx,
+				stream,
+				w.id,
+				id,
+				int64(viper.GetInt(flushSizeFlag)-len(messages)),
+				block,
+			)
+			if err != nil {
+				if errors.Is(err, persisters.ErrStreamNotFound) {
+					// The messages that were already read were deleted together with the stream, e.g. by a worker that reclaimed them, so we drop them
+					if err := w.recreateStream(stream); err != nil {
+						return err
+					}
+
+					id = "0"
+
+					continue l
+				}
+
+				return err
+			}
+
+			if id != persisters.StreamIDNew {
+				if len(received) == 0 {
+					id = persisters.StreamIDNew
+				} else {
+					id = received[len(received)-1].ID
+				}
+			} else if len(received) == 0 {
+				// The flush interval was reached before the batch was full
+				break
+			}
+
+			if len(received) > 0 && len(messages) == 0 {
+				firstReceivedAt = time.Now()
+			}
+
+			messages = append(messages, received...)
+		}
+
+		if len(messages) == 0 {
+			continue
+		}
+
+		if err := handle(messages); err != nil {
+			failedAttempts++
+
+			if maxAttempts := viper.GetInt(batchMaxAttemptsFlag); maxAttempts <= 0 || failedAttempts < maxAttempts {
+				log.Println("Could not handle batch, retrying in", retryDelay, ":", err)
+
+				time.Sleep(retryDelay)
+				retryDelay = min(retryDelay*2, w.maxRetryDelay)
+
+				// The messages of the failed batch are still pending, so we read them again before reading new messages
+				id = "0"
+
+				continue
+			}
+
+			// A single message that can't be handled, e.g. because its text contains a NUL byte, would otherwise block the stream forever
+			log.Println("Could not handle batch after", failedAttempts, "attempts, handling its messages one at a time:", err)
+
+			if err := w.handleIndividually(stream, messages, handle); err != nil {
+				return err
+			}
+		}
+
+		failedAttempts = 0
+		retryDelay = w.minRetryDelay
+
+		ids := []string{}
+		for _, message := range messages {
+			ids = append(ids, message.ID)
+		}
+
+		if err := w.broker.AcknowledgeStream(w.ctx, stream, ids...); err != nil {
+			return err
+		}
+
+		if viper.GetBool(verboseFlag) {
+			log.Println("Flushed batch of", len(messages), "messages from", stream)
+		}
+	}
+}
+
+// recreateStream recreates a stream and its consumer group after they were deleted, e.g. because the other workers reclaimed
+// this worker's post classify stream after it missed its heartbeats, and registers this worker again if that was the case
+func (w *worker) recreateStream(stream string) error {
+	log.Println("Stream", stream, "was deleted, recreating it")
+
+	if viper.GetBool(shardedFlag) && stream == w.persister.GetPostClassifyStream(w.id) {
+		return w.persister.RegisterWorker(w.ctx, w.id)
+	}
+
+	return w.broker.CreateStream(w.ctx, stream)
+}
+
+// handleIndividually handles the messages of a batch that failed one at a time and moves the ones that still fail to the stream's dead-letter stream
+func (w *worker) handleIndividually(stream string, messages []persisters.StreamMessage, handle func(messages []persisters.StreamMessage) error) error {
+	deadLetters := 0
+	for _, message := range messages {
+		if err := handle([]persisters.StreamMessage{message}); err != nil {
+			log.Println("Could not handle message", message.ID, "from", stream, "moving it to", w.persister.GetDeadLetterStream(stream), ":", err)
+
+			if err := w.persister.DeadLetterMessage(w.ctx, stream, message, err); err != nil {
+				return err
+			}
+
+			deadLetters++
+		}
+	}
+
+	log.Println("Handled", len(messages)-deadLetters, "of", len(messages), "messages from", stream, "one at a time")
+
+	return nil
+}
+
+// handlePostInserts creates the posts in a batch of post insert messages and dispatches the new ones for classification
+func (w *worker) handlePostInserts(messages []persisters.StreamMessage) error {
+	var (
+		dids       = []string{}
+		rkeys      = []string{}
+		createdAts = []time.Time{}
+		texts      = []string{}
+		replies    = []bool{}
+		langs      = []string{}
+
+		subjectDids  = []string{}
+		subjectRkeys = []string{}
+	)
+	for _, message := range messages {
+		rawDid, ok := message.Values["did"]
+		if !ok {
+			log.Println(errMessageMissingDID)
+
+			continue
+		}
+
+		did, ok := rawDid.(string)
+		if !ok {
+			log.Println(errMessageInvalidDID)
+
+			continue
+		}
+
+		rawRkey, ok := message.Values["rkey"]
+		if !ok {
+			log.Println(errMessageMissingRkey)
+
+			continue
+		}
+
+		rkey, ok := rawRkey.(string)
+		if !ok {
+			log.Println(errMessageInvalidRkey)
+
+			continue
+		}
+
+		rawCreatedAt, ok := message.Values["createdAt"]
+		if !ok {
+			log.Println(errMessageMissingCreatedAt)
+
+			continue
+		}
+
+		createdAtRFC, ok := rawCreatedAt.(string)
+		if !ok {
+			log.Println(errMessageInvalidCreatedAt)
+
+			continue
+		}
+
+		createdAt, err := time.Parse(time.RFC3339Nano, createdAtRFC)
+		if err != nil {
+			createdAt, err = time.Parse("2006-01-02T15:04:05.999999", createdAtRFC) // For some reason, Bsky sometimes seems to not specify the timezone
+			if err != nil {
+				log.Println(errMessageInvalidCreatedAt)
+
+				continue
+			}
+		}
+
+		rawText, ok := message.Values["text"]
+		if !ok {
+			log.Println(errMessageMissingText)
+
+			continue
+		}
+
+		text, ok := rawText.(string)
+		if !ok {
+			log.Println(errMessageInvalidText)
+
+			continue
+		}
+
+		rawReply, ok := message.Values["reply"]
+		if !ok {
+			log.Println(errMessageMissingReply)
+
+			continue
+		}
+
+		replyValue, ok := rawReply.(string)
+		if !ok {
+			log.Println(errMessageInvalidReply)
+
+			continue
+		}
+
+		reply := replyValue == "true"
+
+		rawLangs, ok := message.Values["langs"]
+		if !ok {
+			log.Println(errMessageMissingLangs)
+
+			continue
+		}
+
+		langsJoined, ok := rawLangs.(string)
+		if !ok {
+			log.Println(errMessageInvalidLangs)
+
+			continue
+		}
+
+		// Only reposts have a subject, and messages that were published before reposts were supported don't contain it at all
+		subjectDid, _ := message.Values["subjectDid"].(string)
+		subjectRkey, _ := message.Values["subjectRkey"].(string)
+
+		dids = append(dids, did)
+		rkeys = append(rkeys, rkey)
+		createdAts = append(createdAts, createdAt)
+		texts = append(texts, text)
+		replies = append(replies, reply)
+		langs = append(langs, langsJoined)
+
+		subjectDids = append(subjectDids, subjectDid)
+		subjectRkeys = append(subjectRkeys, subjectRkey)
+	}
+
+	// Posts that already exist are not returned
+	posts, err := w.persister.CreatePosts(
+		w.ctx,
+		dids,
+		rkeys,
+		createdAts,
+		texts,
+		replies,
+		langs,
+		subjectDids,
+		subjectRkeys,
+	)
+	if err != nil {
+		return err
+	}
+
+	if viper.GetBool(verboseFlag) {
+		log.Println("Created", len(posts), "posts")
+	}
+
+	return w.dispatch(posts)
+}
+
+// handlePostLikes adds the likes in a batch of post like messages and dispatches the liked posts for reclassification
+func (w *worker) handlePostLikes(messages []persisters.StreamMessage) error {
+	var (
+		dids  = []string{}
+		rkeys = []string{}
+		likes = []int32{}
+
+		indexes = map[string]int{}
+	)
+	for _, message := range messages {
+		rawDid, ok := message.Values["did"]
+		if !ok {
+			log.Println(errMessageMissingDID)
+
+			continue
+		}
+
+		did, ok := rawDid.(string)
+		if !ok {
+			log.Println(errMessageInvalidDID)
+
+			continue
+		}
+
+		rawRkey, ok := message.Values["rkey"]
+		if !ok {
+			log.Println(errMessageMissingRkey)
+
+			continue
+		}
+
+		rkey, ok := rawRkey.(string)
+		if !ok {
+			log.Println(errMessageInvalidRkey)
+
+			continue
+		}
+
+		// A post can only be updated once per query, so we add up the likes in the batch
+		if i, ok := indexes[path.Join(did, rkey)]; ok {
+			likes[i]++
+
+			continue
+		}
+
+		indexes[path.Join(did, rkey)] = len(likes)
+
+		dids = append(dids, did)
+		rkeys = append(rkeys, rkey)
+		likes = append(likes, 1)
+	}
+
+	// Likes for posts that we don't know about are ignored
+	posts, err := w.persister.LikePosts(
+		w.ctx,
+		dids,
+		rkeys,
+		likes,
+	)
+	if err != nil {
+		return err
+	}
+
+	if viper.GetBool(verboseFlag) {
+		log.Println("Liked", len(posts), "posts")
+	}
+
+	return w.dispatch(posts)
+}
+
+// handlePostInteractions dispatches the posts in a batch of post interaction messages for reclassification
+func (w *worker) handlePostInteractions(messages []persisters.StreamMessage) error {
+	var (
+		dids  = []string{}
+		rkeys = []string{}
+
+		seen = map[string]struct{}{}
+	)
+	for _, message := range messages {
+		rawDid, ok := message.Values["did"]
+		if !ok {
+			log.Println(errMessageMissingDID)
+
+			continue
+		}
+
+		did, ok := rawDid.(string)
+		if !ok {
+			log.Println(errMessageInvalidDID)
+
+			continue
+		}
+
+		rawRkey, ok := message.Values["rkey"]
+		if !ok {
+			log.Println(errMessageMissingRkey)
+
+			continue
+		}
+
+		rkey, ok := rawRkey.(string)
+		if !ok {
+			log.Println(errMessageInvalidRkey)
+
+			continue
+		}
+
+		// A post only needs to be reclassified once per batch, no matter how many interactions it received
+		if _, ok := seen[path.Join(did, rkey)]; ok {
+			continue
+		}
+
+		seen[path.Join(did, rkey)] = struct{}{}
+
+		dids = append(dids, did)
+		rkeys = append(rkeys, rkey)
+	}
+
+	// Posts that were deleted in the meantime are not returned
+	posts, err := w.persister.GetPosts(w.ctx, dids, rkeys)
+	if err != nil {
+		return err
+	}
+
+	if viper.GetBool(verboseFlag) {
+		log.Println("Reclassifying", len(posts), "posts with new interactions")
+	}
+
+	return w.dispatch(posts)
+}
+
+// handlePostClassifies classifies the posts that other sharded workers forwarded to this worker
+func (w *worker) handlePostClassifies(messages []persisters.StreamMessage) error {
+	dids, rkeys := parsePostClassifyMessages(messages)
+
+	// Posts that were deleted before they could be classified are not returned
+	posts, err := w.persister.GetPosts(w.ctx, dids, rkeys)
+	if err != nil {
+		return err
+	}
+
+	uniquePosts := []models.Post{}
+	seen := map[string]struct{}{}
+	for _, post := range posts {
+		if _, ok := seen[path.Join(post.Did, post.Rkey)]; ok {
+			continue
+		}
+
+		seen[path.Join(post.Did, post.Rkey)] = struct{}{}
+		uniquePosts = append(uniquePosts, post)
+	}
+
+	return w.classifyPosts(uniquePosts)
+}
@@ -0,0 +1,339 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"signature"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pojntfx/atmosfeed/pkg/hashrings"
+	"github.com/pojntfx/atmosfeed/pkg/models"
+	"github.com/pojntfx/atmosfeed/pkg/persisters"
+	"github.com/spf13/viper"
+)
+
+func TestParsePostClassifyMessages(t *testing.T) {
+	dids, rkeys := parsePostClassifyMessages([]persisters.StreamMessage{
+		{ID: "1-0", Values: map[string]interface{}{"did": "did:plc:a", "rkey": "1"}},
+		{ID: "2-0", Values: map[string]interface{}{"rkey": "2"}},
+		{ID: "3-0", Values: map[string]interface{}{"did": "did:plc:c"}},
+		{ID: "4-0", Values: map[string]interface{}{"did": 4, "rkey": "4"}},
+		{ID: "5-0", Values: map[string]interface{}{"did": "did:plc:e", "rkey": 5}},
+		{ID: "6-0", Values: map[string]interface{}{"did": "did:plc:f", "rkey": "6"}},
+	})
+
+	if expected := []string{"did:plc:a", "did:plc:f"}; !slices.Equal(dids, expected) {
+		t.Fatalf("expected DIDs %v, got %v", expected, dids)
+	}
+
+	if expected := []string{"1", "6"}; !slices.Equal(rkeys, expected) {
+		t.Fatalf("expected rkeys %v, got %v", expected, rkeys)
+	}
+}
+
+var errTestInvalidText = errors.New("invalid byte sequence for encoding \"UTF8\": 0x00")
+
+// testWorkerPersister keeps posts in memory and records feed posts instead of writing them to the database;
+// all other methods are handled by the embedded persister, which only works for the methods that don't need a database
+type testWorkerPersister struct {
+	workerPersister
+
+	feedPosts chan feedPost
+}
+
+func newTestWorkerPersister(broker persisters.Broker) *testWorkerPersister {
+	return &testWorkerPersister{
+		workerPersister: persisters.NewWorkerPersister("", broker, nil, ""),
+
+		feedPosts: make(chan feedPost),
+	}
+}
+
+func (p *testWorkerPersister) CreatePosts(ctx context.Context, dids []string, rkeys []string, createdAts []time.Time, texts []string, replies []bool, langs []string, subjectDids []string, subjectRkeys []string) ([]models.Post, error) {
+	posts := []models.Post{}
+	for i := range dids {
+		// Like PostgreSQL, we reject text columns with NUL bytes
+		if strings.ContainsRune(texts[i], 0) {
+			return nil, errTestInvalidText
+		}
+
+		posts = append(posts, models.Post{
+			Did:         dids[i],
+			Rkey:        rkeys[i],
+			CreatedAt:   createdAts[i],
+			Text:        texts[i],
+			Reply:       replies[i],
+			Langs:       strings.Split(langs[i], ","),
+			SubjectDid:  subjectDids[i],
+			SubjectRkey: subjectRkeys[i],
+		})
+	}
+
+	return posts, nil
+}
+
+func (p *testWorkerPersister) GetInteractionCounts(ctx context.Context, postDids []string, postRkeys []string) ([]models.GetInteractionCountsRow, error) {
+	return []models.GetInteractionCountsRow{}, nil
+}
+
+func (p *testWorkerPersister) UpsertFeedPosts(ctx context.Context, feedDids []string, feedRkeys []string, postDids []string, postRkeys []string, weights []int32) error {
+	for i := range feedDids {
+		p.feedPosts <- feedPost{
+			feedDid:  feedDids[i],
+			feedRkey: feedRkeys[i],
+			postDid:  postDids[i],
+			postRkey: postRkeys[i],
+			weight:   weights[i],
+		}
+	}
+
+	return nil
+}
+
+// testClassifier adds posts that mention a keyword to its feed
+type testClassifier struct {
+	keyword string
+}
+
+func (c testClassifier) Run(ctx context.Context, s *signature.Signature) error {
+	s.Context.Weight = -1
+	if strings.Contains(s.Context.Post.Text, c.keyword) {
+		s.Context.Weight = 1
+	}
+
+	return nil
+}
+
+func newTestWorker(ctx context.Context, persister workerPersister, broker persisters.Broker, classifiers map[string]classifier) *worker {
+	viper.Set(shardedFlag, false)
+	viper.Set(flushSizeFlag, 10)
+	viper.Set(flushIntervalFlag, 10*time.Millisecond)
+	viper.Set(classifierTimeoutFlag, time.Second)
+	viper.Set(batchMaxAttemptsFlag, 2)
+	viper.Set(pendingTimeoutFlag, 0)
+
+	return &worker{
+		ctx: ctx,
+		id:  "test-worker",
+
+		persister: persister,
+		broker:    broker,
+
+		ring:  hashrings.NewHashRing(hashrings.DefaultReplicas),
+		feeds: map[string]struct{}{},
+
+		classifiers: classifiers,
+
+		minRetryDelay: time.Millisecond,
+		maxRetryDelay: time.Millisecond,
+	}
+}
+
+func newTestPostInsert(rkey, text string) map[string]interface{} {
+	return map[string]interface{}{
+		"did":       "did:plc:author",
+		"rkey":      rkey,
+		"createdAt": "2023-10-01T12:00:00.000Z",
+		"text":      text,
+		"reply":     "false",
+		"langs":     "en",
+	}
+}
+
+func TestWorkerClassifiesInsertedPosts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	broker := persisters.NewMemoryBroker()
+	defer broker.Close()
+
+	if err := broker.CreateStream(ctx, persisters.StreamPostInsert); err != nil {
+		t.Fatal(err)
+	}
+
+	persister := newTestWorkerPersister(broker)
+
+	w := newTestWorker(ctx, persister, broker, map[string]classifier{
+		"did:plc:feed/atmosfeed": testClassifier{keyword: "atmosfeed"},
+	})
+
+	for _, values := range []map[string]interface{}{
+		newTestPostInsert("1", "Hello from atmosfeed"),
+		newTestPostInsert("2", "Hello from elsewhere"),
+	} {
+		if err := broker.AppendToStream(ctx, persisters.StreamPostInsert, 0, values); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- w.consume(persisters.StreamPostInsert, w.handlePostInserts)
+	}()
+
+	select {
+	case fp := <-persister.feedPosts:
+		expected := feedPost{feedDid: "did:plc:feed", feedRkey: "atmosfeed", postDid: "did:plc:author", postRkey: "1", weight: 1}
+		if fp != expected {
+			t.Fatalf("expected %+v, got %+v", expected, fp)
+		}
+
+	case err := <-errs:
+		t.Fatalf("expected feed post, got %v", err)
+
+	case <-time.After(10 * time.Second):
+		t.Fatal("expected feed post, got none")
+	}
+
+	cancel()
+
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+}
+
+func TestWorkerMovesPoisonedMessagesToDeadLetterStream(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	broker := persisters.NewMemoryBroker()
+	defer broker.Close()
+
+	if err := broker.CreateStream(ctx, persisters.StreamPostInsert); err != nil {
+		t.Fatal(err)
+	}
+
+	persister := newTestWorkerPersister(broker)
+
+	w := newTestWorker(ctx, persister, broker, map[string]classifier{
+		"did:plc:feed/atmosfeed": testClassifier{keyword: "atmosfeed"},
+	})
+
+	for _, values := range []map[string]interface{}{
+		newTestPostInsert("1", "First post about atmosfeed"),
+		newTestPostInsert("2", "Poisoned post about atmosfeed\x00"),
+		newTestPostInsert("3", "Third post about atmosfeed"),
+	} {
+		if err := broker.AppendToStream(ctx, persisters.StreamPostInsert, 0, values); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- w.consume(persisters.StreamPostInsert, w.handlePostInserts)
+	}()
+
+	// The batch fails as a whole, so the other posts are only classified once the messages are handled one at a time
+	rkeys := []string{}
+	for len(rkeys) < 2 {
+		select {
+		case fp := <-persister.feedPosts:
+			rkeys = append(rkeys, fp.postRkey)
+
+		case err := <-errs:
+			t.Fatalf("expected feed posts, got %v", err)
+
+		case <-time.After(10 * time.Second):
+			t.Fatalf("expected feed posts, got %v", rkeys)
+		}
+	}
+
+	if expected := []string{"1", "3"}; !slices.Equal(rkeys, expected) {
+		t.Fatalf("expected feed posts for %v, got %v", expected, rkeys)
+	}
+
+	deadLetterStream := persister.GetDeadLetterStream(persisters.StreamPostInsert)
+	if err := broker.CreateStream(ctx, deadLetterStream); err != nil {
+		t.Fatal(err)
+	}
+
+	deadLetters, err := broker.ReadStream(ctx, deadLetterStream, "test", persisters.StreamIDNew, 10, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deadLetters) != 1 {
+		t.Fatalf("expected 1 dead letter, got %v", len(deadLetters))
+	}
+
+	if rkey := deadLetters[0].Values["rkey"]; rkey != "2" {
+		t.Fatalf("expected dead letter for post 2, got %v", rkey)
+	}
+
+	if reason := deadLetters[0].Values["error"]; reason != errTestInvalidText.Error() {
+		t.Fatalf("expected dead letter with reason %v, got %v", errTestInvalidText, reason)
+	}
+
+	cancel()
+
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+
+	// All messages were acknowledged, so none of them are handled again after a restart
+	pending, err := broker.ReadStream(context.Background(), persisters.StreamPostInsert, w.id, "0", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pending) != 0 {
+		t.Fatalf("expected no pending messages, got %v", len(pending))
+	}
+}
+
+func TestWorkerRecreatesDeletedStream(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	broker := persisters.NewMemoryBroker()
+	defer broker.Close()
+
+	if err := broker.CreateStream(ctx, persisters.StreamPostInsert); err != nil {
+		t.Fatal(err)
+	}
+
+	persister := newTestWorkerPersister(broker)
+
+	w := newTestWorker(ctx, persister, broker, map[string]classifier{
+		"did:plc:feed/atmosfeed": testClassifier{keyword: "atmosfeed"},
+	})
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- w.consume(persisters.StreamPostInsert, w.handlePostInserts)
+	}()
+
+	// Another worker reclaims the stream while this worker is waiting for messages, which deletes it together with its consumer group
+	time.Sleep(10 * time.Millisecond)
+
+	if _, err := broker.ClaimStream(ctx, persisters.StreamPostInsert); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := broker.AppendToStream(ctx, persisters.StreamPostInsert, 0, newTestPostInsert("1", "Hello from atmosfeed")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case fp := <-persister.feedPosts:
+		if fp.postRkey != "1" {
+			t.Fatalf("expected feed post for post 1, got %+v", fp)
+		}
+
+	case err := <-errs:
+		t.Fatalf("expected feed post, got %v", err)
+
+	case <-time.After(10 * time.Second):
+		t.Fatal("expected feed post, got none")
+	}
+
+	cancel()
+
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+}
@@ -1,7 +1,30 @@
 export interface IFeedMetadata {
+  did: string;
   rkey: string;
-  pinnedDID: string;
-  pinnedRkey: string;
+  role: string;
+  pins: IFeedPin[];
+  classifierErrors: number;
+  classifierTimeouts: number;
+  quarantined: boolean;
+  classifierLimitViolations: number;
+  retention: number;
+  ranking: string;
+  halfLife: number;
+  ttl: number;
+  maxPageSize: number;
+  defaultPageSize: number;
+  hideViewerPosts: boolean;
+  hideBlockedPosts: boolean;
+  reranker: boolean;
+  suspended: boolean;
+}
+
+export interface IFeedPin {
+  postDID: string;
+  postRkey: string;
+  position: number;
+  startsAt?: string;
+  endsAt?: string;
 }
 
 export interface IFeed {
@@ -15,11 +38,17 @@ export interface IStructuredUserdata {
   feeds?: IStructuredUserdataFeed[];
   posts?: IStructuredUserdataPost[];
   feedPosts?: IStructuredUserdataFeedPost[];
+  feedPins?: IStructuredUserdataFeedPin[];
+  blocks?: IStructuredUserdataBlock[];
+  interactions?: IStructuredUserdataInteraction[];
+  collaborators?: IStructuredUserdataFeedCollaborator[];
+  auditEvents?: IStructuredUserdataAuditEvent[];
 }
 
 export interface IStructuredUserdataFeed {
   did: string;
   rkey: string;
+  reranker: boolean;
 }
 
 export interface IStructuredUserdataPost {
@@ -30,6 +59,8 @@ export interface IStructuredUserdataPost {
   reply: boolean;
   langs: string[];
   likes: number;
+  subjectDID: string;
+  subjectRkey: string;
 }
 
 export interface IStructuredUserdataFeedPost {
@@ -39,3 +70,55 @@ export interface IStructuredUserdataFeedPost {
   postRkey: string;
   weight: number;
 }
+
+export interface IStructuredUserdataFeedPin {
+  feedDID: string;
+  feedRkey: string;
+  postDID: string;
+  postRkey: string;
+  position: number;
+  startsAt?: string;
+  endsAt?: string;
+}
+
+export interface IStructuredUserdataBlock {
+  did: string;
+  rkey: string;
+  subject: string;
+}
+
+export interface IStructuredUserdataInteraction {
+  feedDID: string;
+  feedRkey: string;
+  postDID: string;
+  postRkey: string;
+  did: string;
+  event: string;
+  createdAt: string;
+}
+
+export interface IStructuredUserdataFeedCollaborator {
+  feedDID: string;
+  feedRkey: string;
+  did: string;
+  role: string;
+}
+
+export interface IStructuredUserdataAuditEvent {
+  id: number;
+  did: string;
+  action: string;
+  feedDID: string;
+  feedRkey: string;
+  classifierHash: string;
+  origin: string;
+  createdAt: string;
+}
+
+export interface IQuotaExceededError {
+  error: "QuotaExceeded";
+  message: string;
+  quota: "feeds" | "classifierSize" | "storage";
+  limit: number;
+  usage: number;
+}
@@ -1,5 +1,10 @@
 import { AtUri, BskyAgent } from "@atproto/api";
-import { IFeed, IFeedMetadata, IStructuredUserdata } from "./models";
+import {
+  IFeed,
+  IFeedMetadata,
+  IQuotaExceededError,
+  IStructuredUserdata,
+} from "./models";
 
 const lexiconFeedGenerator = "app.bsky.feed.generator";
 
@@ -39,14 +44,21 @@ export class RestAPI {
 
     return atmosfeedFeeds.reduce(
       (acc, v) => {
+        // Feeds that are shared with us are managed through the CLI
+        if (v.did !== this.did) {
+          return acc;
+        }
+
         const bskyFeed = bskyFeeds.data.feeds.find(
           (f) => new AtUri(f.uri).rkey === v.rkey
         );
 
+        // The UI only supports editing a single pinned post
         let pinnedPost: string | undefined;
-        if (v.pinnedDID && v.pinnedRkey) {
+        const pin = v.pins?.[0];
+        if (pin) {
           pinnedPost = new URL(
-            `https://bsky.app/profile/${v.pinnedDID}/post/${v.pinnedRkey}`
+            `https://bsky.app/profile/${pin.postDID}/post/${pin.postRkey}`
           ).toString();
         }
 
@@ -108,7 +120,7 @@ export class RestAPI {
       service: this.service,
     }).toString();
 
-    await fetch(atmosfeedURL.toString(), {
+    const res = await fetch(atmosfeedURL.toString(), {
       method: "PUT",
       body: classifier,
       headers: {
@@ -117,6 +129,18 @@ export class RestAPI {
       },
     });
 
+    if (!res.ok) {
+      const quotaErr = (await res
+        .json()
+        .catch(() => undefined)) as IQuotaExceededError | undefined;
+
+      throw new Error(
+        quotaErr?.error === "QuotaExceeded"
+          ? quotaErr.message
+          : `could not apply feed on Atmosfeed: ${res.status} ${res.statusText}`
+      );
+    }
+
     await this.patchFeed(rkey, pinnedDID, pinnedRkey);
   }
 
@@ -235,7 +259,22 @@ export class RestAPI {
     }
   }
 
-  async deleteUserdata() {
+  async logout() {
+    const atmosfeedURL = new URL(this.apiURL + "admin/session");
+
+    atmosfeedURL.search = new URLSearchParams({
+      service: this.service,
+    }).toString();
+
+    await fetch(atmosfeedURL.toString(), {
+      method: "DELETE",
+      headers: {
+        Authorization: "Bearer " + this.accessJWT,
+      },
+    });
+  }
+
+    async deleteUserdata() {
     const atmosfeedURL = new URL(this.apiURL + "userdata");
 
     atmosfeedURL.search = new URLSearchParams({
@@ -283,4 +322,22 @@ export class RestAPI {
       })
     ).blob();
   }
+
+  async exportReranker(rkey: string): Promise<Blob> {
+    const atmosfeedURL = new URL(this.apiURL + "userdata/blob");
+
+    atmosfeedURL.search = new URLSearchParams({
+      service: this.service,
+      resource: "reranker",
+      rkey,
+    }).toString();
+
+    return (
+      await fetch(atmosfeedURL.toString(), {
+        headers: {
+          Authorization: "Bearer " + this.accessJWT,
+        },
+      })
+    ).blob();
+  }
 }
@@ -21,10 +21,15 @@ export const useAPI = (
   const [did, setDID] = useState("");
   const [accessJWT, setAccessJWT] = useState("");
 
+  const [api, setAPI] = useState<RestAPI>();
+
   const logout = useCallback(() => {
+    // The cached session expires on its own, so invalidating it is best-effort
+    api?.logout().catch(() => {});
+
     setAPI(undefined);
     clearAppPassword();
-  }, [clearAppPassword]);
+  }, [api, clearAppPassword]);
 
   useAsyncEffect(async () => {
     if (!username || !appPassword || !service) {
@@ -80,7 +85,6 @@ export const useAPI = (
     }
   }, [agent]);
 
-  const [api, setAPI] = useState<RestAPI>();
   useAsyncEffect(() => {
     if (!atmosfeedAPI || !service || !accessJWT || !agent || !did) {
       return;
@@ -350,6 +354,21 @@ export const useAPI = (
               a.click();
 
               URL.revokeObjectURL(url);
+
+              if (!f.reranker) {
+                return;
+              }
+
+              const reranker = await api.exportReranker(f.rkey);
+
+              const rerankerURL = URL.createObjectURL(reranker);
+              const rerankerA = document.createElement("a");
+
+              rerankerA.href = rerankerURL;
+              rerankerA.download = f.rkey + ".reranker.scale";
+              rerankerA.click();
+
+              URL.revokeObjectURL(rerankerURL);
             })
           );
         }
@@ -0,0 +1,96 @@
+package cursors
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// FeedCursor is a position in a feed, which is sorted by score, creation time and post key, all in descending order.
+// Since the post key is unique, every post has exactly one position, so paging can't skip or repeat posts.
+// Pinned posts come before all other posts; while paging through them, only the number of pins that were already returned is set.
+type FeedCursor struct {
+	Pins      int
+	Score     float64
+	CreatedAt time.Time
+	Did       string
+	Rkey      string
+}
+
+// InPins returns whether the cursor points to a pinned post rather than to a post in the feed
+func (c FeedCursor) InPins() bool {
+	return c.Did == "" && c.Rkey == ""
+}
+
+type encodedFeedCursor struct {
+	Pins      int     `json:"p,omitempty"`
+	Score     float64 `json:"s"`
+	CreatedAt int64   `json:"c"`
+	Did       string  `json:"d"`
+	Rkey      string  `json:"r"`
+}
+
+// Encode returns the cursor as an opaque string
+func (c FeedCursor) Encode() string {
+	// PostgreSQL timestamps have microsecond precision, so we can encode them without losing any
+	rawCursor, err := json.Marshal(encodedFeedCursor{
+		Pins:      c.Pins,
+		Score:     c.Score,
+		CreatedAt: c.CreatedAt.UnixMicro(),
+		Did:       c.Did,
+		Rkey:      c.Rkey,
+	})
+	if err != nil {
+		// Marshalling a struct of strings and finite numbers can't fail
+		panic(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(rawCursor)
+}
+
+// DecodeFeedCursor parses a cursor returned by FeedCursor.Encode
+func DecodeFeedCursor(cursor string) (FeedCursor, error) {
+	rawCursor, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return FeedCursor{}, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	var c encodedFeedCursor
+	if err := json.Unmarshal(rawCursor, &c); err != nil {
+		return FeedCursor{}, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	if c.Pins < 0 {
+		return FeedCursor{}, ErrInvalidCursor
+	}
+
+	// A cursor either points to a post, or to a pin if it has no post key
+	if c.Did == "" && c.Rkey == "" {
+		if c.Pins == 0 {
+			return FeedCursor{}, ErrInvalidCursor
+		}
+
+		return FeedCursor{
+			Pins: c.Pins,
+		}, nil
+	}
+
+	if strings.TrimSpace(c.Did) == "" || strings.TrimSpace(c.Rkey) == "" {
+		return FeedCursor{}, ErrInvalidCursor
+	}
+
+	return FeedCursor{
+		Pins:      c.Pins,
+		Score:     c.Score,
+		CreatedAt: time.UnixMicro(c.CreatedAt).UTC(),
+		Did:       c.Did,
+		Rkey:      c.Rkey,
+	}, nil
+}
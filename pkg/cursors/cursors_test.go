@@ -0,0 +1,313 @@
+package cursors
+
+import (
+	"cmp"
+	"errors"
+	"math/rand"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type testPost struct {
+	score     float64
+	createdAt time.Time
+	did       string
+	rkey      string
+}
+
+func (p testPost) key() string {
+	return p.did + "/" + p.rkey
+}
+
+// compareTestPosts orders posts like GetFeedPostsCursor: by score, creation time, DID and rkey, all in descending order.
+// TestFeedPostsPagingReturnsEveryPostOnce in the persisters package checks the same property against the query itself.
+func compareTestPosts(a, b testPost) int {
+	if c := cmp.Compare(b.score, a.score); c != 0 {
+		return c
+	}
+
+	if c := b.createdAt.Compare(a.createdAt); c != 0 {
+		return c
+	}
+
+	if c := cmp.Compare(b.did, a.did); c != 0 {
+		return c
+	}
+
+	return cmp.Compare(b.rkey, a.rkey)
+}
+
+// getTestFeedPosts returns the posts after the cursor like GetFeedPosts and GetFeedPostsCursor do
+func getTestFeedPosts(posts []testPost, cursor FeedCursor, limit int) []testPost {
+	sorted := slices.Clone(posts)
+	slices.SortFunc(sorted, compareTestPosts)
+
+	res := []testPost{}
+	for _, post := range sorted {
+		if len(res) >= limit {
+			break
+		}
+
+		if !cursor.InPins() && compareTestPosts(post, testPost{
+			score:     cursor.Score,
+			createdAt: cursor.CreatedAt,
+			did:       cursor.Did,
+			rkey:      cursor.Rkey,
+		}) <= 0 {
+			continue
+		}
+
+		res = append(res, post)
+	}
+
+	return res
+}
+
+// getTestFeedPage returns a page of pinned and other posts and the cursor for the next page like getFeedSkeleton does
+func getTestFeedPage(pins []string, posts []testPost, rawCursor string, limit int) ([]string, string, error) {
+	cursor := FeedCursor{}
+	if rawCursor != "" {
+		var err error
+		cursor, err = DecodeFeedCursor(rawCursor)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	page := []string{}
+	returnedPins := 0
+	if cursor.InPins() {
+		for i := cursor.Pins; i < len(pins) && len(page) < limit; i++ {
+			page = append(page, pins[i])
+
+			returnedPins++
+		}
+	}
+
+	remainingLimit := limit - len(page)
+
+	feedPosts := []testPost{}
+	if remainingLimit > 0 {
+		feedPosts = getTestFeedPosts(posts, cursor, remainingLimit)
+	}
+
+	for _, post := range feedPosts {
+		page = append(page, post.key())
+	}
+
+	nextCursor := ""
+	if remainingLimit == 0 {
+		nextCursor = FeedCursor{
+			Pins: cursor.Pins + returnedPins,
+		}.Encode()
+	} else if len(feedPosts) > 0 && len(feedPosts) >= remainingLimit {
+		lastFeedPost := feedPosts[len(feedPosts)-1]
+
+		nextCursor = FeedCursor{
+			Score:     lastFeedPost.score,
+			CreatedAt: lastFeedPost.createdAt,
+			Did:       lastFeedPost.did,
+			Rkey:      lastFeedPost.rkey,
+		}.Encode()
+	}
+
+	return page, nextCursor, nil
+}
+
+func newTestFeed(rng *rand.Rand) ([]string, []testPost) {
+	// Few distinct values make ties in every sort column likely
+	var (
+		scores    = []float64{0, 0.5, 1, rng.Float64(), -rng.Float64() * 1e6}
+		base      = time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC)
+		createdAt = []time.Time{
+			base,
+			base.Add(time.Microsecond),
+			base.Add(time.Duration(rng.Int63n(int64(time.Hour)))).Truncate(time.Microsecond),
+		}
+		dids = []string{"did:plc:a", "did:plc:b", "did:web:c.example.com"}
+	)
+
+	pins := []string{}
+	for i := rng.Intn(5); i > 0; i-- {
+		pins = append(pins, "did:plc:pinned/"+strconv.Itoa(i))
+	}
+
+	posts := []testPost{}
+	seen := map[string]struct{}{}
+	for i := rng.Intn(60); i > 0; i-- {
+		post := testPost{
+			score:     scores[rng.Intn(len(scores))],
+			createdAt: createdAt[rng.Intn(len(createdAt))],
+			did:       dids[rng.Intn(len(dids))],
+			rkey:      strconv.Itoa(rng.Intn(30)),
+		}
+
+		// Post keys are unique in a feed
+		if _, ok := seen[post.key()]; ok {
+			continue
+		}
+
+		seen[post.key()] = struct{}{}
+		posts = append(posts, post)
+	}
+
+	return pins, posts
+}
+
+func TestPagingReturnsEveryPostOnce(t *testing.T) {
+	for seed := int64(0); seed < 2000; seed++ {
+		var (
+			rng         = rand.New(rand.NewSource(seed))
+			pins, posts = newTestFeed(rng)
+			limit       = 1 + rng.Intn(7)
+			deleteRate  = rng.Float64() * 0.3
+		)
+
+		var (
+			returned = map[string]int{}
+			deleted  = map[string]struct{}{}
+			cursor   = ""
+			maxPages = len(pins) + len(posts) + 1
+		)
+		for pages := 0; ; pages++ {
+			if pages > maxPages {
+				t.Fatalf("seed %v: paging did not terminate", seed)
+			}
+
+			page, nextCursor, err := getTestFeedPage(pins, posts, cursor, limit)
+			if err != nil {
+				t.Fatalf("seed %v: could not get page: %v", seed, err)
+			}
+
+			if len(page) > limit {
+				t.Fatalf("seed %v: expected at most %v posts, got %v", seed, limit, len(page))
+			}
+
+			for _, post := range page {
+				returned[post]++
+			}
+
+			if nextCursor == "" {
+				break
+			}
+
+			cursor = nextCursor
+
+			// Posts can be deleted between two pages, including the post that the cursor points to
+			decodedCursor, err := DecodeFeedCursor(cursor)
+			if err != nil {
+				t.Fatalf("seed %v: could not decode returned cursor: %v", seed, err)
+			}
+
+			remaining := []testPost{}
+			for _, post := range posts {
+				referenced := !decodedCursor.InPins() && post.did == decodedCursor.Did && post.rkey == decodedCursor.Rkey
+				if (referenced && rng.Intn(2) == 0) || rng.Float64() < deleteRate {
+					deleted[post.key()] = struct{}{}
+
+					continue
+				}
+
+				remaining = append(remaining, post)
+			}
+			posts = remaining
+		}
+
+		for _, pin := range pins {
+			if count := returned[pin]; count != 1 {
+				t.Fatalf("seed %v: expected pin %v to be returned once, got %v", seed, pin, count)
+			}
+		}
+
+		for _, post := range posts {
+			if count := returned[post.key()]; count != 1 {
+				t.Fatalf("seed %v: expected post %v to be returned once, got %v", seed, post.key(), count)
+			}
+		}
+
+		for post, count := range returned {
+			if count > 1 {
+				t.Fatalf("seed %v: expected post %v to be returned at most once, got %v", seed, post, count)
+			}
+		}
+
+		for post := range deleted {
+			if count := returned[post]; count > 1 {
+				t.Fatalf("seed %v: expected deleted post %v to be returned at most once, got %v", seed, post, count)
+			}
+		}
+	}
+}
+
+func TestEncodeDecodeFeedCursor(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor FeedCursor
+	}{
+		{"pins", FeedCursor{Pins: 3}},
+		{"post", FeedCursor{Score: 1.5, CreatedAt: time.Date(2023, 10, 1, 12, 0, 0, 123456000, time.UTC), Did: "did:plc:a", Rkey: "3k2a"}},
+		{"post after pins", FeedCursor{Pins: 2, Score: -0.1, CreatedAt: time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC), Did: "did:plc:a", Rkey: "3k2a"}},
+		{"post with extreme score", FeedCursor{Score: -1e300, CreatedAt: time.UnixMicro(0).UTC(), Did: "did:web:example.com", Rkey: "self"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded, err := DecodeFeedCursor(tt.cursor.Encode())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if decoded != tt.cursor {
+				t.Fatalf("expected %+v, got %+v", tt.cursor, decoded)
+			}
+		})
+	}
+}
+
+func TestDecodeFeedCursorRejectsInvalidCursors(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "!"},
+		{"not JSON", "bm90IGpzb24"},
+		{"empty cursor", FeedCursor{}.Encode()},
+		{"negative pins", FeedCursor{Pins: -1}.Encode()},
+		{"missing rkey", FeedCursor{Did: "did:plc:a"}.Encode()},
+		{"missing DID", FeedCursor{Rkey: "3k2a"}.Encode()},
+		{"blank rkey", FeedCursor{Did: "did:plc:a", Rkey: " "}.Encode()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeFeedCursor(tt.cursor); !errors.Is(err, ErrInvalidCursor) {
+				t.Fatalf("expected %v, got %v", ErrInvalidCursor, err)
+			}
+		})
+	}
+}
+
+func FuzzDecodeFeedCursor(f *testing.F) {
+	f.Add(FeedCursor{Pins: 1}.Encode())
+	f.Add(FeedCursor{Score: 1, CreatedAt: time.UnixMicro(1).UTC(), Did: "did:plc:a", Rkey: "3k2a"}.Encode())
+	f.Add("")
+
+	f.Fuzz(func(t *testing.T, rawCursor string) {
+		cursor, err := DecodeFeedCursor(rawCursor)
+		if err != nil {
+			return
+		}
+
+		// Every cursor that we accept must point to the same position after encoding it again
+		decoded, err := DecodeFeedCursor(cursor.Encode())
+		if err != nil {
+			t.Fatalf("could not decode re-encoded cursor %+v: %v", cursor, err)
+		}
+
+		if decoded != cursor {
+			t.Fatalf("expected %+v, got %+v", cursor, decoded)
+		}
+	})
+}
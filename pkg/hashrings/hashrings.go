@@ -0,0 +1,80 @@
+package hashrings
+
+import (
+	"hash/crc32"
+	"slices"
+	"strconv"
+)
+
+const (
+	DefaultReplicas = 128
+)
+
+// HashRing assigns keys to members using consistent hashing, so that only a small share of keys
+// is assigned to different members if members join or leave. It is not safe for concurrent use.
+type HashRing struct {
+	replicas int
+	members  []string
+	hashes   []uint32
+	owners   map[uint32]string
+}
+
+func NewHashRing(replicas int) *HashRing {
+	return &HashRing{
+		replicas: replicas,
+		members:  []string{},
+		hashes:   []uint32{},
+		owners:   map[uint32]string{},
+	}
+}
+
+// SetMembers replaces the members of the ring and returns whether they changed
+func (r *HashRing) SetMembers(members []string) bool {
+	sorted := slices.Clone(members)
+	slices.Sort(sorted)
+	sorted = slices.Compact(sorted)
+
+	if slices.Equal(sorted, r.members) {
+		return false
+	}
+
+	r.members = sorted
+	r.hashes = []uint32{}
+	r.owners = map[uint32]string{}
+
+	for _, member := range r.members {
+		for i := 0; i < r.replicas; i++ {
+			hash := crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + "/" + member))
+			if _, ok := r.owners[hash]; ok {
+				continue
+			}
+
+			r.hashes = append(r.hashes, hash)
+			r.owners[hash] = member
+		}
+	}
+
+	slices.Sort(r.hashes)
+
+	return true
+}
+
+func (r *HashRing) Members() []string {
+	return slices.Clone(r.members)
+}
+
+// Get returns the member that owns a key, or an empty string if the ring has no members
+func (r *HashRing) Get(key string) string {
+	if len(r.hashes) == 0 {
+		return ""
+	}
+
+	hash := crc32.ChecksumIEEE([]byte(key))
+
+	i, _ := slices.BinarySearch(r.hashes, hash)
+	if i == len(r.hashes) {
+		i = 0
+	}
+
+	return r.owners[r.hashes[i]]
+}
@@ -0,0 +1,162 @@
+package hashrings
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func testKeys(count int) []string {
+	keys := []string{}
+	for i := 0; i < count; i++ {
+		keys = append(keys, "did:plc:"+strconv.Itoa(i)+"/feed")
+	}
+
+	return keys
+}
+
+func getOwners(r *HashRing, keys []string) map[string]string {
+	owners := map[string]string{}
+	for _, key := range keys {
+		owners[key] = r.Get(key)
+	}
+
+	return owners
+}
+
+func TestGetWithoutMembers(t *testing.T) {
+	r := NewHashRing(DefaultReplicas)
+
+	if owner := r.Get("did:plc:a/feed"); owner != "" {
+		t.Fatalf("expected empty ring to return no owner, got %q", owner)
+	}
+
+	if r.SetMembers([]string{"a"}) != true {
+		t.Fatal("expected adding a member to change the ring")
+	}
+
+	if r.SetMembers([]string{}) != true {
+		t.Fatal("expected removing all members to change the ring")
+	}
+
+	if owner := r.Get("did:plc:a/feed"); owner != "" {
+		t.Fatalf("expected ring without members to return no owner, got %q", owner)
+	}
+}
+
+func TestSetMembersDetectsChanges(t *testing.T) {
+	r := NewHashRing(DefaultReplicas)
+
+	tests := []struct {
+		name    string
+		members []string
+		changed bool
+	}{
+		{"empty ring", []string{}, false},
+		{"first members", []string{"a", "b"}, true},
+		{"same members", []string{"a", "b"}, false},
+		{"reordered members", []string{"b", "a"}, false},
+		{"duplicate members", []string{"b", "a", "b"}, false},
+		{"joined member", []string{"a", "b", "c"}, true},
+		{"left member", []string{"a", "c"}, true},
+	}
+
+	for _, tt := range tests {
+		if changed := r.SetMembers(tt.members); changed != tt.changed {
+			t.Fatalf("%v: expected changed to be %v, got %v", tt.name, tt.changed, changed)
+		}
+	}
+
+	if members := r.Members(); !slices.Equal(members, []string{"a", "c"}) {
+		t.Fatalf("expected members [a c], got %v", members)
+	}
+}
+
+func TestGetIsIndependentOfMemberOrder(t *testing.T) {
+	var (
+		keys = testKeys(1000)
+		r1   = NewHashRing(DefaultReplicas)
+		r2   = NewHashRing(DefaultReplicas)
+	)
+
+	r1.SetMembers([]string{"a", "b", "c"})
+	r2.SetMembers([]string{"c", "a", "b", "a"})
+
+	for _, key := range keys {
+		if o1, o2 := r1.Get(key), r2.Get(key); o1 != o2 {
+			t.Fatalf("expected %v to be owned by the same member on both rings, got %v and %v", key, o1, o2)
+		}
+	}
+}
+
+func TestSetMembersMovesFewKeys(t *testing.T) {
+	var (
+		keys = testKeys(10000)
+		r    = NewHashRing(DefaultReplicas)
+	)
+
+	r.SetMembers([]string{"a", "b", "c", "d"})
+	before := getOwners(r, keys)
+
+	// If a member joins, keys may only move to the new member
+	r.SetMembers([]string{"a", "b", "c", "d", "e"})
+	joined := getOwners(r, keys)
+
+	moved := 0
+	for _, key := range keys {
+		if before[key] == joined[key] {
+			continue
+		}
+
+		if joined[key] != "e" {
+			t.Fatalf("expected %v to move to the joined member, got %v", key, joined[key])
+		}
+
+		moved++
+	}
+
+	// Ideally 1/5 of the keys move; allow for some imbalance
+	if moved == 0 || moved > len(keys)*2/5 {
+		t.Fatalf("expected about %v keys to move on join, got %v", len(keys)/5, moved)
+	}
+
+	// If a member leaves, only its keys may move
+	r.SetMembers([]string{"a", "b", "c", "d"})
+	left := getOwners(r, keys)
+
+	for _, key := range keys {
+		if joined[key] != "e" && joined[key] != left[key] {
+			t.Fatalf("expected %v to stay on %v after another member left, got %v", key, joined[key], left[key])
+		}
+
+		if left[key] != before[key] {
+			t.Fatalf("expected %v to move back to %v after the joined member left, got %v", key, before[key], left[key])
+		}
+	}
+}
+
+func TestGetDistributesKeys(t *testing.T) {
+	var (
+		keys    = testKeys(10000)
+		members = []string{"a", "b", "c", "d"}
+		r       = NewHashRing(DefaultReplicas)
+	)
+
+	r.SetMembers(members)
+
+	counts := map[string]int{}
+	for _, owner := range getOwners(r, keys) {
+		counts[owner]++
+	}
+
+	for _, member := range members {
+		// Ideally each member owns 1/4 of the keys; allow for some imbalance
+		if count := counts[member]; count < len(keys)/8 || count > len(keys)/2 {
+			t.Fatalf("expected %v to own about %v keys, got %v", member, len(keys)/len(members), count)
+		}
+	}
+
+	if len(counts) != len(members) {
+		t.Fatalf("expected keys to be owned by %v members, got %v", len(members), counts)
+	}
+}
@@ -0,0 +1,925 @@
+package limiters
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	wasmPageSize = 64 * 1024
+
+	sectionCustom    = 0
+	sectionType      = 1
+	sectionImport    = 2
+	sectionFunction  = 3
+	sectionMemory    = 5
+	sectionGlobal    = 6
+	sectionExport    = 7
+	sectionCode      = 10
+	sectionDataCount = 12
+
+	externalFunction = 0x00
+	externalTable    = 0x01
+	externalMemory   = 0x02
+	externalGlobal   = 0x03
+	externalTag      = 0x04
+
+	limitsMin    = 0x00
+	limitsMinMax = 0x01
+
+	functionType = 0x60
+
+	valueTypeI32 = 0x7f
+	valueTypeI64 = 0x7e
+	mutable      = 0x01
+
+	// wazero, which runs the Scale functions, reports a signed integer overflow with this message
+	trapLimitExceeded = "integer overflow"
+)
+
+var (
+	wasmMagic = []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+
+	ErrInvalidModule        = errors.New("invalid WebAssembly module")
+	ErrUnsupportedModule    = errors.New("unsupported WebAssembly module")
+	ErrMemoryLimitExceeded  = errors.New("memory limit exceeded")
+	ErrUnsupportedOpcode    = errors.New("unsupported opcode")
+	errUnexpectedEndOfInput = errors.New("unexpected end of input")
+)
+
+type section struct {
+	id      byte
+	content []byte
+}
+
+type reader struct {
+	b   []byte
+	off int
+}
+
+func (r *reader) byte() (byte, error) {
+	if r.off >= len(r.b) {
+		return 0, errUnexpectedEndOfInput
+	}
+
+	b := r.b[r.off]
+	r.off++
+
+	return b, nil
+}
+
+func (r *reader) bytes(n int) ([]byte, error) {
+	if n < 0 || r.off+n > len(r.b) {
+		return nil, errUnexpectedEndOfInput
+	}
+
+	b := r.b[r.off : r.off+n]
+	r.off += n
+
+	return b, nil
+}
+
+func (r *reader) u32() (uint32, error) {
+	var (
+		res   uint32
+		shift uint
+	)
+	for {
+		b, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+
+		if shift >= 32 {
+			return 0, ErrInvalidModule
+		}
+
+		res |= uint32(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return res, nil
+		}
+
+		shift += 7
+	}
+}
+
+// skipLEB skips a signed or unsigned LEB128-encoded integer
+func (r *reader) skipLEB() error {
+	for {
+		b, err := r.byte()
+		if err != nil {
+			return err
+		}
+
+		if b&0x80 == 0 {
+			return nil
+		}
+	}
+}
+
+func (r *reader) done() bool {
+	return r.off >= len(r.b)
+}
+
+func appendU32(b []byte, v uint32) []byte {
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+
+		if v != 0 {
+			b = append(b, c|0x80)
+		} else {
+			return append(b, c)
+		}
+	}
+}
+
+func appendS64(b []byte, v int64) []byte {
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+
+		if (v == 0 && c&0x40 == 0) || (v == -1 && c&0x40 != 0) {
+			return append(b, c)
+		}
+
+		b = append(b, c|0x80)
+	}
+}
+
+func parseModule(module []byte) ([]section, error) {
+	if !bytes.HasPrefix(module, wasmMagic) {
+		return nil, ErrInvalidModule
+	}
+
+	r := &reader{b: module, off: len(wasmMagic)}
+
+	sections := []section{}
+	for !r.done() {
+		id, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+
+		size, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := r.bytes(int(size))
+		if err != nil {
+			return nil, err
+		}
+
+		sections = append(sections, section{id, content})
+	}
+
+	return sections, nil
+}
+
+func encodeModule(sections []section) []byte {
+	module := append([]byte{}, wasmMagic...)
+	for _, s := range sections {
+		module = append(module, s.id)
+		module = appendU32(module, uint32(len(s.content)))
+		module = append(module, s.content...)
+	}
+
+	return module
+}
+
+// LimitMemory caps the maximum size of all memories defined by a WebAssembly module to maxBytes,
+// so that attempts to grow beyond it trap at runtime in a way that IsLimitExceeded detects. Modules
+// which require more memory than maxBytes to be instantiated are rejected with ErrMemoryLimitExceeded.
+func LimitMemory(module []byte, maxBytes uint64) ([]byte, error) {
+	maxPages := maxBytes / wasmPageSize
+	if maxPages > 1<<16 {
+		maxPages = 1 << 16
+	}
+
+	sections, err := parseModule(module)
+	if err != nil {
+		return nil, err
+	}
+
+	limited := false
+	for i, s := range sections {
+		if s.id != sectionMemory {
+			continue
+		}
+
+		r := &reader{b: s.content}
+
+		count, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+
+		content := appendU32([]byte{}, count)
+		for j := uint32(0); j < count; j++ {
+			flags, err := r.byte()
+			if err != nil {
+				return nil, err
+			}
+
+			if flags != limitsMin && flags != limitsMinMax {
+				// Shared and 64-bit memories can't be limited reliably
+				return nil, ErrUnsupportedModule
+			}
+
+			min, err := r.u32()
+			if err != nil {
+				return nil, err
+			}
+
+			max := uint32(maxPages)
+			if flags == limitsMinMax {
+				declaredMax, err := r.u32()
+				if err != nil {
+					return nil, err
+				}
+
+				if declaredMax < max {
+					max = declaredMax
+				}
+			}
+
+			if uint64(min) > maxPages {
+				return nil, fmt.Errorf("%w: module requires %v bytes, but only %v bytes are allowed", ErrMemoryLimitExceeded, uint64(min)*wasmPageSize, maxBytes)
+			}
+
+			content = append(content, limitsMinMax)
+			content = appendU32(content, min)
+			content = appendU32(content, max)
+
+			limited = true
+		}
+
+		sections[i].content = content
+	}
+
+	if !limited || findSection(sections, sectionCode) == -1 {
+		return encodeModule(sections), nil
+	}
+
+	// Append a mutable i32 global which holds the result of the last memory.grow, since it is both checked and returned
+	sections, grownGlobal, err := appendGlobal(sections, []byte{valueTypeI32, mutable, 0x41, 0x00, 0x0b})
+	if err != nil {
+		return nil, err
+	}
+
+	// A failed memory.grow returns -1, which most guests turn into a panic that looks like any other error
+	check := []byte{0x24}
+	check = appendU32(check, grownGlobal) // global.set
+	check = append(check, 0x23)
+	check = appendU32(check, grownGlobal)               // global.get
+	check = append(check, 0x41, 0x7f, 0x46, 0x04, 0x40) // i32.const -1, i32.eq, if
+	check = appendLimitExceededTrap(check)
+	check = append(check, 0x0b, 0x23) // end, global.get
+	check = appendU32(check, grownGlobal)
+
+	importedFunctions, _, err := countImports(sections)
+	if err != nil {
+		return nil, err
+	}
+
+	codeSection := findSection(sections, sectionCode)
+
+	bodies, err := instrumentCode(sections[codeSection].content, importedFunctions, nil, afterOpcode(0x40, check)) // memory.grow
+	if err != nil {
+		return nil, err
+	}
+
+	sections[codeSection].content = encodeCode(bodies)
+
+	return encodeModule(sections), nil
+}
+
+// LimitFuel instruments a WebAssembly module so that every call to an exported function may only consume
+// up to fuel units before trapping in a way that IsLimitExceeded detects. One unit of fuel is consumed for every
+// function call and loop iteration, which bounds the amount of work an invocation can do independently of the
+// wall-clock time it takes.
+//
+// Exports are redirected to wrapper functions which refuel before calling the exported function, so the fuel
+// is only reset when the host calls into the module and not when the module calls one of its own exports.
+func LimitFuel(module []byte, fuel uint64) ([]byte, error) {
+	sections, err := parseModule(module)
+	if err != nil {
+		return nil, err
+	}
+
+	if findSection(sections, sectionCode) == -1 {
+		return module, nil
+	}
+
+	// Append a mutable i64 global which holds the remaining fuel
+	global := []byte{valueTypeI64, mutable, 0x42}
+	global = appendS64(global, int64(fuel))
+	global = append(global, 0x0b)
+
+	sections, fuelGlobal, err := appendGlobal(sections, global)
+	if err != nil {
+		return nil, err
+	}
+
+	importedFunctions, _, err := countImports(sections)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		typeParams      = []uint32{}
+		functionTypes   = []uint32{}
+		functionSection = -1
+		exportSection   = -1
+		codeSection     = -1
+	)
+	for i, s := range sections {
+		r := &reader{b: s.content}
+
+		switch s.id {
+		case sectionType:
+			if typeParams, err = parseTypeParams(r); err != nil {
+				return nil, err
+			}
+
+		case sectionFunction:
+			functionSection = i
+
+			count, err := r.u32()
+			if err != nil {
+				return nil, err
+			}
+
+			for j := uint32(0); j < count; j++ {
+				typeIndex, err := r.u32()
+				if err != nil {
+					return nil, err
+				}
+
+				functionTypes = append(functionTypes, typeIndex)
+			}
+
+		case sectionExport:
+			exportSection = i
+
+		case sectionCode:
+			codeSection = i
+		}
+	}
+
+	consume := []byte{}
+	consume = append(consume, 0x23)
+	consume = appendU32(consume, fuelGlobal)    // global.get
+	consume = append(consume, 0x50, 0x04, 0x40) // i64.eqz, if
+	consume = appendLimitExceededTrap(consume)
+	consume = append(consume, 0x0b) // end
+	consume = append(consume, 0x23)
+	consume = appendU32(consume, fuelGlobal)    // global.get
+	consume = append(consume, 0x42, 0x01, 0x7d) // i64.const 1, i64.sub
+	consume = append(consume, 0x24)
+	consume = appendU32(consume, fuelGlobal) // global.set
+
+	refuel := []byte{0x42}
+	refuel = appendS64(refuel, int64(fuel)) // i64.const
+	refuel = append(refuel, 0x24)
+	refuel = appendU32(refuel, fuelGlobal) // global.set
+
+	if functionSection == -1 {
+		return nil, ErrInvalidModule
+	}
+
+	bodies, err := instrumentCode(sections[codeSection].content, importedFunctions, consume, afterOpcode(0x03, consume)) // loop
+	if err != nil {
+		return nil, err
+	}
+
+	if len(bodies) != len(functionTypes) {
+		return nil, ErrInvalidModule
+	}
+
+	if exportSection != -1 {
+		// Wrappers are appended after all other functions so that the indices of the existing functions don't change
+		wrappers := map[uint32]uint32{}
+		if sections[exportSection].content, err = rewriteExports(sections[exportSection].content, func(index uint32) (uint32, error) {
+			// Imported functions are implemented by the host, so they don't need to be refueled
+			if index < importedFunctions {
+				return index, nil
+			}
+
+			if wrapper, ok := wrappers[index]; ok {
+				return wrapper, nil
+			}
+
+			typeIndex := functionTypes[index-importedFunctions]
+			if typeIndex >= uint32(len(typeParams)) {
+				return 0, ErrInvalidModule
+			}
+
+			wrapper := importedFunctions + uint32(len(functionTypes))
+			functionTypes = append(functionTypes, typeIndex)
+			bodies = append(bodies, wrapFunction(index, typeParams[typeIndex], refuel))
+
+			wrappers[index] = wrapper
+
+			return wrapper, nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	functions := appendU32([]byte{}, uint32(len(functionTypes)))
+	for _, typeIndex := range functionTypes {
+		functions = appendU32(functions, typeIndex)
+	}
+	sections[functionSection].content = functions
+
+	sections[codeSection].content = encodeCode(bodies)
+
+	return encodeModule(sections), nil
+}
+
+// IsLimitExceeded returns whether calling into a module that was instrumented by LimitMemory or LimitFuel failed because it exceeded
+// one of the limits; the limiters trap with a signed integer overflow, which compilers check for before dividing, so it is
+// unlikely to be confused with other traps
+func IsLimitExceeded(err error) bool {
+	return err != nil && strings.Contains(err.Error(), trapLimitExceeded)
+}
+
+// appendLimitExceededTrap appends instructions which trap with a signed integer overflow, see IsLimitExceeded
+func appendLimitExceededTrap(b []byte) []byte {
+	b = append(b, 0x42)
+	b = appendS64(b, math.MinInt64) // i64.const
+
+	return append(b, 0x42, 0x7f, 0x7f, 0x1a) // i64.const -1, i64.div_s, drop
+}
+
+func findSection(sections []section, id byte) int {
+	for i, s := range sections {
+		if s.id == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// countImports returns the amount of functions and globals that a module imports
+func countImports(sections []section) (uint32, uint32, error) {
+	importSection := findSection(sections, sectionImport)
+	if importSection == -1 {
+		return 0, 0, nil
+	}
+
+	r := &reader{b: sections[importSection].content}
+
+	count, err := r.u32()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var importedFunctions, importedGlobals uint32
+	for j := uint32(0); j < count; j++ {
+		// Module and field names
+		for k := 0; k < 2; k++ {
+			l, err := r.u32()
+			if err != nil {
+				return 0, 0, err
+			}
+
+			if _, err := r.bytes(int(l)); err != nil {
+				return 0, 0, err
+			}
+		}
+
+		kind, err := r.byte()
+		if err != nil {
+			return 0, 0, err
+		}
+
+		switch kind {
+		case externalFunction:
+			importedFunctions++
+
+			if _, err := r.u32(); err != nil {
+				return 0, 0, err
+			}
+
+		case externalTable:
+			if _, err := r.byte(); err != nil {
+				return 0, 0, err
+			}
+
+			if err := skipLimits(r); err != nil {
+				return 0, 0, err
+			}
+
+		case externalMemory:
+			if err := skipLimits(r); err != nil {
+				return 0, 0, err
+			}
+
+		case externalGlobal:
+			importedGlobals++
+
+			if _, err := r.bytes(2); err != nil {
+				return 0, 0, err
+			}
+
+		case externalTag:
+			if _, err := r.byte(); err != nil {
+				return 0, 0, err
+			}
+
+			if _, err := r.u32(); err != nil {
+				return 0, 0, err
+			}
+
+		default:
+			return 0, 0, ErrUnsupportedModule
+		}
+	}
+
+	return importedFunctions, importedGlobals, nil
+}
+
+// appendGlobal adds a global to a module, creating its global section if it doesn't have one yet, and returns the global's index
+func appendGlobal(sections []section, global []byte) ([]section, uint32, error) {
+	_, importedGlobals, err := countImports(sections)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	globalSection := findSection(sections, sectionGlobal)
+	if globalSection == -1 {
+		// The global section has to be placed in front of the first export, start, element, data count, code or data section
+		globalSection = len(sections)
+		for i, s := range sections {
+			if s.id >= sectionExport && s.id <= sectionDataCount {
+				globalSection = i
+
+				break
+			}
+		}
+
+		sections = append(sections[:globalSection], append([]section{{id: sectionGlobal, content: []byte{0}}}, sections[globalSection:]...)...)
+	}
+
+	r := &reader{b: sections[globalSection].content}
+
+	definedGlobals, err := r.u32()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	content := appendU32([]byte{}, definedGlobals+1)
+	content = append(content, r.b[r.off:]...)
+	content = append(content, global...)
+
+	sections[globalSection].content = content
+
+	return sections, importedGlobals + definedGlobals, nil
+}
+
+// instrumentCode instruments the bodies of all functions in a code section with instrumentFunction
+func instrumentCode(code []byte, importedFunctions uint32, prologue []byte, after func(opcode byte) []byte) ([][]byte, error) {
+	r := &reader{b: code}
+
+	count, err := r.u32()
+	if err != nil {
+		return nil, err
+	}
+
+	bodies := [][]byte{}
+	for j := uint32(0); j < count; j++ {
+		size, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := r.bytes(int(size))
+		if err != nil {
+			return nil, err
+		}
+
+		instrumented, err := instrumentFunction(body, prologue, after)
+		if err != nil {
+			return nil, fmt.Errorf("could not instrument function %v: %w", importedFunctions+j, err)
+		}
+
+		bodies = append(bodies, instrumented)
+	}
+
+	return bodies, nil
+}
+
+func encodeCode(bodies [][]byte) []byte {
+	code := appendU32([]byte{}, uint32(len(bodies)))
+	for _, body := range bodies {
+		code = appendU32(code, uint32(len(body)))
+		code = append(code, body...)
+	}
+
+	return code
+}
+
+// parseTypeParams returns the amount of parameters of every function type in a type section
+func parseTypeParams(r *reader) ([]uint32, error) {
+	count, err := r.u32()
+	if err != nil {
+		return nil, err
+	}
+
+	params := []uint32{}
+	for i := uint32(0); i < count; i++ {
+		form, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+
+		if form != functionType {
+			return nil, ErrUnsupportedModule
+		}
+
+		for j := 0; j < 2; j++ {
+			types, err := r.u32()
+			if err != nil {
+				return nil, err
+			}
+
+			// Value types are single bytes unless they are typed references, which we don't support
+			valueTypes, err := r.bytes(int(types))
+			if err != nil {
+				return nil, err
+			}
+
+			for _, valueType := range valueTypes {
+				if valueType < 0x6f || valueType > 0x7f {
+					return nil, ErrUnsupportedModule
+				}
+			}
+
+			if j == 0 {
+				params = append(params, types)
+			}
+		}
+	}
+
+	return params, nil
+}
+
+// rewriteExports replaces the index of every exported function with the index returned by redirect
+func rewriteExports(exports []byte, redirect func(index uint32) (uint32, error)) ([]byte, error) {
+	r := &reader{b: exports}
+
+	count, err := r.u32()
+	if err != nil {
+		return nil, err
+	}
+
+	content := appendU32([]byte{}, count)
+	for j := uint32(0); j < count; j++ {
+		l, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+
+		name, err := r.bytes(int(l))
+		if err != nil {
+			return nil, err
+		}
+
+		kind, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+
+		index, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+
+		if kind == externalFunction {
+			if index, err = redirect(index); err != nil {
+				return nil, err
+			}
+		}
+
+		content = appendU32(content, l)
+		content = append(content, name...)
+		content = append(content, kind)
+		content = appendU32(content, index)
+	}
+
+	return content, nil
+}
+
+// wrapFunction returns the body of a function which refuels and then calls the function at index with all of its parameters
+func wrapFunction(index uint32, params uint32, refuel []byte) []byte {
+	body := []byte{0x00} // No locals
+	body = append(body, refuel...)
+
+	for i := uint32(0); i < params; i++ {
+		body = append(body, 0x20) // local.get
+		body = appendU32(body, i)
+	}
+
+	body = append(body, 0x10) // call
+	body = appendU32(body, index)
+
+	return append(body, 0x0b) // end
+}
+
+func skipLimits(r *reader) error {
+	flags, err := r.byte()
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.u32(); err != nil {
+		return err
+	}
+
+	if flags&0x01 != 0 {
+		if _, err := r.u32(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func skipBlockType(r *reader) error {
+	b, err := r.byte()
+	if err != nil {
+		return err
+	}
+
+	// Empty block type or value type
+	if b == 0x40 || (b >= 0x6f && b <= 0x7f) {
+		return nil
+	}
+
+	// Type index, encoded as a signed 33-bit integer
+	r.off--
+
+	return r.skipLEB()
+}
+
+// afterOpcode returns an after function for instrumentFunction which inserts instructions after every instruction with opcode
+func afterOpcode(opcode byte, instructions []byte) func(opcode byte) []byte {
+	return func(o byte) []byte {
+		if o == opcode {
+			return instructions
+		}
+
+		return nil
+	}
+}
+
+// instrumentFunction inserts prologue at the start of a function body and the instructions that after returns for an
+// instruction behind it and its immediates, e.g. at the start of a loop's body
+func instrumentFunction(body, prologue []byte, after func(opcode byte) []byte) ([]byte, error) {
+	r := &reader{b: body}
+
+	localGroups, err := r.u32()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := uint32(0); i < localGroups; i++ {
+		if _, err := r.u32(); err != nil {
+			return nil, err
+		}
+
+		if _, err := r.byte(); err != nil {
+			return nil, err
+		}
+	}
+
+	out := append([]byte{}, body[:r.off]...)
+	out = append(out, prologue...)
+
+	start := r.off
+	for !r.done() {
+		opcode, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case opcode == 0x02, opcode == 0x03, opcode == 0x04, opcode == 0x06: // block, loop, if, try
+			if err := skipBlockType(r); err != nil {
+				return nil, err
+			}
+
+		case opcode == 0x0c, opcode == 0x0d, opcode == 0x10, opcode == 0x12, // br, br_if, call, return_call
+			opcode == 0x07, opcode == 0x08, opcode == 0x09, opcode == 0x18, // catch, throw, rethrow, delegate
+			opcode >= 0x20 && opcode <= 0x26, // local.*, global.*, table.get, table.set
+			opcode == 0x3f, opcode == 0x40,   // memory.size, memory.grow
+			opcode == 0x41, opcode == 0x42, // i32.const, i64.const
+			opcode == 0xd2: // ref.func
+			if err := r.skipLEB(); err != nil {
+				return nil, err
+			}
+
+		case opcode == 0x0e: // br_table
+			targets, err := r.u32()
+			if err != nil {
+				return nil, err
+			}
+
+			for i := uint32(0); i <= targets; i++ {
+				if err := r.skipLEB(); err != nil {
+					return nil, err
+				}
+			}
+
+		case opcode == 0x11, opcode == 0x13: // call_indirect, return_call_indirect
+			if err := r.skipLEB(); err != nil {
+				return nil, err
+			}
+
+			if err := r.skipLEB(); err != nil {
+				return nil, err
+			}
+
+		case opcode == 0x1c: // select with types
+			types, err := r.u32()
+			if err != nil {
+				return nil, err
+			}
+
+			if _, err := r.bytes(int(types)); err != nil {
+				return nil, err
+			}
+
+		case opcode >= 0x28 && opcode <= 0x3e: // loads and stores
+			if err := r.skipLEB(); err != nil {
+				return nil, err
+			}
+
+			if err := r.skipLEB(); err != nil {
+				return nil, err
+			}
+
+		case opcode == 0x43: // f32.const
+			if _, err := r.bytes(4); err != nil {
+				return nil, err
+			}
+
+		case opcode == 0x44: // f64.const
+			if _, err := r.bytes(8); err != nil {
+				return nil, err
+			}
+
+		case opcode == 0xd0: // ref.null
+			if _, err := r.byte(); err != nil {
+				return nil, err
+			}
+
+		case opcode == 0xfc: // Saturating truncation, bulk memory and table instructions
+			subopcode, err := r.u32()
+			if err != nil {
+				return nil, err
+			}
+
+			immediates := 0
+			switch {
+			case subopcode <= 7:
+				immediates = 0
+			case subopcode == 9, subopcode == 11, subopcode == 13, subopcode >= 15 && subopcode <= 17:
+				immediates = 1
+			case subopcode == 8, subopcode == 10, subopcode == 12, subopcode == 14:
+				immediates = 2
+			default:
+				return nil, fmt.Errorf("%w: 0xfc %v", ErrUnsupportedOpcode, subopcode)
+			}
+
+			for i := 0; i < immediates; i++ {
+				if err := r.skipLEB(); err != nil {
+					return nil, err
+				}
+			}
+
+		case opcode <= 0x01, opcode == 0x05, opcode == 0x0b, opcode == 0x0f, opcode == 0x19, // unreachable, nop, else, end, return, catch_all
+			opcode == 0x1a, opcode == 0x1b, // drop, select
+			opcode >= 0x45 && opcode <= 0xc4, // numeric instructions
+			opcode == 0xd1:                   // ref.is_null
+
+		default:
+			return nil, fmt.Errorf("%w: %#x", ErrUnsupportedOpcode, opcode)
+		}
+
+		if instructions := after(opcode); len(instructions) > 0 {
+			out = append(out, body[start:r.off]...)
+			out = append(out, instructions...)
+			start = r.off
+		}
+	}
+
+	return append(out, body[start:]...), nil
+}
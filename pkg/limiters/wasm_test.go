@@ -0,0 +1,1044 @@
+package limiters
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+var (
+	errTrap           = errors.New("trap")
+	errDivideByZero   = errors.New("integer divide by zero")
+	errOverflow       = errors.New(trapLimitExceeded)
+	errStepLimit      = errors.New("step limit reached")
+	errCallDepthLimit = errors.New("call depth limit reached")
+)
+
+const (
+	testStepLimit      = 1_000_000
+	testCallDepthLimit = 1_000
+)
+
+func vector(items ...[]byte) []byte {
+	b := appendU32([]byte{}, uint32(len(items)))
+	for _, item := range items {
+		b = append(b, item...)
+	}
+
+	return b
+}
+
+func funcType(params, results int) []byte {
+	b := []byte{functionType}
+	b = appendU32(b, uint32(params))
+	b = append(b, bytes.Repeat([]byte{0x7f}, params)...)
+	b = appendU32(b, uint32(results))
+
+	return append(b, bytes.Repeat([]byte{0x7f}, results)...)
+}
+
+func export(name string, index uint32) []byte {
+	b := appendU32([]byte{}, uint32(len(name)))
+	b = append(b, name...)
+	b = append(b, externalFunction)
+
+	return appendU32(b, index)
+}
+
+// body returns a function body with the given amount of i32 locals
+func body(locals uint32, instructions ...byte) []byte {
+	b := []byte{0x00}
+	if locals > 0 {
+		b = appendU32([]byte{0x01}, locals)
+		b = append(b, 0x7f)
+	}
+
+	b = append(b, instructions...)
+
+	return append(b, 0x0b)
+}
+
+type testModule struct {
+	types     [][]byte
+	functions []uint32
+	memory    []byte
+	exports   [][]byte
+	bodies    [][]byte
+}
+
+func (m testModule) encode() []byte {
+	sections := []section{
+		{id: sectionType, content: vector(m.types...)},
+	}
+
+	functions := appendU32([]byte{}, uint32(len(m.functions)))
+	for _, t := range m.functions {
+		functions = appendU32(functions, t)
+	}
+	sections = append(sections, section{id: sectionFunction, content: functions})
+
+	if m.memory != nil {
+		sections = append(sections, section{id: sectionMemory, content: vector(m.memory)})
+	}
+
+	sections = append(sections, section{id: sectionExport, content: vector(m.exports...)})
+
+	bodies := [][]byte{}
+	for _, b := range m.bodies {
+		bodies = append(bodies, append(appendU32([]byte{}, uint32(len(b))), b...))
+	}
+	sections = append(sections, section{id: sectionCode, content: vector(bodies...)})
+
+	return encodeModule(sections)
+}
+
+// interpreter executes the subset of WebAssembly that is used by the modules in these tests
+type interpreter struct {
+	params    []uint32
+	results   []uint32
+	functions []uint32
+	locals    [][]int64
+	bodies    [][]byte
+	globals   []int64
+	exports   map[string]uint32
+
+	pages    int64
+	maxPages int64
+
+	steps int
+	depth int
+}
+
+func readS64(r *reader) (int64, error) {
+	var (
+		res   int64
+		shift uint
+	)
+	for {
+		b, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+
+		res |= int64(b&0x7f) << shift
+		shift += 7
+
+		if b&0x80 == 0 {
+			if shift < 64 && b&0x40 != 0 {
+				res |= -1 << shift
+			}
+
+			return res, nil
+		}
+	}
+}
+
+func newInterpreter(module []byte) (*interpreter, error) {
+	sections, err := parseModule(module)
+	if err != nil {
+		return nil, err
+	}
+
+	i := &interpreter{
+		exports: map[string]uint32{},
+
+		maxPages: 1 << 16,
+	}
+	for _, s := range sections {
+		r := &reader{b: s.content}
+
+		count, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+
+		for j := uint32(0); j < count; j++ {
+			switch s.id {
+			case sectionType:
+				if _, err := r.byte(); err != nil {
+					return nil, err
+				}
+
+				for k := 0; k < 2; k++ {
+					n, err := r.u32()
+					if err != nil {
+						return nil, err
+					}
+
+					if _, err := r.bytes(int(n)); err != nil {
+						return nil, err
+					}
+
+					if k == 0 {
+						i.params = append(i.params, n)
+					} else {
+						i.results = append(i.results, n)
+					}
+				}
+
+			case sectionFunction:
+				t, err := r.u32()
+				if err != nil {
+					return nil, err
+				}
+
+				i.functions = append(i.functions, t)
+
+			case sectionMemory:
+				flags, err := r.byte()
+				if err != nil {
+					return nil, err
+				}
+
+				min, err := r.u32()
+				if err != nil {
+					return nil, err
+				}
+
+				i.pages = int64(min)
+
+				if flags&0x01 != 0 {
+					max, err := r.u32()
+					if err != nil {
+						return nil, err
+					}
+
+					i.maxPages = int64(max)
+				}
+
+			case sectionGlobal:
+				if _, err := r.bytes(3); err != nil { // Value type, mutability and i64.const
+					return nil, err
+				}
+
+				v, err := readS64(r)
+				if err != nil {
+					return nil, err
+				}
+
+				if _, err := r.byte(); err != nil { // end
+					return nil, err
+				}
+
+				i.globals = append(i.globals, v)
+
+			case sectionExport:
+				l, err := r.u32()
+				if err != nil {
+					return nil, err
+				}
+
+				name, err := r.bytes(int(l))
+				if err != nil {
+					return nil, err
+				}
+
+				if _, err := r.byte(); err != nil {
+					return nil, err
+				}
+
+				index, err := r.u32()
+				if err != nil {
+					return nil, err
+				}
+
+				i.exports[string(name)] = index
+
+			case sectionCode:
+				size, err := r.u32()
+				if err != nil {
+					return nil, err
+				}
+
+				b, err := r.bytes(int(size))
+				if err != nil {
+					return nil, err
+				}
+
+				br := &reader{b: b}
+
+				groups, err := br.u32()
+				if err != nil {
+					return nil, err
+				}
+
+				locals := []int64{}
+				for k := uint32(0); k < groups; k++ {
+					n, err := br.u32()
+					if err != nil {
+						return nil, err
+					}
+
+					if _, err := br.byte(); err != nil {
+						return nil, err
+					}
+
+					locals = append(locals, make([]int64, n)...)
+				}
+
+				i.locals = append(i.locals, locals)
+				i.bodies = append(i.bodies, b[br.off:])
+			}
+		}
+	}
+
+	return i, nil
+}
+
+// call calls an export like the host would
+func (i *interpreter) call(name string, args ...int64) ([]int64, error) {
+	index, ok := i.exports[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown export %v", name)
+	}
+
+	i.steps = 0
+
+	return i.invoke(index, args)
+}
+
+type label struct {
+	loop   bool
+	start  int
+	end    int
+	height int
+}
+
+// matchBlocks returns the positions of the else and end instructions that belong to each block, loop and if
+func matchBlocks(code []byte) (map[int]int, map[int]int, error) {
+	var (
+		elses = map[int]int{}
+		ends  = map[int]int{}
+		open  = []int{}
+	)
+
+	r := &reader{b: code}
+	for !r.done() {
+		pc := r.off
+
+		opcode, err := r.byte()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch opcode {
+		case 0x02, 0x03, 0x04:
+			if err := skipBlockType(r); err != nil {
+				return nil, nil, err
+			}
+
+			open = append(open, pc)
+
+		case 0x05:
+			elses[open[len(open)-1]] = pc
+
+		case 0x0b:
+			if len(open) > 0 {
+				ends[open[len(open)-1]] = pc
+				open = open[:len(open)-1]
+			}
+
+		case 0x0c, 0x0d, 0x10, 0x20, 0x21, 0x22, 0x23, 0x24, 0x3f, 0x40, 0x41, 0x42:
+			if err := r.skipLEB(); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	return elses, ends, nil
+}
+
+func (i *interpreter) invoke(index uint32, args []int64) ([]int64, error) {
+	i.depth++
+	defer func() {
+		i.depth--
+	}()
+
+	if i.depth > testCallDepthLimit {
+		return nil, errCallDepthLimit
+	}
+
+	if int(index) >= len(i.bodies) {
+		return nil, fmt.Errorf("unknown function %v", index)
+	}
+
+	var (
+		code    = i.bodies[index]
+		results = int(i.results[i.functions[index]])
+		locals  = append(append([]int64{}, args...), i.locals[index]...)
+		stack   = []int64{}
+		labels  = []label{}
+	)
+
+	elses, ends, err := matchBlocks(code)
+	if err != nil {
+		return nil, err
+	}
+
+	pop := func() int64 {
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		return v
+	}
+
+	r := &reader{b: code}
+
+	branch := func(depth uint32) bool {
+		if int(depth) >= len(labels) {
+			return true
+		}
+
+		target := labels[len(labels)-1-int(depth)]
+		stack = stack[:target.height]
+
+		if target.loop {
+			labels = labels[:len(labels)-int(depth)]
+			r.off = target.start
+		} else {
+			labels = labels[:len(labels)-1-int(depth)]
+			r.off = target.end + 1
+		}
+
+		return false
+	}
+
+	for !r.done() {
+		i.steps++
+		if i.steps > testStepLimit {
+			return nil, errStepLimit
+		}
+
+		pc := r.off
+
+		opcode, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case 0x00: // unreachable
+			return nil, errTrap
+
+		case 0x01: // nop
+
+		case 0x02, 0x03: // block, loop
+			if err := skipBlockType(r); err != nil {
+				return nil, err
+			}
+
+			labels = append(labels, label{loop: opcode == 0x03, start: r.off, end: ends[pc], height: len(stack)})
+
+		case 0x04: // if
+			if err := skipBlockType(r); err != nil {
+				return nil, err
+			}
+
+			if pop() != 0 {
+				labels = append(labels, label{end: ends[pc], height: len(stack)})
+			} else if e, ok := elses[pc]; ok {
+				labels = append(labels, label{end: ends[pc], height: len(stack)})
+				r.off = e + 1
+			} else {
+				r.off = ends[pc] + 1
+			}
+
+		case 0x05: // else, which is reached at the end of the then branch
+			r.off = labels[len(labels)-1].end + 1
+			labels = labels[:len(labels)-1]
+
+		case 0x0b: // end
+			if len(labels) == 0 {
+				r.off = len(code)
+			} else {
+				labels = labels[:len(labels)-1]
+			}
+
+		case 0x0c, 0x0d: // br, br_if
+			depth, err := r.u32()
+			if err != nil {
+				return nil, err
+			}
+
+			if opcode == 0x0d && pop() == 0 {
+				continue
+			}
+
+			if branch(depth) {
+				r.off = len(code)
+			}
+
+		case 0x0f: // return
+			r.off = len(code)
+
+		case 0x10: // call
+			callee, err := r.u32()
+			if err != nil {
+				return nil, err
+			}
+
+			params := int(i.params[i.functions[callee]])
+			args := append([]int64{}, stack[len(stack)-params:]...)
+			stack = stack[:len(stack)-params]
+
+			res, err := i.invoke(callee, args)
+			if err != nil {
+				return nil, err
+			}
+
+			stack = append(stack, res...)
+
+		case 0x1a: // drop
+			pop()
+
+		case 0x20, 0x21, 0x22: // local.get, local.set, local.tee
+			index, err := r.u32()
+			if err != nil {
+				return nil, err
+			}
+
+			switch opcode {
+			case 0x20:
+				stack = append(stack, locals[index])
+			case 0x21:
+				locals[index] = pop()
+			case 0x22:
+				locals[index] = stack[len(stack)-1]
+			}
+
+		case 0x23, 0x24: // global.get, global.set
+			index, err := r.u32()
+			if err != nil {
+				return nil, err
+			}
+
+			if opcode == 0x23 {
+				stack = append(stack, i.globals[index])
+			} else {
+				i.globals[index] = pop()
+			}
+
+		case 0x3f, 0x40: // memory.size, memory.grow
+			if _, err := r.byte(); err != nil {
+				return nil, err
+			}
+
+			if opcode == 0x3f {
+				stack = append(stack, i.pages)
+			} else if delta := pop(); i.pages+delta > i.maxPages {
+				stack = append(stack, -1)
+			} else {
+				stack = append(stack, i.pages)
+				i.pages += delta
+			}
+
+		case 0x41, 0x42: // i32.const, i64.const
+			v, err := readS64(r)
+			if err != nil {
+				return nil, err
+			}
+
+			stack = append(stack, v)
+
+		case 0x45, 0x50: // i32.eqz, i64.eqz
+			if pop() == 0 {
+				stack = append(stack, 1)
+			} else {
+				stack = append(stack, 0)
+			}
+
+		case 0x46: // i32.eq
+			b, a := int32(pop()), int32(pop())
+			if a == b {
+				stack = append(stack, 1)
+			} else {
+				stack = append(stack, 0)
+			}
+
+		case 0x48: // i32.lt_s
+			b, a := int32(pop()), int32(pop())
+			if a < b {
+				stack = append(stack, 1)
+			} else {
+				stack = append(stack, 0)
+			}
+
+		case 0x6a: // i32.add
+			b, a := int32(pop()), int32(pop())
+			stack = append(stack, int64(a+b))
+
+		case 0x6b: // i32.sub
+			b, a := int32(pop()), int32(pop())
+			stack = append(stack, int64(a-b))
+
+		case 0x7d: // i64.sub
+			b, a := pop(), pop()
+			stack = append(stack, a-b)
+
+		case 0x7f: // i64.div_s
+			b, a := pop(), pop()
+			if b == 0 {
+				return nil, errDivideByZero
+			}
+
+			if a == math.MinInt64 && b == -1 {
+				return nil, errOverflow
+			}
+
+			stack = append(stack, a/b)
+
+		default:
+			return nil, fmt.Errorf("unsupported opcode %#x in test interpreter", opcode)
+		}
+	}
+
+	return stack[len(stack)-results:], nil
+}
+
+// Returns a module which exports "run", an infinite loop
+func loopModule() []byte {
+	return testModule{
+		types:     [][]byte{funcType(0, 0)},
+		functions: []uint32{0},
+		exports:   [][]byte{export("run", 0)},
+		bodies: [][]byte{
+			body(0,
+				0x03, 0x40, // loop
+				0x0c, 0x00, // br 0
+				0x0b, // end
+			),
+		},
+	}.encode()
+}
+
+// Returns a module which exports "run", which calls itself forever
+func recursionModule() []byte {
+	return testModule{
+		types:     [][]byte{funcType(0, 0)},
+		functions: []uint32{0},
+		exports:   [][]byte{export("run", 0)},
+		bodies: [][]byte{
+			body(0,
+				0x10, 0x00, // call 0
+			),
+		},
+	}.encode()
+}
+
+// Returns a module which exports "step", which does nothing, and "run", which calls "step" in an infinite loop
+func exportCallsExportModule() []byte {
+	return testModule{
+		types:     [][]byte{funcType(0, 0)},
+		functions: []uint32{0, 0},
+		exports:   [][]byte{export("step", 0), export("run", 1)},
+		bodies: [][]byte{
+			body(0),
+			body(0,
+				0x03, 0x40, // loop
+				0x10, 0x00, // call 0
+				0x0c, 0x00, // br 0
+				0x0b, // end
+			),
+		},
+	}.encode()
+}
+
+// Returns a module which exports "count", which counts from 0 to its parameter in a loop and returns the result
+func countModule() []byte {
+	return testModule{
+		types:     [][]byte{funcType(1, 1)},
+		functions: []uint32{0},
+		exports:   [][]byte{export("count", 0)},
+		bodies: [][]byte{
+			body(1,
+				0x02, 0x40, // block
+				0x03, 0x40, // loop
+				0x20, 0x01, // local.get 1
+				0x20, 0x00, // local.get 0
+				0x48,       // i32.lt_s
+				0x45,       // i32.eqz
+				0x0d, 0x01, // br_if 1
+				0x20, 0x01, // local.get 1
+				0x41, 0x01, // i32.const 1
+				0x6a,       // i32.add
+				0x21, 0x01, // local.set 1
+				0x0c, 0x00, // br 0
+				0x0b,       // end
+				0x0b,       // end
+				0x20, 0x01, // local.get 1
+			),
+		},
+	}.encode()
+}
+
+func TestLimitFuelTrapsRunawayModules(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		module []byte
+	}{
+		{"loop", loopModule()},
+		{"recursion", recursionModule()},
+		{"export calls export", exportCallsExportModule()},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// Without instrumentation, the module only stops because of the test interpreter's limits
+			i, err := newInterpreter(tt.module)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := i.call("run"); !errors.Is(err, errStepLimit) && !errors.Is(err, errCallDepthLimit) {
+				t.Fatalf("expected uninstrumented module to run until the test limits, got %v", err)
+			}
+
+			limited, err := LimitFuel(tt.module, 100)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			i, err = newInterpreter(limited)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := i.call("run"); !IsLimitExceeded(err) {
+				t.Fatalf("expected instrumented module to exceed its limit, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLimitFuelRefuelsOnlyOnHostCalls(t *testing.T) {
+	limited, err := LimitFuel(countModule(), 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i, err := newInterpreter(limited)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each call consumes one unit for the call and one per loop iteration, so every call needs to be refueled to succeed
+	for j := 0; j < 5; j++ {
+		res, err := i.call("count", 40)
+		if err != nil {
+			t.Fatalf("call %v: %v", j, err)
+		}
+
+		if len(res) != 1 || res[0] != 40 {
+			t.Fatalf("call %v: expected [40], got %v", j, res)
+		}
+	}
+
+	if _, err := i.call("count", 60); !IsLimitExceeded(err) {
+		t.Fatalf("expected call that exceeds the fuel to exceed its limit, got %v", err)
+	}
+
+	// The fuel of the next call doesn't depend on the call that trapped
+	if _, err := i.call("count", 40); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLimitFuelKeepsFunctionIndices(t *testing.T) {
+	limited, err := LimitFuel(exportCallsExportModule(), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i, err := newInterpreter(limited)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two wrappers are appended after the two original functions
+	if len(i.bodies) != 4 {
+		t.Fatalf("expected 4 functions, got %v", len(i.bodies))
+	}
+
+	if i.exports["step"] != 2 || i.exports["run"] != 3 {
+		t.Fatalf("expected exports to point to the wrappers, got %v", i.exports)
+	}
+
+	// The original functions only consume fuel, while the wrappers refuel before calling them
+	refuel := appendS64([]byte{0x42}, 100)
+	for j, b := range i.bodies {
+		if wrapper := j >= 2; bytes.Contains(b, refuel) != wrapper {
+			t.Fatalf("function %v: expected refuel to only be in the wrappers", j)
+		}
+	}
+}
+
+func TestLimitFuelWithoutCode(t *testing.T) {
+	module := encodeModule([]section{{id: sectionType, content: vector()}})
+
+	limited, err := LimitFuel(module, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(limited, module) {
+		t.Fatal("expected module without code to be unchanged")
+	}
+}
+
+func TestLimitFuelRejectsInvalidModules(t *testing.T) {
+	if _, err := LimitFuel([]byte("not wasm"), 100); !errors.Is(err, ErrInvalidModule) {
+		t.Fatalf("expected %v, got %v", ErrInvalidModule, err)
+	}
+
+	truncated := loopModule()
+	if _, err := LimitFuel(truncated[:len(truncated)-2], 100); err == nil {
+		t.Fatal("expected truncated module to be rejected")
+	}
+}
+
+func memoryModule(limits ...byte) []byte {
+	return testModule{
+		types:     [][]byte{funcType(0, 0)},
+		functions: []uint32{0},
+		memory:    limits,
+		exports:   [][]byte{export("run", 0)},
+		bodies:    [][]byte{body(0)},
+	}.encode()
+}
+
+func getMemoryLimits(t *testing.T, module []byte) []byte {
+	sections, err := parseModule(module)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range sections {
+		if s.id == sectionMemory {
+			return s.content
+		}
+	}
+
+	t.Fatal("missing memory section")
+
+	return nil
+}
+
+func TestLimitMemory(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		limits   []byte
+		maxBytes uint64
+		expected []byte
+		err      error
+	}{
+		{"adds maximum", []byte{limitsMin, 0x01}, 4 * wasmPageSize, []byte{0x01, limitsMinMax, 0x01, 0x04}, nil},
+		{"lowers maximum", []byte{limitsMinMax, 0x01, 0x10}, 4 * wasmPageSize, []byte{0x01, limitsMinMax, 0x01, 0x04}, nil},
+		{"keeps lower maximum", []byte{limitsMinMax, 0x01, 0x02}, 4 * wasmPageSize, []byte{0x01, limitsMinMax, 0x01, 0x02}, nil},
+		{"rounds down to pages", []byte{limitsMin, 0x01}, 4*wasmPageSize + 1, []byte{0x01, limitsMinMax, 0x01, 0x04}, nil},
+		{"rejects larger minimum", []byte{limitsMin, 0x05}, 4 * wasmPageSize, nil, ErrMemoryLimitExceeded},
+		{"rejects shared memory", []byte{0x03, 0x01, 0x02}, 4 * wasmPageSize, nil, ErrUnsupportedModule},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			limited, err := LimitMemory(memoryModule(tt.limits...), tt.maxBytes)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("expected %v, got %v", tt.err, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if limits := getMemoryLimits(t, limited); !bytes.Equal(limits, tt.expected) {
+				t.Fatalf("expected limits %x, got %x", tt.expected, limits)
+			}
+		})
+	}
+}
+
+func TestLimitMemoryWithoutMemory(t *testing.T) {
+	module := loopModule()
+
+	limited, err := LimitMemory(module, wasmPageSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(limited, module) {
+		t.Fatal("expected module without memory to be unchanged")
+	}
+}
+
+// Returns a module which exports "grow", which grows its memory by the given amount of pages and returns the previous amount of pages or -1
+func growModule(limits ...byte) []byte {
+	return testModule{
+		types:     [][]byte{funcType(1, 1)},
+		functions: []uint32{0},
+		memory:    limits,
+		exports:   [][]byte{export("grow", 0)},
+		bodies: [][]byte{
+			body(0,
+				0x20, 0x00, // local.get 0
+				0x40, 0x00, // memory.grow 0
+			),
+		},
+	}.encode()
+}
+
+func TestLimitMemoryTrapsFailedGrows(t *testing.T) {
+	// Without instrumentation, a grow beyond the maximum returns -1 to the module
+	i, err := newInterpreter(growModule(limitsMinMax, 0x01, 0x04))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res, err := i.call("grow", 4); err != nil || len(res) != 1 || res[0] != -1 {
+		t.Fatalf("expected uninstrumented grow to return [-1], got %v and %v", res, err)
+	}
+
+	limited, err := LimitMemory(growModule(limitsMin, 0x01), 4*wasmPageSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i, err = newInterpreter(limited)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Grows within the limit still return the previous amount of pages
+	for _, tt := range []struct {
+		delta    int64
+		expected int64
+	}{
+		{2, 1},
+		{1, 3},
+	} {
+		res, err := i.call("grow", tt.delta)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(res) != 1 || res[0] != tt.expected {
+			t.Fatalf("expected [%v], got %v", tt.expected, res)
+		}
+	}
+
+	if _, err := i.call("grow", 1); !IsLimitExceeded(err) {
+		t.Fatalf("expected grow beyond the limit to exceed it, got %v", err)
+	}
+}
+
+func TestLimitMemoryAndFuel(t *testing.T) {
+	memoryLimited, err := LimitMemory(growModule(limitsMin, 0x01), 2*wasmPageSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	limited, err := LimitFuel(memoryLimited, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i, err := newInterpreter(limited)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both limiters append a global, so their indices must not collide
+	if len(i.globals) != 2 {
+		t.Fatalf("expected 2 globals, got %v", len(i.globals))
+	}
+
+	if res, err := i.call("grow", 1); err != nil || len(res) != 1 || res[0] != 1 {
+		t.Fatalf("expected [1], got %v and %v", res, err)
+	}
+
+	if _, err := i.call("grow", 1); !IsLimitExceeded(err) {
+		t.Fatalf("expected grow beyond the limit to exceed it, got %v", err)
+	}
+}
+
+func TestIsLimitExceeded(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"no error", nil, false},
+		{"limit exceeded", fmt.Errorf("could not run function: wasm error: %v", trapLimitExceeded), true},
+		{"unreachable", errors.New("could not run function: wasm error: unreachable"), false},
+		{"divide by zero", fmt.Errorf("could not run function: %w", errDivideByZero), false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := IsLimitExceeded(tt.err); actual != tt.expected {
+				t.Fatalf("expected %v, got %v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestInstrumentFunctionSkipsImmediates(t *testing.T) {
+	consume := []byte{0x01} // nop, so that insertions are easy to count
+
+	// Immediates that contain the loop opcode must not be treated as loops
+	immediates := []byte{
+		0x41, 0x03, // i32.const 3
+		0x1a,                                                 // drop
+		0x44, 0x03, 0x03, 0x03, 0x03, 0x03, 0x03, 0x03, 0x03, // f64.const
+		0x1a,                         // drop
+		0x43, 0x03, 0x03, 0x03, 0x03, // f32.const
+		0x1a,       // drop
+		0x41, 0x00, // i32.const 0
+		0x28, 0x02, 0x03, // i32.load align=2 offset=3
+		0x1a,       // drop
+		0x02, 0x40, // block
+		0x41, 0x00, // i32.const 0
+		0x0e, 0x02, 0x00, 0x00, 0x00, // br_table 0 0 0
+		0x0b,       // end
+		0x41, 0x00, // i32.const 0
+		0x41, 0x03, // i32.const 3
+		0x41, 0x00, // i32.const 0
+		0xfc, 0x0b, 0x00, // memory.fill 0
+	}
+
+	instrumented, err := instrumentFunction(body(0, append(append([]byte{}, immediates...),
+		0x03, 0x40, // loop
+		0x03, 0x7f, // loop (result i32)
+		0x41, 0x03, // i32.const 3
+		0x0b, // end
+		0x1a, // drop
+		0x0b, // end
+	)...), consume, afterOpcode(0x03, consume))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// consume is inserted at the start of the function and at the start of each of the two loops
+	expected := append([]byte{0x00}, consume...)
+	expected = append(expected, immediates...)
+	expected = append(expected, 0x03, 0x40)
+	expected = append(expected, consume...)
+	expected = append(expected, 0x03, 0x7f)
+	expected = append(expected, consume...)
+	expected = append(expected, 0x41, 0x03, 0x0b, 0x1a, 0x0b, 0x0b)
+
+	if !bytes.Equal(instrumented, expected) {
+		t.Fatalf("expected %x, got %x", expected, instrumented)
+	}
+}
+
+func TestInstrumentFunctionRejectsUnsupportedOpcodes(t *testing.T) {
+	for _, instructions := range [][]byte{
+		{0xfd, 0x00}, // SIMD
+		{0xfc, 0x20}, // Unknown 0xfc subopcode
+		{0xfe, 0x00}, // Threads
+	} {
+		if _, err := instrumentFunction(body(0, instructions...), []byte{0x01}, afterOpcode(0x03, []byte{0x01})); !errors.Is(err, ErrUnsupportedOpcode) {
+			t.Fatalf("%x: expected %v, got %v", instructions, ErrUnsupportedOpcode, err)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+-- +goose Up
+alter table feeds
+add column classifier_errors int not null default 0,
+    add column classifier_timeouts int not null default 0,
+    add column quarantined boolean not null default false;
+-- +goose Down
+alter table feeds drop column quarantined,
+    drop column classifier_timeouts,
+    drop column classifier_errors;
\ No newline at end of file
@@ -0,0 +1,5 @@
+-- +goose Up
+alter table feeds
+add column classifier_limit_violations int not null default 0;
+-- +goose Down
+alter table feeds drop column classifier_limit_violations;
\ No newline at end of file
@@ -0,0 +1,7 @@
+-- +goose Up
+alter table feeds
+add column retention int not null default 0;
+create index posts_created_at_idx on posts (created_at);
+-- +goose Down
+drop index posts_created_at_idx;
+alter table feeds drop column retention;
\ No newline at end of file
@@ -0,0 +1,30 @@
+-- +goose Up
+alter table feeds
+add column ranking text not null default 'weight',
+    add column half_life int not null default 0;
+alter table feed_posts
+add column created_at timestamp,
+    add column score double precision not null default 0;
+update feed_posts fp
+set created_at = p.created_at,
+    score = fp.weight
+from posts p
+where p.did = fp.post_did
+    and p.rkey = fp.post_rkey;
+alter table feed_posts
+alter column created_at
+set not null;
+create index feed_posts_score_idx on feed_posts (
+    feed_did,
+    feed_rkey,
+    score desc,
+    created_at desc,
+    post_did desc,
+    post_rkey desc
+);
+-- +goose Down
+drop index feed_posts_score_idx;
+alter table feed_posts drop column score,
+    drop column created_at;
+alter table feeds drop column half_life,
+    drop column ranking;
\ No newline at end of file
@@ -0,0 +1,9 @@
+-- +goose Up
+alter table feeds
+add column ttl int not null default 0,
+    add column max_page_size int not null default 0,
+    add column default_page_size int not null default 0;
+-- +goose Down
+alter table feeds drop column default_page_size,
+    drop column max_page_size,
+    drop column ttl;
\ No newline at end of file
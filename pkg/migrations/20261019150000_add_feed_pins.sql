@@ -0,0 +1,46 @@
+-- +goose Up
+create table feed_pins (
+    feed_did text not null,
+    feed_rkey text not null,
+    post_did text not null,
+    post_rkey text not null,
+    position int not null,
+    starts_at timestamp,
+    ends_at timestamp,
+    foreign key (feed_did, feed_rkey) references feeds(did, rkey) ON DELETE CASCADE,
+    primary key (feed_did, feed_rkey, post_did, post_rkey)
+);
+create index feed_pins_position_idx on feed_pins (feed_did, feed_rkey, position);
+insert into feed_pins (feed_did, feed_rkey, post_did, post_rkey, position)
+select did,
+    rkey,
+    pinned_did,
+    pinned_rkey,
+    0
+from feeds
+where pinned_did <> ''
+    and pinned_rkey <> '';
+alter table feeds drop column pinned_rkey,
+    drop column pinned_did;
+-- +goose Down
+alter table feeds
+add column pinned_did text not null default '',
+    add column pinned_rkey text not null default '';
+update feeds
+set pinned_did = p.post_did,
+    pinned_rkey = p.post_rkey
+from (
+        select distinct on (feed_did, feed_rkey) feed_did,
+            feed_rkey,
+            post_did,
+            post_rkey
+        from feed_pins
+        order by feed_did,
+            feed_rkey,
+            position,
+            post_did,
+            post_rkey
+    ) p
+where feeds.did = p.feed_did
+    and feeds.rkey = p.feed_rkey;
+drop table feed_pins;
\ No newline at end of file
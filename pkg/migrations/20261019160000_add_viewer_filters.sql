@@ -0,0 +1,15 @@
+-- +goose Up
+alter table feeds
+add column hide_viewer_posts boolean not null default false,
+    add column hide_blocked_posts boolean not null default false;
+create table blocks (
+    did text not null,
+    rkey text not null,
+    subject text not null,
+    primary key (did, rkey)
+);
+create index blocks_subject_idx on blocks (did, subject);
+-- +goose Down
+drop table blocks;
+alter table feeds drop column hide_blocked_posts,
+    drop column hide_viewer_posts;
\ No newline at end of file
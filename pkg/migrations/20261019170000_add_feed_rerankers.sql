@@ -0,0 +1,5 @@
+-- +goose Up
+alter table feeds
+add column reranker boolean not null default false;
+-- +goose Down
+alter table feeds drop column reranker;
\ No newline at end of file
@@ -0,0 +1,16 @@
+-- +goose Up
+create table interactions (
+    feed_did text not null,
+    feed_rkey text not null,
+    post_did text not null,
+    post_rkey text not null,
+    did text not null,
+    event text not null,
+    created_at timestamp not null default now(),
+    primary key (feed_did, feed_rkey, post_did, post_rkey, did, event),
+    foreign key (feed_did, feed_rkey, post_did, post_rkey) references feed_posts(feed_did, feed_rkey, post_did, post_rkey) on delete cascade
+);
+create index interactions_post_idx on interactions (post_did, post_rkey);
+create index interactions_did_idx on interactions (did);
+-- +goose Down
+drop table interactions;
\ No newline at end of file
@@ -0,0 +1,14 @@
+-- +goose Up
+alter table posts
+add column subject_did text not null default '',
+    add column subject_rkey text not null default '';
+create index posts_subject_idx on posts (subject_did, subject_rkey);
+alter table feed_posts
+add column subject_did text not null default '',
+    add column subject_rkey text not null default '';
+-- +goose Down
+alter table feed_posts drop column subject_rkey,
+    drop column subject_did;
+drop index posts_subject_idx;
+alter table posts drop column subject_rkey,
+    drop column subject_did;
\ No newline at end of file
@@ -0,0 +1,13 @@
+-- +goose Up
+create table api_tokens (
+    id text not null primary key,
+    did text not null,
+    hash text not null unique,
+    description text not null,
+    feeds text [] not null,
+    scopes text [] not null,
+    created_at timestamp not null default now()
+);
+create index api_tokens_did_idx on api_tokens (did);
+-- +goose Down
+drop table api_tokens;
\ No newline at end of file
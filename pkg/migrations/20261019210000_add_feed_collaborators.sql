@@ -0,0 +1,12 @@
+-- +goose Up
+create table feed_collaborators (
+    feed_did text not null,
+    feed_rkey text not null,
+    did text not null,
+    role text not null,
+    foreign key (feed_did, feed_rkey) references feeds(did, rkey) ON DELETE CASCADE,
+    primary key (feed_did, feed_rkey, did)
+);
+create index feed_collaborators_did_idx on feed_collaborators (did);
+-- +goose Down
+drop table feed_collaborators;
\ No newline at end of file
@@ -0,0 +1,11 @@
+-- +goose Up
+alter table feeds
+add column suspended boolean not null default false;
+create table banned_dids (
+    did text not null primary key,
+    reason text not null,
+    created_at timestamp not null default now()
+);
+-- +goose Down
+drop table banned_dids;
+alter table feeds drop column suspended;
\ No newline at end of file
@@ -0,0 +1,14 @@
+-- +goose Up
+alter table feeds
+add column classifier_size bigint not null default 0,
+    add column reranker_size bigint not null default 0;
+create table quotas (
+    did text not null primary key,
+    max_feeds int,
+    max_classifier_size bigint,
+    max_storage bigint
+);
+-- +goose Down
+drop table quotas;
+alter table feeds drop column reranker_size,
+    drop column classifier_size;
\ No newline at end of file
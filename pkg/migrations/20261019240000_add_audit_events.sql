@@ -0,0 +1,24 @@
+-- +goose Up
+create table audit_events (
+    id bigserial not null primary key,
+    did text not null,
+    action text not null,
+    feed_did text not null,
+    feed_rkey text not null,
+    classifier_hash text not null,
+    origin text not null,
+    created_at timestamp not null default now()
+);
+create index audit_events_feed_idx on audit_events (feed_did, feed_rkey);
+create index audit_events_did_idx on audit_events (did);
+-- +goose StatementBegin
+create function reject_audit_event_changes() returns trigger as $$ begin raise exception 'audit events are append-only';
+end;
+$$ language plpgsql;
+-- +goose StatementEnd
+create trigger audit_events_append_only before
+update
+    or delete on audit_events for each row execute function reject_audit_event_changes();
+-- +goose Down
+drop table audit_events;
+drop function reject_audit_event_changes;
\ No newline at end of file
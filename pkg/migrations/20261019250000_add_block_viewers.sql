@@ -0,0 +1,8 @@
+-- +goose Up
+create table block_viewers (
+    did text not null primary key,
+    last_seen_at timestamp not null
+);
+create index block_viewers_last_seen_at_idx on block_viewers (last_seen_at);
+-- +goose Down
+drop table block_viewers;
\ No newline at end of file
@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+create or replace function reject_audit_event_changes() returns trigger as $$ begin if tg_op = 'UPDATE'
+    and new.id = old.id
+    and new.action = old.action
+    and new.feed_rkey = old.feed_rkey
+    and new.classifier_hash = old.classifier_hash
+    and new.created_at = old.created_at
+    and (
+        new.did = old.did
+        or new.did like 'deleted:%'
+    )
+    and (
+        new.feed_did = old.feed_did
+        or new.feed_did like 'deleted:%'
+    )
+    and (
+        new.origin = old.origin
+        or new.origin = ''
+    ) then return new;
+end if;
+raise exception 'audit events are append-only';
+end;
+$$ language plpgsql;
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+create or replace function reject_audit_event_changes() returns trigger as $$ begin raise exception 'audit events are append-only';
+end;
+$$ language plpgsql;
+-- +goose StatementEnd
@@ -7,9 +7,41 @@ package models
 
 import (
 	"context"
+	"database/sql"
 	"time"
+
+	"github.com/lib/pq"
 )
 
+const deleteExpiredFeedPosts = `-- name: DeleteExpiredFeedPosts :execrows
+delete from feed_posts
+where (feed_did, feed_rkey, post_did, post_rkey) in (
+        select fp.feed_did,
+            fp.feed_rkey,
+            fp.post_did,
+            fp.post_rkey
+        from feed_posts fp
+            join feeds f on f.did = fp.feed_did
+            and f.rkey = fp.feed_rkey
+        where f.retention > 0
+            and fp.created_at < $1::timestamp - make_interval(secs => f.retention)
+        limit $2
+    )
+`
+
+type DeleteExpiredFeedPostsParams struct {
+	Now       time.Time
+	BatchSize int32
+}
+
+func (q *Queries) DeleteExpiredFeedPosts(ctx context.Context, arg DeleteExpiredFeedPostsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteExpiredFeedPosts, arg.Now, arg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const deleteFeed = `-- name: DeleteFeed :exec
 delete from feeds
 where did = $1
@@ -26,6 +58,47 @@ func (q *Queries) DeleteFeed(ctx context.Context, arg DeleteFeedParams) error {
 	return err
 }
 
+const deleteFeedPin = `-- name: DeleteFeedPin :exec
+delete from feed_pins
+where feed_did = $1
+    and feed_rkey = $2
+    and post_did = $3
+    and post_rkey = $4
+`
+
+type DeleteFeedPinParams struct {
+	FeedDid  string
+	FeedRkey string
+	PostDid  string
+	PostRkey string
+}
+
+func (q *Queries) DeleteFeedPin(ctx context.Context, arg DeleteFeedPinParams) error {
+	_, err := q.db.ExecContext(ctx, deleteFeedPin,
+		arg.FeedDid,
+		arg.FeedRkey,
+		arg.PostDid,
+		arg.PostRkey,
+	)
+	return err
+}
+
+const deleteFeedPins = `-- name: DeleteFeedPins :exec
+delete from feed_pins
+where feed_did = $1
+    and feed_rkey = $2
+`
+
+type DeleteFeedPinsParams struct {
+	FeedDid  string
+	FeedRkey string
+}
+
+func (q *Queries) DeleteFeedPins(ctx context.Context, arg DeleteFeedPinsParams) error {
+	_, err := q.db.ExecContext(ctx, deleteFeedPins, arg.FeedDid, arg.FeedRkey)
+	return err
+}
+
 const deleteFeedPostsForDid = `-- name: DeleteFeedPostsForDid :exec
 delete from feed_posts
 where post_did = $1
@@ -36,59 +109,257 @@ func (q *Queries) DeleteFeedPostsForDid(ctx context.Context, postDid string) err
 	return err
 }
 
+const getActiveFeedPins = `-- name: GetActiveFeedPins :many
+select post_did,
+    post_rkey
+from feed_pins
+where feed_did = $1
+    and feed_rkey = $2
+    and (
+        starts_at is null
+        or starts_at <= $3::timestamp
+    )
+    and (
+        ends_at is null
+        or ends_at > $3::timestamp
+    )
+order by position,
+    post_did,
+    post_rkey
+`
+
+type GetActiveFeedPinsParams struct {
+	FeedDid  string
+	FeedRkey string
+	Now      time.Time
+}
+
+type GetActiveFeedPinsRow struct {
+	PostDid  string
+	PostRkey string
+}
+
+func (q *Queries) GetActiveFeedPins(ctx context.Context, arg GetActiveFeedPinsParams) ([]GetActiveFeedPinsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getActiveFeedPins, arg.FeedDid, arg.FeedRkey, arg.Now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetActiveFeedPinsRow
+	for rows.Next() {
+		var i GetActiveFeedPinsRow
+		if err := rows.Scan(&i.PostDid, &i.PostRkey); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFeed = `-- name: GetFeed :one
+select did, rkey, classifier_errors, classifier_timeouts, quarantined, classifier_limit_violations, retention, ranking, half_life, ttl, max_page_size, default_page_size, hide_viewer_posts, hide_blocked_posts, reranker, suspended, classifier_size, reranker_size
+from feeds
+where did = $1
+    and rkey = $2
+`
+
+type GetFeedParams struct {
+	Did  string
+	Rkey string
+}
+
+func (q *Queries) GetFeed(ctx context.Context, arg GetFeedParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeed, arg.Did, arg.Rkey)
+	var i Feed
+	err := row.Scan(
+		&i.Did,
+		&i.Rkey,
+		&i.ClassifierErrors,
+		&i.ClassifierTimeouts,
+		&i.Quarantined,
+		&i.ClassifierLimitViolations,
+		&i.Retention,
+		&i.Ranking,
+		&i.HalfLife,
+		&i.Ttl,
+		&i.MaxPageSize,
+		&i.DefaultPageSize,
+		&i.HideViewerPosts,
+		&i.HideBlockedPosts,
+		&i.Reranker,
+		&i.Suspended,
+		&i.ClassifierSize,
+		&i.RerankerSize,
+	)
+	return i, err
+}
+
+const getFeedPins = `-- name: GetFeedPins :many
+select feed_did, feed_rkey, post_did, post_rkey, position, starts_at, ends_at
+from feed_pins
+where feed_did = $1
+    and feed_rkey = $2
+order by position,
+    post_did,
+    post_rkey
+`
+
+type GetFeedPinsParams struct {
+	FeedDid  string
+	FeedRkey string
+}
+
+func (q *Queries) GetFeedPins(ctx context.Context, arg GetFeedPinsParams) ([]FeedPin, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedPins, arg.FeedDid, arg.FeedRkey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FeedPin
+	for rows.Next() {
+		var i FeedPin
+		if err := rows.Scan(
+			&i.FeedDid,
+			&i.FeedRkey,
+			&i.PostDid,
+			&i.PostRkey,
+			&i.Position,
+			&i.StartsAt,
+			&i.EndsAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFeedPinsForDid = `-- name: GetFeedPinsForDid :many
+select feed_did, feed_rkey, post_did, post_rkey, position, starts_at, ends_at
+from feed_pins
+where feed_did = $1
+order by feed_rkey,
+    position,
+    post_did,
+    post_rkey
+`
+
+func (q *Queries) GetFeedPinsForDid(ctx context.Context, feedDid string) ([]FeedPin, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedPinsForDid, feedDid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FeedPin
+	for rows.Next() {
+		var i FeedPin
+		if err := rows.Scan(
+			&i.FeedDid,
+			&i.FeedRkey,
+			&i.PostDid,
+			&i.PostRkey,
+			&i.Position,
+			&i.StartsAt,
+			&i.EndsAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getFeedPosts = `-- name: GetFeedPosts :many
-with pinned_post as (
-    select pinned_did,
-        pinned_rkey
-    from feeds f
-    where f.did = $1
-        and f.rkey = $2
-),
-feed_posts as (
-    select p.did,
-        p.rkey
-    from posts p
-        join feed_posts fp on p.did = fp.post_did
-        and p.rkey = fp.post_rkey
-    where fp.feed_did = $1
-        and fp.feed_rkey = $2
-        and p.created_at > $3
-    order by fp.weight desc
-    limit $4
-)
-select did,
-    rkey
-from (
-        select pinned_did as did,
-            pinned_rkey as rkey
-        from pinned_post p
-        where p.pinned_did <> ''
-            and p.pinned_rkey <> ''
-        union all
-        select did,
-            rkey
-        from feed_posts
-    ) as results
+select post_did,
+    post_rkey,
+    created_at,
+    score,
+    subject_did,
+    subject_rkey
+from feed_posts
+where feed_did = $1
+    and feed_rkey = $2
+    and created_at > $3
+    and not exists (
+        select 1
+        from feed_pins p
+        where p.feed_did = feed_posts.feed_did
+            and p.feed_rkey = feed_posts.feed_rkey
+            and p.post_did = feed_posts.post_did
+            and p.post_rkey = feed_posts.post_rkey
+            and (
+                p.starts_at is null
+                or p.starts_at <= $4::timestamp
+            )
+            and (
+                p.ends_at is null
+                or p.ends_at > $4::timestamp
+            )
+    )
+    and post_did <> $5::text
+    and (
+        subject_did = ''
+        or subject_did <> $5::text
+    )
+    and not exists (
+        select 1
+        from blocks b
+        where b.did = $6::text
+            and b.subject in (feed_posts.post_did, feed_posts.subject_did)
+    )
+order by score desc,
+    created_at desc,
+    post_did desc,
+    post_rkey desc
+limit $7
 `
 
 type GetFeedPostsParams struct {
-	Did       string
-	Rkey      string
-	CreatedAt time.Time
-	Limit     int32
+	FeedDid     string
+	FeedRkey    string
+	Ttl         time.Time
+	Now         time.Time
+	ExcludedDid string
+	BlockerDid  string
+	PageSize    int32
 }
 
 type GetFeedPostsRow struct {
-	Did  string
-	Rkey string
+	PostDid     string
+	PostRkey    string
+	CreatedAt   time.Time
+	Score       float64
+	SubjectDid  string
+	SubjectRkey string
 }
 
 func (q *Queries) GetFeedPosts(ctx context.Context, arg GetFeedPostsParams) ([]GetFeedPostsRow, error) {
 	rows, err := q.db.QueryContext(ctx, getFeedPosts,
-		arg.Did,
-		arg.Rkey,
-		arg.CreatedAt,
-		arg.Limit,
+		arg.FeedDid,
+		arg.FeedRkey,
+		arg.Ttl,
+		arg.Now,
+		arg.ExcludedDid,
+		arg.BlockerDid,
+		arg.PageSize,
 	)
 	if err != nil {
 		return nil, err
@@ -97,7 +368,14 @@ func (q *Queries) GetFeedPosts(ctx context.Context, arg GetFeedPostsParams) ([]G
 	var items []GetFeedPostsRow
 	for rows.Next() {
 		var i GetFeedPostsRow
-		if err := rows.Scan(&i.Did, &i.Rkey); err != nil {
+		if err := rows.Scan(
+			&i.PostDid,
+			&i.PostRkey,
+			&i.CreatedAt,
+			&i.Score,
+			&i.SubjectDid,
+			&i.SubjectRkey,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -112,50 +390,92 @@ func (q *Queries) GetFeedPosts(ctx context.Context, arg GetFeedPostsParams) ([]G
 }
 
 const getFeedPostsCursor = `-- name: GetFeedPostsCursor :many
-with referenceposttime as (
-    select created_at
-    from posts
-    where posts.did = $5
-        and posts.rkey = $6
-)
-select p.did,
-    p.rkey
-from posts p
-    join feed_posts fp on p.did = fp.post_did
-    and p.rkey = fp.post_rkey
-where fp.feed_did = $1
-    and fp.feed_rkey = $2
-    and p.created_at > $3
-    and p.created_at < (
-        select created_at
-        from referenceposttime
+select post_did,
+    post_rkey,
+    created_at,
+    score,
+    subject_did,
+    subject_rkey
+from feed_posts
+where feed_did = $1
+    and feed_rkey = $2
+    and created_at > $3
+    and not exists (
+        select 1
+        from feed_pins p
+        where p.feed_did = feed_posts.feed_did
+            and p.feed_rkey = feed_posts.feed_rkey
+            and p.post_did = feed_posts.post_did
+            and p.post_rkey = feed_posts.post_rkey
+            and (
+                p.starts_at is null
+                or p.starts_at <= $4::timestamp
+            )
+            and (
+                p.ends_at is null
+                or p.ends_at > $4::timestamp
+            )
+    )
+    and post_did <> $5::text
+    and (
+        subject_did = ''
+        or subject_did <> $5::text
+    )
+    and not exists (
+        select 1
+        from blocks b
+        where b.did = $6::text
+            and b.subject in (feed_posts.post_did, feed_posts.subject_did)
     )
-order by fp.weight desc
-limit $4
+    and (score, created_at, post_did, post_rkey) < (
+        $7::double precision,
+        $8::timestamp,
+        $9::text,
+        $10::text
+    )
+order by score desc,
+    created_at desc,
+    post_did desc,
+    post_rkey desc
+limit $11
 `
 
 type GetFeedPostsCursorParams struct {
-	FeedDid   string
-	FeedRkey  string
-	CreatedAt time.Time
-	Limit     int32
-	Did       string
-	Rkey      string
+	FeedDid         string
+	FeedRkey        string
+	Ttl             time.Time
+	Now             time.Time
+	ExcludedDid     string
+	BlockerDid      string
+	CursorScore     float64
+	CursorCreatedAt time.Time
+	CursorDid       string
+	CursorRkey      string
+	PageSize        int32
 }
 
 type GetFeedPostsCursorRow struct {
-	Did  string
-	Rkey string
+	PostDid     string
+	PostRkey    string
+	CreatedAt   time.Time
+	Score       float64
+	SubjectDid  string
+	SubjectRkey string
 }
 
 func (q *Queries) GetFeedPostsCursor(ctx context.Context, arg GetFeedPostsCursorParams) ([]GetFeedPostsCursorRow, error) {
 	rows, err := q.db.QueryContext(ctx, getFeedPostsCursor,
 		arg.FeedDid,
 		arg.FeedRkey,
-		arg.CreatedAt,
-		arg.Limit,
-		arg.Did,
-		arg.Rkey,
+		arg.Ttl,
+		arg.Now,
+		arg.ExcludedDid,
+		arg.BlockerDid,
+		arg.CursorScore,
+		arg.CursorCreatedAt,
+		arg.CursorDid,
+		arg.CursorRkey,
+		arg.PageSize,
 	)
 	if err != nil {
 		return nil, err
@@ -164,7 +484,14 @@ func (q *Queries) GetFeedPostsCursor(ctx context.Context, arg GetFeedPostsCursor
 	var items []GetFeedPostsCursorRow
 	for rows.Next() {
 		var i GetFeedPostsCursorRow
-		if err := rows.Scan(&i.Did, &i.Rkey); err != nil {
+		if err := rows.Scan(
+			&i.PostDid,
+			&i.PostRkey,
+			&i.CreatedAt,
+			&i.Score,
+			&i.SubjectDid,
+			&i.SubjectRkey,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -179,7 +506,7 @@ func (q *Queries) GetFeedPostsCursor(ctx context.Context, arg GetFeedPostsCursor
 }
 
 const getFeedPostsForDid = `-- name: GetFeedPostsForDid :many
-select feed_did, feed_rkey, post_did, post_rkey, weight
+select feed_did, feed_rkey, post_did, post_rkey, weight, created_at, score, subject_did, subject_rkey
 from feed_posts
 where post_did = $1
 `
@@ -199,6 +526,88 @@ func (q *Queries) GetFeedPostsForDid(ctx context.Context, postDid string) ([]Fee
 			&i.PostDid,
 			&i.PostRkey,
 			&i.Weight,
+			&i.CreatedAt,
+			&i.Score,
+			&i.SubjectDid,
+			&i.SubjectRkey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFeedStats = `-- name: GetFeedStats :many
+select feeds.did, feeds.rkey, feeds.classifier_errors, feeds.classifier_timeouts, feeds.quarantined, feeds.classifier_limit_violations, feeds.retention, feeds.ranking, feeds.half_life, feeds.ttl, feeds.max_page_size, feeds.default_page_size, feeds.hide_viewer_posts, feeds.hide_blocked_posts, feeds.reranker, feeds.suspended, feeds.classifier_size, feeds.reranker_size,
+    (
+        select count(*)
+        from feed_posts
+        where feed_posts.feed_did = feeds.did
+            and feed_posts.feed_rkey = feeds.rkey
+    ) as posts
+from feeds
+order by feeds.did,
+    feeds.rkey
+`
+
+type GetFeedStatsRow struct {
+	Did                       string
+	Rkey                      string
+	ClassifierErrors          int32
+	ClassifierTimeouts        int32
+	Quarantined               bool
+	ClassifierLimitViolations int32
+	Retention                 int32
+	Ranking                   string
+	HalfLife                  int32
+	Ttl                       int32
+	MaxPageSize               int32
+	DefaultPageSize           int32
+	HideViewerPosts           bool
+	HideBlockedPosts          bool
+	Reranker                  bool
+	Suspended                 bool
+	ClassifierSize            int64
+	RerankerSize              int64
+	Posts                     int64
+}
+
+func (q *Queries) GetFeedStats(ctx context.Context) ([]GetFeedStatsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedStats)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFeedStatsRow
+	for rows.Next() {
+		var i GetFeedStatsRow
+		if err := rows.Scan(
+			&i.Did,
+			&i.Rkey,
+			&i.ClassifierErrors,
+			&i.ClassifierTimeouts,
+			&i.Quarantined,
+			&i.ClassifierLimitViolations,
+			&i.Retention,
+			&i.Ranking,
+			&i.HalfLife,
+			&i.Ttl,
+			&i.MaxPageSize,
+			&i.DefaultPageSize,
+			&i.HideViewerPosts,
+			&i.HideBlockedPosts,
+			&i.Reranker,
+			&i.Suspended,
+			&i.ClassifierSize,
+			&i.RerankerSize,
+			&i.Posts,
 		); err != nil {
 			return nil, err
 		}
@@ -214,7 +623,7 @@ func (q *Queries) GetFeedPostsForDid(ctx context.Context, postDid string) ([]Fee
 }
 
 const getFeeds = `-- name: GetFeeds :many
-select did, rkey, pinned_did, pinned_rkey
+select did, rkey, classifier_errors, classifier_timeouts, quarantined, classifier_limit_violations, retention, ranking, half_life, ttl, max_page_size, default_page_size, hide_viewer_posts, hide_blocked_posts, reranker, suspended, classifier_size, reranker_size
 from feeds
 `
 
@@ -230,8 +639,22 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 		if err := rows.Scan(
 			&i.Did,
 			&i.Rkey,
-			&i.PinnedDid,
-			&i.PinnedRkey,
+			&i.ClassifierErrors,
+			&i.ClassifierTimeouts,
+			&i.Quarantined,
+			&i.ClassifierLimitViolations,
+			&i.Retention,
+			&i.Ranking,
+			&i.HalfLife,
+			&i.Ttl,
+			&i.MaxPageSize,
+			&i.DefaultPageSize,
+			&i.HideViewerPosts,
+			&i.HideBlockedPosts,
+			&i.Reranker,
+			&i.Suspended,
+			&i.ClassifierSize,
+			&i.RerankerSize,
 		); err != nil {
 			return nil, err
 		}
@@ -247,7 +670,7 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 }
 
 const getFeedsForDid = `-- name: GetFeedsForDid :many
-select did, rkey, pinned_did, pinned_rkey
+select did, rkey, classifier_errors, classifier_timeouts, quarantined, classifier_limit_violations, retention, ranking, half_life, ttl, max_page_size, default_page_size, hide_viewer_posts, hide_blocked_posts, reranker, suspended, classifier_size, reranker_size
 from feeds
 where did = $1
 `
@@ -264,8 +687,22 @@ func (q *Queries) GetFeedsForDid(ctx context.Context, did string) ([]Feed, error
 		if err := rows.Scan(
 			&i.Did,
 			&i.Rkey,
-			&i.PinnedDid,
-			&i.PinnedRkey,
+			&i.ClassifierErrors,
+			&i.ClassifierTimeouts,
+			&i.Quarantined,
+			&i.ClassifierLimitViolations,
+			&i.Retention,
+			&i.Ranking,
+			&i.HalfLife,
+			&i.Ttl,
+			&i.MaxPageSize,
+			&i.DefaultPageSize,
+			&i.HideViewerPosts,
+			&i.HideBlockedPosts,
+			&i.Reranker,
+			&i.Suspended,
+			&i.ClassifierSize,
+			&i.RerankerSize,
 		); err != nil {
 			return nil, err
 		}
@@ -280,74 +717,436 @@ func (q *Queries) GetFeedsForDid(ctx context.Context, did string) ([]Feed, error
 	return items, nil
 }
 
-const upsertFeedClassifier = `-- name: UpsertFeedClassifier :exec
-insert into feeds (did, rkey, pinned_did, pinned_rkey)
-values ($1, $2, '', '') on conflict (did, rkey) do nothing
+const getPublicFeeds = `-- name: GetPublicFeeds :many
+select did,
+    rkey
+from feeds
+where not quarantined
+    and not suspended
+order by did,
+    rkey
 `
 
-type UpsertFeedClassifierParams struct {
+type GetPublicFeedsRow struct {
 	Did  string
 	Rkey string
 }
 
-func (q *Queries) UpsertFeedClassifier(ctx context.Context, arg UpsertFeedClassifierParams) error {
-	_, err := q.db.ExecContext(ctx, upsertFeedClassifier, arg.Did, arg.Rkey)
+func (q *Queries) GetPublicFeeds(ctx context.Context) ([]GetPublicFeedsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPublicFeeds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPublicFeedsRow
+	for rows.Next() {
+		var i GetPublicFeedsRow
+		if err := rows.Scan(&i.Did, &i.Rkey); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const incrementFeedClassifierErrors = `-- name: IncrementFeedClassifierErrors :one
+update feeds
+set classifier_errors = classifier_errors + 1
+where did = $1
+    and rkey = $2
+returning classifier_errors,
+    classifier_timeouts
+`
+
+type IncrementFeedClassifierErrorsParams struct {
+	Did  string
+	Rkey string
+}
+
+type IncrementFeedClassifierErrorsRow struct {
+	ClassifierErrors   int32
+	ClassifierTimeouts int32
+}
+
+func (q *Queries) IncrementFeedClassifierErrors(ctx context.Context, arg IncrementFeedClassifierErrorsParams) (IncrementFeedClassifierErrorsRow, error) {
+	row := q.db.QueryRowContext(ctx, incrementFeedClassifierErrors, arg.Did, arg.Rkey)
+	var i IncrementFeedClassifierErrorsRow
+	err := row.Scan(&i.ClassifierErrors, &i.ClassifierTimeouts)
+	return i, err
+}
+
+const incrementFeedClassifierLimitViolations = `-- name: IncrementFeedClassifierLimitViolations :exec
+update feeds
+set classifier_limit_violations = classifier_limit_violations + 1
+where did = $1
+    and rkey = $2
+`
+
+type IncrementFeedClassifierLimitViolationsParams struct {
+	Did  string
+	Rkey string
+}
+
+func (q *Queries) IncrementFeedClassifierLimitViolations(ctx context.Context, arg IncrementFeedClassifierLimitViolationsParams) error {
+	_, err := q.db.ExecContext(ctx, incrementFeedClassifierLimitViolations, arg.Did, arg.Rkey)
 	return err
 }
 
-const upsertFeedMetadata = `-- name: UpsertFeedMetadata :exec
-insert into feeds (did, rkey, pinned_did, pinned_rkey)
-values ($1, $2, $3, $4) on conflict (did, rkey) do
-update
-set pinned_did = excluded.pinned_did,
-    pinned_rkey = excluded.pinned_rkey
+const incrementFeedClassifierTimeouts = `-- name: IncrementFeedClassifierTimeouts :one
+update feeds
+set classifier_timeouts = classifier_timeouts + 1
+where did = $1
+    and rkey = $2
+returning classifier_errors,
+    classifier_timeouts
 `
 
-type UpsertFeedMetadataParams struct {
-	Did        string
-	Rkey       string
-	PinnedDid  string
-	PinnedRkey string
+type IncrementFeedClassifierTimeoutsParams struct {
+	Did  string
+	Rkey string
+}
+
+type IncrementFeedClassifierTimeoutsRow struct {
+	ClassifierErrors   int32
+	ClassifierTimeouts int32
 }
 
-func (q *Queries) UpsertFeedMetadata(ctx context.Context, arg UpsertFeedMetadataParams) error {
-	_, err := q.db.ExecContext(ctx, upsertFeedMetadata,
+func (q *Queries) IncrementFeedClassifierTimeouts(ctx context.Context, arg IncrementFeedClassifierTimeoutsParams) (IncrementFeedClassifierTimeoutsRow, error) {
+	row := q.db.QueryRowContext(ctx, incrementFeedClassifierTimeouts, arg.Did, arg.Rkey)
+	var i IncrementFeedClassifierTimeoutsRow
+	err := row.Scan(&i.ClassifierErrors, &i.ClassifierTimeouts)
+	return i, err
+}
+
+const quarantineFeed = `-- name: QuarantineFeed :execrows
+update feeds
+set quarantined = true
+where did = $1
+    and rkey = $2
+    and not quarantined
+`
+
+type QuarantineFeedParams struct {
+	Did  string
+	Rkey string
+}
+
+func (q *Queries) QuarantineFeed(ctx context.Context, arg QuarantineFeedParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, quarantineFeed, arg.Did, arg.Rkey)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateFeedPageSettings = `-- name: UpdateFeedPageSettings :exec
+update feeds
+set ttl = coalesce($1, ttl),
+    max_page_size = coalesce($2, max_page_size),
+    default_page_size = coalesce($3, default_page_size)
+where did = $4
+    and rkey = $5
+`
+
+type UpdateFeedPageSettingsParams struct {
+	Ttl             sql.NullInt32
+	MaxPageSize     sql.NullInt32
+	DefaultPageSize sql.NullInt32
+	Did             string
+	Rkey            string
+}
+
+func (q *Queries) UpdateFeedPageSettings(ctx context.Context, arg UpdateFeedPageSettingsParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedPageSettings,
+		arg.Ttl,
+		arg.MaxPageSize,
+		arg.DefaultPageSize,
 		arg.Did,
 		arg.Rkey,
-		arg.PinnedDid,
-		arg.PinnedRkey,
 	)
 	return err
 }
 
-const upsertFeedPost = `-- name: UpsertFeedPost :exec
-insert into feed_posts (
+const updateFeedRanking = `-- name: UpdateFeedRanking :exec
+with updated_feed as (
+    update feeds
+    set ranking = $3,
+        half_life = $4
+    where did = $1
+        and rkey = $2
+    returning did,
+        rkey,
+        ranking,
+        half_life
+)
+update feed_posts f
+set score = case updated_feed.ranking
+        when 'newest' then extract(
+            epoch
+            from f.created_at
+        )::double precision
+        when 'decayed' then ln(f.weight + 1) + extract(
+            epoch
+            from f.created_at
+        )::double precision * ln(2) / greatest(updated_feed.half_life, 1)
+        else f.weight
+    end
+from updated_feed
+where f.feed_did = updated_feed.did
+    and f.feed_rkey = updated_feed.rkey
+`
+
+type UpdateFeedRankingParams struct {
+	Did      string
+	Rkey     string
+	Ranking  string
+	HalfLife int32
+}
+
+func (q *Queries) UpdateFeedRanking(ctx context.Context, arg UpdateFeedRankingParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedRanking,
+		arg.Did,
+		arg.Rkey,
+		arg.Ranking,
+		arg.HalfLife,
+	)
+	return err
+}
+
+const updateFeedRetention = `-- name: UpdateFeedRetention :exec
+update feeds
+set retention = $3
+where did = $1
+    and rkey = $2
+`
+
+type UpdateFeedRetentionParams struct {
+	Did       string
+	Rkey      string
+	Retention int32
+}
+
+func (q *Queries) UpdateFeedRetention(ctx context.Context, arg UpdateFeedRetentionParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedRetention, arg.Did, arg.Rkey, arg.Retention)
+	return err
+}
+
+const updateFeedReranker = `-- name: UpdateFeedReranker :execrows
+update feeds
+set reranker = $3,
+    reranker_size = $4
+where did = $1
+    and rkey = $2
+`
+
+type UpdateFeedRerankerParams struct {
+	Did          string
+	Rkey         string
+	Reranker     bool
+	RerankerSize int64
+}
+
+func (q *Queries) UpdateFeedReranker(ctx context.Context, arg UpdateFeedRerankerParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateFeedReranker,
+		arg.Did,
+		arg.Rkey,
+		arg.Reranker,
+		arg.RerankerSize,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateFeedSuspended = `-- name: UpdateFeedSuspended :execrows
+update feeds
+set suspended = $3
+where did = $1
+    and rkey = $2
+`
+
+type UpdateFeedSuspendedParams struct {
+	Did       string
+	Rkey      string
+	Suspended bool
+}
+
+func (q *Queries) UpdateFeedSuspended(ctx context.Context, arg UpdateFeedSuspendedParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateFeedSuspended, arg.Did, arg.Rkey, arg.Suspended)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateFeedViewerFilters = `-- name: UpdateFeedViewerFilters :exec
+update feeds
+set hide_viewer_posts = coalesce($1, hide_viewer_posts),
+    hide_blocked_posts = coalesce($2, hide_blocked_posts)
+where did = $3
+    and rkey = $4
+`
+
+type UpdateFeedViewerFiltersParams struct {
+	HideViewerPosts  sql.NullBool
+	HideBlockedPosts sql.NullBool
+	Did              string
+	Rkey             string
+}
+
+func (q *Queries) UpdateFeedViewerFilters(ctx context.Context, arg UpdateFeedViewerFiltersParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedViewerFilters,
+		arg.HideViewerPosts,
+		arg.HideBlockedPosts,
+		arg.Did,
+		arg.Rkey,
+	)
+	return err
+}
+
+const upsertFeedClassifier = `-- name: UpsertFeedClassifier :exec
+insert into feeds (did, rkey, classifier_size)
+values ($1, $2, $3) on conflict (did, rkey) do
+update
+set classifier_errors = 0,
+    classifier_timeouts = 0,
+    classifier_limit_violations = 0,
+    quarantined = false,
+    classifier_size = excluded.classifier_size
+`
+
+type UpsertFeedClassifierParams struct {
+	Did            string
+	Rkey           string
+	ClassifierSize int64
+}
+
+func (q *Queries) UpsertFeedClassifier(ctx context.Context, arg UpsertFeedClassifierParams) error {
+	_, err := q.db.ExecContext(ctx, upsertFeedClassifier, arg.Did, arg.Rkey, arg.ClassifierSize)
+	return err
+}
+
+const upsertFeedPin = `-- name: UpsertFeedPin :execrows
+insert into feed_pins (
         feed_did,
         feed_rkey,
         post_did,
         post_rkey,
-        weight
+        position,
+        starts_at,
+        ends_at
     )
-values ($1, $2, $3, $4, $5) on conflict (feed_did, feed_rkey, post_did, post_rkey) do
+select did,
+    rkey,
+    $1::text,
+    $2::text,
+    $3::int,
+    $4::timestamp,
+    $5::timestamp
+from feeds
+where did = $6
+    and rkey = $7 on conflict (feed_did, feed_rkey, post_did, post_rkey) do
 update
-set weight = excluded.weight
+set position = excluded.position,
+    starts_at = excluded.starts_at,
+    ends_at = excluded.ends_at
 `
 
-type UpsertFeedPostParams struct {
-	FeedDid  string
-	FeedRkey string
+type UpsertFeedPinParams struct {
 	PostDid  string
 	PostRkey string
-	Weight   int32
+	Position int32
+	StartsAt sql.NullTime
+	EndsAt   sql.NullTime
+	FeedDid  string
+	FeedRkey string
 }
 
-func (q *Queries) UpsertFeedPost(ctx context.Context, arg UpsertFeedPostParams) error {
-	_, err := q.db.ExecContext(ctx, upsertFeedPost,
-		arg.FeedDid,
-		arg.FeedRkey,
+func (q *Queries) UpsertFeedPin(ctx context.Context, arg UpsertFeedPinParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, upsertFeedPin,
 		arg.PostDid,
 		arg.PostRkey,
-		arg.Weight,
+		arg.Position,
+		arg.StartsAt,
+		arg.EndsAt,
+		arg.FeedDid,
+		arg.FeedRkey,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const upsertFeedPosts = `-- name: UpsertFeedPosts :exec
+insert into feed_posts (
+        feed_did,
+        feed_rkey,
+        post_did,
+        post_rkey,
+        weight,
+        created_at,
+        score,
+        subject_did,
+        subject_rkey
+    )
+select f.feed_did,
+    f.feed_rkey,
+    f.post_did,
+    f.post_rkey,
+    f.weight,
+    posts.created_at,
+    case feeds.ranking
+        when 'newest' then extract(
+            epoch
+            from posts.created_at
+        )::double precision
+        when 'decayed' then ln(f.weight + 1) + extract(
+            epoch
+            from posts.created_at
+        )::double precision * ln(2) / greatest(feeds.half_life, 1)
+        else f.weight
+    end,
+    posts.subject_did,
+    posts.subject_rkey
+from unnest(
+        $1::text [],
+        $2::text [],
+        $3::text [],
+        $4::text [],
+        $5::int []
+    ) as f(feed_did, feed_rkey, post_did, post_rkey, weight)
+    join feeds on feeds.did = f.feed_did
+    and feeds.rkey = f.feed_rkey
+    join posts on posts.did = f.post_did
+    and posts.rkey = f.post_rkey on conflict (feed_did, feed_rkey, post_did, post_rkey) do
+update
+set weight = excluded.weight,
+    score = excluded.score
+`
+
+type UpsertFeedPostsParams struct {
+	FeedDids  []string
+	FeedRkeys []string
+	PostDids  []string
+	PostRkeys []string
+	Weights   []int32
+}
+
+func (q *Queries) UpsertFeedPosts(ctx context.Context, arg UpsertFeedPostsParams) error {
+	_, err := q.db.ExecContext(ctx, upsertFeedPosts,
+		pq.Array(arg.FeedDids),
+		pq.Array(arg.FeedRkeys),
+		pq.Array(arg.PostDids),
+		pq.Array(arg.PostRkeys),
+		pq.Array(arg.Weights),
 	)
 	return err
 }
@@ -5,30 +5,123 @@
 package models
 
 import (
+	"database/sql"
 	"time"
 )
 
-type Feed struct {
+type AuditEvent struct {
+	ID             int64
+	Did            string
+	Action         string
+	FeedDid        string
+	FeedRkey       string
+	ClassifierHash string
+	Origin         string
+	CreatedAt      time.Time
+}
+
+type ApiToken struct {
+	ID          string
+	Did         string
+	Hash        string
+	Description string
+	Feeds       []string
+	Scopes      []string
+	CreatedAt   time.Time
+}
+
+type BannedDid struct {
+	Did       string
+	Reason    string
+	CreatedAt time.Time
+}
+
+type Block struct {
+	Did     string
+	Rkey    string
+	Subject string
+}
+
+type BlockViewer struct {
 	Did        string
-	Rkey       string
-	PinnedDid  string
-	PinnedRkey string
+	LastSeenAt time.Time
 }
 
-type FeedPost struct {
+type Feed struct {
+	Did                       string
+	Rkey                      string
+	ClassifierErrors          int32
+	ClassifierTimeouts        int32
+	Quarantined               bool
+	ClassifierLimitViolations int32
+	Retention                 int32
+	Ranking                   string
+	HalfLife                  int32
+	Ttl                       int32
+	MaxPageSize               int32
+	DefaultPageSize           int32
+	HideViewerPosts           bool
+	HideBlockedPosts          bool
+	Reranker                  bool
+	Suspended                 bool
+	ClassifierSize            int64
+	RerankerSize              int64
+}
+
+type FeedCollaborator struct {
+	FeedDid  string
+	FeedRkey string
+	Did      string
+	Role     string
+}
+
+type FeedPin struct {
 	FeedDid  string
 	FeedRkey string
 	PostDid  string
 	PostRkey string
-	Weight   int32
+	Position int32
+	StartsAt sql.NullTime
+	EndsAt   sql.NullTime
 }
 
-type Post struct {
+type FeedPost struct {
+	FeedDid     string
+	FeedRkey    string
+	PostDid     string
+	PostRkey    string
+	Weight      int32
+	CreatedAt   time.Time
+	Score       float64
+	SubjectDid  string
+	SubjectRkey string
+}
+
+type Interaction struct {
+	FeedDid   string
+	FeedRkey  string
+	PostDid   string
+	PostRkey  string
 	Did       string
-	Rkey      string
+	Event     string
 	CreatedAt time.Time
-	Text      string
-	Reply     bool
-	Langs     []string
-	Likes     int32
+}
+
+type Post struct {
+	Did         string
+	Rkey        string
+	CreatedAt   time.Time
+	Text        string
+	Reply       bool
+	Langs       []string
+	Likes       int32
+	SubjectDid  string
+	SubjectRkey string
+}
+
+type Quota struct {
+	Did               string
+	MaxFeeds          sql.NullInt32
+	MaxClassifierSize sql.NullInt64
+	MaxStorage        sql.NullInt64
 }
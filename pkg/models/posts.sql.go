@@ -12,7 +12,7 @@ import (
 	"github.com/lib/pq"
 )
 
-const createPost = `-- name: CreatePost :one
+const createPosts = `-- name: CreatePosts :many
 insert into posts (
         did,
         rkey,
@@ -20,41 +20,94 @@ insert into posts (
         text,
         reply,
         langs,
-        likes
+        likes,
+        subject_did,
+        subject_rkey
     )
-values ($1, $2, $3, $4, $5, $6, 0)
-returning did, rkey, created_at, text, reply, langs, likes
+select p.did,
+    p.rkey,
+    p.created_at,
+    coalesce(s.text, p.text),
+    coalesce(s.reply, p.reply),
+    coalesce(s.langs, regexp_split_to_array(p.langs, ',')),
+    0,
+    p.subject_did,
+    p.subject_rkey
+from unnest(
+        $1::text [],
+        $2::text [],
+        $3::timestamp [],
+        $4::text [],
+        $5::boolean [],
+        $6::text [],
+        $7::text [],
+        $8::text []
+    ) as p(
+        did,
+        rkey,
+        created_at,
+        text,
+        reply,
+        langs,
+        subject_did,
+        subject_rkey
+    )
+    left join posts s on s.did = p.subject_did
+    and s.rkey = p.subject_rkey on conflict (did, rkey) do nothing
+returning did, rkey, created_at, text, reply, langs, likes, subject_did, subject_rkey
 `
 
-type CreatePostParams struct {
-	Did       string
-	Rkey      string
-	CreatedAt time.Time
-	Text      string
-	Reply     bool
-	Langs     []string
+type CreatePostsParams struct {
+	Dids         []string
+	Rkeys        []string
+	CreatedAts   []time.Time
+	Texts        []string
+	Replies      []bool
+	Langs        []string
+	SubjectDids  []string
+	SubjectRkeys []string
 }
 
-func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
-	row := q.db.QueryRowContext(ctx, createPost,
-		arg.Did,
-		arg.Rkey,
-		arg.CreatedAt,
-		arg.Text,
-		arg.Reply,
+func (q *Queries) CreatePosts(ctx context.Context, arg CreatePostsParams) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, createPosts,
+		pq.Array(arg.Dids),
+		pq.Array(arg.Rkeys),
+		pq.Array(arg.CreatedAts),
+		pq.Array(arg.Texts),
+		pq.Array(arg.Replies),
 		pq.Array(arg.Langs),
+		pq.Array(arg.SubjectDids),
+		pq.Array(arg.SubjectRkeys),
 	)
-	var i Post
-	err := row.Scan(
-		&i.Did,
-		&i.Rkey,
-		&i.CreatedAt,
-		&i.Text,
-		&i.Reply,
-		pq.Array(&i.Langs),
-		&i.Likes,
-	)
-	return i, err
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Post
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.Did,
+			&i.Rkey,
+			&i.CreatedAt,
+			&i.Text,
+			&i.Reply,
+			pq.Array(&i.Langs),
+			&i.Likes,
+			&i.SubjectDid,
+			&i.SubjectRkey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
 const deleteAllPosts = `-- name: DeleteAllPosts :exec
@@ -66,10 +119,40 @@ func (q *Queries) DeleteAllPosts(ctx context.Context) error {
 	return err
 }
 
+const deleteExpiredPosts = `-- name: DeleteExpiredPosts :execrows
+delete from posts
+where (did, rkey) in (
+        select did,
+            rkey
+        from posts
+        where created_at < $1
+        limit $2
+    )
+`
+
+type DeleteExpiredPostsParams struct {
+	CreatedAt time.Time
+	Limit     int32
+}
+
+func (q *Queries) DeleteExpiredPosts(ctx context.Context, arg DeleteExpiredPostsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteExpiredPosts, arg.CreatedAt, arg.Limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const deletePost = `-- name: DeletePost :exec
 delete from posts
-where did = $1
-    and rkey = $2
+where (
+        did = $1
+        and rkey = $2
+    )
+    or (
+        subject_did = $1
+        and subject_rkey = $2
+    )
 `
 
 type DeletePostParams struct {
@@ -85,6 +168,7 @@ func (q *Queries) DeletePost(ctx context.Context, arg DeletePostParams) error {
 const deletePostsForDid = `-- name: DeletePostsForDid :exec
 delete from posts
 where did = $1
+    or subject_did = $1
 `
 
 func (q *Queries) DeletePostsForDid(ctx context.Context, did string) error {
@@ -92,8 +176,53 @@ func (q *Queries) DeletePostsForDid(ctx context.Context, did string) error {
 	return err
 }
 
+const getPosts = `-- name: GetPosts :many
+select posts.did, posts.rkey, posts.created_at, posts.text, posts.reply, posts.langs, posts.likes, posts.subject_did, posts.subject_rkey
+from posts
+    join unnest($1::text [], $2::text []) as p(did, rkey) on posts.did = p.did
+    and posts.rkey = p.rkey
+`
+
+type GetPostsParams struct {
+	Dids  []string
+	Rkeys []string
+}
+
+func (q *Queries) GetPosts(ctx context.Context, arg GetPostsParams) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, getPosts, pq.Array(arg.Dids), pq.Array(arg.Rkeys))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Post
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.Did,
+			&i.Rkey,
+			&i.CreatedAt,
+			&i.Text,
+			&i.Reply,
+			pq.Array(&i.Langs),
+			&i.Likes,
+			&i.SubjectDid,
+			&i.SubjectRkey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getPostsForDid = `-- name: GetPostsForDid :many
-select did, rkey, created_at, text, reply, langs, likes
+select did, rkey, created_at, text, reply, langs, likes, subject_did, subject_rkey
 from posts
 where did = $1
 `
@@ -115,6 +244,8 @@ func (q *Queries) GetPostsForDid(ctx context.Context, did string) ([]Post, error
 			&i.Reply,
 			pq.Array(&i.Langs),
 			&i.Likes,
+			&i.SubjectDid,
+			&i.SubjectRkey,
 		); err != nil {
 			return nil, err
 		}
@@ -129,30 +260,54 @@ func (q *Queries) GetPostsForDid(ctx context.Context, did string) ([]Post, error
 	return items, nil
 }
 
-const likePost = `-- name: LikePost :one
+const likePosts = `-- name: LikePosts :many
 update posts
-set likes = likes + 1
-where did = $1
-    and rkey = $2
-returning did, rkey, created_at, text, reply, langs, likes
+set likes = posts.likes + l.likes
+from unnest(
+        $1::text [],
+        $2::text [],
+        $3::int []
+    ) as l(did, rkey, likes)
+where posts.did = l.did
+    and posts.rkey = l.rkey
+returning posts.did, posts.rkey, posts.created_at, posts.text, posts.reply, posts.langs, posts.likes, posts.subject_did, posts.subject_rkey
 `
 
-type LikePostParams struct {
-	Did  string
-	Rkey string
+type LikePostsParams struct {
+	Dids  []string
+	Rkeys []string
+	Likes []int32
 }
 
-func (q *Queries) LikePost(ctx context.Context, arg LikePostParams) (Post, error) {
-	row := q.db.QueryRowContext(ctx, likePost, arg.Did, arg.Rkey)
-	var i Post
-	err := row.Scan(
-		&i.Did,
-		&i.Rkey,
-		&i.CreatedAt,
-		&i.Text,
-		&i.Reply,
-		pq.Array(&i.Langs),
-		&i.Likes,
-	)
-	return i, err
+func (q *Queries) LikePosts(ctx context.Context, arg LikePostsParams) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, likePosts, pq.Array(arg.Dids), pq.Array(arg.Rkeys), pq.Array(arg.Likes))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Post
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.Did,
+			&i.Rkey,
+			&i.CreatedAt,
+			&i.Text,
+			&i.Reply,
+			pq.Array(&i.Langs),
+			&i.Likes,
+			&i.SubjectDid,
+			&i.SubjectRkey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
@@ -0,0 +1,64 @@
+package persisters
+
+import (
+	"context"
+
+	"github.com/pojntfx/atmosfeed/pkg/models"
+)
+
+func (p *ManagerPersister) CreateApiToken(
+	ctx context.Context,
+	id string,
+	did string,
+	hash string,
+	description string,
+	feeds []string,
+	scopes []string,
+) (models.ApiToken, error) {
+	return p.queries.CreateApiToken(ctx, models.CreateApiTokenParams{
+		ID:          id,
+		Did:         did,
+		Hash:        hash,
+		Description: description,
+		Feeds:       feeds,
+		Scopes:      scopes,
+	})
+}
+
+func (p *ManagerPersister) GetApiTokenByHash(
+	ctx context.Context,
+	hash string,
+) (models.ApiToken, error) {
+	return p.queries.GetApiTokenByHash(ctx, hash)
+}
+
+func (p *ManagerPersister) GetApiTokensForDid(
+	ctx context.Context,
+	did string,
+) ([]models.ApiToken, error) {
+	return p.queries.GetApiTokensForDid(ctx, did)
+}
+
+// DeleteApiToken deletes a token of a DID, and returns whether it was found
+func (p *ManagerPersister) DeleteApiToken(
+	ctx context.Context,
+	did string,
+	id string,
+) (bool, error) {
+	rows, err := p.queries.DeleteApiToken(ctx, models.DeleteApiTokenParams{
+		Did: did,
+		ID:  id,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+func (p *ManagerPersister) DeleteApiTokensForDid(
+	ctx context.Context,
+	did string,
+) error {
+	return p.queries.DeleteApiTokensForDid(ctx, did)
+}
@@ -0,0 +1,71 @@
+package persisters
+
+import (
+	"context"
+
+	"github.com/pojntfx/atmosfeed/pkg/models"
+)
+
+func (p *ManagerPersister) CreateAuditEvent(
+	ctx context.Context,
+	did string,
+	action string,
+	feedDid string,
+	feedRkey string,
+	classifierHash string,
+	origin string,
+) error {
+	return p.queries.CreateAuditEvent(ctx, models.CreateAuditEventParams{
+		Did:            did,
+		Action:         action,
+		FeedDid:        feedDid,
+		FeedRkey:       feedRkey,
+		ClassifierHash: classifierHash,
+		Origin:         origin,
+	})
+}
+
+func (p *ManagerPersister) GetAuditEventsForFeed(
+	ctx context.Context,
+	feedDid string,
+	feedRkey string,
+	limit int32,
+) ([]models.AuditEvent, error) {
+	return p.queries.GetAuditEventsForFeed(ctx, models.GetAuditEventsForFeedParams{
+		FeedDid:  feedDid,
+		FeedRkey: feedRkey,
+		Limit:    limit,
+	})
+}
+
+// GetAuditEvents returns the latest audit events, optionally only those that were caused by or concern the feeds of a DID
+func (p *ManagerPersister) GetAuditEvents(
+	ctx context.Context,
+	did string,
+	limit int32,
+) ([]models.AuditEvent, error) {
+	return p.queries.GetAuditEvents(ctx, models.GetAuditEventsParams{
+		Did:       did,
+		MaxEvents: limit,
+	})
+}
+
+func (p *ManagerPersister) GetAuditEventsForDid(
+	ctx context.Context,
+	did string,
+) ([]models.AuditEvent, error) {
+	return p.queries.GetAuditEventsForDid(ctx, did)
+}
+
+// PseudonymizeAuditEventsForDid replaces a DID in the audit log with a pseudonym and removes the origins of the events that it caused,
+// which is the only change to audit events that the database allows
+func (p *ManagerPersister) PseudonymizeAuditEventsForDid(
+	ctx context.Context,
+	did string,
+	pseudonym string,
+) error {
+	return p.queries.PseudonymizeAuditEventsForDid(ctx, models.PseudonymizeAuditEventsForDidParams{
+		Did:       did,
+		Pseudonym: pseudonym,
+	})
+}
@@ -0,0 +1,45 @@
+package persisters
+
+import (
+	"context"
+
+	"github.com/pojntfx/atmosfeed/pkg/models"
+)
+
+func (p *ManagerPersister) UpsertBannedDid(
+	ctx context.Context,
+	did string,
+	reason string,
+) error {
+	return p.queries.UpsertBannedDid(ctx, models.UpsertBannedDidParams{
+		Did:    did,
+		Reason: reason,
+	})
+}
+
+func (p *ManagerPersister) GetBannedDids(
+	ctx context.Context,
+) ([]models.BannedDid, error) {
+	return p.queries.GetBannedDids(ctx)
+}
+
+// IsAnyDidBanned returns whether at least one of the DIDs is banned
+func (p *ManagerPersister) IsAnyDidBanned(
+	ctx context.Context,
+	dids ...string,
+) (bool, error) {
+	return p.queries.IsAnyDidBanned(ctx, dids)
+}
+
+// DeleteBannedDid lifts the ban of a DID, and returns whether it was found
+func (p *ManagerPersister) DeleteBannedDid(
+	ctx context.Context,
+	did string,
+) (bool, error) {
+	rows, err := p.queries.DeleteBannedDid(ctx, did)
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
@@ -0,0 +1,86 @@
+package persisters
+
+import (
+	"context"
+	"time"
+
+	"github.com/pojntfx/atmosfeed/pkg/models"
+)
+
+// CreateBlock indexes a block if its author is a viewer of a feed that hides blocked posts, and ignores it otherwise
+func (p *ManagerPersister) CreateBlock(
+	ctx context.Context,
+	did string,
+	rkey string,
+	subject string,
+) error {
+	return p.queries.CreateBlock(ctx, models.CreateBlockParams{
+		Did:     did,
+		Rkey:    rkey,
+		Subject: subject,
+	})
+}
+
+func (p *ManagerPersister) DeleteBlock(
+	ctx context.Context,
+	did string,
+	rkey string,
+) error {
+	return p.queries.DeleteBlock(ctx, models.DeleteBlockParams{
+		Did:  did,
+		Rkey: rkey,
+	})
+}
+
+func (p *ManagerPersister) GetBlocksForDid(
+	ctx context.Context,
+	did string,
+) ([]models.Block, error) {
+	return p.queries.GetBlocksForDid(ctx, did)
+}
+
+// DeleteBlocksForDid removes a DID's blocks and stops indexing new ones until it requests a feed that hides blocked posts again
+func (p *ManagerPersister) DeleteBlocksForDid(
+	ctx context.Context,
+	did string,
+) error {
+	if err := p.queries.DeleteBlockViewer(ctx, did); err != nil {
+		return err
+	}
+
+	return p.queries.DeleteBlocksForDid(ctx, did)
+}
+
+// UpsertBlockViewer starts indexing the blocks of a viewer of a feed that hides blocked posts; to limit writes,
+// the time the viewer was last seen is only updated if it is older than refreshBefore
+func (p *ManagerPersister) UpsertBlockViewer(
+	ctx context.Context,
+	did string,
+	lastSeenAt time.Time,
+	refreshBefore time.Time,
+) error {
+	return p.queries.UpsertBlockViewer(ctx, models.UpsertBlockViewerParams{
+		Did:           did,
+		LastSeenAt:    lastSeenAt,
+		RefreshBefore: refreshBefore,
+	})
+}
+
+func (p *ManagerPersister) DeleteExpiredBlockViewers(
+	ctx context.Context,
+	lastSeenAt time.Time,
+	batchSize int32,
+) (int64, error) {
+	return p.queries.DeleteExpiredBlockViewers(ctx, models.DeleteExpiredBlockViewersParams{
+		LastSeenAt: lastSeenAt,
+		Limit:      batchSize,
+	})
+}
+
+// DeleteOrphanedBlocks removes blocks of DIDs that aren't viewers of a feed that hides blocked posts anymore
+func (p *ManagerPersister) DeleteOrphanedBlocks(
+	ctx context.Context,
+	batchSize int32,
+) (int64, error) {
+	return p.queries.DeleteOrphanedBlocks(ctx, batchSize)
+}
@@ -0,0 +1,768 @@
+package persisters
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	schemeMemory = "memory"
+
+	// Messages that are read with this ID are new messages that haven't been delivered to any consumer yet;
+	// all other IDs read the messages that were delivered to the consumer but not acknowledged, starting after the ID
+	StreamIDNew = ">"
+
+	memorySubscriptionSize = 100
+)
+
+var (
+	ErrStreamNotFound       = errors.New("stream not found")
+	ErrNoCache              = errors.New("no cache available")
+	errInvalidStreamMessage = errors.New("invalid stream message")
+)
+
+// claimStream returns all messages of a stream and deletes it in one step, so that concurrent claims can't return the same messages
+var claimStream = redis.NewScript(`
+local messages = redis.call("XRANGE", KEYS[1], "-", "+")
+redis.call("DEL", KEYS[1])
+
+return messages
+`)
+
+// StreamMessage is a message in a stream; all values are returned as strings
+type StreamMessage struct {
+	ID     string
+	Values map[string]interface{}
+}
+
+// StreamEntry is a message that is appended to a stream
+type StreamEntry struct {
+	Stream string
+	Values map[string]interface{}
+}
+
+// Subscription receives the payloads of the messages that are published to a topic
+type Subscription interface {
+	Channel() <-chan string
+	Close() error
+}
+
+// Broker distributes messages between the manager and workers, either through topics, which deliver each message to all current subscribers,
+// or through streams, which deliver each message to one consumer of the stream's consumer group until it is acknowledged
+type Broker interface {
+	Publish(ctx context.Context, topic string, payload string) error
+	Subscribe(ctx context.Context, topic string) (Subscription, error)
+
+	// CreateStream creates a stream and its consumer group if they don't exist yet, so that messages are kept until they are read;
+	// the group starts at the beginning of the stream, so messages that were appended before it was (re)created are read too
+	CreateStream(ctx context.Context, stream string) error
+	DeleteStream(ctx context.Context, stream string) error
+
+	// AppendToStream adds a message to a stream, where a maxLen of 0 means that the stream isn't trimmed
+	AppendToStream(ctx context.Context, stream string, maxLen int64, values map[string]interface{}) error
+
+	// AppendToStreams adds messages to any amount of streams in a single round trip
+	AppendToStreams(ctx context.Context, maxLen int64, entries []StreamEntry) error
+
+	// ReadStream reads up to count messages for a consumer, blocking for up to block (or until a message arrives if block is 0);
+	// if no message arrived in time, it returns no messages, and if the stream or its consumer group was deleted, it returns ErrStreamNotFound
+	ReadStream(ctx context.Context, stream string, consumer string, id string, count int64, block time.Duration) ([]StreamMessage, error)
+	AcknowledgeStream(ctx context.Context, stream string, ids ...string) error
+
+	// ClaimStream atomically removes a stream and returns all of its messages, including the ones that were delivered but not acknowledged,
+	// so that the messages of a stream are taken over by exactly one caller
+	ClaimStream(ctx context.Context, stream string) ([]StreamMessage, error)
+
+	// ClaimPendingStream takes over up to count messages that were delivered to any consumer at least minIdle ago but not acknowledged,
+	// e.g. because the consumer crashed, and returns them; they are then pending for the given consumer
+	ClaimPendingStream(ctx context.Context, stream string, consumer string, minIdle time.Duration, count int64) ([]StreamMessage, error)
+
+	Close() error
+}
+
+// NewBroker returns the broker for a URL and the Redis client that is used as the cache for sessions, feed skeletons, rate limits and workers;
+// memory:// selects an in-process broker, which doesn't have a cache and only delivers messages within the same process
+func NewBroker(rawURL string) (Broker, *redis.Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if u.Scheme == schemeMemory {
+		return NewMemoryBroker(), nil, nil
+	}
+
+	options, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cache := redis.NewClient(options)
+
+	return NewRedisBroker(cache), cache, nil
+}
+
+// RedisBroker uses Redis pub/sub for topics and Redis streams with a consumer group of the same name for streams
+type RedisBroker struct {
+	client *redis.Client
+}
+
+func NewRedisBroker(client *redis.Client) *RedisBroker {
+	return &RedisBroker{
+		client: client,
+	}
+}
+
+func (b *RedisBroker) Publish(ctx context.Context, topic string, payload string) error {
+	return b.client.Publish(ctx, topic, payload).Err()
+}
+
+type redisSubscription struct {
+	pubsub    *redis.PubSub
+	payloads  chan string
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (s *redisSubscription) Channel() <-chan string {
+	return s.payloads
+}
+
+func (s *redisSubscription) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+	})
+
+	return s.pubsub.Close()
+}
+
+func (b *RedisBroker) Subscribe(ctx context.Context, topic string) (Subscription, error) {
+	pubsub := b.client.Subscribe(ctx, topic)
+
+	// Waiting for the confirmation ensures that no messages that are published after Subscribe returns are missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+
+		return nil, err
+	}
+
+	s := &redisSubscription{
+		pubsub:   pubsub,
+		payloads: make(chan string),
+		closed:   make(chan struct{}),
+	}
+
+	go func() {
+		defer close(s.payloads)
+
+		for message := range pubsub.Channel() {
+			select {
+			case s.payloads <- message.Payload:
+			case <-s.closed:
+				return
+			}
+		}
+	}()
+
+	return s, nil
+}
+
+// isRedisStreamNotFound returns whether Redis rejected a stream command because the stream or its consumer group was deleted,
+// which also unblocks the consumers that are waiting for messages
+func isRedisStreamNotFound(err error) bool {
+	return strings.HasPrefix(err.Error(), errNoGroup) || strings.HasPrefix(err.Error(), errUnblocked)
+}
+
+func (b *RedisBroker) CreateStream(ctx context.Context, stream string) error {
+	if _, err := b.client.XGroupCreateMkStream(ctx, stream, stream, "0").Result(); err != nil && !strings.Contains(err.Error(), errBusyGroup) {
+		return err
+	}
+
+	return nil
+}
+
+func (b *RedisBroker) DeleteStream(ctx context.Context, stream string) error {
+	return b.client.Del(ctx, stream).Err()
+}
+
+func (b *RedisBroker) AppendToStream(ctx context.Context, stream string, maxLen int64, values map[string]interface{}) error {
+	return b.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: stream,
+		MaxLen: maxLen,
+		Approx: maxLen > 0,
+		Values: values,
+	}).Err()
+}
+
+func (b *RedisBroker) AppendToStreams(ctx context.Context, maxLen int64, entries []StreamEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	_, err := b.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		// Pipelines implement all stream commands, but the Pipeliner interface doesn't include them
+		streams, ok := pipe.(redis.StreamCmdable)
+		if !ok {
+			return errors.ErrUnsupported
+		}
+
+		for _, entry := range entries {
+			streams.XAdd(ctx, &redis.XAddArgs{
+				Stream: entry.Stream,
+				MaxLen: maxLen,
+				Approx: maxLen > 0,
+				Values: entry.Values,
+			})
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+func (b *RedisBroker) ReadStream(ctx context.Context, stream string, consumer string, id string, count int64, block time.Duration) ([]StreamMessage, error) {
+	streams, err := b.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    stream,
+		Consumer: consumer,
+		Streams:  []string{stream, id},
+		Block:    block,
+		Count:    count,
+	}).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return []StreamMessage{}, nil
+		}
+
+		if isRedisStreamNotFound(err) {
+			return nil, fmt.Errorf("%w: %v", ErrStreamNotFound, err)
+		}
+
+		return nil, err
+	}
+
+	messages := []StreamMessage{}
+	for _, stream := range streams {
+		for _, message := range stream.Messages {
+			messages = append(messages, StreamMessage{
+				ID:     message.ID,
+				Values: message.Values,
+			})
+		}
+	}
+
+	return messages, nil
+}
+
+func (b *RedisBroker) AcknowledgeStream(ctx context.Context, stream string, ids ...string) error {
+	return b.client.XAck(ctx, stream, stream, ids...).Err()
+}
+
+func (b *RedisBroker) ClaimStream(ctx context.Context, stream string) ([]StreamMessage, error) {
+	res, err := claimStream.Run(ctx, b.client, []string{stream}).Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	// Each message is returned as an array of its ID and an array of alternating field names and values
+	messages := []StreamMessage{}
+	for _, rawMessage := range res {
+		message, ok := rawMessage.([]interface{})
+		if !ok || len(message) != 2 {
+			return nil, errInvalidStreamMessage
+		}
+
+		id, ok := message[0].(string)
+		if !ok {
+			return nil, errInvalidStreamMessage
+		}
+
+		fields, ok := message[1].([]interface{})
+		if !ok || len(fields)%2 != 0 {
+			return nil, errInvalidStreamMessage
+		}
+
+		values := map[string]interface{}{}
+		for i := 0; i < len(fields); i += 2 {
+			key, ok := fields[i].(string)
+			if !ok {
+				return nil, errInvalidStreamMessage
+			}
+
+			values[key] = fields[i+1]
+		}
+
+		messages = append(messages, StreamMessage{
+			ID:     id,
+			Values: values,
+		})
+	}
+
+	return messages, nil
+}
+
+func (b *RedisBroker) ClaimPendingStream(ctx context.Context, stream string, consumer string, minIdle time.Duration, count int64) ([]StreamMessage, error) {
+	claimed, _, err := b.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   stream,
+		Group:    stream,
+		Consumer: consumer,
+		MinIdle:  minIdle,
+		Start:    "0-0",
+		Count:    count,
+	}).Result()
+	if err != nil {
+		if isRedisStreamNotFound(err) {
+			return nil, fmt.Errorf("%w: %v", ErrStreamNotFound, err)
+		}
+
+		return nil, err
+	}
+
+	messages := []StreamMessage{}
+	for _, message := range claimed {
+		messages = append(messages, StreamMessage{
+			ID:     message.ID,
+			Values: message.Values,
+		})
+	}
+
+	return messages, nil
+}
+
+func (b *RedisBroker) Close() error {
+	return b.client.Close()
+}
+
+type memorySubscription struct {
+	broker *MemoryBroker
+	topic  string
+
+	// Publishers hold a read lock while sending, so the payloads channel is only closed once no publisher is sending anymore
+	lock      sync.RWMutex
+	payloads  chan string
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (s *memorySubscription) Channel() <-chan string {
+	return s.payloads
+}
+
+func (s *memorySubscription) publish(ctx context.Context, payload string) error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	// The payloads channel might already be closed if the subscription was closed before we acquired the lock
+	select {
+	case <-s.closed:
+		return nil
+
+	default:
+	}
+
+	select {
+	case s.payloads <- payload:
+		return nil
+
+	case <-s.closed:
+		return nil
+
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *memorySubscription) Close() error {
+	s.closeOnce.Do(func() {
+		s.broker.lock.Lock()
+		delete(s.broker.subscriptions[s.topic], s)
+		s.broker.lock.Unlock()
+
+		// Closing this first unblocks publishers that are waiting for the subscriber
+		close(s.closed)
+
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		close(s.payloads)
+	})
+
+	return nil
+}
+
+type memoryStream struct {
+	lastID      uint64
+	messages    []StreamMessage
+	pending     map[string][]StreamMessage
+	deliveredAt map[string]time.Time
+	available   chan struct{}
+}
+
+// MemoryBroker delivers messages between goroutines of the same process, which is useful for tests and single-process deployments
+type MemoryBroker struct {
+	lock          sync.Mutex
+	subscriptions map[string]map[*memorySubscription]struct{}
+	streams       map[string]*memoryStream
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		subscriptions: map[string]map[*memorySubscription]struct{}{},
+		streams:       map[string]*memoryStream{},
+	}
+}
+
+func (b *MemoryBroker) Publish(ctx context.Context, topic string, payload string) error {
+	b.lock.Lock()
+	subscriptions := []*memorySubscription{}
+	for s := range b.subscriptions[topic] {
+		subscriptions = append(subscriptions, s)
+	}
+	b.lock.Unlock()
+
+	for _, s := range subscriptions {
+		if err := s.publish(ctx, payload); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(ctx context.Context, topic string) (Subscription, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	s := &memorySubscription{
+		broker: b,
+		topic:  topic,
+
+		payloads: make(chan string, memorySubscriptionSize),
+		closed:   make(chan struct{}),
+	}
+
+	if _, ok := b.subscriptions[topic]; !ok {
+		b.subscriptions[topic] = map[*memorySubscription]struct{}{}
+	}
+	b.subscriptions[topic][s] = struct{}{}
+
+	return s, nil
+}
+
+func (b *MemoryBroker) getOrCreateStream(stream string) *memoryStream {
+	s, ok := b.streams[stream]
+	if !ok {
+		s = &memoryStream{
+			messages:    []StreamMessage{},
+			pending:     map[string][]StreamMessage{},
+			deliveredAt: map[string]time.Time{},
+			available:   make(chan struct{}),
+		}
+
+		b.streams[stream] = s
+	}
+
+	return s
+}
+
+func (b *MemoryBroker) CreateStream(ctx context.Context, stream string) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	_ = b.getOrCreateStream(stream)
+
+	return nil
+}
+
+func (b *MemoryBroker) DeleteStream(ctx context.Context, stream string) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.deleteStream(stream)
+
+	return nil
+}
+
+// deleteStream removes a stream and wakes up its consumers, which then return ErrStreamNotFound like Redis does; the caller must hold the lock
+func (b *MemoryBroker) deleteStream(stream string) {
+	s, ok := b.streams[stream]
+	if !ok {
+		return
+	}
+
+	delete(b.streams, stream)
+
+	close(s.available)
+}
+
+func (b *MemoryBroker) AppendToStream(ctx context.Context, stream string, maxLen int64, values map[string]interface{}) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.appendToStream(stream, maxLen, values)
+
+	return nil
+}
+
+func (b *MemoryBroker) AppendToStreams(ctx context.Context, maxLen int64, entries []StreamEntry) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for _, entry := range entries {
+		b.appendToStream(entry.Stream, maxLen, entry.Values)
+	}
+
+	return nil
+}
+
+// appendToStream adds a message to a stream; the caller must hold the lock
+func (b *MemoryBroker) appendToStream(stream string, maxLen int64, values map[string]interface{}) {
+	s := b.getOrCreateStream(stream)
+
+	s.lastID++
+
+	// Values are stored as strings, just like Redis does
+	message := StreamMessage{
+		ID:     fmt.Sprintf("%v-0", s.lastID),
+		Values: map[string]interface{}{},
+	}
+	for key, value := range values {
+		message.Values[key] = fmt.Sprint(value)
+	}
+
+	s.messages = append(s.messages, message)
+	if maxLen > 0 && int64(len(s.messages)) > maxLen {
+		s.messages = s.messages[int64(len(s.messages))-maxLen:]
+	}
+
+	// Closing the channel wakes up all consumers that are waiting for new messages
+	close(s.available)
+	s.available = make(chan struct{})
+}
+
+func getMemoryStreamSequence(id string) uint64 {
+	sequence, err := strconv.ParseUint(strings.SplitN(id, "-", 2)[0], 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return sequence
+}
+
+func (b *MemoryBroker) ReadStream(ctx context.Context, stream string, consumer string, id string, count int64, block time.Duration) ([]StreamMessage, error) {
+	var timeout <-chan time.Time
+	if block > 0 {
+		timer := time.NewTimer(block)
+		defer timer.Stop()
+
+		timeout = timer.C
+	}
+
+	var waited *memoryStream
+	for {
+		b.lock.Lock()
+
+		// A stream that was deleted while we were waiting counts as deleted even if it has been recreated since
+		s, ok := b.streams[stream]
+		if !ok || (waited != nil && s != waited) {
+			b.lock.Unlock()
+
+			return nil, fmt.Errorf("%w: %v", ErrStreamNotFound, stream)
+		}
+
+		// Pending messages are returned without blocking, even if there are none
+		if id != StreamIDNew {
+			after := getMemoryStreamSequence(id)
+
+			messages := []StreamMessage{}
+			for _, message := range s.pending[consumer] {
+				if count > 0 && int64(len(messages)) >= count {
+					break
+				}
+
+				if getMemoryStreamSequence(message.ID) > after {
+					messages = append(messages, message)
+
+					// Like Redis, reading a pending message again counts as a new delivery
+					s.deliveredAt[message.ID] = time.Now()
+				}
+			}
+
+			b.lock.Unlock()
+
+			return messages, nil
+		}
+
+		if len(s.messages) > 0 {
+			n := int64(len(s.messages))
+			if count > 0 && n > count {
+				n = count
+			}
+
+			messages := append([]StreamMessage{}, s.messages[:n]...)
+			s.messages = s.messages[n:]
+			s.pending[consumer] = append(s.pending[consumer], messages...)
+			for _, message := range messages {
+				s.deliveredAt[message.ID] = time.Now()
+			}
+
+			b.lock.Unlock()
+
+			return messages, nil
+		}
+
+		available := s.available
+		waited = s
+
+		b.lock.Unlock()
+
+		select {
+		case <-available:
+			continue
+
+		case <-timeout:
+			return []StreamMessage{}, nil
+
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (b *MemoryBroker) AcknowledgeStream(ctx context.Context, stream string, ids ...string) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	s, ok := b.streams[stream]
+	if !ok {
+		return nil
+	}
+
+	acknowledged := map[string]struct{}{}
+	for _, id := range ids {
+		acknowledged[id] = struct{}{}
+
+		delete(s.deliveredAt, id)
+	}
+
+	for consumer, messages := range s.pending {
+		pending := []StreamMessage{}
+		for _, message := range messages {
+			if _, ok := acknowledged[message.ID]; !ok {
+				pending = append(pending, message)
+			}
+		}
+
+		s.pending[consumer] = pending
+	}
+
+	return nil
+}
+
+func (b *MemoryBroker) ClaimStream(ctx context.Context, stream string) ([]StreamMessage, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	s, ok := b.streams[stream]
+	if !ok {
+		return []StreamMessage{}, nil
+	}
+
+	b.deleteStream(stream)
+
+	messages := []StreamMessage{}
+	for _, pending := range s.pending {
+		messages = append(messages, pending...)
+	}
+	messages = append(messages, s.messages...)
+
+	slices.SortFunc(messages, func(a, b StreamMessage) int {
+		return cmp.Compare(getMemoryStreamSequence(a.ID), getMemoryStreamSequence(b.ID))
+	})
+
+	return messages, nil
+}
+
+func (b *MemoryBroker) ClaimPendingStream(ctx context.Context, stream string, consumer string, minIdle time.Duration, count int64) ([]StreamMessage, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	s, ok := b.streams[stream]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrStreamNotFound, stream)
+	}
+
+	idle := []StreamMessage{}
+	for _, messages := range s.pending {
+		for _, message := range messages {
+			if time.Since(s.deliveredAt[message.ID]) >= minIdle {
+				idle = append(idle, message)
+			}
+		}
+	}
+
+	// Like XAUTOCLAIM, we claim the oldest messages first
+	slices.SortFunc(idle, func(a, b StreamMessage) int {
+		return cmp.Compare(getMemoryStreamSequence(a.ID), getMemoryStreamSequence(b.ID))
+	})
+
+	if count > 0 && int64(len(idle)) > count {
+		idle = idle[:count]
+	}
+
+	claimed := map[string]struct{}{}
+	for _, message := range idle {
+		claimed[message.ID] = struct{}{}
+
+		s.deliveredAt[message.ID] = time.Now()
+	}
+
+	for c, messages := range s.pending {
+		pending := []StreamMessage{}
+		for _, message := range messages {
+			if _, ok := claimed[message.ID]; !ok {
+				pending = append(pending, message)
+			}
+		}
+
+		s.pending[c] = pending
+	}
+
+	s.pending[consumer] = append(s.pending[consumer], idle...)
+
+	// Pending messages are read in the order of their IDs
+	slices.SortFunc(s.pending[consumer], func(a, b StreamMessage) int {
+		return cmp.Compare(getMemoryStreamSequence(a.ID), getMemoryStreamSequence(b.ID))
+	})
+
+	return idle, nil
+}
+
+func (b *MemoryBroker) Close() error {
+	b.lock.Lock()
+	subscriptions := []*memorySubscription{}
+	for _, topic := range b.subscriptions {
+		for s := range topic {
+			subscriptions = append(subscriptions, s)
+		}
+	}
+	b.lock.Unlock()
+
+	for _, s := range subscriptions {
+		if err := s.Close(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
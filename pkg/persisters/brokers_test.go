@@ -0,0 +1,523 @@
+package persisters
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testStream = "test/stream"
+
+func getMessageIDs(messages []StreamMessage) []string {
+	ids := []string{}
+	for _, message := range messages {
+		ids = append(ids, message.ID)
+	}
+
+	return ids
+}
+
+func getMessageValues(messages []StreamMessage, key string) []string {
+	values := []string{}
+	for _, message := range messages {
+		value, _ := message.Values[key].(string)
+
+		values = append(values, value)
+	}
+
+	return values
+}
+
+func TestNewBroker(t *testing.T) {
+	broker, cache, err := NewBroker("memory://")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer broker.Close()
+
+	if _, ok := broker.(*MemoryBroker); !ok {
+		t.Fatalf("expected memory broker, got %T", broker)
+	}
+
+	if cache != nil {
+		t.Fatalf("expected memory broker to not have a cache, got %v", cache)
+	}
+
+	if _, _, err := NewBroker("ftp://localhost"); err == nil {
+		t.Fatal("expected unknown scheme to be rejected")
+	}
+}
+
+func TestMemoryBrokerPublish(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = NewMemoryBroker()
+	)
+	defer broker.Close()
+
+	// Messages that are published without subscribers are dropped
+	if err := broker.Publish(ctx, TopicFeedUpsert, "dropped"); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := broker.Subscribe(ctx, TopicFeedUpsert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := broker.Subscribe(ctx, TopicFeedUpsert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := broker.Subscribe(ctx, TopicFeedDelete)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := broker.Publish(ctx, TopicFeedUpsert, "did:plc:a/feed"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []Subscription{first, second} {
+		select {
+		case payload := <-s.Channel():
+			if payload != "did:plc:a/feed" {
+				t.Fatalf("expected %v, got %v", "did:plc:a/feed", payload)
+			}
+
+		case <-time.After(time.Second):
+			t.Fatal("expected every subscriber to receive the message")
+		}
+	}
+
+	select {
+	case payload := <-other.Channel():
+		t.Fatalf("expected subscriber of other topic to not receive the message, got %v", payload)
+
+	default:
+	}
+
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := <-first.Channel(); ok {
+		t.Fatal("expected channel of closed subscription to be closed")
+	}
+
+	// Publishing to a topic with closed subscriptions must neither block nor fail
+	if err := broker.Publish(ctx, TopicFeedUpsert, "did:plc:b/feed"); err != nil {
+		t.Fatal(err)
+	}
+
+	if payload := <-second.Channel(); payload != "did:plc:b/feed" {
+		t.Fatalf("expected %v, got %v", "did:plc:b/feed", payload)
+	}
+
+	if err := broker.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := <-second.Channel(); ok {
+		t.Fatal("expected closing the broker to close all subscriptions")
+	}
+}
+
+func TestMemoryBrokerPublishRespectsContext(t *testing.T) {
+	broker := NewMemoryBroker()
+	defer broker.Close()
+
+	if _, err := broker.Subscribe(context.Background(), TopicFeedUpsert); err != nil {
+		t.Fatal(err)
+	}
+
+	// The subscriber never reads, so the publisher blocks once the subscription's buffer is full
+	for i := 0; i < memorySubscriptionSize; i++ {
+		if err := broker.Publish(context.Background(), TopicFeedUpsert, strconv.Itoa(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	if err := broker.Publish(ctx, TopicFeedUpsert, "blocked"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestMemoryBrokerReadStream(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = NewMemoryBroker()
+	)
+	defer broker.Close()
+
+	if _, err := broker.ReadStream(ctx, testStream, "a", StreamIDNew, 10, time.Millisecond); !errors.Is(err, ErrStreamNotFound) {
+		t.Fatalf("expected %v, got %v", ErrStreamNotFound, err)
+	}
+
+	if err := broker.CreateStream(ctx, testStream); err != nil {
+		t.Fatal(err)
+	}
+
+	// Messages that are appended before anyone reads the stream are kept
+	for i := 0; i < 5; i++ {
+		if err := broker.AppendToStream(ctx, testStream, 0, map[string]interface{}{"i": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	messages, err := broker.ReadStream(ctx, testStream, "a", StreamIDNew, 3, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Values are returned as strings, just like Redis returns them
+	if values := getMessageValues(messages, "i"); !slices.Equal(values, []string{"0", "1", "2"}) {
+		t.Fatalf("expected values [0 1 2], got %v", values)
+	}
+
+	// Each message is only delivered to one consumer
+	others, err := broker.ReadStream(ctx, testStream, "b", StreamIDNew, 10, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if values := getMessageValues(others, "i"); !slices.Equal(values, []string{"3", "4"}) {
+		t.Fatalf("expected values [3 4], got %v", values)
+	}
+
+	if err := broker.AcknowledgeStream(ctx, testStream, messages[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Messages that were delivered but not acknowledged are read again, e.g. after a restart
+	pending, err := broker.ReadStream(ctx, testStream, "a", "0", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := getMessageIDs(pending); !slices.Equal(ids, getMessageIDs(messages[1:])) {
+		t.Fatalf("expected pending messages %v, got %v", getMessageIDs(messages[1:]), ids)
+	}
+
+	after, err := broker.ReadStream(ctx, testStream, "a", pending[0].ID, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := getMessageIDs(after); !slices.Equal(ids, getMessageIDs(pending[1:])) {
+		t.Fatalf("expected pending messages after %v to be %v, got %v", pending[0].ID, getMessageIDs(pending[1:]), ids)
+	}
+
+	// If no message arrives in time, no messages are returned
+	empty, err := broker.ReadStream(ctx, testStream, "a", StreamIDNew, 10, time.Millisecond*10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(empty) != 0 {
+		t.Fatalf("expected no messages, got %v", empty)
+	}
+}
+
+func TestMemoryBrokerReadStreamBlocks(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = NewMemoryBroker()
+	)
+	defer broker.Close()
+
+	if err := broker.CreateStream(ctx, testStream); err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		time.Sleep(time.Millisecond * 10)
+
+		if err := broker.AppendToStream(ctx, testStream, 0, map[string]interface{}{"did": "did:plc:a"}); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	// A block of 0 waits until a message arrives
+	messages, err := broker.ReadStream(ctx, testStream, "a", StreamIDNew, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if values := getMessageValues(messages, "did"); !slices.Equal(values, []string{"did:plc:a"}) {
+		t.Fatalf("expected values [did:plc:a], got %v", values)
+	}
+
+	canceledCtx, cancel := context.WithTimeout(ctx, time.Millisecond*10)
+	defer cancel()
+
+	if _, err := broker.ReadStream(canceledCtx, testStream, "a", StreamIDNew, 10, 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestMemoryBrokerAppendToStreamTrims(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = NewMemoryBroker()
+	)
+	defer broker.Close()
+
+	if err := broker.CreateStream(ctx, testStream); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := broker.AppendToStream(ctx, testStream, 2, map[string]interface{}{"i": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	messages, err := broker.ReadStream(ctx, testStream, "a", StreamIDNew, 10, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if values := getMessageValues(messages, "i"); !slices.Equal(values, []string{"3", "4"}) {
+		t.Fatalf("expected only the latest messages [3 4] to be kept, got %v", values)
+	}
+}
+
+func TestMemoryBrokerAppendToStreams(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = NewMemoryBroker()
+	)
+	defer broker.Close()
+
+	if err := broker.AppendToStreams(ctx, 0, []StreamEntry{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := broker.AppendToStreams(ctx, 0, []StreamEntry{
+		{Stream: "test/a", Values: map[string]interface{}{"i": 1}},
+		{Stream: "test/b", Values: map[string]interface{}{"i": 2}},
+		{Stream: "test/a", Values: map[string]interface{}{"i": 3}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for stream, expected := range map[string][]string{
+		"test/a": {"1", "3"},
+		"test/b": {"2"},
+	} {
+		messages, err := broker.ReadStream(ctx, stream, "a", StreamIDNew, 10, time.Millisecond)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if values := getMessageValues(messages, "i"); !slices.Equal(values, expected) {
+			t.Fatalf("expected %v in %v, got %v", expected, stream, values)
+		}
+	}
+}
+
+func TestMemoryBrokerClaimStream(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = NewMemoryBroker()
+	)
+	defer broker.Close()
+
+	if messages, err := broker.ClaimStream(ctx, testStream); err != nil || len(messages) != 0 {
+		t.Fatalf("expected claiming a missing stream to return no messages, got %v and %v", messages, err)
+	}
+
+	if err := broker.CreateStream(ctx, testStream); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 6; i++ {
+		if err := broker.AppendToStream(ctx, testStream, 0, map[string]interface{}{"i": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Some messages are acknowledged, some are only delivered and some aren't delivered at all
+	delivered, err := broker.ReadStream(ctx, testStream, "b", StreamIDNew, 2, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := broker.ReadStream(ctx, testStream, "a", StreamIDNew, 2, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := broker.AcknowledgeStream(ctx, testStream, delivered[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	messages, err := broker.ClaimStream(ctx, testStream)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if values := getMessageValues(messages, "i"); !slices.Equal(values, []string{"1", "2", "3", "4", "5"}) {
+		t.Fatalf("expected all unacknowledged messages [1 2 3 4 5] in order, got %v", values)
+	}
+
+	// The stream is removed, so a second claim doesn't return the same messages again
+	if messages, err := broker.ClaimStream(ctx, testStream); err != nil || len(messages) != 0 {
+		t.Fatalf("expected second claim to return no messages, got %v and %v", messages, err)
+	}
+
+	if _, err := broker.ReadStream(ctx, testStream, "a", StreamIDNew, 10, time.Millisecond); !errors.Is(err, ErrStreamNotFound) {
+		t.Fatalf("expected %v, got %v", ErrStreamNotFound, err)
+	}
+}
+
+func TestMemoryBrokerClaimPendingStream(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = NewMemoryBroker()
+	)
+	defer broker.Close()
+
+	if _, err := broker.ClaimPendingStream(ctx, testStream, "a", 0, 10); !errors.Is(err, ErrStreamNotFound) {
+		t.Fatalf("expected %v, got %v", ErrStreamNotFound, err)
+	}
+
+	if err := broker.CreateStream(ctx, testStream); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 4; i++ {
+		if err := broker.AppendToStream(ctx, testStream, 0, map[string]interface{}{"i": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Consumer b crashes after receiving three messages and acknowledging the first one
+	delivered, err := broker.ReadStream(ctx, testStream, "b", StreamIDNew, 3, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := broker.AcknowledgeStream(ctx, testStream, delivered[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if messages, err := broker.ClaimPendingStream(ctx, testStream, "a", time.Hour, 10); err != nil || len(messages) != 0 {
+		t.Fatalf("expected messages that weren't idle for long enough to not be claimed, got %v and %v", messages, err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	messages, err := broker.ClaimPendingStream(ctx, testStream, "a", 10*time.Millisecond, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if values := getMessageValues(messages, "i"); !slices.Equal(values, []string{"1"}) {
+		t.Fatalf("expected oldest idle message [1], got %v", values)
+	}
+
+	messages, err = broker.ClaimPendingStream(ctx, testStream, "a", 10*time.Millisecond, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if values := getMessageValues(messages, "i"); !slices.Equal(values, []string{"2"}) {
+		t.Fatalf("expected remaining idle message [2], got %v", values)
+	}
+
+	// The claimed messages are now pending for consumer a and no longer for consumer b
+	pending, err := broker.ReadStream(ctx, testStream, "a", "0", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if values := getMessageValues(pending, "i"); !slices.Equal(values, []string{"1", "2"}) {
+		t.Fatalf("expected claimed messages [1 2] to be pending for a, got %v", values)
+	}
+
+	if pending, err := broker.ReadStream(ctx, testStream, "b", "0", 10, 0); err != nil || len(pending) != 0 {
+		t.Fatalf("expected no messages to be pending for b, got %v and %v", pending, err)
+	}
+}
+
+func TestMemoryBrokerDeleteStream(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = NewMemoryBroker()
+	)
+	defer broker.Close()
+
+	if err := broker.CreateStream(ctx, testStream); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := broker.AppendToStream(ctx, testStream, 0, map[string]interface{}{"i": 0}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Creating a stream that already exists keeps its messages
+	if err := broker.CreateStream(ctx, testStream); err != nil {
+		t.Fatal(err)
+	}
+
+	messages, err := broker.ReadStream(ctx, testStream, "a", StreamIDNew, 10, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if values := getMessageValues(messages, "i"); !slices.Equal(values, []string{"0"}) {
+		t.Fatalf("expected values [0], got %v", values)
+	}
+
+	if err := broker.DeleteStream(ctx, testStream); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := broker.ReadStream(ctx, testStream, "a", StreamIDNew, 10, time.Millisecond); !errors.Is(err, ErrStreamNotFound) {
+		t.Fatalf("expected %v, got %v", ErrStreamNotFound, err)
+	}
+}
+
+func TestMemoryBrokerClaimStreamUnblocksReadStream(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = NewMemoryBroker()
+	)
+	defer broker.Close()
+
+	if err := broker.CreateStream(ctx, testStream); err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := broker.ReadStream(ctx, testStream, "a", StreamIDNew, 10, 0)
+
+		errs <- err
+	}()
+
+	// Like Redis, consumers that are waiting for messages are unblocked once the stream is claimed by another worker
+	time.Sleep(10 * time.Millisecond)
+
+	if _, err := broker.ClaimStream(ctx, testStream); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrStreamNotFound) {
+			t.Fatalf("expected %v, got %v", ErrStreamNotFound, err)
+		}
+
+	case <-time.After(10 * time.Second):
+		t.Fatal("expected ReadStream to return, but it is still blocking")
+	}
+}
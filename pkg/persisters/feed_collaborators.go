@@ -0,0 +1,92 @@
+package persisters
+
+import (
+	"context"
+
+	"github.com/pojntfx/atmosfeed/pkg/models"
+)
+
+// UpsertFeedCollaborator grants a DID a role on a feed, and returns whether the feed exists
+func (p *ManagerPersister) UpsertFeedCollaborator(
+	ctx context.Context,
+	feedDid string,
+	feedRkey string,
+	did string,
+	role string,
+) (bool, error) {
+	rows, err := p.queries.UpsertFeedCollaborator(ctx, models.UpsertFeedCollaboratorParams{
+		Did:      did,
+		Role:     role,
+		FeedDid:  feedDid,
+		FeedRkey: feedRkey,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+func (p *ManagerPersister) GetFeedCollaboratorRole(
+	ctx context.Context,
+	feedDid string,
+	feedRkey string,
+	did string,
+) (string, error) {
+	return p.queries.GetFeedCollaboratorRole(ctx, models.GetFeedCollaboratorRoleParams{
+		FeedDid:  feedDid,
+		FeedRkey: feedRkey,
+		Did:      did,
+	})
+}
+
+func (p *ManagerPersister) GetFeedCollaborators(
+	ctx context.Context,
+	feedDid string,
+	feedRkey string,
+) ([]models.FeedCollaborator, error) {
+	return p.queries.GetFeedCollaborators(ctx, models.GetFeedCollaboratorsParams{
+		FeedDid:  feedDid,
+		FeedRkey: feedRkey,
+	})
+}
+
+func (p *ManagerPersister) GetFeedCollaboratorsForDid(
+	ctx context.Context,
+	did string,
+) ([]models.FeedCollaborator, error) {
+	return p.queries.GetFeedCollaboratorsForDid(ctx, did)
+}
+
+func (p *ManagerPersister) GetSharedFeedsForDid(
+	ctx context.Context,
+	did string,
+) ([]models.GetSharedFeedsForDidRow, error) {
+	return p.queries.GetSharedFeedsForDid(ctx, did)
+}
+
+// DeleteFeedCollaborator revokes a DID's role on a feed, and returns whether it was found
+func (p *ManagerPersister) DeleteFeedCollaborator(
+	ctx context.Context,
+	feedDid string,
+	feedRkey string,
+	did string,
+) (bool, error) {
+	rows, err := p.queries.DeleteFeedCollaborator(ctx, models.DeleteFeedCollaboratorParams{
+		FeedDid:  feedDid,
+		FeedRkey: feedRkey,
+		Did:      did,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+func (p *ManagerPersister) DeleteFeedCollaboratorsForDid(
+	ctx context.Context,
+	did string,
+) error {
+	return p.queries.DeleteFeedCollaboratorsForDid(ctx, did)
+}
@@ -2,11 +2,13 @@ package persisters
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"io"
 	"path"
 	"time"
 
-	"github.com/minio/minio-go/v7"
+	"github.com/pojntfx/atmosfeed/pkg/blobs"
 	"github.com/pojntfx/atmosfeed/pkg/models"
 )
 
@@ -15,49 +17,26 @@ func (p *ManagerPersister) UpsertFeedClassifier(
 	did string,
 	rkey string,
 	classifier io.Reader,
+	size int64,
 ) error {
-	if _, err := p.blobs.PutObject(
-		ctx,
-		p.bucket,
-		path.Join(did, rkey),
-		classifier,
-		-1,
-		minio.PutObjectOptions{},
-	); err != nil {
+	key, err := blobs.GetKey("", did, rkey)
+	if err != nil {
 		return err
 	}
 
-	if err := p.queries.UpsertFeedClassifier(ctx, models.UpsertFeedClassifierParams{
-		Did:  did,
-		Rkey: rkey,
-	}); err != nil {
+	if err := p.blobs.Put(ctx, key, classifier, size); err != nil {
 		return err
 	}
 
-	if _, err := p.broker.Publish(ctx, TopicFeedUpsert, path.Join(did, rkey)).Result(); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (p *ManagerPersister) UpsertFeedMetadata(
-	ctx context.Context,
-	did string,
-	rkey string,
-	pinnedDID string,
-	pinnedRkey string,
-) error {
-	if err := p.queries.UpsertFeedMetadata(ctx, models.UpsertFeedMetadataParams{
-		Did:        did,
-		Rkey:       rkey,
-		PinnedDid:  pinnedDID,
-		PinnedRkey: pinnedRkey,
+	if err := p.queries.UpsertFeedClassifier(ctx, models.UpsertFeedClassifierParams{
+		Did:            did,
+		Rkey:           rkey,
+		ClassifierSize: size,
 	}); err != nil {
 		return err
 	}
 
-	if _, err := p.broker.Publish(ctx, TopicFeedUpsert, path.Join(did, rkey)).Result(); err != nil {
+	if err := p.broker.Publish(ctx, TopicFeedUpsert, path.Join(did, rkey)); err != nil {
 		return err
 	}
 
@@ -70,6 +49,13 @@ func (p *WorkerPersister) GetFeeds(
 	return p.queries.GetFeeds(ctx)
 }
 
+// GetPublicFeeds returns the feeds that can be advertised, which excludes quarantined and suspended feeds
+func (p *ManagerPersister) GetPublicFeeds(
+	ctx context.Context,
+) ([]models.GetPublicFeedsRow, error) {
+	return p.queries.GetPublicFeeds(ctx)
+}
+
 func (p *ManagerPersister) GetFeedsForDid(
 	ctx context.Context,
 	did string,
@@ -77,30 +63,148 @@ func (p *ManagerPersister) GetFeedsForDid(
 	return p.queries.GetFeedsForDid(ctx, did)
 }
 
+func (p *WorkerPersister) GetFeed(
+	ctx context.Context,
+	did string,
+	rkey string,
+) (models.Feed, error) {
+	return p.queries.GetFeed(ctx, models.GetFeedParams{
+		Did:  did,
+		Rkey: rkey,
+	})
+}
+
+func (p *ManagerPersister) GetFeed(
+	ctx context.Context,
+	did string,
+	rkey string,
+) (models.Feed, error) {
+	return p.queries.GetFeed(ctx, models.GetFeedParams{
+		Did:  did,
+		Rkey: rkey,
+	})
+}
+
 func (p *ManagerPersister) GetFeedClassifier(
 	ctx context.Context,
 	did string,
 	rkey string,
-) (io.Reader, error) {
-	return p.blobs.GetObject(
-		ctx,
-		p.bucket,
-		path.Join(did, rkey),
-		minio.GetObjectOptions{},
-	)
+) (io.ReadCloser, error) {
+	key, err := blobs.GetKey("", did, rkey)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.blobs.Get(ctx, key)
 }
 
 func (p *WorkerPersister) GetFeedClassifier(
 	ctx context.Context,
 	did string,
 	rkey string,
-) (io.Reader, error) {
-	return p.blobs.GetObject(
-		ctx,
-		p.bucket,
-		path.Join(did, rkey),
-		minio.GetObjectOptions{},
-	)
+) (io.ReadCloser, error) {
+	key, err := blobs.GetKey("", did, rkey)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.blobs.Get(ctx, key)
+}
+
+func (p *ManagerPersister) UpsertFeedReranker(
+	ctx context.Context,
+	did string,
+	rkey string,
+	reranker io.Reader,
+	size int64,
+) (bool, error) {
+	key, err := blobs.GetKey(rerankersPrefix, did, rkey)
+	if err != nil {
+		return false, err
+	}
+
+	if err := p.blobs.Put(ctx, key, reranker, size); err != nil {
+		return false, err
+	}
+
+	rows, err := p.queries.UpdateFeedReranker(ctx, models.UpdateFeedRerankerParams{
+		Did:          did,
+		Rkey:         rkey,
+		Reranker:     true,
+		RerankerSize: size,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	// Rerankers can only be added to existing feeds, so we don't keep the reranker around if the feed doesn't exist
+	if rows == 0 {
+		if err := p.blobs.Remove(ctx, key); err != nil {
+			return false, err
+		}
+
+		return false, nil
+	}
+
+	if err := p.broker.Publish(ctx, TopicFeedRerankerUpsert, path.Join(did, rkey)); err != nil {
+		return false, err
+	}
+
+	if err := p.InvalidateFeedSkeletons(ctx, did, rkey); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (p *ManagerPersister) GetFeedReranker(
+	ctx context.Context,
+	did string,
+	rkey string,
+) (io.ReadCloser, error) {
+	key, err := blobs.GetKey(rerankersPrefix, did, rkey)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.blobs.Get(ctx, key)
+}
+
+func (p *ManagerPersister) DeleteFeedReranker(
+	ctx context.Context,
+	did string,
+	rkey string,
+) (bool, error) {
+	rows, err := p.queries.UpdateFeedReranker(ctx, models.UpdateFeedRerankerParams{
+		Did:          did,
+		Rkey:         rkey,
+		Reranker:     false,
+		RerankerSize: 0,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if rows == 0 {
+		return false, nil
+	}
+
+	// Blobs can't be stored under invalid keys, so there is nothing to remove for them
+	if key, err := blobs.GetKey(rerankersPrefix, did, rkey); err == nil {
+		if err := p.blobs.Remove(ctx, key); err != nil {
+			return false, err
+		}
+	}
+
+	if err := p.broker.Publish(ctx, TopicFeedRerankerDelete, path.Join(did, rkey)); err != nil {
+		return false, err
+	}
+
+	if err := p.InvalidateFeedSkeletons(ctx, did, rkey); err != nil {
+		return false, err
+	}
+
+	return true, nil
 }
 
 func (p *ManagerPersister) DeleteFeed(
@@ -115,46 +219,166 @@ func (p *ManagerPersister) DeleteFeed(
 		return err
 	}
 
-	if err := p.blobs.RemoveObject(ctx, p.bucket, path.Join(did, rkey), minio.RemoveObjectOptions{}); err != nil {
+	// Blobs can't be stored under invalid keys, so there is nothing to remove for them.
+	// Removing an object that doesn't exist succeeds, so we don't need to check whether the feed has a reranker
+	for _, prefix := range []string{"", rerankersPrefix} {
+		key, err := blobs.GetKey(prefix, did, rkey)
+		if err != nil {
+			continue
+		}
+
+		if err := p.blobs.Remove(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	if err := p.broker.Publish(ctx, TopicFeedDelete, path.Join(did, rkey)); err != nil {
 		return err
 	}
 
-	if _, err := p.broker.Publish(ctx, TopicFeedDelete, path.Join(did, rkey)).Result(); err != nil {
+	if err := p.InvalidateFeedSkeletons(ctx, did, rkey); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (p *WorkerPersister) UpsertFeedPost(
+func (p *ManagerPersister) GetFeedStats(
 	ctx context.Context,
-	feedDid string,
-	feedRkey string,
-	postDid string,
-	postRkey string,
-	weight int32,
+) ([]models.GetFeedStatsRow, error) {
+	return p.queries.GetFeedStats(ctx)
+}
+
+// UpdateFeedSuspended suspends or unsuspends a feed, and returns whether it was found
+func (p *ManagerPersister) UpdateFeedSuspended(
+	ctx context.Context,
+	did string,
+	rkey string,
+	suspended bool,
+) (bool, error) {
+	rows, err := p.queries.UpdateFeedSuspended(ctx, models.UpdateFeedSuspendedParams{
+		Did:       did,
+		Rkey:      rkey,
+		Suspended: suspended,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if rows == 0 {
+		return false, nil
+	}
+
+	// Workers release the classifiers of suspended feeds and fetch them again once they are unsuspended
+	if err := p.broker.Publish(ctx, TopicFeedUpsert, path.Join(did, rkey)); err != nil {
+		return false, err
+	}
+
+	if err := p.InvalidateFeedSkeletons(ctx, did, rkey); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (p *WorkerPersister) IncrementFeedClassifierErrors(
+	ctx context.Context,
+	did string,
+	rkey string,
+) (models.IncrementFeedClassifierErrorsRow, error) {
+	return p.queries.IncrementFeedClassifierErrors(ctx, models.IncrementFeedClassifierErrorsParams{
+		Did:  did,
+		Rkey: rkey,
+	})
+}
+
+func (p *WorkerPersister) IncrementFeedClassifierTimeouts(
+	ctx context.Context,
+	did string,
+	rkey string,
+) (models.IncrementFeedClassifierTimeoutsRow, error) {
+	return p.queries.IncrementFeedClassifierTimeouts(ctx, models.IncrementFeedClassifierTimeoutsParams{
+		Did:  did,
+		Rkey: rkey,
+	})
+}
+
+func (p *WorkerPersister) IncrementFeedClassifierLimitViolations(
+	ctx context.Context,
+	did string,
+	rkey string,
 ) error {
-	return p.queries.UpsertFeedPost(ctx, models.UpsertFeedPostParams{
-		FeedDid:  feedDid,
-		FeedRkey: feedRkey,
-		PostDid:  postDid,
-		PostRkey: postRkey,
-		Weight:   weight,
+	return p.queries.IncrementFeedClassifierLimitViolations(ctx, models.IncrementFeedClassifierLimitViolationsParams{
+		Did:  did,
+		Rkey: rkey,
 	})
 }
 
+// QuarantineFeed quarantines a feed and returns whether it wasn't quarantined before; workers are only notified on this transition
+func (p *WorkerPersister) QuarantineFeed(
+	ctx context.Context,
+	did string,
+	rkey string,
+) (bool, error) {
+	rows, err := p.queries.QuarantineFeed(ctx, models.QuarantineFeedParams{
+		Did:  did,
+		Rkey: rkey,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if rows == 0 {
+		return false, nil
+	}
+
+	if err := p.broker.Publish(ctx, TopicFeedQuarantine, path.Join(did, rkey)); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (p *WorkerPersister) UpsertFeedPosts(
+	ctx context.Context,
+	feedDids []string,
+	feedRkeys []string,
+	postDids []string,
+	postRkeys []string,
+	weights []int32,
+) error {
+	if err := p.queries.UpsertFeedPosts(ctx, models.UpsertFeedPostsParams{
+		FeedDids:  feedDids,
+		FeedRkeys: feedRkeys,
+		PostDids:  postDids,
+		PostRkeys: postRkeys,
+		Weights:   weights,
+	}); err != nil {
+		return err
+	}
+
+	// Managers cache pages of feeds, so they need to fetch them again to include the new posts
+	return invalidateFeedSkeletons(ctx, p.cache, feedDids, feedRkeys)
+}
+
 func (p *ManagerPersister) GetFeedPosts(
 	ctx context.Context,
 	feedDid string,
 	feedRkey string,
 	ttl time.Time,
+	now time.Time,
+	excludedDid string,
+	blockerDid string,
 	limit int32,
 ) ([]models.GetFeedPostsRow, error) {
 	return p.queries.GetFeedPosts(ctx, models.GetFeedPostsParams{
-		Did:       feedDid,
-		Rkey:      feedRkey,
-		CreatedAt: ttl,
-		Limit:     limit,
+		FeedDid:     feedDid,
+		FeedRkey:    feedRkey,
+		Ttl:         ttl,
+		Now:         now,
+		ExcludedDid: excludedDid,
+		BlockerDid:  blockerDid,
+		PageSize:    limit,
 	})
 }
 
@@ -163,18 +387,113 @@ func (p *ManagerPersister) GetFeedPostsCursor(
 	feedDid string,
 	feedRkey string,
 	ttl time.Time,
+	now time.Time,
+	excludedDid string,
+	blockerDid string,
 	limit int32,
-	postDid string,
-	postRkey string,
+	cursorScore float64,
+	cursorCreatedAt time.Time,
+	cursorDid string,
+	cursorRkey string,
 ) ([]models.GetFeedPostsCursorRow, error) {
 	return p.queries.GetFeedPostsCursor(ctx, models.GetFeedPostsCursorParams{
-		FeedDid:   feedDid,
-		FeedRkey:  feedRkey,
-		CreatedAt: ttl,
-		Limit:     limit,
-		Did:       postDid,
-		Rkey:      postRkey,
+		FeedDid:         feedDid,
+		FeedRkey:        feedRkey,
+		Ttl:             ttl,
+		Now:             now,
+		ExcludedDid:     excludedDid,
+		BlockerDid:      blockerDid,
+		CursorScore:     cursorScore,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorDid:       cursorDid,
+		CursorRkey:      cursorRkey,
+		PageSize:        limit,
+	})
+}
+
+func (p *ManagerPersister) GetFeedPins(
+	ctx context.Context,
+	feedDid string,
+	feedRkey string,
+) ([]models.FeedPin, error) {
+	return p.queries.GetFeedPins(ctx, models.GetFeedPinsParams{
+		FeedDid:  feedDid,
+		FeedRkey: feedRkey,
+	})
+}
+
+func (p *ManagerPersister) GetFeedPinsForDid(
+	ctx context.Context,
+	feedDid string,
+) ([]models.FeedPin, error) {
+	return p.queries.GetFeedPinsForDid(ctx, feedDid)
+}
+
+func (p *ManagerPersister) GetActiveFeedPins(
+	ctx context.Context,
+	feedDid string,
+	feedRkey string,
+	now time.Time,
+) ([]models.GetActiveFeedPinsRow, error) {
+	return p.queries.GetActiveFeedPins(ctx, models.GetActiveFeedPinsParams{
+		FeedDid:  feedDid,
+		FeedRkey: feedRkey,
+		Now:      now,
+	})
+}
+
+func (p *ManagerPersister) UpsertFeedPin(
+	ctx context.Context,
+	feedDid string,
+	feedRkey string,
+	postDid string,
+	postRkey string,
+	position int32,
+	startsAt sql.NullTime,
+	endsAt sql.NullTime,
+) (bool, error) {
+	// Pins can only be added to feeds that exist
+	rows, err := p.queries.UpsertFeedPin(ctx, models.UpsertFeedPinParams{
+		PostDid:  postDid,
+		PostRkey: postRkey,
+		Position: position,
+		StartsAt: startsAt,
+		EndsAt:   endsAt,
+		FeedDid:  feedDid,
+		FeedRkey: feedRkey,
 	})
+	if err != nil {
+		return false, err
+	}
+
+	if rows == 0 {
+		return false, nil
+	}
+
+	if err := p.InvalidateFeedSkeletons(ctx, feedDid, feedRkey); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (p *ManagerPersister) DeleteFeedPin(
+	ctx context.Context,
+	feedDid string,
+	feedRkey string,
+	postDid string,
+	postRkey string,
+) error {
+	if err := p.queries.DeleteFeedPin(ctx, models.DeleteFeedPinParams{
+		FeedDid:  feedDid,
+		FeedRkey: feedRkey,
+		PostDid:  postDid,
+		PostRkey: postRkey,
+	}); err != nil {
+		return err
+	}
+
+	return p.InvalidateFeedSkeletons(ctx, feedDid, feedRkey)
 }
 
 func (p *ManagerPersister) GetFeedPostsForDid(
@@ -190,3 +509,137 @@ func (p *ManagerPersister) DeleteFeedPostsForDid(
 ) error {
 	return p.queries.DeleteFeedPostsForDid(ctx, did)
 }
+
+// FeedMetadataUpdate holds the feed settings to change; settings that aren't valid aren't changed
+type FeedMetadataUpdate struct {
+	// If ReplacePins is set, all pins are replaced with the pinned post, or removed if it is empty
+	ReplacePins bool
+	PinnedDid   string
+	PinnedRkey  string
+
+	Retention sql.NullInt32
+
+	Ranking  sql.NullString
+	HalfLife int32
+
+	Ttl             sql.NullInt32
+	MaxPageSize     sql.NullInt32
+	DefaultPageSize sql.NullInt32
+
+	HideViewerPosts  sql.NullBool
+	HideBlockedPosts sql.NullBool
+}
+
+// UpdateFeedMetadata applies all changes in a single transaction, so that a failure doesn't leave a feed partially updated;
+// it returns false if the feed doesn't exist
+func (p *ManagerPersister) UpdateFeedMetadata(
+	ctx context.Context,
+	did string,
+	rkey string,
+	update FeedMetadataUpdate,
+) (bool, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	queries := p.queries.WithTx(tx)
+
+	// The updates below don't fail for missing feeds, so we check that the feed exists first
+	if _, err := queries.GetFeed(ctx, models.GetFeedParams{
+		Did:  did,
+		Rkey: rkey,
+	}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	if update.ReplacePins {
+		if err := queries.DeleteFeedPins(ctx, models.DeleteFeedPinsParams{
+			FeedDid:  did,
+			FeedRkey: rkey,
+		}); err != nil {
+			return false, err
+		}
+
+		if update.PinnedDid != "" && update.PinnedRkey != "" {
+			if _, err := queries.UpsertFeedPin(ctx, models.UpsertFeedPinParams{
+				PostDid:  update.PinnedDid,
+				PostRkey: update.PinnedRkey,
+				FeedDid:  did,
+				FeedRkey: rkey,
+			}); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	if update.Retention.Valid {
+		if err := queries.UpdateFeedRetention(ctx, models.UpdateFeedRetentionParams{
+			Did:       did,
+			Rkey:      rkey,
+			Retention: update.Retention.Int32,
+		}); err != nil {
+			return false, err
+		}
+	}
+
+	if update.Ranking.Valid {
+		if err := queries.UpdateFeedRanking(ctx, models.UpdateFeedRankingParams{
+			Did:      did,
+			Rkey:     rkey,
+			Ranking:  update.Ranking.String,
+			HalfLife: update.HalfLife,
+		}); err != nil {
+			return false, err
+		}
+	}
+
+	if update.Ttl.Valid || update.MaxPageSize.Valid || update.DefaultPageSize.Valid {
+		if err := queries.UpdateFeedPageSettings(ctx, models.UpdateFeedPageSettingsParams{
+			Ttl:             update.Ttl,
+			MaxPageSize:     update.MaxPageSize,
+			DefaultPageSize: update.DefaultPageSize,
+			Did:             did,
+			Rkey:            rkey,
+		}); err != nil {
+			return false, err
+		}
+	}
+
+	if update.HideViewerPosts.Valid || update.HideBlockedPosts.Valid {
+		if err := queries.UpdateFeedViewerFilters(ctx, models.UpdateFeedViewerFiltersParams{
+			HideViewerPosts:  update.HideViewerPosts,
+			HideBlockedPosts: update.HideBlockedPosts,
+			Did:              did,
+			Rkey:             rkey,
+		}); err != nil {
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	if err := p.InvalidateFeedSkeletons(ctx, did, rkey); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (p *ManagerPersister) DeleteExpiredFeedPosts(
+	ctx context.Context,
+	now time.Time,
+	batchSize int32,
+) (int64, error) {
+	return p.queries.DeleteExpiredFeedPosts(ctx, models.DeleteExpiredFeedPostsParams{
+		Now:       now,
+		BatchSize: batchSize,
+	})
+}
@@ -0,0 +1,254 @@
+package persisters
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pojntfx/atmosfeed/pkg/cursors"
+	"github.com/pojntfx/atmosfeed/pkg/models"
+)
+
+// testPostgresURLEnv points the tests that need a database to a PostgreSQL database; they migrate it and only add rows with unique keys, so it doesn't need to be empty
+const testPostgresURLEnv = "ATMOSFEED_TEST_POSTGRES_URL"
+
+func newTestPersisters(t *testing.T) (*ManagerPersister, *WorkerPersister) {
+	pgaddr := os.Getenv(testPostgresURLEnv)
+	if pgaddr == "" {
+		t.Skipf("%v is not set, skipping test that needs PostgreSQL", testPostgresURLEnv)
+	}
+
+	var (
+		ctx    = context.Background()
+		broker = NewMemoryBroker()
+		s3url  = "file://" + t.TempDir()
+	)
+	t.Cleanup(func() {
+		_ = broker.Close()
+	})
+
+	manager := NewManagerPersister(pgaddr, broker, nil, s3url)
+	if err := manager.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	worker := NewWorkerPersister(pgaddr, broker, nil, s3url)
+	if err := worker.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	return manager, worker
+}
+
+// getTestFeedPage returns a page of a feed and the cursor for the next page like getFeedSkeleton does, including encoding the cursor
+func getTestFeedPage(ctx context.Context, persister *ManagerPersister, feedDid, feedRkey, rawCursor string, limit int32) ([]string, string, error) {
+	rows := []models.GetFeedPostsRow{}
+	if rawCursor == "" {
+		var err error
+		rows, err = persister.GetFeedPosts(ctx, feedDid, feedRkey, time.Time{}, time.Now(), "", "", limit)
+		if err != nil {
+			return nil, "", err
+		}
+	} else {
+		cursor, err := cursors.DecodeFeedCursor(rawCursor)
+		if err != nil {
+			return nil, "", err
+		}
+
+		cursorRows, err := persister.GetFeedPostsCursor(ctx, feedDid, feedRkey, time.Time{}, time.Now(), "", "", limit, cursor.Score, cursor.CreatedAt, cursor.Did, cursor.Rkey)
+		if err != nil {
+			return nil, "", err
+		}
+
+		for _, row := range cursorRows {
+			rows = append(rows, models.GetFeedPostsRow(row))
+		}
+	}
+
+	page := []string{}
+	for _, row := range rows {
+		page = append(page, row.PostDid+"/"+row.PostRkey)
+	}
+
+	if len(rows) < int(limit) {
+		return page, "", nil
+	}
+
+	last := rows[len(rows)-1]
+
+	return page, cursors.FeedCursor{
+		Score:     last.Score,
+		CreatedAt: last.CreatedAt,
+		Did:       last.PostDid,
+		Rkey:      last.PostRkey,
+	}.Encode(), nil
+}
+
+// TestFeedPostsPagingReturnsEveryPostOnce pages through feeds with GetFeedPosts and GetFeedPostsCursor, so unlike the tests of the
+// cursors package it checks the keyset conditions of the queries themselves, including the timestamp precision of the database
+func TestFeedPostsPagingReturnsEveryPostOnce(t *testing.T) {
+	var (
+		ctx                 = context.Background()
+		manager, worker     = newTestPersisters(t)
+		run                 = strconv.FormatInt(time.Now().UnixNano(), 36)
+		feedDid             = "did:plc:atmosfeed-test"
+		base                = time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC)
+		authors             = []string{"did:plc:a", "did:plc:b", "did:web:c.example.com"}
+		weights             = []int32{0, 1, 2}
+		postDids, postRkeys = []string{}, []string{}
+	)
+	t.Cleanup(func() {
+		for i := range postDids {
+			_ = manager.DeletePost(ctx, postDids[i], postRkeys[i])
+		}
+	})
+
+	for seed := int64(0); seed < 100; seed++ {
+		var (
+			rng        = rand.New(rand.NewSource(seed))
+			feedRkey   = "paging-" + run + "-" + strconv.FormatInt(seed, 10)
+			limit      = int32(1 + rng.Intn(7))
+			deleteRate = rng.Float64() * 0.3
+		)
+
+		if err := manager.UpsertFeedClassifier(ctx, feedDid, feedRkey, bytes.NewReader(nil), 0); err != nil {
+			t.Fatalf("seed %v: could not create feed: %v", seed, err)
+		}
+		t.Cleanup(func() {
+			_ = manager.DeleteFeed(ctx, feedDid, feedRkey)
+		})
+
+		// Few distinct values make ties in every sort column likely
+		createdAts := []time.Time{
+			base,
+			base.Add(time.Microsecond),
+			base.Add(time.Duration(rng.Int63n(int64(time.Hour)))).Truncate(time.Microsecond),
+		}
+
+		var (
+			dids, rkeys    = []string{}, []string{}
+			postCreatedAts = []time.Time{}
+			postWeights    = []int32{}
+			empty          = []string{}
+			replies        = []bool{}
+			seen           = map[string]struct{}{}
+		)
+		for i := rng.Intn(60); i > 0; i-- {
+			did := authors[rng.Intn(len(authors))]
+			rkey := run + "-" + strconv.FormatInt(seed, 10) + "-" + strconv.Itoa(rng.Intn(30))
+
+			// Post keys are unique in a feed
+			if _, ok := seen[did+"/"+rkey]; ok {
+				continue
+			}
+			seen[did+"/"+rkey] = struct{}{}
+
+			dids = append(dids, did)
+			rkeys = append(rkeys, rkey)
+			postCreatedAts = append(postCreatedAts, createdAts[rng.Intn(len(createdAts))])
+			postWeights = append(postWeights, weights[rng.Intn(len(weights))])
+			empty = append(empty, "")
+			replies = append(replies, false)
+		}
+
+		if len(dids) > 0 {
+			if _, err := worker.CreatePosts(ctx, dids, rkeys, postCreatedAts, empty, replies, empty, empty, empty); err != nil {
+				t.Fatalf("seed %v: could not create posts: %v", seed, err)
+			}
+
+			postDids = append(postDids, dids...)
+			postRkeys = append(postRkeys, rkeys...)
+
+			feedDids, feedRkeys := []string{}, []string{}
+			for range dids {
+				feedDids = append(feedDids, feedDid)
+				feedRkeys = append(feedRkeys, feedRkey)
+			}
+
+			if err := worker.UpsertFeedPosts(ctx, feedDids, feedRkeys, dids, rkeys, postWeights); err != nil {
+				t.Fatalf("seed %v: could not add posts to feed: %v", seed, err)
+			}
+		}
+
+		var (
+			returned  = map[string]int{}
+			deleted   = map[string]struct{}{}
+			remaining = map[string]struct{}{}
+			cursor    = ""
+			maxPages  = len(dids) + 1
+		)
+		for i := range dids {
+			remaining[dids[i]+"/"+rkeys[i]] = struct{}{}
+		}
+
+		for pages := 0; ; pages++ {
+			if pages > maxPages {
+				t.Fatalf("seed %v: paging did not terminate", seed)
+			}
+
+			page, nextCursor, err := getTestFeedPage(ctx, manager, feedDid, feedRkey, cursor, limit)
+			if err != nil {
+				t.Fatalf("seed %v: could not get page: %v", seed, err)
+			}
+
+			if len(page) > int(limit) {
+				t.Fatalf("seed %v: expected at most %v posts, got %v", seed, limit, len(page))
+			}
+
+			for _, post := range page {
+				returned[post]++
+			}
+
+			if nextCursor == "" {
+				break
+			}
+
+			cursor = nextCursor
+
+			// Posts can be deleted between two pages, including the post that the cursor points to
+			decodedCursor, err := cursors.DecodeFeedCursor(cursor)
+			if err != nil {
+				t.Fatalf("seed %v: could not decode returned cursor: %v", seed, err)
+			}
+
+			for i := range dids {
+				key := dids[i] + "/" + rkeys[i]
+				if _, ok := remaining[key]; !ok {
+					continue
+				}
+
+				referenced := dids[i] == decodedCursor.Did && rkeys[i] == decodedCursor.Rkey
+				if (referenced && rng.Intn(2) == 0) || rng.Float64() < deleteRate {
+					if err := manager.DeletePost(ctx, dids[i], rkeys[i]); err != nil {
+						t.Fatalf("seed %v: could not delete post: %v", seed, err)
+					}
+
+					delete(remaining, key)
+					deleted[key] = struct{}{}
+				}
+			}
+		}
+
+		for post := range remaining {
+			if count := returned[post]; count != 1 {
+				t.Fatalf("seed %v: expected post %v to be returned once, got %v", seed, post, count)
+			}
+		}
+
+		for post, count := range returned {
+			if count > 1 {
+				t.Fatalf("seed %v: expected post %v to be returned at most once, got %v", seed, post, count)
+			}
+		}
+
+		for post := range deleted {
+			if count := returned[post]; count > 1 {
+				t.Fatalf("seed %v: expected deleted post %v to be returned at most once, got %v", seed, post, count)
+			}
+		}
+	}
+}
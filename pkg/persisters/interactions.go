@@ -0,0 +1,52 @@
+package persisters
+
+import (
+	"context"
+
+	"github.com/pojntfx/atmosfeed/pkg/models"
+)
+
+// CreateInteractions stores a viewer's interactions with posts in feeds, and returns the posts for which new interactions were stored
+func (p *ManagerPersister) CreateInteractions(
+	ctx context.Context,
+	did string,
+	feedDids []string,
+	feedRkeys []string,
+	postDids []string,
+	postRkeys []string,
+	events []string,
+) ([]models.CreateInteractionsRow, error) {
+	return p.queries.CreateInteractions(ctx, models.CreateInteractionsParams{
+		Did:       did,
+		FeedDids:  feedDids,
+		FeedRkeys: feedRkeys,
+		PostDids:  postDids,
+		PostRkeys: postRkeys,
+		Events:    events,
+	})
+}
+
+func (p *WorkerPersister) GetInteractionCounts(
+	ctx context.Context,
+	postDids []string,
+	postRkeys []string,
+) ([]models.GetInteractionCountsRow, error) {
+	return p.queries.GetInteractionCounts(ctx, models.GetInteractionCountsParams{
+		PostDids:  postDids,
+		PostRkeys: postRkeys,
+	})
+}
+
+func (p *ManagerPersister) GetInteractionsForDid(
+	ctx context.Context,
+	did string,
+) ([]models.Interaction, error) {
+	return p.queries.GetInteractionsForDid(ctx, did)
+}
+
+func (p *ManagerPersister) DeleteInteractionsForDid(
+	ctx context.Context,
+	did string,
+) error {
+	return p.queries.DeleteInteractionsForDid(ctx, did)
+}
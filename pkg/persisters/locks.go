@@ -0,0 +1,80 @@
+package persisters
+
+import (
+	"context"
+
+	"github.com/pojntfx/atmosfeed/pkg/models"
+)
+
+const (
+	LockRetention int64 = 1
+)
+
+const (
+	LockQuota int32 = 1
+)
+
+// TryLock takes a PostgreSQL advisory lock that is shared by all managers and returns a function which releases it,
+// or nil if another manager holds the lock. The lock is also released if the manager's database connection is lost.
+func (p *ManagerPersister) TryLock(
+	ctx context.Context,
+	key int64,
+) (func() error, error) {
+	// Advisory locks belong to a database session, so they must be taken and released on the same connection
+	conn, err := p.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	queries := models.New(conn)
+
+	locked, err := queries.TryAdvisoryLock(ctx, key)
+	if err != nil {
+		_ = conn.Close()
+
+		return nil, err
+	}
+
+	if !locked {
+		return nil, conn.Close()
+	}
+
+	return func() error {
+		defer conn.Close()
+
+		return queries.AdvisoryUnlock(context.Background(), key)
+	}, nil
+}
+
+// LockDid waits for a PostgreSQL advisory lock on a DID that is shared by all managers and returns a function which releases it.
+// Locks with different namespaces don't block each other, and the lock is also released if the manager's database connection is lost.
+func (p *ManagerPersister) LockDid(
+	ctx context.Context,
+	namespace int32,
+	did string,
+) (func() error, error) {
+	conn, err := p.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	queries := models.New(conn)
+
+	if err := queries.AdvisoryLockDid(ctx, models.AdvisoryLockDidParams{
+		Namespace: namespace,
+		Did:       did,
+	}); err != nil {
+		_ = conn.Close()
+
+		return nil, err
+	}
+
+	return func() error {
+		defer conn.Close()
+
+		return queries.AdvisoryUnlockDid(context.Background(), models.AdvisoryUnlockDidParams{
+			Namespace: namespace,
+			Did:       did,
+		})
+	}, nil
+}
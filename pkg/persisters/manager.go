@@ -5,11 +5,8 @@ package persisters
 import (
 	"context"
 	"database/sql"
-	"net/url"
-	"strings"
 
-	"github.com/minio/minio-go/v7"
-	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/pojntfx/atmosfeed/pkg/blobs"
 	"github.com/pojntfx/atmosfeed/pkg/migrations"
 	"github.com/pojntfx/atmosfeed/pkg/models"
 	"github.com/pressly/goose/v3"
@@ -17,42 +14,85 @@ import (
 )
 
 const (
-	TopicFeedUpsert = "feed/upsert"
-	TopicFeedDelete = "feed/delete"
+	TopicFeedUpsert     = "feed/upsert"
+	TopicFeedDelete     = "feed/delete"
+	TopicFeedQuarantine = "feed/quarantine"
 
-	StreamPostInsert = "post/insert"
-	StreamPostLike   = "post/like"
+	TopicFeedRerankerUpsert = "feed/reranker/upsert"
+	TopicFeedRerankerDelete = "feed/reranker/delete"
+
+	StreamPostInsert      = "post/insert"
+	StreamPostLike        = "post/like"
+	StreamPostInteraction = "post/interaction"
+	StreamPostClassify    = "post/classify"
+	StreamDeadLetter      = "dead-letter"
+
+	TopicWorkerMembership = "worker/membership"
+	KeyWorkers            = "workers"
+
+	RankingWeight  = "weight"
+	RankingNewest  = "newest"
+	RankingDecayed = "decayed"
 
 	errBusyGroup = "BUSYGROUP Consumer Group name already exists"
+	errNoGroup   = "NOGROUP"
+	errUnblocked = "UNBLOCKED"
+
+	// Rerankers are stored under a separate prefix since S3 implementations like MinIO don't allow an object to also be a prefix
+	rerankersPrefix = "rerankers"
 )
 
+// The manager appends to these streams and the workers read from them, so both create them to keep messages that arrive before the other side started
+var postStreams = []string{StreamPostInsert, StreamPostLike, StreamPostInteraction}
+
+// Migrate applies all pending database migrations
+func Migrate(pgaddr string) error {
+	db, err := sql.Open("postgres", pgaddr)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return migrate(db)
+}
+
+func migrate(db *sql.DB) error {
+	goose.SetBaseFS(migrations.FS)
+
+	if err := goose.SetDialect("postgres"); err != nil {
+		return err
+	}
+
+	return goose.Up(db, ".")
+}
+
 type ManagerPersister struct {
 	pgaddr  string
 	queries *models.Queries
 	db      *sql.DB
 	s3url   string
 
-	broker *redis.Client
-	blobs  *minio.Client
-	bucket string
+	broker Broker
+	cache  *redis.Client
+	blobs  blobs.Store
 }
 
-func NewManagerPersister(pgaddr string, broker *redis.Client, s3url string) *ManagerPersister {
+// NewManagerPersister creates a persister; the cache is used for sessions, feed skeletons and rate limits and may be nil, which disables them
+func NewManagerPersister(pgaddr string, broker Broker, cache *redis.Client, s3url string) *ManagerPersister {
 	return &ManagerPersister{
 		pgaddr: pgaddr,
 		s3url:  s3url,
 
 		broker: broker,
+		cache:  cache,
 	}
 }
 
 func (p *ManagerPersister) Init(ctx context.Context) error {
-	if _, err := p.broker.XGroupCreateMkStream(ctx, StreamPostInsert, StreamPostInsert, "$").Result(); err != nil && !strings.Contains(err.Error(), errBusyGroup) {
-		return err
-	}
-
-	if _, err := p.broker.XGroupCreateMkStream(ctx, StreamPostLike, StreamPostLike, "$").Result(); err != nil && !strings.Contains(err.Error(), errBusyGroup) {
-		return err
+	for _, stream := range postStreams {
+		if err := p.broker.CreateStream(ctx, stream); err != nil {
+			return err
+		}
 	}
 
 	var err error
@@ -61,46 +101,20 @@ func (p *ManagerPersister) Init(ctx context.Context) error {
 		return err
 	}
 
-	goose.SetBaseFS(migrations.FS)
-
-	if err := goose.SetDialect("postgres"); err != nil {
-		return err
-	}
-
-	if err := goose.Up(p.db, "."); err != nil {
+	if err := migrate(p.db); err != nil {
 		return err
 	}
 
 	p.queries = models.New(p.db)
 
-	u, err := url.Parse(p.s3url)
+	p.blobs, err = blobs.NewStore(p.s3url)
 	if err != nil {
 		return err
 	}
 
-	user := u.User
-	pw, _ := user.Password()
-
-	p.blobs, err = minio.New(u.Host, &minio.Options{
-		Creds:  credentials.NewStaticV4(user.Username(), pw, ""),
-		Secure: u.Scheme == "https",
-	})
-	if err != nil {
-		return err
-	}
-
-	p.bucket = u.Query().Get("bucket")
-
-	bucketExists, err := p.blobs.BucketExists(ctx, p.bucket)
-	if err != nil {
+	if err := p.blobs.Init(ctx); err != nil {
 		return err
 	}
 
-	if !bucketExists {
-		if err := p.blobs.MakeBucket(ctx, p.bucket, minio.MakeBucketOptions{}); err != nil {
-			return err
-		}
-	}
-
 	return nil
 }
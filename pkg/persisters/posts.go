@@ -7,33 +7,61 @@ import (
 	"github.com/pojntfx/atmosfeed/pkg/models"
 )
 
-func (p *WorkerPersister) CreatePost(
+func (p *WorkerPersister) CreatePosts(
 	ctx context.Context,
-	did string,
-	rkey string,
-	createdAt time.Time,
-	text string,
-	reply bool,
+	dids []string,
+	rkeys []string,
+	createdAts []time.Time,
+	texts []string,
+	replies []bool,
 	langs []string,
-) (models.Post, error) {
-	return p.queries.CreatePost(ctx, models.CreatePostParams{
-		Did:       did,
-		Rkey:      rkey,
-		CreatedAt: createdAt,
-		Text:      text,
-		Reply:     reply,
-		Langs:     langs,
+	subjectDids []string,
+	subjectRkeys []string,
+) ([]models.Post, error) {
+	return p.queries.CreatePosts(ctx, models.CreatePostsParams{
+		Dids:         dids,
+		Rkeys:        rkeys,
+		CreatedAts:   createdAts,
+		Texts:        texts,
+		Replies:      replies,
+		Langs:        langs,
+		SubjectDids:  subjectDids,
+		SubjectRkeys: subjectRkeys,
 	})
 }
 
-func (p *WorkerPersister) LikePost(
+func (p *WorkerPersister) LikePosts(
 	ctx context.Context,
-	did string,
-	rkey string,
-) (models.Post, error) {
-	return p.queries.LikePost(ctx, models.LikePostParams{
-		Did:  did,
-		Rkey: rkey,
+	dids []string,
+	rkeys []string,
+	likes []int32,
+) ([]models.Post, error) {
+	return p.queries.LikePosts(ctx, models.LikePostsParams{
+		Dids:  dids,
+		Rkeys: rkeys,
+		Likes: likes,
+	})
+}
+
+func (p *WorkerPersister) GetPosts(
+	ctx context.Context,
+	dids []string,
+	rkeys []string,
+) ([]models.Post, error) {
+	return p.queries.GetPosts(ctx, models.GetPostsParams{
+		Dids:  dids,
+		Rkeys: rkeys,
+	})
+}
+
+func (p *ManagerPersister) GetPosts(
+	ctx context.Context,
+	dids []string,
+	rkeys []string,
+) ([]models.Post, error) {
+	return p.queries.GetPosts(ctx, models.GetPostsParams{
+		Dids:  dids,
+		Rkeys: rkeys,
 	})
 }
 
@@ -67,3 +95,14 @@ func (p *ManagerPersister) DeletePostsForDid(
 ) error {
 	return p.queries.DeletePostsForDid(ctx, did)
 }
+
+func (p *ManagerPersister) DeleteExpiredPosts(
+	ctx context.Context,
+	createdBefore time.Time,
+	batchSize int32,
+) (int64, error) {
+	return p.queries.DeleteExpiredPosts(ctx, models.DeleteExpiredPostsParams{
+		CreatedAt: createdBefore,
+		Limit:     batchSize,
+	})
+}
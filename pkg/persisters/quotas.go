@@ -0,0 +1,56 @@
+package persisters
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pojntfx/atmosfeed/pkg/models"
+)
+
+func (p *ManagerPersister) UpsertQuota(
+	ctx context.Context,
+	did string,
+	maxFeeds sql.NullInt32,
+	maxClassifierSize sql.NullInt64,
+	maxStorage sql.NullInt64,
+) error {
+	return p.queries.UpsertQuota(ctx, models.UpsertQuotaParams{
+		Did:               did,
+		MaxFeeds:          maxFeeds,
+		MaxClassifierSize: maxClassifierSize,
+		MaxStorage:        maxStorage,
+	})
+}
+
+func (p *ManagerPersister) GetQuota(
+	ctx context.Context,
+	did string,
+) (models.Quota, error) {
+	return p.queries.GetQuota(ctx, did)
+}
+
+func (p *ManagerPersister) GetQuotaUsageForDid(
+	ctx context.Context,
+	did string,
+) (models.GetQuotaUsageForDidRow, error) {
+	return p.queries.GetQuotaUsageForDid(ctx, did)
+}
+
+func (p *ManagerPersister) GetQuotaUsages(
+	ctx context.Context,
+) ([]models.GetQuotaUsagesRow, error) {
+	return p.queries.GetQuotaUsages(ctx)
+}
+
+// DeleteQuota removes the quota overrides of a DID, and returns whether there were any
+func (p *ManagerPersister) DeleteQuota(
+	ctx context.Context,
+	did string,
+) (bool, error) {
+	rows, err := p.queries.DeleteQuota(ctx, did)
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
@@ -0,0 +1,95 @@
+package persisters
+
+import (
+	"context"
+	"path"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	keyRateLimits = "ratelimits"
+)
+
+// takeRateLimitToken refills a token bucket based on the time since it was last used and takes cost tokens from it if there is one;
+// the time is taken from Redis so that all managers share the same clock
+var takeRateLimitToken = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "updated")
+local tokens = tonumber(bucket[1]) or burst
+local updated = tonumber(bucket[2]) or now
+
+tokens = math.min(burst, tokens + math.max(0, now - updated) * rate / 1000000)
+
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - cost
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) * 1000000 / rate)
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "updated", tostring(now))
+redis.call("PEXPIRE", KEYS[1], math.max(1, math.ceil(burst * 1000 / rate)))
+
+return {allowed, wait}
+`)
+
+func getRateLimitKey(class string, subject string) string {
+	return path.Join(keyRateLimits, class, subject)
+}
+
+// TakeRateLimitToken takes a token from the bucket of a subject in an endpoint class, which is refilled with rate tokens per second up to burst tokens;
+// it returns whether a token was available, and how long to wait for the next token otherwise. Without a cache, all requests are allowed
+func (p *ManagerPersister) TakeRateLimitToken(
+	ctx context.Context,
+	class string,
+	subject string,
+	rate float64,
+	burst int64,
+) (bool, time.Duration, error) {
+	if p.cache == nil {
+		return true, 0, nil
+	}
+
+	return p.runRateLimit(ctx, class, subject, rate, burst, 1)
+}
+
+// PeekRateLimitToken returns whether the bucket of a subject in an endpoint class has a token left like TakeRateLimitToken does, but without taking it
+func (p *ManagerPersister) PeekRateLimitToken(
+	ctx context.Context,
+	class string,
+	subject string,
+	rate float64,
+	burst int64,
+) (bool, time.Duration, error) {
+	if p.cache == nil {
+		return true, 0, nil
+	}
+
+	return p.runRateLimit(ctx, class, subject, rate, burst, 0)
+}
+
+func (p *ManagerPersister) runRateLimit(
+	ctx context.Context,
+	class string,
+	subject string,
+	rate float64,
+	burst int64,
+	cost int64,
+) (bool, time.Duration, error) {
+	res, err := takeRateLimitToken.Run(ctx, p.cache, []string{getRateLimitKey(class, subject)}, rate, burst, cost).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+
+	return res[0] == 1, time.Duration(res[1]) * time.Microsecond, nil
+}
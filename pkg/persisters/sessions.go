@@ -0,0 +1,118 @@
+package persisters
+
+import (
+	"context"
+	"errors"
+	"path"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	keySessions       = "sessions"
+	keySessionsForDid = "dids"
+
+	fieldSessionDid     = "did"
+	fieldSessionService = "service"
+)
+
+func getSessionKey(tokenHash string) string {
+	return path.Join(keySessions, tokenHash)
+}
+
+// The hashes of all cached sessions of a DID are tracked so that they can be invalidated at once
+func getSessionsForDidKey(did string) string {
+	return path.Join(keySessions, keySessionsForDid, did)
+}
+
+// GetSession returns the DID of a cached session, and whether it was found in the cache
+func (p *ManagerPersister) GetSession(
+	ctx context.Context,
+	tokenHash string,
+	service string,
+) (string, bool, error) {
+	if p.cache == nil {
+		return "", false, nil
+	}
+
+	// Missing sessions are returned as empty hashes
+	session, err := p.cache.HGetAll(ctx, getSessionKey(tokenHash)).Result()
+	if err != nil {
+		return "", false, err
+	}
+
+	// Sessions are only valid for the PDS that issued them
+	did, ok := session[fieldSessionDid]
+	if !ok || session[fieldSessionService] != service {
+		return "", false, nil
+	}
+
+	return did, true, nil
+}
+
+func (p *ManagerPersister) CacheSession(
+	ctx context.Context,
+	tokenHash string,
+	service string,
+	did string,
+	ttl time.Duration,
+) error {
+	if p.cache == nil {
+		return nil
+	}
+
+	key, didKey := getSessionKey(tokenHash), getSessionsForDidKey(did)
+
+	_, err := p.cache.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, fieldSessionDid, did, fieldSessionService, service)
+		pipe.Expire(ctx, key, ttl)
+
+		// The index needs to live as long as the longest-lived session of the DID
+		pipe.SAdd(ctx, didKey, tokenHash)
+		pipe.ExpireNX(ctx, didKey, ttl)
+		pipe.ExpireGT(ctx, didKey, ttl)
+
+		return nil
+	})
+
+	return err
+}
+
+func (p *ManagerPersister) InvalidateSession(
+	ctx context.Context,
+	tokenHash string,
+) error {
+	if p.cache == nil {
+		return nil
+	}
+
+	_, err := p.cache.Del(ctx, getSessionKey(tokenHash)).Result()
+
+	return err
+}
+
+func (p *ManagerPersister) InvalidateSessionsForDid(
+	ctx context.Context,
+	did string,
+) error {
+	if p.cache == nil {
+		return nil
+	}
+
+	didKey := getSessionsForDidKey(did)
+
+	tokenHashes, err := p.cache.SMembers(ctx, didKey).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+
+	keys := []string{didKey}
+	for _, tokenHash := range tokenHashes {
+		keys = append(keys, getSessionKey(tokenHash))
+	}
+
+	_, err = p.cache.Del(ctx, keys...).Result()
+
+	return err
+}
@@ -0,0 +1,101 @@
+package persisters
+
+import (
+	"context"
+	"errors"
+	"path"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	keyFeedSkeletons = "skeletons"
+)
+
+// All cached pages of a feed are stored in a single hash so that they can be invalidated at once
+func getFeedSkeletonsKey(did string, rkey string) string {
+	return path.Join(keyFeedSkeletons, did, rkey)
+}
+
+func invalidateFeedSkeletons(ctx context.Context, cache *redis.Client, feedDids []string, feedRkeys []string) error {
+	if cache == nil {
+		return nil
+	}
+
+	keys := []string{}
+	seen := map[string]struct{}{}
+	for i := range feedDids {
+		key := getFeedSkeletonsKey(feedDids[i], feedRkeys[i])
+		if _, ok := seen[key]; ok {
+			continue
+		}
+
+		seen[key] = struct{}{}
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	_, err := cache.Del(ctx, keys...).Result()
+
+	return err
+}
+
+// GetFeedSkeleton returns a cached page of a feed, and whether it was found in the cache
+func (p *ManagerPersister) GetFeedSkeleton(
+	ctx context.Context,
+	did string,
+	rkey string,
+	page string,
+) ([]byte, bool, error) {
+	if p.cache == nil {
+		return nil, false, nil
+	}
+
+	skeleton, err := p.cache.HGet(ctx, getFeedSkeletonsKey(did, rkey), page).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, false, nil
+		}
+
+		return nil, false, err
+	}
+
+	return skeleton, true, nil
+}
+
+func (p *ManagerPersister) CacheFeedSkeleton(
+	ctx context.Context,
+	did string,
+	rkey string,
+	page string,
+	skeleton []byte,
+	ttl time.Duration,
+) error {
+	if p.cache == nil {
+		return nil
+	}
+
+	key := getFeedSkeletonsKey(did, rkey)
+
+	// The expiry is only set when the hash is created so that no page is cached for longer than the TTL
+	_, err := p.cache.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, page, skeleton)
+		pipe.ExpireNX(ctx, key, ttl)
+
+		return nil
+	})
+
+	return err
+}
+
+func (p *ManagerPersister) InvalidateFeedSkeletons(
+	ctx context.Context,
+	did string,
+	rkey string,
+) error {
+	return invalidateFeedSkeletons(ctx, p.cache, []string{did}, []string{rkey})
+}
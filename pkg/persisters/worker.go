@@ -1,11 +1,10 @@
 package persisters
 
 import (
+	"context"
 	"database/sql"
-	"net/url"
 
-	"github.com/minio/minio-go/v7"
-	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/pojntfx/atmosfeed/pkg/blobs"
 	"github.com/pojntfx/atmosfeed/pkg/models"
 	"github.com/redis/go-redis/v9"
 )
@@ -16,21 +15,29 @@ type WorkerPersister struct {
 	db      *sql.DB
 	s3url   string
 
-	broker *redis.Client
-	blobs  *minio.Client
-	bucket string
+	broker Broker
+	cache  *redis.Client
+	blobs  blobs.Store
 }
 
-func NewWorkerPersister(pgaddr string, broker *redis.Client, s3url string) *WorkerPersister {
+// NewWorkerPersister creates a persister; the cache is used to invalidate feed skeletons and to track sharded workers and may be nil, which disables them
+func NewWorkerPersister(pgaddr string, broker Broker, cache *redis.Client, s3url string) *WorkerPersister {
 	return &WorkerPersister{
 		pgaddr: pgaddr,
 		s3url:  s3url,
 
 		broker: broker,
+		cache:  cache,
 	}
 }
 
-func (p *WorkerPersister) Init() error {
+func (p *WorkerPersister) Init(ctx context.Context) error {
+	for _, stream := range postStreams {
+		if err := p.broker.CreateStream(ctx, stream); err != nil {
+			return err
+		}
+	}
+
 	var err error
 	p.db, err = sql.Open("postgres", p.pgaddr)
 	if err != nil {
@@ -39,23 +46,10 @@ func (p *WorkerPersister) Init() error {
 
 	p.queries = models.New(p.db)
 
-	u, err := url.Parse(p.s3url)
-	if err != nil {
-		return err
-	}
-
-	user := u.User
-	pw, _ := user.Password()
-
-	p.blobs, err = minio.New(u.Host, &minio.Options{
-		Creds:  credentials.NewStaticV4(user.Username(), pw, ""),
-		Secure: u.Scheme == "https",
-	})
+	p.blobs, err = blobs.NewStore(p.s3url)
 	if err != nil {
 		return err
 	}
 
-	p.bucket = u.Query().Get("bucket")
-
 	return nil
 }
@@ -0,0 +1,188 @@
+package persisters
+
+import (
+	"context"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	maxPostClassifyStreamLength = 100000
+	maxDeadLetterStreamLength   = 100000
+)
+
+func (p *WorkerPersister) GetPostClassifyStream(workerID string) string {
+	return path.Join(StreamPostClassify, workerID)
+}
+
+// GetDeadLetterStream returns the stream that keeps the messages of a stream that could not be handled
+func (p *WorkerPersister) GetDeadLetterStream(stream string) string {
+	return path.Join(StreamDeadLetter, stream)
+}
+
+// DeadLetterMessage appends a message that could not be handled to the dead-letter stream of its stream, together with its ID and the reason,
+// so that it can be inspected and appended to its stream again once the reason was fixed
+func (p *WorkerPersister) DeadLetterMessage(
+	ctx context.Context,
+	stream string,
+	message StreamMessage,
+	reason error,
+) error {
+	values := map[string]interface{}{}
+	for key, value := range message.Values {
+		values[key] = value
+	}
+
+	values["messageID"] = message.ID
+	values["error"] = reason.Error()
+
+	return p.broker.AppendToStream(ctx, p.GetDeadLetterStream(stream), maxDeadLetterStreamLength, values)
+}
+
+func (p *WorkerPersister) RegisterWorker(
+	ctx context.Context,
+	workerID string,
+) error {
+	// Workers can only be tracked in the cache, so sharding isn't available without it
+	if p.cache == nil {
+		return ErrNoCache
+	}
+
+	if err := p.broker.CreateStream(ctx, p.GetPostClassifyStream(workerID)); err != nil {
+		return err
+	}
+
+	if err := p.HeartbeatWorker(ctx, workerID); err != nil {
+		return err
+	}
+
+	if err := p.broker.Publish(ctx, TopicWorkerMembership, workerID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (p *WorkerPersister) HeartbeatWorker(
+	ctx context.Context,
+	workerID string,
+) error {
+	if p.cache == nil {
+		return ErrNoCache
+	}
+
+	_, err := p.cache.ZAdd(ctx, KeyWorkers, redis.Z{
+		Score:  float64(time.Now().Unix()),
+		Member: workerID,
+	}).Result()
+
+	return err
+}
+
+func getWorkerDeadline(ttl time.Duration) string {
+	return strconv.FormatInt(time.Now().Add(-ttl).Unix(), 10)
+}
+
+// GetWorkers returns all workers which have sent a heartbeat within ttl
+func (p *WorkerPersister) GetWorkers(
+	ctx context.Context,
+	ttl time.Duration,
+) ([]string, error) {
+	if p.cache == nil {
+		return nil, ErrNoCache
+	}
+
+	return p.cache.ZRangeByScore(ctx, KeyWorkers, &redis.ZRangeBy{
+		Min: getWorkerDeadline(ttl),
+		Max: "+inf",
+	}).Result()
+}
+
+// GetDepartedWorkers returns all workers which have left or haven't sent a heartbeat within ttl, but whose posts haven't been reclaimed yet
+func (p *WorkerPersister) GetDepartedWorkers(
+	ctx context.Context,
+	ttl time.Duration,
+) ([]string, error) {
+	if p.cache == nil {
+		return nil, ErrNoCache
+	}
+
+	return p.cache.ZRangeByScore(ctx, KeyWorkers, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: "(" + getWorkerDeadline(ttl),
+	}).Result()
+}
+
+// ReclaimWorker takes over the posts that were queued for a departed worker, including the ones it didn't acknowledge,
+// and removes the worker; if multiple workers reclaim the same worker, only one of them receives its posts
+func (p *WorkerPersister) ReclaimWorker(
+	ctx context.Context,
+	workerID string,
+) ([]StreamMessage, error) {
+	if p.cache == nil {
+		return nil, ErrNoCache
+	}
+
+	messages, err := p.broker.ClaimStream(ctx, p.GetPostClassifyStream(workerID))
+	if err != nil {
+		return nil, err
+	}
+
+	// The worker is only removed after its posts were claimed so that they are never orphaned
+	if _, err := p.cache.ZRem(ctx, KeyWorkers, workerID).Result(); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// UnregisterWorker marks a worker as departed, so that the remaining workers reclaim the posts that are still queued for it
+func (p *WorkerPersister) UnregisterWorker(
+	ctx context.Context,
+	workerID string,
+) error {
+	if p.cache == nil {
+		return ErrNoCache
+	}
+
+	if _, err := p.cache.ZAdd(ctx, KeyWorkers, redis.Z{
+		Score:  0,
+		Member: workerID,
+	}).Result(); err != nil {
+		return err
+	}
+
+	if err := p.broker.Publish(ctx, TopicWorkerMembership, workerID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// PublishPostsClassify queues posts for classification on each of the given workers in a single round trip
+func (p *WorkerPersister) PublishPostsClassify(
+	ctx context.Context,
+	workerIDs []string,
+	dids []string,
+	rkeys []string,
+) error {
+	entries := []StreamEntry{}
+	for _, workerID := range workerIDs {
+		stream := p.GetPostClassifyStream(workerID)
+
+		for i := range dids {
+			entries = append(entries, StreamEntry{
+				Stream: stream,
+				Values: map[string]interface{}{
+					"did":  dids[i],
+					"rkey": rkeys[i],
+				},
+			})
+		}
+	}
+
+	return p.broker.AppendToStreams(ctx, maxPostClassifyStreamLength, entries)
+}
@@ -0,0 +1,122 @@
+package persisters
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestWorkerPersisterWithoutCache(t *testing.T) {
+	var (
+		ctx       = context.Background()
+		broker    = NewMemoryBroker()
+		persister = NewWorkerPersister("", broker, nil, "")
+	)
+	defer broker.Close()
+
+	if err := persister.RegisterWorker(ctx, "a"); !errors.Is(err, ErrNoCache) {
+		t.Fatalf("expected %v, got %v", ErrNoCache, err)
+	}
+
+	// Without a cache, registering must not leave a stream behind that nobody ever reads
+	if _, err := broker.ReadStream(ctx, persister.GetPostClassifyStream("a"), "a", StreamIDNew, 10, time.Millisecond); !errors.Is(err, ErrStreamNotFound) {
+		t.Fatalf("expected %v, got %v", ErrStreamNotFound, err)
+	}
+
+	for name, fn := range map[string]func() error{
+		"HeartbeatWorker": func() error {
+			return persister.HeartbeatWorker(ctx, "a")
+		},
+		"GetWorkers": func() error {
+			_, err := persister.GetWorkers(ctx, time.Minute)
+
+			return err
+		},
+		"GetDepartedWorkers": func() error {
+			_, err := persister.GetDepartedWorkers(ctx, time.Minute)
+
+			return err
+		},
+		"ReclaimWorker": func() error {
+			_, err := persister.ReclaimWorker(ctx, "a")
+
+			return err
+		},
+		"UnregisterWorker": func() error {
+			return persister.UnregisterWorker(ctx, "a")
+		},
+	} {
+		if err := fn(); !errors.Is(err, ErrNoCache) {
+			t.Fatalf("%v: expected %v, got %v", name, ErrNoCache, err)
+		}
+	}
+}
+
+func TestPublishPostsClassify(t *testing.T) {
+	var (
+		ctx       = context.Background()
+		broker    = NewMemoryBroker()
+		persister = NewWorkerPersister("", broker, nil, "")
+
+		dids  = []string{"did:plc:a", "did:plc:b"}
+		rkeys = []string{"1", "2"}
+	)
+	defer broker.Close()
+
+	for _, workerID := range []string{"a", "b", "c"} {
+		if err := broker.CreateStream(ctx, persister.GetPostClassifyStream(workerID)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := persister.PublishPostsClassify(ctx, []string{"a", "b"}, dids, rkeys); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every given worker receives all posts
+	for _, workerID := range []string{"a", "b"} {
+		messages, err := broker.ReadStream(ctx, persister.GetPostClassifyStream(workerID), workerID, StreamIDNew, 10, time.Millisecond)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if values := getMessageValues(messages, "did"); !slices.Equal(values, dids) {
+			t.Fatalf("expected worker %v to receive DIDs %v, got %v", workerID, dids, values)
+		}
+
+		if values := getMessageValues(messages, "rkey"); !slices.Equal(values, rkeys) {
+			t.Fatalf("expected worker %v to receive rkeys %v, got %v", workerID, rkeys, values)
+		}
+	}
+
+	messages, err := broker.ReadStream(ctx, persister.GetPostClassifyStream("c"), "c", StreamIDNew, 10, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(messages) != 0 {
+		t.Fatalf("expected other workers to not receive posts, got %v", messages)
+	}
+}
+
+func TestWorkerPersisterInitCreatesStreams(t *testing.T) {
+	var (
+		ctx       = context.Background()
+		broker    = NewMemoryBroker()
+		persister = NewWorkerPersister("postgresql://localhost/atmosfeed", broker, nil, "file:///tmp/atmosfeed")
+	)
+	defer broker.Close()
+
+	// Opening the database doesn't connect to it, so Init succeeds without PostgreSQL
+	if err := persister.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, stream := range []string{StreamPostInsert, StreamPostLike, StreamPostInteraction} {
+		if _, err := broker.ReadStream(ctx, stream, "a", StreamIDNew, 10, time.Millisecond); err != nil {
+			t.Fatalf("expected stream %v to be created, got %v", stream, err)
+		}
+	}
+}
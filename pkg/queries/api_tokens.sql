@@ -0,0 +1,21 @@
+-- name: CreateApiToken :one
+insert into api_tokens (id, did, hash, description, feeds, scopes)
+values ($1, $2, $3, $4, $5, $6)
+returning *;
+-- name: GetApiTokenByHash :one
+select *
+from api_tokens
+where hash = $1;
+-- name: GetApiTokensForDid :many
+select *
+from api_tokens
+where did = $1
+order by created_at,
+    id;
+-- name: DeleteApiToken :execrows
+delete from api_tokens
+where did = $1
+    and id = $2;
+-- name: DeleteApiTokensForDid :exec
+delete from api_tokens
+where did = $1;
\ No newline at end of file
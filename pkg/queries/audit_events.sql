@@ -0,0 +1,47 @@
+-- name: CreateAuditEvent :exec
+insert into audit_events (
+        did,
+        action,
+        feed_did,
+        feed_rkey,
+        classifier_hash,
+        origin
+    )
+values ($1, $2, $3, $4, $5, $6);
+-- name: GetAuditEventsForFeed :many
+select *
+from audit_events
+where feed_did = $1
+    and feed_rkey = $2
+order by id desc
+limit $3;
+-- name: GetAuditEvents :many
+select *
+from audit_events
+where @did::text = ''
+    or did = @did::text
+    or feed_did = @did::text
+order by id desc
+limit @max_events;
+-- name: GetAuditEventsForDid :many
+select *
+from audit_events
+where did = $1
+    or feed_did = $1
+order by id;
+-- name: PseudonymizeAuditEventsForDid :exec
+update audit_events
+set did = case
+        when did = @did::text then @pseudonym::text
+        else did
+    end,
+    feed_did = case
+        when feed_did = @did::text then @pseudonym::text
+        else feed_did
+    end,
+    origin = case
+        when did = @did::text then ''
+        else origin
+    end
+where did = @did::text
+    or feed_did = @did::text;
\ No newline at end of file
@@ -0,0 +1,19 @@
+-- name: UpsertBannedDid :exec
+insert into banned_dids (did, reason)
+values ($1, $2) on conflict (did) do
+update
+set reason = excluded.reason;
+-- name: GetBannedDids :many
+select *
+from banned_dids
+order by created_at,
+    did;
+-- name: IsAnyDidBanned :one
+select exists (
+        select 1
+        from banned_dids
+        where did = any(@dids::text [])
+    );
+-- name: DeleteBannedDid :execrows
+delete from banned_dids
+where did = $1;
\ No newline at end of file
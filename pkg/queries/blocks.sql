@@ -0,0 +1,51 @@
+-- name: CreateBlock :exec
+insert into blocks (did, rkey, subject)
+select sqlc.arg(did)::text,
+    sqlc.arg(rkey)::text,
+    sqlc.arg(subject)::text
+where exists (
+        select 1
+        from block_viewers
+        where did = sqlc.arg(did)::text
+    ) on conflict (did, rkey) do nothing;
+-- name: DeleteBlock :exec
+delete from blocks
+where did = $1
+    and rkey = $2;
+-- name: GetBlocksForDid :many
+select *
+from blocks
+where did = $1;
+-- name: DeleteBlocksForDid :exec
+delete from blocks
+where did = $1;
+-- name: UpsertBlockViewer :exec
+insert into block_viewers (did, last_seen_at)
+values ($1, $2) on conflict (did) do
+update
+set last_seen_at = excluded.last_seen_at
+where block_viewers.last_seen_at < sqlc.arg(refresh_before)::timestamp;
+-- name: DeleteBlockViewer :exec
+delete from block_viewers
+where did = $1;
+-- name: DeleteExpiredBlockViewers :execrows
+delete from block_viewers
+where did in (
+        select did
+        from block_viewers
+        where last_seen_at < $1
+        limit $2
+    );
+-- name: DeleteOrphanedBlocks :execrows
+delete from blocks
+where (did, rkey) in (
+        select b.did,
+            b.rkey
+        from blocks b
+        where not exists (
+                select 1
+                from block_viewers v
+                where v.did = b.did
+            )
+        limit $1
+    );
\ No newline at end of file
@@ -0,0 +1,48 @@
+-- name: UpsertFeedCollaborator :execrows
+insert into feed_collaborators (feed_did, feed_rkey, did, role)
+select did,
+    rkey,
+    @did::text,
+    @role::text
+from feeds
+where did = @feed_did
+    and rkey = @feed_rkey on conflict (feed_did, feed_rkey, did) do
+update
+set role = excluded.role;
+-- name: GetFeedCollaboratorRole :one
+select role
+from feed_collaborators
+where feed_did = $1
+    and feed_rkey = $2
+    and did = $3;
+-- name: GetFeedCollaborators :many
+select *
+from feed_collaborators
+where feed_did = $1
+    and feed_rkey = $2
+order by did;
+-- name: GetFeedCollaboratorsForDid :many
+select *
+from feed_collaborators
+where feed_did = $1
+    or did = $1
+order by feed_did,
+    feed_rkey,
+    did;
+-- name: GetSharedFeedsForDid :many
+select feeds.*,
+    feed_collaborators.role
+from feeds
+    join feed_collaborators on feed_collaborators.feed_did = feeds.did
+    and feed_collaborators.feed_rkey = feeds.rkey
+where feed_collaborators.did = $1
+order by feeds.did,
+    feeds.rkey;
+-- name: DeleteFeedCollaborator :execrows
+delete from feed_collaborators
+where feed_did = $1
+    and feed_rkey = $2
+    and did = $3;
+-- name: DeleteFeedCollaboratorsForDid :exec
+delete from feed_collaborators
+where did = $1;
\ No newline at end of file
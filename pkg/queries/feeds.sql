@@ -1,12 +1,12 @@
--- name: UpsertFeedMetadata :exec
-insert into feeds (did, rkey, pinned_did, pinned_rkey)
-values ($1, $2, $3, $4) on conflict (did, rkey) do
-update
-set pinned_did = excluded.pinned_did,
-    pinned_rkey = excluded.pinned_rkey;
 -- name: UpsertFeedClassifier :exec
-insert into feeds (did, rkey, pinned_did, pinned_rkey)
-values ($1, $2, '', '') on conflict (did, rkey) do nothing;
+insert into feeds (did, rkey, classifier_size)
+values ($1, $2, $3) on conflict (did, rkey) do
+update
+set classifier_errors = 0,
+    classifier_timeouts = 0,
+    classifier_limit_violations = 0,
+    quarantined = false,
+    classifier_size = excluded.classifier_size;
 -- name: GetFeeds :many
 select *
 from feeds;
@@ -14,79 +14,337 @@ from feeds;
 select *
 from feeds
 where did = $1;
+-- name: GetPublicFeeds :many
+select did,
+    rkey
+from feeds
+where not quarantined
+    and not suspended
+order by did,
+    rkey;
+-- name: GetFeed :one
+select *
+from feeds
+where did = $1
+    and rkey = $2;
+-- name: IncrementFeedClassifierErrors :one
+update feeds
+set classifier_errors = classifier_errors + 1
+where did = $1
+    and rkey = $2
+returning classifier_errors,
+    classifier_timeouts;
+-- name: IncrementFeedClassifierTimeouts :one
+update feeds
+set classifier_timeouts = classifier_timeouts + 1
+where did = $1
+    and rkey = $2
+returning classifier_errors,
+    classifier_timeouts;
+-- name: IncrementFeedClassifierLimitViolations :exec
+update feeds
+set classifier_limit_violations = classifier_limit_violations + 1
+where did = $1
+    and rkey = $2;
+-- name: QuarantineFeed :execrows
+update feeds
+set quarantined = true
+where did = $1
+    and rkey = $2
+    and not quarantined;
+-- name: UpdateFeedRetention :exec
+update feeds
+set retention = $3
+where did = $1
+    and rkey = $2;
+-- name: UpdateFeedRanking :exec
+with updated_feed as (
+    update feeds
+    set ranking = $3,
+        half_life = $4
+    where did = $1
+        and rkey = $2
+    returning did,
+        rkey,
+        ranking,
+        half_life
+)
+update feed_posts f
+set score = case updated_feed.ranking
+        when 'newest' then extract(
+            epoch
+            from f.created_at
+        )::double precision
+        when 'decayed' then ln(f.weight + 1) + extract(
+            epoch
+            from f.created_at
+        )::double precision * ln(2) / greatest(updated_feed.half_life, 1)
+        else f.weight
+    end
+from updated_feed
+where f.feed_did = updated_feed.did
+    and f.feed_rkey = updated_feed.rkey;
+-- name: UpdateFeedPageSettings :exec
+update feeds
+set ttl = coalesce(sqlc.narg(ttl), ttl),
+    max_page_size = coalesce(sqlc.narg(max_page_size), max_page_size),
+    default_page_size = coalesce(sqlc.narg(default_page_size), default_page_size)
+where did = sqlc.arg(did)
+    and rkey = sqlc.arg(rkey);
+-- name: UpdateFeedReranker :execrows
+update feeds
+set reranker = $3,
+    reranker_size = $4
+where did = $1
+    and rkey = $2;
+-- name: UpdateFeedViewerFilters :exec
+update feeds
+set hide_viewer_posts = coalesce(sqlc.narg(hide_viewer_posts), hide_viewer_posts),
+    hide_blocked_posts = coalesce(sqlc.narg(hide_blocked_posts), hide_blocked_posts)
+where did = sqlc.arg(did)
+    and rkey = sqlc.arg(rkey);
 -- name: DeleteFeed :exec
 delete from feeds
 where did = $1
     and rkey = $2;
--- name: UpsertFeedPost :exec
+-- name: GetFeedPins :many
+select *
+from feed_pins
+where feed_did = $1
+    and feed_rkey = $2
+order by position,
+    post_did,
+    post_rkey;
+-- name: GetFeedPinsForDid :many
+select *
+from feed_pins
+where feed_did = $1
+order by feed_rkey,
+    position,
+    post_did,
+    post_rkey;
+-- name: GetActiveFeedPins :many
+select post_did,
+    post_rkey
+from feed_pins
+where feed_did = @feed_did
+    and feed_rkey = @feed_rkey
+    and (
+        starts_at is null
+        or starts_at <= @now::timestamp
+    )
+    and (
+        ends_at is null
+        or ends_at > @now::timestamp
+    )
+order by position,
+    post_did,
+    post_rkey;
+-- name: UpsertFeedPin :execrows
+insert into feed_pins (
+        feed_did,
+        feed_rkey,
+        post_did,
+        post_rkey,
+        position,
+        starts_at,
+        ends_at
+    )
+select did,
+    rkey,
+    @post_did::text,
+    @post_rkey::text,
+    @position::int,
+    sqlc.narg(starts_at)::timestamp,
+    sqlc.narg(ends_at)::timestamp
+from feeds
+where did = @feed_did
+    and rkey = @feed_rkey on conflict (feed_did, feed_rkey, post_did, post_rkey) do
+update
+set position = excluded.position,
+    starts_at = excluded.starts_at,
+    ends_at = excluded.ends_at;
+-- name: DeleteFeedPin :exec
+delete from feed_pins
+where feed_did = $1
+    and feed_rkey = $2
+    and post_did = $3
+    and post_rkey = $4;
+-- name: DeleteFeedPins :exec
+delete from feed_pins
+where feed_did = $1
+    and feed_rkey = $2;
+-- name: UpsertFeedPosts :exec
 insert into feed_posts (
         feed_did,
         feed_rkey,
         post_did,
         post_rkey,
-        weight
+        weight,
+        created_at,
+        score,
+        subject_did,
+        subject_rkey
     )
-values ($1, $2, $3, $4, $5) on conflict (feed_did, feed_rkey, post_did, post_rkey) do
+select f.feed_did,
+    f.feed_rkey,
+    f.post_did,
+    f.post_rkey,
+    f.weight,
+    posts.created_at,
+    case feeds.ranking
+        when 'newest' then extract(
+            epoch
+            from posts.created_at
+        )::double precision
+        when 'decayed' then ln(f.weight + 1) + extract(
+            epoch
+            from posts.created_at
+        )::double precision * ln(2) / greatest(feeds.half_life, 1)
+        else f.weight
+    end,
+    posts.subject_did,
+    posts.subject_rkey
+from unnest(
+        @feed_dids::text [],
+        @feed_rkeys::text [],
+        @post_dids::text [],
+        @post_rkeys::text [],
+        @weights::int []
+    ) as f(feed_did, feed_rkey, post_did, post_rkey, weight)
+    join feeds on feeds.did = f.feed_did
+    and feeds.rkey = f.feed_rkey
+    join posts on posts.did = f.post_did
+    and posts.rkey = f.post_rkey on conflict (feed_did, feed_rkey, post_did, post_rkey) do
 update
-set weight = excluded.weight;
+set weight = excluded.weight,
+    score = excluded.score;
 -- name: GetFeedPosts :many
-with pinned_post as (
-    select pinned_did,
-        pinned_rkey
-    from feeds f
-    where f.did = $1
-        and f.rkey = $2
-),
-feed_posts as (
-    select p.did,
-        p.rkey
-    from posts p
-        join feed_posts fp on p.did = fp.post_did
-        and p.rkey = fp.post_rkey
-    where fp.feed_did = $1
-        and fp.feed_rkey = $2
-        and p.created_at > $3
-    order by fp.weight desc
-    limit $4
-)
-select did,
-    rkey
-from (
-        select pinned_did as did,
-            pinned_rkey as rkey
-        from pinned_post p
-        where p.pinned_did <> ''
-            and p.pinned_rkey <> ''
-        union all
-        select did,
-            rkey
-        from feed_posts
-    ) as results;
+select post_did,
+    post_rkey,
+    created_at,
+    score,
+    subject_did,
+    subject_rkey
+from feed_posts
+where feed_did = @feed_did
+    and feed_rkey = @feed_rkey
+    and created_at > @ttl
+    and not exists (
+        select 1
+        from feed_pins p
+        where p.feed_did = feed_posts.feed_did
+            and p.feed_rkey = feed_posts.feed_rkey
+            and p.post_did = feed_posts.post_did
+            and p.post_rkey = feed_posts.post_rkey
+            and (
+                p.starts_at is null
+                or p.starts_at <= @now::timestamp
+            )
+            and (
+                p.ends_at is null
+                or p.ends_at > @now::timestamp
+            )
+    )
+    and post_did <> @excluded_did::text
+    and (
+        subject_did = ''
+        or subject_did <> @excluded_did::text
+    )
+    and not exists (
+        select 1
+        from blocks b
+        where b.did = @blocker_did::text
+            and b.subject in (feed_posts.post_did, feed_posts.subject_did)
+    )
+order by score desc,
+    created_at desc,
+    post_did desc,
+    post_rkey desc
+limit @page_size;
 -- name: GetFeedPostsCursor :many
-with referenceposttime as (
-    select created_at
-    from posts
-    where posts.did = $5
-        and posts.rkey = $6
-)
-select p.did,
-    p.rkey
-from posts p
-    join feed_posts fp on p.did = fp.post_did
-    and p.rkey = fp.post_rkey
-where fp.feed_did = $1
-    and fp.feed_rkey = $2
-    and p.created_at > $3
-    and p.created_at < (
-        select created_at
-        from referenceposttime
+select post_did,
+    post_rkey,
+    created_at,
+    score,
+    subject_did,
+    subject_rkey
+from feed_posts
+where feed_did = @feed_did
+    and feed_rkey = @feed_rkey
+    and created_at > @ttl
+    and not exists (
+        select 1
+        from feed_pins p
+        where p.feed_did = feed_posts.feed_did
+            and p.feed_rkey = feed_posts.feed_rkey
+            and p.post_did = feed_posts.post_did
+            and p.post_rkey = feed_posts.post_rkey
+            and (
+                p.starts_at is null
+                or p.starts_at <= @now::timestamp
+            )
+            and (
+                p.ends_at is null
+                or p.ends_at > @now::timestamp
+            )
+    )
+    and post_did <> @excluded_did::text
+    and (
+        subject_did = ''
+        or subject_did <> @excluded_did::text
+    )
+    and not exists (
+        select 1
+        from blocks b
+        where b.did = @blocker_did::text
+            and b.subject in (feed_posts.post_did, feed_posts.subject_did)
     )
-order by fp.weight desc
-limit $4;
+    and (score, created_at, post_did, post_rkey) < (
+        @cursor_score::double precision,
+        @cursor_created_at::timestamp,
+        @cursor_did::text,
+        @cursor_rkey::text
+    )
+order by score desc,
+    created_at desc,
+    post_did desc,
+    post_rkey desc
+limit @page_size;
 -- name: GetFeedPostsForDid :many
 select *
 from feed_posts
 where post_did = $1;
 -- name: DeleteFeedPostsForDid :exec
 delete from feed_posts
-where post_did = $1;
\ No newline at end of file
+where post_did = $1;
+-- name: DeleteExpiredFeedPosts :execrows
+delete from feed_posts
+where (feed_did, feed_rkey, post_did, post_rkey) in (
+        select fp.feed_did,
+            fp.feed_rkey,
+            fp.post_did,
+            fp.post_rkey
+        from feed_posts fp
+            join feeds f on f.did = fp.feed_did
+            and f.rkey = fp.feed_rkey
+        where f.retention > 0
+            and fp.created_at < @now::timestamp - make_interval(secs => f.retention)
+        limit @batch_size
+    );
+-- name: GetFeedStats :many
+select feeds.*,
+    (
+        select count(*)
+        from feed_posts
+        where feed_posts.feed_did = feeds.did
+            and feed_posts.feed_rkey = feeds.rkey
+    ) as posts
+from feeds
+order by feeds.did,
+    feeds.rkey;
+-- name: UpdateFeedSuspended :execrows
+update feeds
+set suspended = $3
+where did = $1
+    and rkey = $2;
\ No newline at end of file
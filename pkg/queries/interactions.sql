@@ -0,0 +1,61 @@
+-- name: CreateInteractions :many
+insert into interactions (
+        feed_did,
+        feed_rkey,
+        post_did,
+        post_rkey,
+        did,
+        event
+    )
+select feed_posts.feed_did,
+    feed_posts.feed_rkey,
+    feed_posts.post_did,
+    feed_posts.post_rkey,
+    @did::text,
+    i.event
+from unnest(
+        @feed_dids::text [],
+        @feed_rkeys::text [],
+        @post_dids::text [],
+        @post_rkeys::text [],
+        @events::text []
+    ) as i(feed_did, feed_rkey, post_did, post_rkey, event)
+    join feed_posts on feed_posts.feed_did = i.feed_did
+    and feed_posts.feed_rkey = i.feed_rkey
+    and (
+        (
+            feed_posts.post_did = i.post_did
+            and feed_posts.post_rkey = i.post_rkey
+        )
+        or (
+            feed_posts.subject_did = i.post_did
+            and feed_posts.subject_rkey = i.post_rkey
+        )
+    ) on conflict do nothing
+returning post_did,
+    post_rkey;
+-- name: GetInteractionCounts :many
+select feed_did,
+    feed_rkey,
+    post_did,
+    post_rkey,
+    event,
+    count(*) as count
+from interactions
+where (post_did, post_rkey) in (
+        select p.did,
+            p.rkey
+        from unnest(@post_dids::text [], @post_rkeys::text []) as p(did, rkey)
+    )
+group by feed_did,
+    feed_rkey,
+    post_did,
+    post_rkey,
+    event;
+-- name: GetInteractionsForDid :many
+select *
+from interactions
+where did = $1;
+-- name: DeleteInteractionsForDid :exec
+delete from interactions
+where did = $1;
\ No newline at end of file
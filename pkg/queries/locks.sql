@@ -0,0 +1,8 @@
+-- name: TryAdvisoryLock :one
+select pg_try_advisory_lock(sqlc.arg(key)::bigint) as locked;
+-- name: AdvisoryUnlock :exec
+select pg_advisory_unlock(sqlc.arg(key)::bigint);
+-- name: AdvisoryLockDid :exec
+select pg_advisory_lock(sqlc.arg(namespace)::integer, hashtext(sqlc.arg(did)::text));
+-- name: AdvisoryUnlockDid :exec
+select pg_advisory_unlock(sqlc.arg(namespace)::integer, hashtext(sqlc.arg(did)::text));
@@ -1,4 +1,4 @@
--- name: CreatePost :one
+-- name: CreatePosts :many
 insert into posts (
         did,
         rkey,
@@ -6,26 +6,83 @@ insert into posts (
         text,
         reply,
         langs,
-        likes
+        likes,
+        subject_did,
+        subject_rkey
     )
-values ($1, $2, $3, $4, $5, $6, 0)
+select p.did,
+    p.rkey,
+    p.created_at,
+    coalesce(s.text, p.text),
+    coalesce(s.reply, p.reply),
+    coalesce(s.langs, regexp_split_to_array(p.langs, ',')),
+    0,
+    p.subject_did,
+    p.subject_rkey
+from unnest(
+        @dids::text [],
+        @rkeys::text [],
+        @created_ats::timestamp [],
+        @texts::text [],
+        @replies::boolean [],
+        @langs::text [],
+        @subject_dids::text [],
+        @subject_rkeys::text []
+    ) as p(
+        did,
+        rkey,
+        created_at,
+        text,
+        reply,
+        langs,
+        subject_did,
+        subject_rkey
+    )
+    left join posts s on s.did = p.subject_did
+    and s.rkey = p.subject_rkey on conflict (did, rkey) do nothing
 returning *;
--- name: LikePost :one
+-- name: LikePosts :many
 update posts
-set likes = likes + 1
-where did = $1
-    and rkey = $2
-returning *;
+set likes = posts.likes + l.likes
+from unnest(
+        @dids::text [],
+        @rkeys::text [],
+        @likes::int []
+    ) as l(did, rkey, likes)
+where posts.did = l.did
+    and posts.rkey = l.rkey
+returning posts.*;
 -- name: DeletePost :exec
 delete from posts
-where did = $1
-    and rkey = $2;
+where (
+        did = $1
+        and rkey = $2
+    )
+    or (
+        subject_did = $1
+        and subject_rkey = $2
+    );
 -- name: DeleteAllPosts :exec
 delete from posts;
+-- name: GetPosts :many
+select posts.*
+from posts
+    join unnest(@dids::text [], @rkeys::text []) as p(did, rkey) on posts.did = p.did
+    and posts.rkey = p.rkey;
 -- name: GetPostsForDid :many
 select *
 from posts
 where did = $1;
 -- name: DeletePostsForDid :exec
 delete from posts
-where did = $1;
\ No newline at end of file
+where did = $1
+    or subject_did = $1;
+-- name: DeleteExpiredPosts :execrows
+delete from posts
+where (did, rkey) in (
+        select did,
+            rkey
+        from posts
+        where created_at < $1
+        limit $2
+    );
\ No newline at end of file
@@ -0,0 +1,35 @@
+-- name: UpsertQuota :exec
+insert into quotas (did, max_feeds, max_classifier_size, max_storage)
+values ($1, $2, $3, $4) on conflict (did) do
+update
+set max_feeds = excluded.max_feeds,
+    max_classifier_size = excluded.max_classifier_size,
+    max_storage = excluded.max_storage;
+-- name: GetQuota :one
+select *
+from quotas
+where did = $1;
+-- name: DeleteQuota :execrows
+delete from quotas
+where did = $1;
+-- name: GetQuotaUsageForDid :one
+select count(*) as feeds,
+    coalesce(sum(classifier_size + reranker_size), 0)::bigint as storage
+from feeds
+where did = $1;
+-- name: GetQuotaUsages :many
+select coalesce(u.did, quotas.did)::text as did,
+    coalesce(u.feeds, 0)::bigint as feeds,
+    coalesce(u.storage, 0)::bigint as storage,
+    quotas.max_feeds,
+    quotas.max_classifier_size,
+    quotas.max_storage
+from (
+        select did,
+            count(*) as feeds,
+            sum(classifier_size + reranker_size) as storage
+        from feeds
+        group by did
+    ) u
+    full outer join quotas on quotas.did = u.did
+order by 1;
\ No newline at end of file
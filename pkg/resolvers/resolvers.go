@@ -0,0 +1,392 @@
+package resolvers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	DefaultPLCURL             = "https://plc.directory"
+	DefaultMinRefreshInterval = time.Minute
+
+	methodPLC = "did:plc:"
+	methodWeb = "did:web:"
+
+	serviceAtprotoPDS = "atproto_pds"
+
+	maxDocumentSize = 1 << 16
+)
+
+var (
+	ErrUnsupportedDIDMethod = errors.New("unsupported DID method")
+	ErrInvalidDID           = errors.New("invalid DID")
+	ErrDIDNotFound          = errors.New("DID not found")
+	ErrDIDDocumentMismatch  = errors.New("DID document does not match DID")
+	ErrMissingPDS           = errors.New("DID document does not specify a PDS")
+	ErrDocumentTooLarge     = errors.New("DID document is too large")
+	ErrPrivateAddress       = errors.New("refusing to connect to private address")
+)
+
+type DIDDocument struct {
+	ID                 string               `json:"id"`
+	AlsoKnownAs        []string             `json:"alsoKnownAs"`
+	VerificationMethod []VerificationMethod `json:"verificationMethod"`
+	Service            []Service            `json:"service"`
+}
+
+type VerificationMethod struct {
+	ID                 string `json:"id"`
+	Type               string `json:"type"`
+	Controller         string `json:"controller"`
+	PublicKeyMultibase string `json:"publicKeyMultibase"`
+}
+
+type Service struct {
+	ID              string `json:"id"`
+	Type            string `json:"type"`
+	ServiceEndpoint string `json:"serviceEndpoint"`
+}
+
+// GetVerificationMethod returns the verification method with the given fragment, e.g. "atproto"
+func (d *DIDDocument) GetVerificationMethod(fragment string) (VerificationMethod, bool) {
+	for _, method := range d.VerificationMethod {
+		// IDs can either be relative to the document or absolute
+		if method.ID == "#"+fragment || method.ID == d.ID+"#"+fragment {
+			return method, true
+		}
+	}
+
+	return VerificationMethod{}, false
+}
+
+// GetService returns the service with the given fragment, e.g. "atproto_pds"
+func (d *DIDDocument) GetService(fragment string) (Service, bool) {
+	for _, service := range d.Service {
+		if service.ID == "#"+fragment || service.ID == d.ID+"#"+fragment {
+			return service, true
+		}
+	}
+
+	return Service{}, false
+}
+
+// Resolver resolves DIDs to their documents
+type Resolver interface {
+	ResolveDID(ctx context.Context, did string) (*DIDDocument, error)
+}
+
+// ResolvePDS returns the URL of the PDS listed in a DID's document, which is the only server that can vouch for the DID's sessions
+func ResolvePDS(ctx context.Context, resolver Resolver, did string) (string, error) {
+	document, err := resolver.ResolveDID(ctx, did)
+	if err != nil {
+		return "", err
+	}
+
+	service, ok := document.GetService(serviceAtprotoPDS)
+	if !ok {
+		return "", ErrMissingPDS
+	}
+
+	u, err := url.Parse(service.ServiceEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrMissingPDS, err)
+	}
+
+	if (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return "", ErrMissingPDS
+	}
+
+	// PDS endpoints are origins, so we ignore paths such as trailing slashes
+	return (&url.URL{
+		Scheme: u.Scheme,
+		Host:   u.Host,
+	}).String(), nil
+}
+
+var (
+	// Ranges that aren't covered by the netip.Addr methods, such as carrier-grade NAT and NAT64
+	nonPublicPrefixes = []netip.Prefix{
+		netip.MustParsePrefix("0.0.0.0/8"),
+		netip.MustParsePrefix("100.64.0.0/10"),
+		netip.MustParsePrefix("192.0.0.0/24"),
+		netip.MustParsePrefix("198.18.0.0/15"),
+		netip.MustParsePrefix("240.0.0.0/4"),
+		netip.MustParsePrefix("64:ff9b::/96"),
+		netip.MustParsePrefix("64:ff9b:1::/48"),
+	}
+)
+
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	if addr.IsLoopback() ||
+		addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() ||
+		addr.IsUnspecified() {
+		return false
+	}
+
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// NewHTTPClient returns a client for requests to servers that are named by DIDs and their documents, such as did:web hosts and PDSes.
+// Since anyone can choose these servers, requests time out, and the client refuses to connect to loopback, link-local and private
+// addresses unless allowPrivateAddresses is set, which prevents DIDs from being used to reach internal services.
+func NewHTTPClient(timeout time.Duration, allowPrivateAddresses bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !allowPrivateAddresses {
+		// The address is checked after it was resolved, so DNS records that point to private addresses are refused too
+		dialer.Control = func(network, address string, c syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+
+			if !isPublicAddr(addrPort.Addr()) {
+				return fmt.Errorf("%w: %v", ErrPrivateAddress, addrPort.Addr())
+			}
+
+			return nil
+		}
+
+		// Proxies would connect to the servers on our behalf, which bypasses the address check
+		transport.Proxy = nil
+	}
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}
+}
+
+// HTTPResolver resolves did:plc DIDs using a PLC directory and did:web DIDs using their well-known document
+type HTTPResolver struct {
+	client *http.Client
+	plcURL string
+}
+
+func NewHTTPResolver(client *http.Client, plcURL string) *HTTPResolver {
+	return &HTTPResolver{
+		client: client,
+		plcURL: plcURL,
+	}
+}
+
+func (r *HTTPResolver) ResolveDID(ctx context.Context, did string) (*DIDDocument, error) {
+	var documentURL string
+	switch {
+	case strings.HasPrefix(did, methodPLC):
+		u, err := url.Parse(r.plcURL)
+		if err != nil {
+			return nil, err
+		}
+
+		documentURL = u.JoinPath(did).String()
+
+	case strings.HasPrefix(did, methodWeb):
+		// Colons separate path segments, which atproto doesn't allow; ports are encoded as "%3A" instead
+		rawHost := strings.TrimPrefix(did, methodWeb)
+		if strings.Contains(rawHost, ":") {
+			return nil, ErrInvalidDID
+		}
+
+		host, err := url.PathUnescape(rawHost)
+		if err != nil || strings.TrimSpace(host) == "" || strings.Contains(host, "/") {
+			return nil, ErrInvalidDID
+		}
+
+		documentURL = (&url.URL{
+			Scheme: "https",
+			Host:   host,
+			Path:   "/.well-known/did.json",
+		}).String()
+
+	default:
+		return nil, ErrUnsupportedDIDMethod
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, documentURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusGone {
+		return nil, ErrDIDNotFound
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not resolve DID: %v", res.Status)
+	}
+
+	// did:web documents are served by arbitrary servers, so we don't read more than any legitimate document needs
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxDocumentSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(body) > maxDocumentSize {
+		return nil, ErrDocumentTooLarge
+	}
+
+	var document DIDDocument
+	if err := json.Unmarshal(body, &document); err != nil {
+		return nil, err
+	}
+
+	if document.ID != did {
+		return nil, ErrDIDDocumentMismatch
+	}
+
+	return &document, nil
+}
+
+type cachedDocument struct {
+	document  *DIDDocument
+	fetchedAt time.Time
+	expiresAt time.Time
+}
+
+type pendingDocument struct {
+	done     chan struct{}
+	document *DIDDocument
+	err      error
+}
+
+// CachedResolver caches the documents returned by another resolver and resolves each DID only once at a time.
+// It is safe for concurrent use.
+type CachedResolver struct {
+	resolver           Resolver
+	ttl                time.Duration
+	minRefreshInterval time.Duration
+
+	documentsLock sync.Mutex
+	documents     map[string]cachedDocument
+	pending       map[string]*pendingDocument
+	lastSweep     time.Time
+}
+
+// NewCachedResolver creates a resolver which caches documents for ttl; cached documents can only be purged once they are older than minRefreshInterval
+func NewCachedResolver(resolver Resolver, ttl time.Duration, minRefreshInterval time.Duration) *CachedResolver {
+	return &CachedResolver{
+		resolver:           resolver,
+		ttl:                ttl,
+		minRefreshInterval: minRefreshInterval,
+
+		documents: map[string]cachedDocument{},
+		pending:   map[string]*pendingDocument{},
+	}
+}
+
+func (r *CachedResolver) ResolveDID(ctx context.Context, did string) (*DIDDocument, error) {
+	r.documentsLock.Lock()
+	if cached, ok := r.documents[did]; ok && time.Now().Before(cached.expiresAt) {
+		r.documentsLock.Unlock()
+
+		return cached.document, nil
+	}
+
+	// Concurrent requests for the same DID wait for the first one instead of resolving it again
+	pending, ok := r.pending[did]
+	if !ok {
+		pending = &pendingDocument{
+			done: make(chan struct{}),
+		}
+		r.pending[did] = pending
+
+		// The resolution is shared, so it must not be canceled if the request that started it is
+		go r.resolve(context.WithoutCancel(ctx), did, pending)
+	}
+	r.documentsLock.Unlock()
+
+	select {
+	case <-pending.done:
+		return pending.document, pending.err
+
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (r *CachedResolver) resolve(ctx context.Context, did string, pending *pendingDocument) {
+	defer close(pending.done)
+
+	pending.document, pending.err = r.resolver.ResolveDID(ctx, did)
+
+	r.documentsLock.Lock()
+	defer r.documentsLock.Unlock()
+
+	delete(r.pending, did)
+
+	if pending.err != nil {
+		return
+	}
+
+	// Expired documents are only replaced if they are requested again, so we remove them periodically to prevent the cache from growing forever
+	now := time.Now()
+	if now.Sub(r.lastSweep) > r.ttl {
+		for key, cached := range r.documents {
+			if !now.Before(cached.expiresAt) {
+				delete(r.documents, key)
+			}
+		}
+
+		r.lastSweep = now
+	}
+
+	r.documents[did] = cachedDocument{
+		document:  pending.document,
+		fetchedAt: now,
+		expiresAt: now.Add(r.ttl),
+	}
+}
+
+// Purge removes a DID's document from the cache, e.g. after its keys were rotated, and returns whether the next lookup resolves it again.
+// Documents that were fetched less than the minimum refresh interval ago are kept, so that invalid requests can't cause a lookup each.
+func (r *CachedResolver) Purge(did string) bool {
+	r.documentsLock.Lock()
+	defer r.documentsLock.Unlock()
+
+	cached, ok := r.documents[did]
+	if !ok {
+		return true
+	}
+
+	if time.Since(cached.fetchedAt) < r.minRefreshInterval {
+		return false
+	}
+
+	delete(r.documents, did)
+
+	return true
+}
@@ -0,0 +1,432 @@
+package resolvers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testDID = "did:plc:test"
+)
+
+var (
+	errTestResolver = errors.New("test resolver error")
+)
+
+type testResolver struct {
+	lock    sync.Mutex
+	lookups int
+	err     error
+
+	// If release is set, lookups block until it is closed
+	release chan struct{}
+}
+
+func (r *testResolver) getLookups() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.lookups
+}
+
+func (r *testResolver) ResolveDID(ctx context.Context, did string) (*DIDDocument, error) {
+	r.lock.Lock()
+	r.lookups++
+	err := r.err
+	release := r.release
+	r.lock.Unlock()
+
+	if release != nil {
+		<-release
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &DIDDocument{
+		ID: did,
+	}, nil
+}
+
+func TestCachedResolverCachesDocuments(t *testing.T) {
+	resolver := &testResolver{}
+	cached := NewCachedResolver(resolver, time.Hour, 0)
+
+	for i := 0; i < 3; i++ {
+		document, err := cached.ResolveDID(context.Background(), testDID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if document.ID != testDID {
+			t.Fatalf("expected document for %v, got %v", testDID, document.ID)
+		}
+	}
+
+	if lookups := resolver.getLookups(); lookups != 1 {
+		t.Fatalf("expected 1 lookup, got %v", lookups)
+	}
+}
+
+func TestCachedResolverExpiresDocuments(t *testing.T) {
+	resolver := &testResolver{}
+	cached := NewCachedResolver(resolver, time.Millisecond, 0)
+
+	if _, err := cached.ResolveDID(context.Background(), testDID); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(time.Millisecond * 5)
+
+	if _, err := cached.ResolveDID(context.Background(), testDID); err != nil {
+		t.Fatal(err)
+	}
+
+	if lookups := resolver.getLookups(); lookups != 2 {
+		t.Fatalf("expected 2 lookups, got %v", lookups)
+	}
+}
+
+func TestCachedResolverDoesNotCacheErrors(t *testing.T) {
+	resolver := &testResolver{err: errTestResolver}
+	cached := NewCachedResolver(resolver, time.Hour, 0)
+
+	for i := 0; i < 2; i++ {
+		if _, err := cached.ResolveDID(context.Background(), testDID); !errors.Is(err, errTestResolver) {
+			t.Fatalf("expected %v, got %v", errTestResolver, err)
+		}
+	}
+
+	if lookups := resolver.getLookups(); lookups != 2 {
+		t.Fatalf("expected 2 lookups, got %v", lookups)
+	}
+}
+
+func TestCachedResolverCoalescesLookups(t *testing.T) {
+	resolver := &testResolver{release: make(chan struct{})}
+	cached := NewCachedResolver(resolver, time.Hour, 0)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, err := cached.ResolveDID(context.Background(), testDID)
+			errs <- err
+		}()
+	}
+
+	// Give all lookups time to start before the first one finishes
+	time.Sleep(time.Millisecond * 50)
+	close(resolver.release)
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if lookups := resolver.getLookups(); lookups != 1 {
+		t.Fatalf("expected 1 lookup, got %v", lookups)
+	}
+}
+
+func TestCachedResolverReturnsWhenCanceled(t *testing.T) {
+	resolver := &testResolver{release: make(chan struct{})}
+	defer close(resolver.release)
+
+	cached := NewCachedResolver(resolver, time.Hour, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	if _, err := cached.ResolveDID(ctx, testDID); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestCachedResolverLimitsPurges(t *testing.T) {
+	resolver := &testResolver{}
+	cached := NewCachedResolver(resolver, time.Hour, time.Millisecond*50)
+
+	if !cached.Purge(testDID) {
+		t.Fatal("expected purging an uncached DID to succeed")
+	}
+
+	if _, err := cached.ResolveDID(context.Background(), testDID); err != nil {
+		t.Fatal(err)
+	}
+
+	// The document was just fetched, so purging it again is refused
+	for i := 0; i < 3; i++ {
+		if cached.Purge(testDID) {
+			t.Fatal("expected purging a recently fetched document to be refused")
+		}
+
+		if _, err := cached.ResolveDID(context.Background(), testDID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if lookups := resolver.getLookups(); lookups != 1 {
+		t.Fatalf("expected 1 lookup, got %v", lookups)
+	}
+
+	time.Sleep(time.Millisecond * 60)
+
+	if !cached.Purge(testDID) {
+		t.Fatal("expected purging an older document to succeed")
+	}
+
+	if _, err := cached.ResolveDID(context.Background(), testDID); err != nil {
+		t.Fatal(err)
+	}
+
+	if lookups := resolver.getLookups(); lookups != 2 {
+		t.Fatalf("expected 2 lookups, got %v", lookups)
+	}
+}
+
+type staticResolver struct {
+	document *DIDDocument
+}
+
+func (r *staticResolver) ResolveDID(ctx context.Context, did string) (*DIDDocument, error) {
+	return r.document, nil
+}
+
+func TestResolvePDS(t *testing.T) {
+	tests := []struct {
+		name     string
+		services []Service
+		pdsURL   string
+		err      error
+	}{
+		{"relative service ID", []Service{{ID: "#atproto_pds", ServiceEndpoint: "https://pds.example.com"}}, "https://pds.example.com", nil},
+		{"absolute service ID", []Service{{ID: testDID + "#atproto_pds", ServiceEndpoint: "https://pds.example.com/"}}, "https://pds.example.com", nil},
+		{"endpoint with path", []Service{{ID: "#atproto_pds", ServiceEndpoint: "https://pds.example.com:8443/xrpc"}}, "https://pds.example.com:8443", nil},
+		{"other services only", []Service{{ID: "#atproto_labeler", ServiceEndpoint: "https://labeler.example.com"}}, "", ErrMissingPDS},
+		{"unsupported scheme", []Service{{ID: "#atproto_pds", ServiceEndpoint: "file:///etc/passwd"}}, "", ErrMissingPDS},
+		{"missing host", []Service{{ID: "#atproto_pds", ServiceEndpoint: "https://"}}, "", ErrMissingPDS},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pdsURL, err := ResolvePDS(context.Background(), &staticResolver{&DIDDocument{
+				ID:      testDID,
+				Service: tt.services,
+			}}, testDID)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+
+			if pdsURL != tt.pdsURL {
+				t.Fatalf("expected %q, got %q", tt.pdsURL, pdsURL)
+			}
+		})
+	}
+}
+
+func TestIsPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr   string
+		public bool
+	}{
+		{"1.1.1.1", true},
+		{"2606:4700:4700::1111", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.0.0.1", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:169.254.169.254", false},
+		{"64:ff9b::a9fe:a9fe", false},
+		{"224.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if public := isPublicAddr(netip.MustParseAddr(tt.addr)); public != tt.public {
+				t.Fatalf("expected public to be %v, got %v", tt.public, public)
+			}
+		})
+	}
+}
+
+func TestNewHTTPClientRefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	res, err := NewHTTPClient(time.Second, false).Get(server.URL)
+	if err == nil {
+		res.Body.Close()
+
+		t.Fatal("expected request to loopback address to be refused")
+	}
+
+	if !errors.Is(err, ErrPrivateAddress) {
+		t.Fatalf("expected %v, got %v", ErrPrivateAddress, err)
+	}
+
+	res, err = NewHTTPClient(time.Second, true).Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected request to loopback address to be allowed, got %v", err)
+	}
+	res.Body.Close()
+}
+
+func TestNewHTTPClientTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	start := time.Now()
+	res, err := NewHTTPClient(time.Millisecond*100, true).Get(server.URL)
+	if err == nil {
+		res.Body.Close()
+
+		t.Fatal("expected slow request to time out")
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected request to time out after 100ms, took %v", elapsed)
+	}
+}
+
+func newTestPLCServer(t *testing.T, handler http.HandlerFunc) *HTTPResolver {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return NewHTTPResolver(NewHTTPClient(time.Second, true), server.URL)
+}
+
+func TestHTTPResolverResolvesPLC(t *testing.T) {
+	r := newTestPLCServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/"+testDID {
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(DIDDocument{
+			ID: testDID,
+		})
+	})
+
+	document, err := r.ResolveDID(context.Background(), testDID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if document.ID != testDID {
+		t.Fatalf("expected document for %v, got %v", testDID, document.ID)
+	}
+
+	if _, err := r.ResolveDID(context.Background(), "did:plc:other"); !errors.Is(err, ErrDIDNotFound) {
+		t.Fatalf("expected %v, got %v", ErrDIDNotFound, err)
+	}
+}
+
+func TestHTTPResolverRejectsInvalidDocuments(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		err     error
+	}{
+		{"other DID", func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewEncoder(w).Encode(DIDDocument{ID: "did:plc:other"})
+		}, ErrDIDDocumentMismatch},
+		{"oversized document", func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewEncoder(w).Encode(DIDDocument{ID: testDID, AlsoKnownAs: []string{strings.Repeat("a", maxDocumentSize)}})
+		}, ErrDocumentTooLarge},
+		{"gone DID", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusGone)
+		}, ErrDIDNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newTestPLCServer(t, tt.handler).ResolveDID(context.Background(), testDID); !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestHTTPResolverResolvesWeb(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/did.json" {
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(DIDDocument{
+			ID: "did:web:" + strings.ReplaceAll(r.Host, ":", "%3A"),
+		})
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Ports are encoded because colons separate path segments in did:web DIDs
+	did := "did:web:" + strings.ReplaceAll(u.Host, ":", "%3A")
+
+	document, err := NewHTTPResolver(server.Client(), DefaultPLCURL).ResolveDID(context.Background(), did)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if document.ID != did {
+		t.Fatalf("expected document for %v, got %v", did, document.ID)
+	}
+}
+
+func TestHTTPResolverRejectsInvalidDIDs(t *testing.T) {
+	tests := []struct {
+		did string
+		err error
+	}{
+		{"did:web:example.com:user", ErrInvalidDID},
+		{"did:web:", ErrInvalidDID},
+		{"did:web:example.com%2Fpath", ErrInvalidDID},
+		{"did:key:z6Mk", ErrUnsupportedDIDMethod},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.did, func(t *testing.T) {
+			if _, err := NewHTTPResolver(NewHTTPClient(time.Second, false), DefaultPLCURL).ResolveDID(context.Background(), tt.did); !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
@@ -8,7 +8,7 @@ import (
 	interfaces "github.com/loopholelabs/scale-signature-interfaces"
 )
 
-const hash = "4a8a84f12b689b5b500cd44fc143e32a7432a548a8ba913a8deef52fa3a0ce12"
+const hash = "bb1d5ade8c072757c787a9dc7ef895953457aabc947c7ff41daa3e223f74a40c"
 
 var _ interfaces.Signature = (*Signature)(nil)
 
@@ -14,7 +14,8 @@ var (
 )
 
 type Context struct {
-	Post *Post
+	Post         *Post
+	Interactions *Interactions
 
 	Weight int64
 }
@@ -22,7 +23,8 @@ type Context struct {
 func NewContext() *Context {
 	return &Context{
 
-		Post: NewPost(),
+		Post:         NewPost(),
+		Interactions: NewInteractions(),
 
 		Weight: 0,
 	}
@@ -35,6 +37,7 @@ func (x *Context) Encode(b *polyglot.Buffer) {
 	} else {
 
 		x.Post.Encode(b)
+		x.Interactions.Encode(b)
 
 		e.Int64(x.Weight)
 
@@ -65,6 +68,10 @@ func _decodeContext(x *Context, d *polyglot.Decoder) (*Context, error) {
 	if err != nil {
 		return nil, err
 	}
+	x.Interactions, err = _decodeInteractions(nil, d)
+	if err != nil {
+		return nil, err
+	}
 
 	x.Weight, err = d.Int64()
 	if err != nil {
@@ -75,31 +82,37 @@ func _decodeContext(x *Context, d *polyglot.Decoder) (*Context, error) {
 }
 
 type Post struct {
-	Did  string
-	Rkey string
-	Text string
+	Did         string
+	Rkey        string
+	Text        string
+	SubjectDid  string
+	SubjectRkey string
 
 	Langs []string
 
 	CreatedAt int64
 	Likes     int64
 
-	Reply bool
+	Reply  bool
+	Repost bool
 }
 
 func NewPost() *Post {
 	return &Post{
 
-		Did:  "",
-		Rkey: "",
-		Text: "",
+		Did:         "",
+		Rkey:        "",
+		Text:        "",
+		SubjectDid:  "",
+		SubjectRkey: "",
 
 		Langs: make([]string, 0, 0),
 
 		CreatedAt: 0,
 		Likes:     0,
 
-		Reply: false,
+		Reply:  false,
+		Repost: false,
 	}
 }
 
@@ -112,6 +125,8 @@ func (x *Post) Encode(b *polyglot.Buffer) {
 		e.String(x.Did)
 		e.String(x.Rkey)
 		e.String(x.Text)
+		e.String(x.SubjectDid)
+		e.String(x.SubjectRkey)
 
 		e.Slice(uint32(len(x.Langs)), polyglot.StringKind)
 		for _, a := range x.Langs {
@@ -122,6 +137,7 @@ func (x *Post) Encode(b *polyglot.Buffer) {
 		e.Int64(x.Likes)
 
 		e.Bool(x.Reply)
+		e.Bool(x.Repost)
 
 	}
 }
@@ -158,6 +174,14 @@ func _decodePost(x *Post, d *polyglot.Decoder) (*Post, error) {
 	if err != nil {
 		return nil, err
 	}
+	x.SubjectDid, err = d.String()
+	if err != nil {
+		return nil, err
+	}
+	x.SubjectRkey, err = d.String()
+	if err != nil {
+		return nil, err
+	}
 
 	sliceSizeLangs, err := d.Slice(polyglot.StringKind)
 	if err != nil {
@@ -188,6 +212,116 @@ func _decodePost(x *Post, d *polyglot.Decoder) (*Post, error) {
 	if err != nil {
 		return nil, err
 	}
+	x.Repost, err = d.Bool()
+	if err != nil {
+		return nil, err
+	}
+
+	return x, nil
+}
+
+type Interactions struct {
+	RequestMore   int64
+	RequestLess   int64
+	Seen          int64
+	Clickthroughs int64
+	Likes         int64
+	Reposts       int64
+	Replies       int64
+	Quotes        int64
+	Shares        int64
+}
+
+func NewInteractions() *Interactions {
+	return &Interactions{
+
+		RequestMore:   0,
+		RequestLess:   0,
+		Seen:          0,
+		Clickthroughs: 0,
+		Likes:         0,
+		Reposts:       0,
+		Replies:       0,
+		Quotes:        0,
+		Shares:        0,
+	}
+}
+
+func (x *Interactions) Encode(b *polyglot.Buffer) {
+	e := polyglot.Encoder(b)
+	if x == nil {
+		e.Nil()
+	} else {
+
+		e.Int64(x.RequestMore)
+		e.Int64(x.RequestLess)
+		e.Int64(x.Seen)
+		e.Int64(x.Clickthroughs)
+		e.Int64(x.Likes)
+		e.Int64(x.Reposts)
+		e.Int64(x.Replies)
+		e.Int64(x.Quotes)
+		e.Int64(x.Shares)
+
+	}
+}
+
+func DecodeInteractions(x *Interactions, b []byte) (*Interactions, error) {
+	d := polyglot.GetDecoder(b)
+	defer d.Return()
+	return _decodeInteractions(x, d)
+}
+
+func _decodeInteractions(x *Interactions, d *polyglot.Decoder) (*Interactions, error) {
+	if d.Nil() {
+		return nil, nil
+	}
+
+	err, _ := d.Error()
+	if err != nil {
+		return nil, err
+	}
+
+	if x == nil {
+		x = NewInteractions()
+	}
+
+	x.RequestMore, err = d.Int64()
+	if err != nil {
+		return nil, err
+	}
+	x.RequestLess, err = d.Int64()
+	if err != nil {
+		return nil, err
+	}
+	x.Seen, err = d.Int64()
+	if err != nil {
+		return nil, err
+	}
+	x.Clickthroughs, err = d.Int64()
+	if err != nil {
+		return nil, err
+	}
+	x.Likes, err = d.Int64()
+	if err != nil {
+		return nil, err
+	}
+	x.Reposts, err = d.Int64()
+	if err != nil {
+		return nil, err
+	}
+	x.Replies, err = d.Int64()
+	if err != nil {
+		return nil, err
+	}
+	x.Quotes, err = d.Int64()
+	if err != nil {
+		return nil, err
+	}
+	x.Shares, err = d.Int64()
+	if err != nil {
+		return nil, err
+	}
 
 	return x, nil
 }
@@ -0,0 +1,8 @@
+module reranker
+
+go 1.20
+
+require (
+    github.com/loopholelabs/polyglot v1.1.3
+    github.com/loopholelabs/scale-signature-interfaces v0.1.7
+)
\ No newline at end of file
@@ -0,0 +1,171 @@
+package verifiers
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	secp256k1ecdsa "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/mr-tron/base58"
+	"github.com/pojntfx/atmosfeed/pkg/resolvers"
+)
+
+const (
+	AlgorithmES256  = "ES256"
+	AlgorithmES256K = "ES256K"
+
+	verificationMethodMultikey   = "Multikey"
+	verificationMethodP256Legacy = "EcdsaSecp256r1VerificationKey2019"
+	verificationMethodK256Legacy = "EcdsaSecp256k1VerificationKey2019"
+
+	multibaseBase58BTC = "z"
+
+	signatureLength       = 64
+	signatureScalarLength = signatureLength / 2
+)
+
+var (
+	// Varint-encoded multicodecs of the public key types
+	multicodecP256PublicKey = []byte{0x80, 0x24}
+	multicodecK256PublicKey = []byte{0xe7, 0x01}
+)
+
+var (
+	ErrUnsupportedKey    = errors.New("unsupported key")
+	ErrInvalidKey        = errors.New("invalid key")
+	ErrInvalidSignature  = errors.New("invalid signature")
+	ErrAlgorithmMismatch = errors.New("signature algorithm does not match key")
+)
+
+// PublicKey verifies signatures over SHA-256 hashes
+type PublicKey interface {
+	Algorithm() string
+	Verify(hash []byte, signature []byte) error
+}
+
+type p256PublicKey struct {
+	key *ecdsa.PublicKey
+}
+
+func (k *p256PublicKey) Algorithm() string {
+	return AlgorithmES256
+}
+
+func (k *p256PublicKey) Verify(hash []byte, signature []byte) error {
+	if len(signature) != signatureLength {
+		return ErrInvalidSignature
+	}
+
+	r := new(big.Int).SetBytes(signature[:signatureScalarLength])
+	s := new(big.Int).SetBytes(signature[signatureScalarLength:])
+
+	// atproto only allows low-S signatures so that signatures can't be malleated
+	if s.Cmp(new(big.Int).Rsh(elliptic.P256().Params().N, 1)) > 0 {
+		return ErrInvalidSignature
+	}
+
+	if !ecdsa.Verify(k.key, hash, r, s) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+type k256PublicKey struct {
+	key *secp256k1.PublicKey
+}
+
+func (k *k256PublicKey) Algorithm() string {
+	return AlgorithmES256K
+}
+
+func (k *k256PublicKey) Verify(hash []byte, signature []byte) error {
+	if len(signature) != signatureLength {
+		return ErrInvalidSignature
+	}
+
+	var r, s secp256k1.ModNScalar
+	if overflow := r.SetByteSlice(signature[:signatureScalarLength]); overflow || r.IsZero() {
+		return ErrInvalidSignature
+	}
+
+	if overflow := s.SetByteSlice(signature[signatureScalarLength:]); overflow || s.IsZero() {
+		return ErrInvalidSignature
+	}
+
+	if s.IsOverHalfOrder() {
+		return ErrInvalidSignature
+	}
+
+	if !secp256k1ecdsa.NewSignature(&r, &s).Verify(hash, k.key) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+func parseP256PublicKey(rawKey []byte) (PublicKey, error) {
+	x, y := elliptic.UnmarshalCompressed(elliptic.P256(), rawKey)
+	if x == nil {
+		x, y = elliptic.Unmarshal(elliptic.P256(), rawKey)
+		if x == nil {
+			return nil, ErrInvalidKey
+		}
+	}
+
+	return &p256PublicKey{&ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     x,
+		Y:     y,
+	}}, nil
+}
+
+func parseK256PublicKey(rawKey []byte) (PublicKey, error) {
+	key, err := secp256k1.ParsePubKey(rawKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+
+	return &k256PublicKey{key}, nil
+}
+
+// ParsePublicKey parses the public key of a DID document's verification method
+func ParsePublicKey(method resolvers.VerificationMethod) (PublicKey, error) {
+	if !strings.HasPrefix(method.PublicKeyMultibase, multibaseBase58BTC) {
+		return nil, ErrUnsupportedKey
+	}
+
+	rawKey, err := base58.Decode(strings.TrimPrefix(method.PublicKeyMultibase, multibaseBase58BTC))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+
+	switch method.Type {
+	case verificationMethodMultikey:
+		// Multikeys are prefixed with the multicodec of the key type
+		switch {
+		case bytes.HasPrefix(rawKey, multicodecP256PublicKey):
+			return parseP256PublicKey(rawKey[len(multicodecP256PublicKey):])
+
+		case bytes.HasPrefix(rawKey, multicodecK256PublicKey):
+			return parseK256PublicKey(rawKey[len(multicodecK256PublicKey):])
+
+		default:
+			return nil, ErrUnsupportedKey
+		}
+
+	case verificationMethodP256Legacy:
+		return parseP256PublicKey(rawKey)
+
+	case verificationMethodK256Legacy:
+		return parseK256PublicKey(rawKey)
+
+	default:
+		return nil, ErrUnsupportedKey
+	}
+}
@@ -0,0 +1,95 @@
+package verifiers
+
+import (
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/mr-tron/base58"
+	"github.com/pojntfx/atmosfeed/pkg/resolvers"
+)
+
+func TestParsePublicKey(t *testing.T) {
+	var (
+		p256Signer = newP256TestSigner(t)
+		k256Signer = newK256TestSigner(t)
+	)
+
+	p256Legacy := resolvers.VerificationMethod{
+		Type:               verificationMethodP256Legacy,
+		PublicKeyMultibase: multibaseBase58BTC + base58.Encode(elliptic.Marshal(elliptic.P256(), p256Signer.key.X, p256Signer.key.Y)),
+	}
+
+	k256Legacy := resolvers.VerificationMethod{
+		Type:               verificationMethodK256Legacy,
+		PublicKeyMultibase: multibaseBase58BTC + base58.Encode(k256Signer.key.PubKey().SerializeUncompressed()),
+	}
+
+	tests := []struct {
+		name   string
+		method resolvers.VerificationMethod
+		alg    string
+		err    error
+	}{
+		{"P-256 multikey", p256Signer.verificationMethod(), AlgorithmES256, nil},
+		{"secp256k1 multikey", k256Signer.verificationMethod(), AlgorithmES256K, nil},
+		{"legacy P-256 key", p256Legacy, AlgorithmES256, nil},
+		{"legacy secp256k1 key", k256Legacy, AlgorithmES256K, nil},
+		{"unsupported multibase", resolvers.VerificationMethod{Type: verificationMethodMultikey, PublicKeyMultibase: "m" + base64.RawStdEncoding.EncodeToString([]byte{1})}, "", ErrUnsupportedKey},
+		{"unsupported multicodec", resolvers.VerificationMethod{Type: verificationMethodMultikey, PublicKeyMultibase: multibaseBase58BTC + base58.Encode([]byte{0xed, 0x01, 1, 2, 3})}, "", ErrUnsupportedKey},
+		{"unsupported type", resolvers.VerificationMethod{Type: "Ed25519VerificationKey2020", PublicKeyMultibase: k256Signer.verificationMethod().PublicKeyMultibase}, "", ErrUnsupportedKey},
+		{"invalid key", resolvers.VerificationMethod{Type: verificationMethodMultikey, PublicKeyMultibase: multibaseBase58BTC + base58.Encode(append(append([]byte{}, multicodecK256PublicKey...), 1, 2, 3))}, "", ErrInvalidKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ParsePublicKey(tt.method)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+
+			if err == nil && key.Algorithm() != tt.alg {
+				t.Fatalf("expected algorithm %v, got %v", tt.alg, key.Algorithm())
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsInvalidScalars(t *testing.T) {
+	signer := newK256TestSigner(t)
+
+	key, err := ParsePublicKey(signer.verificationMethod())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash := sha256.Sum256([]byte("test"))
+
+	overflow := make([]byte, signatureLength)
+	for i := range overflow[:signatureScalarLength] {
+		overflow[i] = 0xff
+	}
+
+	tests := []struct {
+		name      string
+		signature []byte
+	}{
+		{"short signature", signer.sign(t, hash[:], false)[1:]},
+		{"zero signature", make([]byte, signatureLength)},
+		{"overflowing R", overflow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := key.Verify(hash[:], tt.signature); !errors.Is(err, ErrInvalidSignature) {
+				t.Fatalf("expected %v, got %v", ErrInvalidSignature, err)
+			}
+		})
+	}
+
+	if err := key.Verify(hash[:], signer.sign(t, hash[:], false)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,172 @@
+package verifiers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pojntfx/atmosfeed/pkg/resolvers"
+)
+
+const (
+	verificationMethodAtproto = "atproto"
+)
+
+var (
+	ErrInvalidToken       = errors.New("invalid token")
+	ErrUnsupportedAlg     = errors.New("unsupported token algorithm")
+	ErrInvalidAudience    = errors.New("invalid token audience")
+	ErrInvalidMethod      = errors.New("invalid token method")
+	ErrTokenExpired       = errors.New("token expired")
+	ErrMissingSigningKey  = errors.New("missing signing key")
+	ErrCouldNotResolveDID = errors.New("could not resolve DID")
+)
+
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+}
+
+type tokenClaims struct {
+	Iss string `json:"iss"`
+	Aud string `json:"aud"`
+	Exp int64  `json:"exp"`
+	Iat int64  `json:"iat"`
+	Lxm string `json:"lxm"`
+}
+
+type purger interface {
+	Purge(did string) bool
+}
+
+// ServiceAuthVerifier verifies the inter-service JWTs that the AppView and PDSes send on behalf of a user,
+// which are signed with the atproto signing key of the issuer's DID document
+type ServiceAuthVerifier struct {
+	resolver resolvers.Resolver
+	audience string
+}
+
+func NewServiceAuthVerifier(resolver resolvers.Resolver, audience string) *ServiceAuthVerifier {
+	return &ServiceAuthVerifier{
+		resolver: resolver,
+		audience: audience,
+	}
+}
+
+// Verify checks a token's signature, audience and expiry, and returns the DID of its issuer
+func (v *ServiceAuthVerifier) Verify(ctx context.Context, token string) (string, error) {
+	did, _, err := v.verify(ctx, token)
+
+	return did, err
+}
+
+// VerifyMethod checks a token like Verify, but also requires it to be bound to a specific method (the token's `lxm` claim)
+// so that tokens which were issued for other methods can't be replayed
+func (v *ServiceAuthVerifier) VerifyMethod(ctx context.Context, token string, method string) (string, error) {
+	did, claims, err := v.verify(ctx, token)
+	if err != nil {
+		return "", err
+	}
+
+	if claims.Lxm != method {
+		return "", ErrInvalidMethod
+	}
+
+	return did, nil
+}
+
+func (v *ServiceAuthVerifier) verify(ctx context.Context, token string) (string, tokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", tokenClaims{}, ErrInvalidToken
+	}
+
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", tokenClaims{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	var header tokenHeader
+	if err := json.Unmarshal(rawHeader, &header); err != nil {
+		return "", tokenClaims{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if header.Alg != AlgorithmES256 && header.Alg != AlgorithmES256K {
+		return "", tokenClaims{}, ErrUnsupportedAlg
+	}
+
+	rawClaims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", tokenClaims{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	var claims tokenClaims
+	if err := json.Unmarshal(rawClaims, &claims); err != nil {
+		return "", tokenClaims{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if claims.Aud != v.audience {
+		return "", tokenClaims{}, ErrInvalidAudience
+	}
+
+	// Tokens without an expiry would be valid forever, so we reject them
+	if claims.Exp == 0 || !time.Now().Before(time.Unix(claims.Exp, 0)) {
+		return "", tokenClaims{}, ErrTokenExpired
+	}
+
+	// The issuer can reference a service in the DID document, e.g. "did:plc:example#atproto_labeler"
+	did, _, _ := strings.Cut(claims.Iss, "#")
+	if strings.TrimSpace(did) == "" {
+		return "", tokenClaims{}, ErrInvalidToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", tokenClaims{}, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+
+	if err := v.verifySignature(ctx, did, header.Alg, hash[:], signature); err != nil {
+		// If the issuer rotated their signing key, the cached document is outdated, so we try again with a fresh one
+		// Resolvers only refresh a document once in a while, so invalid tokens can't be used to flood the issuer's PLC directory or server
+		p, ok := v.resolver.(purger)
+		if !ok || !errors.Is(err, ErrInvalidSignature) || !p.Purge(did) {
+			return "", tokenClaims{}, err
+		}
+
+		if err := v.verifySignature(ctx, did, header.Alg, hash[:], signature); err != nil {
+			return "", tokenClaims{}, err
+		}
+	}
+
+	return did, claims, nil
+}
+
+func (v *ServiceAuthVerifier) verifySignature(ctx context.Context, did string, alg string, hash []byte, signature []byte) error {
+	document, err := v.resolver.ResolveDID(ctx, did)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCouldNotResolveDID, err)
+	}
+
+	method, ok := document.GetVerificationMethod(verificationMethodAtproto)
+	if !ok {
+		return ErrMissingSigningKey
+	}
+
+	key, err := ParsePublicKey(method)
+	if err != nil {
+		return err
+	}
+
+	if key.Algorithm() != alg {
+		return ErrAlgorithmMismatch
+	}
+
+	return key.Verify(hash, signature)
+}
@@ -0,0 +1,343 @@
+package verifiers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	secp256k1ecdsa "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/mr-tron/base58"
+	"github.com/pojntfx/atmosfeed/pkg/resolvers"
+)
+
+const (
+	testAudience = "did:web:feeds.example.com"
+	testIssuer   = "did:plc:issuer"
+	testMethod   = "app.bsky.feed.getFeedSkeleton"
+)
+
+// testSigner signs hashes with a private key; if highS is set, it returns the malleated high-S variant of a signature
+type testSigner interface {
+	algorithm() string
+	verificationMethod() resolvers.VerificationMethod
+	sign(t *testing.T, hash []byte, highS bool) []byte
+}
+
+type p256TestSigner struct {
+	key *ecdsa.PrivateKey
+}
+
+func newP256TestSigner(t *testing.T) *p256TestSigner {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &p256TestSigner{key}
+}
+
+func (s *p256TestSigner) algorithm() string {
+	return AlgorithmES256
+}
+
+func (s *p256TestSigner) verificationMethod() resolvers.VerificationMethod {
+	rawKey := append(append([]byte{}, multicodecP256PublicKey...), elliptic.MarshalCompressed(elliptic.P256(), s.key.X, s.key.Y)...)
+
+	return resolvers.VerificationMethod{
+		ID:                 "#" + verificationMethodAtproto,
+		Type:               verificationMethodMultikey,
+		PublicKeyMultibase: multibaseBase58BTC + base58.Encode(rawKey),
+	}
+}
+
+func (s *p256TestSigner) sign(t *testing.T, hash []byte, highS bool) []byte {
+	r, sigS, err := ecdsa.Sign(rand.Reader, s.key, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Go's signatures can be high-S, so we normalize them first
+	n := elliptic.P256().Params().N
+	if sigS.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		sigS.Sub(n, sigS)
+	}
+
+	if highS {
+		sigS.Sub(n, sigS)
+	}
+
+	signature := make([]byte, signatureLength)
+	r.FillBytes(signature[:signatureScalarLength])
+	sigS.FillBytes(signature[signatureScalarLength:])
+
+	return signature
+}
+
+type k256TestSigner struct {
+	key *secp256k1.PrivateKey
+}
+
+func newK256TestSigner(t *testing.T) *k256TestSigner {
+	key, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &k256TestSigner{key}
+}
+
+func (s *k256TestSigner) algorithm() string {
+	return AlgorithmES256K
+}
+
+func (s *k256TestSigner) verificationMethod() resolvers.VerificationMethod {
+	rawKey := append(append([]byte{}, multicodecK256PublicKey...), s.key.PubKey().SerializeCompressed()...)
+
+	return resolvers.VerificationMethod{
+		ID:                 "#" + verificationMethodAtproto,
+		Type:               verificationMethodMultikey,
+		PublicKeyMultibase: multibaseBase58BTC + base58.Encode(rawKey),
+	}
+}
+
+func (s *k256TestSigner) sign(t *testing.T, hash []byte, highS bool) []byte {
+	// Compact signatures are prefixed with a recovery code and always low-S
+	signature := secp256k1ecdsa.SignCompact(s.key, hash, true)[1:]
+
+	if highS {
+		var sigS secp256k1.ModNScalar
+		sigS.SetByteSlice(signature[signatureScalarLength:])
+
+		b := sigS.Negate().Bytes()
+		copy(signature[signatureScalarLength:], b[:])
+	}
+
+	return signature
+}
+
+type testResolver struct {
+	lock      sync.Mutex
+	documents map[string]*resolvers.DIDDocument
+	lookups   int
+}
+
+func newTestResolver(did string, methods ...resolvers.VerificationMethod) *testResolver {
+	r := &testResolver{
+		documents: map[string]*resolvers.DIDDocument{},
+	}
+	r.setMethods(did, methods...)
+
+	return r
+}
+
+func (r *testResolver) setMethods(did string, methods ...resolvers.VerificationMethod) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.documents[did] = &resolvers.DIDDocument{
+		ID:                 did,
+		VerificationMethod: methods,
+	}
+}
+
+func (r *testResolver) getLookups() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.lookups
+}
+
+func (r *testResolver) ResolveDID(ctx context.Context, did string) (*resolvers.DIDDocument, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.lookups++
+
+	document, ok := r.documents[did]
+	if !ok {
+		return nil, resolvers.ErrDIDNotFound
+	}
+
+	return document, nil
+}
+
+type testClaims struct {
+	Iss string `json:"iss"`
+	Aud string `json:"aud,omitempty"`
+	Exp int64  `json:"exp,omitempty"`
+	Lxm string `json:"lxm,omitempty"`
+}
+
+func encodeSegment(t *testing.T, value any) string {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func signToken(t *testing.T, signer testSigner, alg string, claims testClaims, highS bool) string {
+	signingInput := encodeSegment(t, tokenHeader{Alg: alg, Typ: "JWT"}) + "." + encodeSegment(t, claims)
+	hash := sha256.Sum256([]byte(signingInput))
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signer.sign(t, hash[:], highS))
+}
+
+func validClaims() testClaims {
+	return testClaims{
+		Iss: testIssuer,
+		Aud: testAudience,
+		Exp: time.Now().Add(time.Minute).Unix(),
+		Lxm: testMethod,
+	}
+}
+
+func TestVerify(t *testing.T) {
+	for _, newSigner := range []func(t *testing.T) testSigner{
+		func(t *testing.T) testSigner { return newP256TestSigner(t) },
+		func(t *testing.T) testSigner { return newK256TestSigner(t) },
+	} {
+		signer := newSigner(t)
+
+		t.Run(signer.algorithm(), func(t *testing.T) {
+			expired := validClaims()
+			expired.Exp = time.Now().Add(-time.Minute).Unix()
+
+			withoutExpiry := validClaims()
+			withoutExpiry.Exp = 0
+
+			otherAudience := validClaims()
+			otherAudience.Aud = "did:web:other.example.com"
+
+			service := validClaims()
+			service.Iss = testIssuer + "#atproto_labeler"
+
+			otherAlg := AlgorithmES256
+			if signer.algorithm() == AlgorithmES256 {
+				otherAlg = AlgorithmES256K
+			}
+
+			tests := []struct {
+				name  string
+				token string
+				err   error
+			}{
+				{"valid token", signToken(t, signer, signer.algorithm(), validClaims(), false), nil},
+				{"issuer with service", signToken(t, signer, signer.algorithm(), service, false), nil},
+				{"high-S signature", signToken(t, signer, signer.algorithm(), validClaims(), true), ErrInvalidSignature},
+				{"other algorithm", signToken(t, signer, otherAlg, validClaims(), false), ErrAlgorithmMismatch},
+				{"unsupported algorithm", signToken(t, signer, "HS256", validClaims(), false), ErrUnsupportedAlg},
+				{"expired token", signToken(t, signer, signer.algorithm(), expired, false), ErrTokenExpired},
+				{"token without expiry", signToken(t, signer, signer.algorithm(), withoutExpiry, false), ErrTokenExpired},
+				{"other audience", signToken(t, signer, signer.algorithm(), otherAudience, false), ErrInvalidAudience},
+				{"malformed token", "not.a-token", ErrInvalidToken},
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					v := NewServiceAuthVerifier(newTestResolver(testIssuer, signer.verificationMethod()), testAudience)
+
+					did, err := v.Verify(context.Background(), tt.token)
+					if !errors.Is(err, tt.err) {
+						t.Fatalf("expected %v, got %v", tt.err, err)
+					}
+
+					if err == nil && did != testIssuer {
+						t.Fatalf("expected issuer %v, got %v", testIssuer, did)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsTamperedTokens(t *testing.T) {
+	signer := newK256TestSigner(t)
+	v := NewServiceAuthVerifier(newTestResolver(testIssuer, signer.verificationMethod()), testAudience)
+
+	parts := strings.Split(signToken(t, signer, AlgorithmES256K, validClaims(), false), ".")
+
+	claims := validClaims()
+	claims.Iss = "did:plc:attacker"
+	parts[1] = encodeSegment(t, claims)
+
+	if _, err := v.Verify(context.Background(), strings.Join(parts, ".")); err == nil {
+		t.Fatal("expected token with tampered claims to be rejected")
+	}
+}
+
+func TestVerifyMethod(t *testing.T) {
+	signer := newP256TestSigner(t)
+	v := NewServiceAuthVerifier(newTestResolver(testIssuer, signer.verificationMethod()), testAudience)
+
+	token := signToken(t, signer, AlgorithmES256, validClaims(), false)
+
+	if _, err := v.VerifyMethod(context.Background(), token, testMethod); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := v.VerifyMethod(context.Background(), token, "app.bsky.feed.describeFeedGenerator"); !errors.Is(err, ErrInvalidMethod) {
+		t.Fatalf("expected %v, got %v", ErrInvalidMethod, err)
+	}
+}
+
+func TestVerifyRefreshesRotatedKeys(t *testing.T) {
+	var (
+		oldSigner = newK256TestSigner(t)
+		newSigner = newK256TestSigner(t)
+		resolver  = newTestResolver(testIssuer, oldSigner.verificationMethod())
+		cached    = resolvers.NewCachedResolver(resolver, time.Hour, 0)
+		v         = NewServiceAuthVerifier(cached, testAudience)
+	)
+
+	if _, err := v.Verify(context.Background(), signToken(t, oldSigner, AlgorithmES256K, validClaims(), false)); err != nil {
+		t.Fatal(err)
+	}
+
+	resolver.setMethods(testIssuer, newSigner.verificationMethod())
+
+	if _, err := v.Verify(context.Background(), signToken(t, newSigner, AlgorithmES256K, validClaims(), false)); err != nil {
+		t.Fatalf("expected token signed with rotated key to be accepted, got %v", err)
+	}
+
+	if lookups := resolver.getLookups(); lookups != 2 {
+		t.Fatalf("expected 2 lookups, got %v", lookups)
+	}
+}
+
+func TestVerifyLimitsRefreshes(t *testing.T) {
+	var (
+		signer   = newP256TestSigner(t)
+		attacker = newP256TestSigner(t)
+		resolver = newTestResolver(testIssuer, signer.verificationMethod())
+		cached   = resolvers.NewCachedResolver(resolver, time.Hour, time.Hour)
+		v        = NewServiceAuthVerifier(cached, testAudience)
+	)
+
+	// The document was just fetched, so invalid signatures must not cause another lookup each
+	for i := 0; i < 10; i++ {
+		if _, err := v.Verify(context.Background(), signToken(t, attacker, AlgorithmES256, validClaims(), false)); !errors.Is(err, ErrInvalidSignature) {
+			t.Fatalf("expected %v, got %v", ErrInvalidSignature, err)
+		}
+	}
+
+	if lookups := resolver.getLookups(); lookups != 1 {
+		t.Fatalf("expected 1 lookup, got %v", lookups)
+	}
+
+	if _, err := v.Verify(context.Background(), signToken(t, signer, AlgorithmES256, validClaims(), false)); err != nil {
+		t.Fatal(err)
+	}
+}